@@ -0,0 +1,27 @@
+package configs
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/thunthup/aimet-test/jwt"
+)
+
+// JWTVerifier verifies the JWTs of the API, signed with HS256 and the key AUTH_JWT_SECRET of at least 32 bytes.
+// AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE are checked when they are set. Without a key only API keys are accepted.
+func JWTVerifier() *jwt.Verifier {
+	secret := os.Getenv("AUTH_JWT_SECRET")
+	if secret == "" {
+		return nil
+	}
+	if len(secret) < 32 {
+		log.Fatalf("AUTH_JWT_SECRET must have at least 32 bytes")
+	}
+	return &jwt.Verifier{
+		Key:      []byte(secret),
+		Issuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		Audience: os.Getenv("AUTH_JWT_AUDIENCE"),
+		Leeway:   time.Minute,
+	}
+}
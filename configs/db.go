@@ -0,0 +1,23 @@
+package configs
+
+import (
+	"log"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+var DB *gorm.DB
+
+// ConnectDB connects to the database chosen by DB_DRIVER, postgres by default or sqlite.
+// The schema is created by the migrations package.
+func ConnectDB() {
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "postgres":
+		ConnectPostgresDB()
+	case "sqlite":
+		ConnectSQLiteDB()
+	default:
+		log.Fatalf("Unknown database driver %s", driver)
+	}
+}
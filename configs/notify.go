@@ -0,0 +1,37 @@
+package configs
+
+import (
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/notify"
+)
+
+// Notifiers of the reminder methods. Reminders are always logged, they are POSTed to
+// REMINDER_WEBHOOK_URL, signed with REMINDER_WEBHOOK_SECRET, when it is set, and emailed
+// through the SMTP server SMTP_ADDR as SMTP_FROM when both are set. SMTP_USERNAME and
+// SMTP_PASSWORD authenticate to the server when they are set.
+func Notifiers() notify.Notifiers {
+	notifiers := notify.Notifiers{models.ReminderLog: &notify.LogNotifier{}}
+	if url := os.Getenv("REMINDER_WEBHOOK_URL"); url != "" {
+		notifiers[models.ReminderWebhook] = &notify.WebhookNotifier{
+			URL:    url,
+			Secret: os.Getenv("REMINDER_WEBHOOK_SECRET"),
+			Client: &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	addr, from := os.Getenv("SMTP_ADDR"), os.Getenv("SMTP_FROM")
+	if addr != "" && from != "" {
+		smtpNotifier := &notify.SMTPNotifier{Addr: addr, From: from}
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			host, _, _ := net.SplitHostPort(addr)
+			smtpNotifier.Auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		notifiers[models.ReminderEmail] = smtpNotifier
+	}
+	return notifiers
+}
@@ -5,13 +5,10 @@ import (
 	"log"
 	"os"
 
-	"github.com/thunthup/aimet-test/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
-
 func ConnectPostgresDB() {
 
 	DB_HOST := os.Getenv("DB_HOST")
@@ -27,7 +24,6 @@ func ConnectPostgresDB() {
 	if err != nil {
 		log.Fatalf("Error while connecting to database %s", err)
 	}
-	db.AutoMigrate(&models.Event{})
 	DB = db
 
 }
@@ -0,0 +1,47 @@
+package configs
+
+import (
+	"database/sql/driver"
+	"log"
+	"os"
+
+	sqlite3 "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"github.com/thunthup/aimet-test/models"
+	"gorm.io/gorm"
+)
+
+// Searches fold titles with event_search_fold, defined in SQL by the PostgreSQL migrations
+func init() {
+	sqlite3.MustRegisterDeterministicScalarFunction("event_search_fold", 1, func(ctx *sqlite3.FunctionContext, args []driver.Value) (driver.Value, error) {
+		switch text := args[0].(type) {
+		case string:
+			return models.FoldSearchText(text), nil
+		case []byte:
+			return models.FoldSearchText(string(text)), nil
+		}
+		return nil, nil
+	})
+}
+
+// ConnectSQLiteDB opens the SQLite database file at DB_PATH, aimet.db by default
+func ConnectSQLiteDB() {
+	DB_PATH := os.Getenv("DB_PATH")
+	if DB_PATH == "" {
+		DB_PATH = "aimet.db"
+	}
+	db, err := OpenSQLiteDB(DB_PATH)
+	if err != nil {
+		log.Fatalf("Error while connecting to database %s", err)
+	}
+	DB = db
+}
+
+// OpenSQLiteDB opens the SQLite database file at path
+func OpenSQLiteDB(path string) (*gorm.DB, error) {
+	// Writers wait for each other instead of failing while the database is locked. Transactions take
+	// the write lock when they begin, so that what they read holds until they write.
+	return gorm.Open(sqlite.Open(path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"), &gorm.Config{
+		SkipDefaultTransaction: true,
+	})
+}
@@ -0,0 +1,21 @@
+package configs
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// TrashRetention is how long deleted events are kept before they are purged, TRASH_RETENTION_DAYS
+// days and 30 by default. Zero keeps them until they are purged through the API.
+func TrashRetention() time.Duration {
+	days := 30
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 0 {
+			log.Fatalf("Invalid TRASH_RETENTION_DAYS %s", value)
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Context key of the access of the caller, loaded by callerAccess
+const accessKey = "access"
+
+var (
+	errForeignEvent   = errors.New("Event belongs to another user")
+	errCalendarEditor = errors.New("The editor role is required on the calendar")
+)
+
+// access holds the roles of the caller, who may also add events to the default calendar.
+// Requests without a user have no role.
+type access struct {
+	*models.Roles
+}
+
+// Load the access of the caller once per request, answering 500 when it cannot be read
+func (h *Handler) callerAccess(c *gin.Context) (*access, bool) {
+	if value, ok := c.Get(accessKey); ok {
+		return value.(*access), true
+	}
+	caller, err := h.loadAccess(currentUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	c.Set(accessKey, caller)
+	return caller, true
+}
+
+// Read the roles of a user from the owners of the calendars and the grants of the user
+func (h *Handler) loadAccess(user *models.User) (*access, error) {
+	if user == nil || user.Admin {
+		return &access{models.NewRoles(user, nil, nil)}, nil
+	}
+
+	calendars, err := h.Calendars.List()
+	if err != nil {
+		return nil, err
+	}
+	grants, err := h.Grants.ListForUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &access{models.NewRoles(user, calendars, grants)}, nil
+}
+
+// Check whether the caller may add events to a calendar, which needs the editor role but on the default calendar
+func (a *access) canAddTo(calendarID uint) bool {
+	if a.User == nil {
+		return false
+	}
+	return calendarID == a.DefaultCalendar || a.CalendarRole(calendarID).Includes(models.RoleEditor)
+}
+
+// Error refusing an action needing a role on an event, nil when the caller has the role
+func (a *access) authorize(event *models.Event, role models.Role) error {
+	has := a.EventRole(event)
+	if has.Includes(role) {
+		return nil
+	}
+	if !has.Includes(models.RoleViewer) {
+		return errForeignEvent
+	}
+	return fmt.Errorf("The %s role is required on the event", role)
+}
+
+// Repository access selecting the events the caller has the role on, nil for admins who have every role
+func (a *access) filter(role models.Role) *repositories.Access {
+	if a.Admin() {
+		return nil
+	}
+	filter := &repositories.Access{}
+	if a.User != nil {
+		filter.OwnerID = a.User.ID
+	}
+	for id, has := range a.Calendars {
+		if has.Includes(role) {
+			filter.CalendarIDs = append(filter.CalendarIDs, id)
+		}
+	}
+	for id, has := range a.Events {
+		if has.Includes(role) {
+			filter.EventIDs = append(filter.EventIDs, id)
+		}
+	}
+	sort.Slice(filter.CalendarIDs, func(i, j int) bool { return filter.CalendarIDs[i] < filter.CalendarIDs[j] })
+	sort.Slice(filter.EventIDs, func(i, j int) bool { return filter.EventIDs[i] < filter.EventIDs[j] })
+	return filter
+}
+
+// Refuse with 403 a request needing a role on an event the caller does not have.
+// The access of the caller is returned when the request may go on.
+func (h *Handler) authorizeEvent(c *gin.Context, event *models.Event, role models.Role) (*access, bool) {
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return nil, false
+	}
+	if err := caller.authorize(event, role); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return caller, true
+}
+
+// Refuse a request needing a role on a calendar the caller does not have,
+// with 404 when the calendar is not shared with them at all and 403 otherwise
+func (h *Handler) authorizeCalendar(c *gin.Context, calendar *models.Calendar, role models.Role) bool {
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return false
+	}
+	has := caller.CalendarRole(calendar.ID)
+	if has == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return false
+	}
+	if !has.Includes(role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("The %s role is required on the calendar", role)})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+)
+
+// Audit of the changes made by a request, recorded with the revisions of the events.
+// The actor is the name of the authenticated user, or else the X-Actor header. Requests without
+// an X-Request-ID header get a generated one, returned in the X-Request-ID header of the response.
+func requestAudit(c *gin.Context) models.Audit {
+	requestID := c.GetHeader("X-Request-ID")
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	c.Header("X-Request-ID", requestID)
+	actor := c.GetHeader("X-Actor")
+	if user := currentUser(c); user != nil {
+		actor = user.Name
+	}
+	return models.Audit{
+		Actor:      actor,
+		RequestID:  requestID,
+		Method:     c.Request.Method,
+		Path:       c.Request.URL.RequestURI(),
+		RemoteAddr: c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+}
+
+// Random ID of a request
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
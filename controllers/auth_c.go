@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Context key of the user authenticated by Authenticate
+const userKey = "user"
+
+// authError refuses a bearer token, its message is returned to the caller
+type authError struct {
+	reason string
+}
+
+func (e *authError) Error() string {
+	return e.reason
+}
+
+// Authenticate identifies the caller by the bearer token of the Authorization header, either the secret
+// of an API key or a JWT signed with the key of the handler whose subject is the ID of a user.
+// Requests without a valid token are refused with 401.
+func (h *Handler) Authenticate(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		unauthorized(c, "Missing bearer token")
+		return
+	}
+	user, err := h.authenticate(strings.TrimSpace(token), time.Now())
+	var refused *authError
+	if errors.As(err, &refused) {
+		unauthorized(c, refused.reason)
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.Set(userKey, user)
+	c.Next()
+}
+
+// Find the user of a bearer token at the time now
+func (h *Handler) authenticate(token string, now time.Time) (*models.User, error) {
+	var userID uint64
+	if models.IsAPIKey(token) {
+		key, err := h.Users.FindKey(models.HashAPIKey(token))
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, unauthenticated("Invalid API key")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key.Expired(now) {
+			return nil, unauthenticated("API key has expired")
+		}
+		userID = uint64(key.UserID)
+	} else {
+		if h.Tokens == nil {
+			return nil, unauthenticated("Invalid token")
+		}
+		claims, err := h.Tokens.Verify(token, now)
+		if err != nil {
+			return nil, unauthenticated(err.Error())
+		}
+		if userID, err = strconv.ParseUint(claims.Subject, 10, 32); err != nil {
+			return nil, unauthenticated("Unknown user")
+		}
+	}
+
+	user, err := h.Users.Get(uint(userID))
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, unauthenticated("Unknown user")
+	}
+	return user, err
+}
+
+// Error refusing a bearer token for the reason
+func unauthenticated(reason string) error {
+	return &authError{reason: reason}
+}
+
+// Refuse a request that is not authenticated
+func unauthorized(c *gin.Context, reason string) {
+	c.Header("WWW-Authenticate", `Bearer realm="aimet"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": reason})
+}
+
+// RequireAdmin refuses requests of users who are not admins with 403, it runs after Authenticate
+func RequireAdmin(c *gin.Context) {
+	if user := currentUser(c); user == nil || !user.Admin {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	c.Next()
+}
+
+// User authenticated for the request, nil when the request did not go through Authenticate
+func currentUser(c *gin.Context) *models.User {
+	if value, ok := c.Get(userKey); ok {
+		return value.(*models.User)
+	}
+	return nil
+}
+
+// Set the owner of a new event to the caller. Admins may give it to another user with owner_id.
+// The status code tells whether an error comes from the event or from the database.
+func (h *Handler) assignOwner(event *models.Event, caller *models.User) (int, error) {
+	if caller == nil {
+		return http.StatusUnauthorized, errors.New("Missing bearer token")
+	}
+	if !caller.Admin || event.OwnerID == nil || *event.OwnerID == caller.ID {
+		event.OwnerID = &caller.ID
+		return http.StatusOK, nil
+	}
+	if _, err := h.Users.Get(*event.OwnerID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return http.StatusBadRequest, errors.New("Owner not found")
+		}
+		return http.StatusInternalServerError, errors.New("Database error")
+	}
+	return http.StatusOK, nil
+}
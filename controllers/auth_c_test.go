@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/jwt"
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestAuthentication(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	h.Tokens = &jwt.Verifier{Key: []byte("0123456789abcdef0123456789abcdef")}
+	r := gin.Default()
+	api := r.Group("", h.Authenticate)
+	api.GET("/me", h.GetCurrentUser)
+	admin := api.Group("/users", RequireAdmin)
+	admin.POST("", h.CreateUser)
+	admin.POST("/:id/keys", h.CreateAPIKey)
+	admin.DELETE("/:id/keys/:key", h.DeleteAPIKey)
+
+	root := models.User{Name: "root", Admin: true}
+	assert.NilError(t, h.Users.Create(&root))
+	rootKey := models.APIKey{UserID: root.ID}
+	rootSecret, err := rootKey.NewAPIKeySecret()
+	assert.NilError(t, err)
+	assert.NilError(t, h.Users.CreateKey(&rootKey))
+
+	send := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test case 1: requests without a valid token are refused
+	resp := send("GET", "/me", "", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	assert.Equal(t, `Bearer realm="aimet"`, resp.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, `{"error":"Missing bearer token"}`, resp.Body.String())
+	resp = send("GET", "/me", "aimet_unknown", "")
+	assert.Equal(t, `{"error":"Invalid API key"}`, resp.Body.String())
+
+	// Test case 2: admins create users and their API keys, whose secret is only returned once
+	resp = send("POST", "/users", rootSecret, `{"name": "alice"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var alice models.User
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &alice))
+	resp = send("POST", "/users", rootSecret, `{"name": "alice"}`)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+	resp = send("POST", fmt.Sprintf("/users/%d/keys", alice.ID), rootSecret, `{"name": "laptop"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var key struct {
+		ID     uint   `json:"id"`
+		Prefix string `json:"prefix"`
+		Secret string `json:"secret"`
+		Hash   string `json:"hash"`
+	}
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &key))
+	assert.Equal(t, key.Secret[:len(key.Prefix)], key.Prefix)
+	assert.Equal(t, "", key.Hash)
+
+	resp = send("GET", "/me", key.Secret, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Assert(t, bytes.Contains(resp.Body.Bytes(), []byte(`"name":"alice"`)))
+
+	// Test case 3: other users cannot manage users
+	resp = send("POST", "/users", key.Secret, `{"name": "mallory", "admin": true}`)
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	// Test case 4: JWTs name the user by its ID, and must be signed with the key and not expired
+	token, err := jwt.Sign(jwt.Claims{Subject: strconv.Itoa(int(alice.ID)), ExpiresAt: time.Now().Add(time.Hour).Unix()}, h.Tokens.Key)
+	assert.NilError(t, err)
+	resp = send("GET", "/me", token, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	for _, test := range []struct {
+		claims  jwt.Claims
+		key     string
+		message string
+	}{
+		{jwt.Claims{Subject: strconv.Itoa(int(alice.ID)), ExpiresAt: time.Now().Add(-time.Hour).Unix()}, string(h.Tokens.Key), "Token has expired"},
+		{jwt.Claims{Subject: strconv.Itoa(int(alice.ID)), ExpiresAt: time.Now().Add(time.Hour).Unix()}, "another key of 32 bytes or more..", "Invalid token signature"},
+		{jwt.Claims{Subject: "99", ExpiresAt: time.Now().Add(time.Hour).Unix()}, string(h.Tokens.Key), "Unknown user"},
+	} {
+		token, err := jwt.Sign(test.claims, []byte(test.key))
+		assert.NilError(t, err)
+		resp = send("GET", "/me", token, "")
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		assert.Equal(t, `{"error":"`+test.message+`"}`, resp.Body.String())
+	}
+
+	// Test case 5: revoked and expired keys are refused
+	resp = send("DELETE", fmt.Sprintf("/users/%d/keys/%d", alice.ID, key.ID), rootSecret, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = send("GET", "/me", key.Secret, "")
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	expiresAt := time.Now().Add(-time.Minute)
+	expired := models.APIKey{UserID: alice.ID, ExpiresAt: &expiresAt}
+	secret, err := expired.NewAPIKeySecret()
+	assert.NilError(t, err)
+	assert.NilError(t, h.Users.CreateKey(&expired))
+	resp = send("GET", "/me", secret, "")
+	assert.Equal(t, `{"error":"API key has expired"}`, resp.Body.String())
+}
+
+func TestEventOwnership(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := gin.Default()
+	api := r.Group("/api", h.Authenticate)
+	api.GET("/events", h.ListEvents)
+	api.GET("/events/trash", h.ListTrash)
+	api.GET("/events/:id", h.GetEventById)
+	api.POST("/events", h.CreateEvent)
+	api.PUT("/events/:id", h.UpdateEvent)
+	api.DELETE("/events/:id", h.DeleteEvent)
+	api.GET("/events/:id/history", h.GetEventHistory)
+	api.POST("/events/bulk", h.BulkEvents)
+	api.POST("/calendars", h.CreateCalendar)
+	api.DELETE("/calendars/:id", h.DeleteCalendar)
+
+	secrets := map[string]string{}
+	users := map[string]*models.User{}
+	for _, name := range []string{"admin", "alice", "bob"} {
+		user := &models.User{Name: name, Admin: name == "admin"}
+		assert.NilError(t, h.Users.Create(user))
+		key := models.APIKey{UserID: user.ID}
+		secret, err := key.NewAPIKeySecret()
+		assert.NilError(t, err)
+		secrets[name] = secret
+		assert.NilError(t, h.Users.CreateKey(&key))
+		users[name] = user
+	}
+	send := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+secrets[user])
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+	list := func(path, user string) []models.Event {
+		resp := send("GET", path, user, "")
+		assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		var events []models.Event
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &events))
+		return events
+	}
+	body := func(title, start string, ownerID uint) string {
+		return fmt.Sprintf(`{"title": "%s", "event_date": "2024-05-01", "start_time": "%s:00:00+07", "end_time": "%s:30:00+07", "owner_id": %d}`, title, start, start, ownerID)
+	}
+
+	// Test case 1: events are owned by the user creating them, other users cannot choose the owner
+	resp := send("POST", "/api/events", "alice", body("Alice Standup", "09", users["admin"].ID))
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var event models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &event))
+	assert.Equal(t, users["alice"].ID, *event.OwnerID)
+	path := fmt.Sprintf("/api/events/%d", event.ID)
+
+	// Test case 2: admins may give events to another user
+	resp = send("POST", "/api/events", "admin", body("Bob Review", "11", users["bob"].ID))
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	resp = send("POST", "/api/events", "admin", body("Nobody", "13", 99))
+	assert.Equal(t, `{"error":"Owner not found"}`, resp.Body.String())
+
+	// Test case 3: users only see their own events, admins see every event
+	events := list("/api/events?start_date=2024-05-01&end_date=2024-05-01", "bob")
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "Bob Review", events[0].Title)
+	assert.Equal(t, 2, len(list("/api/events?start_date=2024-05-01&end_date=2024-05-01", "admin")))
+	assert.Equal(t, http.StatusNotFound, send("GET", path, "bob", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", path+"/history", "bob", "").Code)
+	assert.Equal(t, http.StatusOK, send("GET", path+"/history", "alice", "").Code)
+
+	// Test case 4: changes to the events of other users are forbidden
+	resp = send("PUT", path, "bob", body("Taken Over", "09", users["bob"].ID))
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, `{"error":"Event belongs to another user"}`, resp.Body.String())
+	assert.Equal(t, http.StatusForbidden, send("DELETE", path, "bob", "").Code)
+	resp = send("POST", "/api/events/bulk", "bob", fmt.Sprintf(`{"operations": [{"op": "delete", "id": %d}]}`, event.ID))
+	assert.Assert(t, bytes.Contains(resp.Body.Bytes(), []byte(`"error":"Event belongs to another user"`)), resp.Body.String())
+
+	// Test case 5: the owner and admins may change it, and its deleted events are only listed for them
+	resp = send("PUT", path, "alice", body("Alice Standup Moved", "10", users["alice"].ID))
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &event))
+	assert.Equal(t, users["alice"].ID, *event.OwnerID)
+	assert.Equal(t, http.StatusOK, send("DELETE", path, "admin", "").Code)
+	assert.Equal(t, 0, len(list("/api/events/trash", "bob")))
+	assert.Equal(t, 1, len(list("/api/events/trash", "alice")))
+
+	// Test case 6: calendars are owned by the user creating them, other users cannot delete them
+	resp = send("POST", "/api/calendars", "alice", `{"name": "Team", "owner_id": 3}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var calendar models.Calendar
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &calendar))
+	assert.Equal(t, users["alice"].ID, *calendar.OwnerID)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", fmt.Sprintf("/api/calendars/%d", calendar.ID), "bob", "").Code)
+	assert.Equal(t, http.StatusOK, send("DELETE", fmt.Sprintf("/api/calendars/%d", calendar.ID), "alice", "").Code)
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Largest number of operations accepted by BulkEvents
+const maxBulkOperations = 1000
+
+// bulkOperation is one change of BulkEvents
+type bulkOperation struct {
+	// Op is create, update or delete
+	Op string `json:"op"`
+	// ID of the event to update or delete
+	ID uint `json:"id"`
+	// Version the event to update or delete must still have, like If-Match, any version when zero
+	Version uint `json:"version"`
+	// Event holds the fields of the event to create or update
+	Event json.RawMessage `json:"event"`
+}
+
+// bulkRequest is the body of BulkEvents
+type bulkRequest struct {
+	// Atomic applies all the operations or none of them
+	Atomic     bool            `json:"atomic"`
+	Operations []bulkOperation `json:"operations" binding:"required"`
+}
+
+// Result of one operation of BulkEvents
+type bulkItem struct {
+	Index   int    `json:"index"`
+	Op      string `json:"op"`
+	Status  string `json:"status"`
+	ID      uint   `json:"id,omitempty"`
+	Version uint   `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// errBulkRejected rolls back an atomic bulk request with rejected operations
+var errBulkRejected = errors.New("Some operations were rejected")
+
+// Create, update and delete events in one request. Operations are applied in order, so each one is
+// checked against the events in the database and the operations before it. A rejected operation
+// does not stop the next ones, but in atomic mode none of the operations is kept when one is rejected.
+func (h *Handler) BulkEvents(c *gin.Context) {
+	var request bulkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(request.Operations) > maxBulkOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d operations are accepted", maxBulkOperations)})
+		return
+	}
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	audit := requestAudit(c)
+
+	items := make([]bulkItem, len(request.Operations))
+	apply := func(h *Handler) error {
+		rejected := false
+		for i := range request.Operations {
+			item, err := h.applyBulkOperation(&request.Operations[i], caller, audit)
+			if err != nil {
+				return err
+			}
+			item.Index = i
+			items[i] = *item
+			rejected = rejected || item.Status == "rejected"
+		}
+		if rejected && request.Atomic {
+			return errBulkRejected
+		}
+		return nil
+	}
+
+	var err error
+	if request.Atomic {
+		err = h.Events.Transaction(func(events repositories.EventRepository, calendars repositories.CalendarRepository) error {
+			return apply(NewHandler(events, calendars, h.Users, h.Grants, h.Webhooks, h.Reminders))
+		})
+	} else {
+		err = apply(h)
+	}
+	if err != nil && !errors.Is(err, errBulkRejected) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Applied operations of a rolled back request are reported as such, created events no longer exist
+	committed := err == nil
+	counts := map[string]int{}
+	for i := range items {
+		if !committed && items[i].Status != "rejected" {
+			items[i].Status, items[i].Version = "rolled_back", 0
+			if items[i].Op == "create" {
+				items[i].ID = 0
+			}
+		}
+		counts[items[i].Status]++
+	}
+
+	status := http.StatusOK
+	if !committed {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"committed": committed,
+		"created":   counts["created"],
+		"updated":   counts["updated"],
+		"deleted":   counts["deleted"],
+		"rejected":  counts["rejected"],
+		"items":     items,
+	})
+}
+
+// Apply one operation of the caller in BulkEvents. Only database errors are returned, other errors reject the operation.
+func (h *Handler) applyBulkOperation(op *bulkOperation, caller *access, audit models.Audit) (*bulkItem, error) {
+	item := &bulkItem{Op: op.Op, ID: op.ID}
+	reject := func(err error) (*bulkItem, error) {
+		item.Status, item.Error = "rejected", err.Error()
+		return item, nil
+	}
+
+	if op.Op == "create" {
+		event, err := bulkEvent(op)
+		if err != nil {
+			return reject(err)
+		}
+		if status, err := h.createEvent(event, caller, audit); err != nil {
+			if status == http.StatusInternalServerError {
+				return nil, err
+			}
+			return reject(err)
+		}
+		item.Status, item.ID, item.Version = "created", event.ID, event.Version
+		return item, nil
+	}
+	if op.Op != "update" && op.Op != "delete" {
+		return reject(errors.New("Operation must be create, update or delete"))
+	}
+
+	existingEvent, err := h.Events.Get(op.ID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return reject(errors.New("Event not found"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := caller.authorize(existingEvent, models.RoleEditor); err != nil {
+		return reject(err)
+	}
+	if op.Version != 0 && op.Version != existingEvent.Version {
+		return reject(repositories.ErrVersionConflict)
+	}
+
+	if op.Op == "delete" {
+		if err := h.Events.Delete(existingEvent, audit); err != nil {
+			if errors.Is(err, repositories.ErrVersionConflict) {
+				return reject(err)
+			}
+			return nil, err
+		}
+		item.Status = "deleted"
+		return item, nil
+	}
+
+	event, err := bulkEvent(op)
+	if err != nil {
+		return reject(err)
+	}
+	if status, err := h.updateEvent(existingEvent, event, caller, audit); err != nil {
+		if status == http.StatusInternalServerError {
+			return nil, err
+		}
+		return reject(err)
+	}
+	item.Status, item.Version = "updated", existingEvent.Version
+	return item, nil
+}
+
+// Decode the event of a create or update operation and check it like the body of CreateEvent
+func bulkEvent(op *bulkOperation) (*models.Event, error) {
+	if len(op.Event) == 0 {
+		return nil, errors.New("Event is required")
+	}
+	var event models.Event
+	if err := json.Unmarshal(op.Event, &event); err != nil {
+		return nil, errors.New("Invalid event")
+	}
+	if err := binding.Validator.ValidateStruct(&event); err != nil {
+		return nil, err
+	}
+	// Overrides are created through the occurrence endpoints
+	event.Overrides = nil
+	return &event, nil
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+func TestBulkEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/events/bulk", h.BulkEvents)
+
+	type bulkResponse struct {
+		Committed bool       `json:"committed"`
+		Created   int        `json:"created"`
+		Updated   int        `json:"updated"`
+		Deleted   int        `json:"deleted"`
+		Rejected  int        `json:"rejected"`
+		Items     []bulkItem `json:"items"`
+	}
+	send := func(body string) (int, bulkResponse) {
+		req, _ := http.NewRequest("POST", "/events/bulk", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		var result bulkResponse
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		return resp.Code, result
+	}
+	count := func(keyword string) int {
+		events, err := h.Events.List(repositories.EventFilter{Keyword: keyword})
+		assert.NilError(t, err)
+		return len(events)
+	}
+
+	// Test case 1: in best-effort mode operations of the same batch are checked against each other
+	status, result := send(`{"operations": [
+		{"op": "create", "event": {"title": "Bulk Lecture 9835-5dc547a01713", "event_date": "4002-01-05", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}},
+		{"op": "create", "event": {"title": "Bulk Clash 9835-5dc547a01713", "event_date": "4002-01-05", "start_time": "09:30:00+07", "end_time": "10:30:00+07"}},
+		{"op": "create", "event": {"title": "Bulk Lab 9835-5dc547a01713", "event_date": "4002-01-05", "start_time": "10:00:00+07", "end_time": "12:00:00+07"}},
+		{"op": "update", "id": 999999, "event": {"title": "Bulk Missing 9835-5dc547a01713", "event_date": "4002-01-05", "start_time": "13:00:00+07", "end_time": "14:00:00+07"}},
+		{"op": "create", "event": {"title": "Bulk Invalid 9835-5dc547a01713", "event_date": "4002-01-05", "start_time": "9am", "end_time": "10am"}},
+		{"op": "move", "id": 1}
+	]}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Assert(t, result.Committed)
+	assert.Equal(t, 2, result.Created)
+	assert.Equal(t, 4, result.Rejected)
+	statuses := []string{}
+	for i, item := range result.Items {
+		assert.Equal(t, i, item.Index)
+		statuses = append(statuses, item.Status)
+	}
+	assert.DeepEqual(t, []string{"created", "rejected", "created", "rejected", "rejected", "rejected"}, statuses)
+	assert.Equal(t, "Event time is overlapping with existing events", result.Items[1].Error)
+	assert.Equal(t, "Event not found", result.Items[3].Error)
+	assert.Equal(t, "Invalid start time format", result.Items[4].Error)
+	assert.Equal(t, "Operation must be create, update or delete", result.Items[5].Error)
+	lecture, lab := result.Items[0], result.Items[2]
+	assert.Equal(t, 2, count("Bulk"))
+
+	// Test case 2: in atomic mode one rejected operation rolls back the others
+	status, result = send(fmt.Sprintf(`{"atomic": true, "operations": [
+		{"op": "create", "event": {"title": "Bulk Seminar 9835-5dc547a01713", "event_date": "4002-01-06", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}},
+		{"op": "update", "id": %d, "event": {"title": "Bulk Lecture Moved 9835-5dc547a01713", "event_date": "4002-01-06", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}},
+		{"op": "delete", "id": %d, "version": 7}
+	]}`, lecture.ID, lab.ID))
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Assert(t, !result.Committed)
+	assert.Equal(t, "rolled_back", result.Items[0].Status)
+	assert.Equal(t, uint(0), result.Items[0].ID)
+	assert.Equal(t, "rejected", result.Items[1].Status)
+	assert.Equal(t, "Event time is overlapping with existing events", result.Items[1].Error)
+	assert.Equal(t, "rejected", result.Items[2].Status)
+	assert.Equal(t, repositories.ErrVersionConflict.Error(), result.Items[2].Error)
+	assert.Equal(t, 0, count("Bulk Seminar"))
+	event, err := h.Events.Get(lecture.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, "4002-01-05", event.EventDate)
+
+	// Test case 3: an atomic batch is applied in order, freeing a time before taking it
+	status, result = send(fmt.Sprintf(`{"atomic": true, "operations": [
+		{"op": "delete", "id": %d, "version": %d},
+		{"op": "update", "id": %d, "version": %d, "event": {"title": "Bulk Lecture Longer 9835-5dc547a01713", "event_date": "4002-01-05", "start_time": "09:00:00+07", "end_time": "11:00:00+07"}}
+	]}`, lab.ID, lab.Version, lecture.ID, lecture.Version))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Assert(t, result.Committed)
+	assert.Equal(t, 1, result.Deleted)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, uint(2), result.Items[1].Version)
+	_, err = h.Events.Get(lab.ID)
+	assert.Equal(t, repositories.ErrNotFound, err)
+
+	// Test case 4: the operations are required
+	status, _ = send(`{"atomic": true}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+var errCalendarNotFound = errors.New("Calendar not found")
+
+// Get the calendars the caller has a role on
+func (h *Handler) ListCalendars(c *gin.Context) {
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	calendars, err := h.Calendars.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	shared := []models.Calendar{}
+	for _, calendar := range calendars {
+		if caller.CalendarRole(calendar.ID) != "" {
+			shared = append(shared, calendar)
+		}
+	}
+	c.JSON(http.StatusOK, shared)
+}
+
+// Get a calendar by ID
+func (h *Handler) GetCalendarById(c *gin.Context) {
+	calendar, ok := h.findCalendar(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+	if !h.authorizeCalendar(c, calendar, models.RoleFreeBusy) {
+		return
+	}
+
+	c.JSON(http.StatusOK, calendar)
+}
+
+// Find the calendar with the ID of the URL parameter
+func (h *Handler) findCalendar(c *gin.Context) (*models.Calendar, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	calendar, err := h.Calendars.Get(uint(id))
+	if err != nil {
+		return nil, false
+	}
+	return calendar, true
+}
+
+// Create a new calendar owned by the caller
+func (h *Handler) CreateCalendar(c *gin.Context) {
+	// Bind JSON request body to Calendar struct
+	var calendar models.Calendar
+	if err := c.ShouldBindJSON(&calendar); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	calendar.IsDefault, calendar.OwnerID = false, nil
+	if user := currentUser(c); user != nil {
+		calendar.OwnerID = &user.ID
+	}
+
+	if err := h.Calendars.Create(&calendar); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, calendar)
+}
+
+// Update the name and description of a calendar
+func (h *Handler) UpdateCalendar(c *gin.Context) {
+	// Check if calendar exists
+	existingCalendar, ok := h.findCalendar(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+	if !h.authorizeCalendar(c, existingCalendar, models.RoleOwner) {
+		return
+	}
+
+	// Bind JSON request body to Calendar struct
+	var updatedCalendar models.Calendar
+	if err := c.ShouldBindJSON(&updatedCalendar); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existingCalendar.Name = updatedCalendar.Name
+	existingCalendar.Description = updatedCalendar.Description
+
+	if err := h.Calendars.Update(existingCalendar); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, existingCalendar)
+}
+
+// Delete a calendar together with its events
+func (h *Handler) DeleteCalendar(c *gin.Context) {
+	// Check if calendar exists
+	calendar, ok := h.findCalendar(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+	if !h.authorizeCalendar(c, calendar, models.RoleOwner) {
+		return
+	}
+	if calendar.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Default calendar cannot be deleted"})
+		return
+	}
+
+	// Delete calendar and its events
+	if err := h.Calendars.Delete(calendar, requestAudit(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar deleted successfully"})
+}
+
+// Put events without a calendar into the default calendar and check that the calendar exists
+func (h *Handler) resolveCalendar(event *models.Event) error {
+	var calendar *models.Calendar
+	var err error
+	if event.CalendarID == 0 {
+		calendar, err = h.Calendars.GetDefault()
+	} else {
+		calendar, err = h.Calendars.Get(event.CalendarID)
+	}
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return errCalendarNotFound
+		}
+		return err
+	}
+	event.CalendarID = calendar.ID
+	return nil
+}
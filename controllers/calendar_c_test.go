@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+func TestCalendarCRUD(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/calendars/:id", h.GetCalendarById)
+	r.POST("/calendars", h.CreateCalendar)
+	r.PUT("/calendars/:id", h.UpdateCalendar)
+	r.DELETE("/calendars/:id", h.DeleteCalendar)
+
+	// Test case 1: create a calendar
+	requestBody := []byte(`{"name": "Test Calendar 9835-5dc547a01713", "description": "Team", "is_default": true}`)
+	req, _ := http.NewRequest("POST", "/calendars", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var calendar models.Calendar
+	err := json.Unmarshal(resp.Body.Bytes(), &calendar)
+	assert.NilError(t, err)
+	assert.Equal(t, "Test Calendar 9835-5dc547a01713", calendar.Name)
+	assert.Equal(t, false, calendar.IsDefault)
+
+	// Test case 2: missing name
+	requestBody = []byte(`{"description": "Team"}`)
+	req, _ = http.NewRequest("POST", "/calendars", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Key: 'Calendar.Name' Error:Field validation for 'Name' failed on the 'required' tag"}`, resp.Body.String())
+
+	// Test case 3: update the calendar
+	requestBody = []byte(`{"name": "Updated Calendar 9835-5dc547a01713", "description": "Project"}`)
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/calendars/%d", calendar.ID), bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/calendars/%d", calendar.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	err = json.Unmarshal(resp.Body.Bytes(), &calendar)
+	assert.NilError(t, err)
+	assert.Equal(t, "Updated Calendar 9835-5dc547a01713", calendar.Name)
+	assert.Equal(t, "Project", calendar.Description)
+
+	// Test case 4: the default calendar cannot be deleted
+	defaultCalendar, err := h.Calendars.GetDefault()
+	assert.NilError(t, err)
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/calendars/%d", defaultCalendar.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Default calendar cannot be deleted"}`, resp.Body.String())
+
+	// Test case 5: delete the calendar together with its events
+	event := models.Event{
+		CalendarID: calendar.ID,
+		Title:      "Test Calendar Event 9835-5dc547a01713",
+		EventDate:  "9994-01-01",
+		StartTime:  "15:00:00+07",
+		EndTime:    "16:00:00+07",
+	}
+	h.Events.Create(&event, models.Audit{})
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/calendars/%d", calendar.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"message":"Calendar deleted successfully"}`, resp.Body.String())
+	_, err = h.Events.Get(event.ID)
+	assert.Equal(t, repositories.ErrNotFound, err)
+
+	// Test case 6: calendar not found
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/calendars/%d", calendar.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"error":"Calendar not found"}`, resp.Body.String())
+}
+
+func TestCalendarScopedEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
+	r.POST("/events", h.CreateEvent)
+
+	work := models.Calendar{Name: "Test Work 9835-5dc547a01713"}
+	home := models.Calendar{Name: "Test Home 9835-5dc547a01713"}
+	h.Calendars.Create(&work)
+	h.Calendars.Create(&home)
+
+	// Test case 1: the same slot can be used in different calendars
+	for _, calendar := range []models.Calendar{work, home} {
+		requestBody := []byte(fmt.Sprintf(`{"calendar_id": %d, "title": "Test Scoped %s 9835-5dc547a01713", "event_date": "9994-02-01", "start_time": "15:00:00+07", "end_time": "16:00:00+07"}`, calendar.ID, calendar.Name))
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+	}
+
+	// Test case 2: overlaps are still rejected inside one calendar
+	requestBody := []byte(fmt.Sprintf(`{"calendar_id": %d, "title": "Test Scoped Overlap 9835-5dc547a01713", "event_date": "9994-02-01", "start_time": "15:30:00+07", "end_time": "16:30:00+07"}`, work.ID))
+	req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 3: unknown calendar
+	requestBody = []byte(`{"calendar_id": 999999999, "title": "Test Scoped Unknown 9835-5dc547a01713", "event_date": "9994-02-02", "start_time": "15:30:00+07", "end_time": "16:30:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Calendar not found"}`, resp.Body.String())
+
+	// Test case 4: filter by one or more calendars
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/events?keyword=Scoped&calendar_id=%d", home.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var eventsResp []models.Event
+	err := json.Unmarshal(resp.Body.Bytes(), &eventsResp)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(eventsResp))
+	assert.Equal(t, home.ID, eventsResp[0].CalendarID)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/events?keyword=Scoped&calendar_id=%d,%d", home.ID, work.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	err = json.Unmarshal(resp.Body.Bytes(), &eventsResp)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(eventsResp))
+
+	// Test case 5: invalid calendar filter
+	req, _ = http.NewRequest("GET", "/events?calendar_id=abc", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Invalid calendar id"}`, resp.Body.String())
+}
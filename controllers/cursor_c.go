@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+)
+
+// Default and largest number of events in a page of ListEvents
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 500
+)
+
+// eventCursor is the position of an occurrence in the sorted event list.
+// Occurrences are sorted by event date, all-day first, start time, id and recurrence date.
+type eventCursor struct {
+	EventDate      string `json:"d"`
+	AllDay         bool   `json:"a,omitempty"`
+	StartTime      string `json:"t,omitempty"`
+	ID             uint   `json:"i"`
+	RecurrenceDate string `json:"r,omitempty"`
+	// Rank is the relevance of the occurrence when events are sorted by relevance
+	Rank float64 `json:"s,omitempty"`
+	// Backward cursors return the page before the position
+	Backward bool `json:"b,omitempty"`
+}
+
+// Page of ListEvents requested by the limit and cursor query parameters
+type eventPage struct {
+	limit  int
+	cursor *eventCursor
+}
+
+// Parse the limit and cursor query parameters
+func parseEventPage(c *gin.Context) (*eventPage, error) {
+	page := &eventPage{limit: defaultPageLimit}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return nil, errors.New("Invalid limit")
+		}
+		page.limit = limit
+	}
+	if value := c.Query("cursor"); value != "" {
+		cursor, err := decodeEventCursor(value)
+		if err != nil {
+			return nil, errors.New("Invalid cursor")
+		}
+		page.cursor = cursor
+	}
+	return page, nil
+}
+
+// Encode the position of an occurrence as an opaque cursor
+func encodeEventCursor(event *models.Event, backward bool) string {
+	return newEventCursor(event, backward).encode()
+}
+
+// Cursor at the position of an occurrence
+func newEventCursor(event *models.Event, backward bool) *eventCursor {
+	cursor := &eventCursor{
+		EventDate:      event.GetEventDate().Format("2006-01-02"),
+		AllDay:         event.AllDay,
+		ID:             event.ID,
+		RecurrenceDate: event.RecurrenceDate,
+		Backward:       backward,
+	}
+	if !event.AllDay {
+		cursor.StartTime = string(event.StartTime)
+	}
+	return cursor
+}
+
+func (k *eventCursor) encode() string {
+	data, _ := json.Marshal(k)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeEventCursor(s string) (*eventCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cursor eventCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	event := cursor.event()
+	if event.GetEventDate().IsZero() || (!cursor.AllDay && event.GetStartTime().IsZero()) {
+		return nil, errors.New("invalid cursor position")
+	}
+	return &cursor, nil
+}
+
+// The occurrence at the position of the cursor, for comparing with other occurrences
+func (k *eventCursor) event() models.Event {
+	return models.Event{
+		ID:             k.ID,
+		EventDate:      k.EventDate,
+		AllDay:         k.AllDay,
+		StartTime:      models.TimeOfDay(k.StartTime),
+		RecurrenceDate: k.RecurrenceDate,
+	}
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+)
+
+// Entity tag of the current version of an event
+func eventETag(event *models.Event) string {
+	return fmt.Sprintf(`"%d"`, event.Version)
+}
+
+// Set the ETag header of a response holding an event
+func setEventETag(c *gin.Context, event *models.Event) {
+	c.Header("ETag", eventETag(event))
+}
+
+// Check the If-Match header of a request against the version of an event.
+// Requests without the header match any version.
+func matchesIfMatch(c *gin.Context, event *models.Event) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+	etag := eventETag(event)
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags never match with the strong comparison If-Match requires
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
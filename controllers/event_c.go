@@ -1,36 +1,64 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/thunthup/aimet-test/configs"
 	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
 )
 
-// Get an event by ID
-func GetEventById(c *gin.Context) {
-	db := configs.DB
-	id := c.Param("id")
-	var event models.Event
+// errOverlapping rejects events overlapping other busy events of their calendar
+var errOverlapping = errors.New("Event time is overlapping with existing events")
 
-	if err := db.First(&event, id).Error; err != nil {
+// Get an event by ID
+func (h *Handler) GetEventById(c *gin.Context) {
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Events the caller cannot view are hidden
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	event, ok := h.findEvent(c)
+	if !ok || !caller.EventRole(event).Includes(models.RoleViewer) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
 
-	eventDate, _ := time.Parse(time.RFC3339, event.EventDate)
-
-	event.EventDate = eventDate.Format("2006-01-02")
-
+	setEventETag(c, event)
+	inCallerZone(loc, event)
 	c.JSON(http.StatusOK, event)
 }
 
+// Find the event with the ID of the URL parameter
+func (h *Handler) findEvent(c *gin.Context) (*models.Event, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	event, err := h.Events.Get(uint(id))
+	if err != nil {
+		return nil, false
+	}
+	return event, true
+}
+
 // Create a new event
-func CreateEvent(c *gin.Context) {
-	db := configs.DB
+func (h *Handler) CreateEvent(c *gin.Context) {
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Bind JSON request body to Event struct
 	var event models.Event
@@ -39,98 +67,262 @@ func CreateEvent(c *gin.Context) {
 		return
 	}
 
-	// Check that end time is after start time and is a valid time
-	startTime, err := time.Parse("15:04:05-07", event.StartTime)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start time format"})
+	// Overrides are created through the occurrence endpoints
+	event.Overrides = nil
+	caller, ok := h.callerAccess(c)
+	if !ok {
 		return
 	}
-	endTime, err := time.Parse("15:04:05-07", event.EndTime)
+	if status, err := h.createEvent(&event, caller, requestAudit(c)); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	setEventETag(c, &event)
+	inCallerZone(loc, &event)
+	c.JSON(http.StatusCreated, event)
+}
+
+// Validate an event and create it with its overrides when it does not overlap other events in its calendar.
+// The event is owned by the caller, who needs the editor role on its calendar.
+// The status code tells whether an error comes from the event or from the database.
+func (h *Handler) createEvent(event *models.Event, caller *access, audit models.Audit) (int, error) {
+	if err := validateEvent(event); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if status, err := h.assignOwner(event, caller.User); err != nil {
+		return status, err
+	}
+
+	// Check that the calendar exists, events without one go to the default calendar
+	if err := h.resolveCalendar(event); err != nil {
+		if errors.Is(err, errCalendarNotFound) {
+			return http.StatusBadRequest, err
+		}
+		return http.StatusInternalServerError, errors.New("Database error")
+	}
+	if !caller.canAddTo(event.CalendarID) {
+		return http.StatusForbidden, errCalendarEditor
+	}
+
+	// Create new event, unless any occurrence overlaps with existing events in the calendar
+	err := h.writeWithoutOverlaps(event, 0, func(events repositories.EventRepository) error {
+		return events.Create(event, audit)
+	})
+	if errors.Is(err, errOverlapping) {
+		return http.StatusBadRequest, err
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end time format"})
+		return http.StatusInternalServerError, errors.New("Database error")
+	}
+	return http.StatusCreated, nil
+}
+
+// Write an event in a transaction holding its calendar once it is checked not to overlap the events of
+// the calendar, but the one with excludeID, so that no other write adds an overlapping event in between.
+// It fails with errOverlapping when the event overlaps.
+func (h *Handler) writeWithoutOverlaps(event *models.Event, excludeID uint, write func(events repositories.EventRepository) error) error {
+	return h.Events.Transaction(func(events repositories.EventRepository, calendars repositories.CalendarRepository) error {
+		if err := events.LockCalendar(event.CalendarID); err != nil {
+			return err
+		}
+		overlapping, err := events.FindOverlaps(event, excludeID)
+		if err != nil {
+			return err
+		}
+		if overlapping {
+			return errOverlapping
+		}
+		return write(events)
+	})
+}
+
+// Get events with filtering and searching
+func (h *Handler) ListEvents(c *gin.Context) {
+	// Get query parameters
+	caller, ok := h.callerAccess(c)
+	if !ok {
 		return
 	}
-	if endTime.Before(startTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
+	filter, err := parseEventFilter(c, caller)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Check that event date is a valid date
-	_, err = time.Parse("2006-01-02", event.EventDate)
+	page, err := parseEventPage(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event date format"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if there is any event at the same day and overlapping time
-	var count int64
-	if err := db.Model(&models.Event{}).
-		Where("event_date = ? AND start_time < ? AND end_time > ?", event.EventDate, event.EndTime, event.StartTime).
-		Count(&count).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	if filter.relevance {
+		h.listEventsByRelevance(c, filter, page)
 		return
 	}
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Event time is overlapping with existing events"})
+
+	// Sort by event date and start time, all-day events come first on each date.
+	// A backward cursor reads the previous page in reverse order.
+	backward := page.cursor != nil && page.cursor.Backward
+	var position *models.Event
+	if page.cursor != nil {
+		event := page.cursor.event()
+		position = &event
+	}
+
+	// Single events are paged by the repository
+	single, recurring := false, true
+	singles := filter.repositoryFilter()
+	singles.Recurring, singles.After, singles.Limit = &single, position, page.limit+1
+	singles.Desc, singles.Reverse = filter.desc, backward
+	events, err := h.Events.List(singles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
-	// Create new event
-	if err := db.Create(&event).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+	// Recurring events are expanded into their occurrences inside the requested range, from the cursor on
+	seriesFilter := filter.repositoryFilter()
+	seriesFilter.Recurring = &recurring
+	series, err := h.Events.List(seriesFilter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
+	occurrences := append([]models.Event{}, events...)
+	for i := range series {
+		expanded, err := pageOccurrences(&series[i], filter.startDate, filter.expansionEnd(), filter.location, &singles, position, page.limit+1)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		occurrences = append(occurrences, expanded...)
+	}
+	sortOccurrences(occurrences, &singles)
 
-	c.JSON(http.StatusCreated, event)
+	// Cut the page and link the pages around it
+	more := len(occurrences) > page.limit
+	if more {
+		occurrences = occurrences[:page.limit]
+	}
+	if backward {
+		for i, j := 0, len(occurrences)-1; i < j; i, j = i+1, j-1 {
+			occurrences[i], occurrences[j] = occurrences[j], occurrences[i]
+		}
+	}
+	if len(occurrences) > 0 {
+		if more || backward {
+			c.Header("X-Next-Cursor", encodeEventCursor(&occurrences[len(occurrences)-1], false))
+		}
+		if (backward && more) || (!backward && page.cursor != nil) {
+			c.Header("X-Prev-Cursor", encodeEventCursor(&occurrences[0], true))
+		}
+	}
+
+	for i := range occurrences {
+		inCallerZone(filter.location, &occurrences[i])
+	}
+	c.JSON(http.StatusOK, occurrences)
 }
 
-// Get events with filtering and searching
-func ListEvents(c *gin.Context) {
-	db := configs.DB
-	var events []models.Event
+// Filters accepted by ListEvents
+type eventFilter struct {
+	startDate time.Time
+	endDate   time.Time
+	// location is the time zone of the caller, the days of the range are in it when it is set
+	location    *time.Location
+	keyword     string
+	search      *models.SearchQuery
+	calendarIDs []uint64
+	// access keeps the events the caller may view, nil keeps every event
+	access  *repositories.Access
+	tags    []string
+	allTags bool
+	desc    bool
+	// relevance sorts the events matching the search by relevance instead of date
+	relevance bool
+}
 
-	// Get query parameters
+// Parse the filter query parameters of ListEvents, keeping the events the caller may view
+func parseEventFilter(c *gin.Context, caller *access) (*eventFilter, error) {
 	startDateStr := c.Query("start_date")
 	endDateStr := c.Query("end_date")
-	keyword := c.Query("keyword")
-	sortOrder := c.DefaultQuery("sort_order", "asc")
 	yearStr := c.Query("year")
 	monthStr := c.Query("month")
+	sortOrder := strings.ToLower(c.DefaultQuery("sort_order", "asc"))
+	filter := &eventFilter{
+		keyword:   c.Query("keyword"),
+		access:    caller.filter(models.RoleViewer),
+		desc:      sortOrder == "desc",
+		relevance: sortOrder == "relevance",
+	}
 
-	// Parse date range parameters
-	var startDate, endDate time.Time
+	// Parse the time zone of the caller
 	var err error
+	if filter.location, err = callerZone(c); err != nil {
+		return nil, err
+	}
+
+	// Parse full-text search, which relevance sort needs
+	if q := c.Query("q"); q != "" {
+		if filter.search, err = models.ParseSearch(q); err != nil {
+			return nil, err
+		}
+	}
+	if filter.relevance && filter.search == nil {
+		return nil, errors.New("Relevance sort needs a search")
+	}
+
+	// Parse calendar filter
+	if filter.calendarIDs, err = parseCalendarIDs(c); err != nil {
+		return nil, err
+	}
+
+	// Parse tag filter, events have any of the tags unless all of them are required
+	seen := map[string]bool{}
+	for _, value := range c.QueryArray("tag") {
+		for _, name := range strings.Split(value, ",") {
+			if name = models.NormalizeTagName(name); name != "" && !seen[name] {
+				seen[name] = true
+				filter.tags = append(filter.tags, name)
+			}
+		}
+	}
+	switch strings.ToLower(c.DefaultQuery("tag_match", "any")) {
+	case "any":
+	case "all":
+		filter.allTags = true
+	default:
+		return nil, errors.New("Tag match must be any or all")
+	}
+
+	// Parse date range parameters
 	if startDateStr != "" {
-		startDate, err = time.Parse("2006-01-02", startDateStr)
+		filter.startDate, err = time.Parse("2006-01-02", startDateStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date"})
-			return
+			return nil, errors.New("Invalid start date")
 		}
 	}
 	if endDateStr != "" {
-		endDate, err = time.Parse("2006-01-02", endDateStr)
+		filter.endDate, err = time.Parse("2006-01-02", endDateStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date"})
-			return
+			return nil, errors.New("Invalid end date")
 		}
 	}
 
-	//overide start and end date if year and month is provided
+	//overide start and end date if year and month is provided.
+	//the days are those of the caller's time zone, see repositoryFilter
 
 	if yearStr != "" && monthStr != "" {
 		year, err := time.Parse("2006", yearStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
-			return
+			return nil, errors.New("Invalid year")
 		}
 		month, err := time.Parse("01", monthStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month"})
-			return
+			return nil, errors.New("Invalid month")
 		}
-		startDate = time.Date(year.Year(), month.Month(), 1, 0, 0, 0, 0, time.Now().Location())
-		endDate = startDate.AddDate(0, 1, 0)
-		endDate = endDate.Add(-time.Millisecond)
+		filter.startDate = time.Date(year.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+		filter.endDate = filter.startDate.AddDate(0, 1, -1)
 
 	}
 
@@ -138,61 +330,81 @@ func ListEvents(c *gin.Context) {
 	if yearStr != "" && monthStr == "" {
 		year, err := time.Parse("2006", yearStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
-			return
+			return nil, errors.New("Invalid year")
 		}
-		startDate = time.Date(year.Year(), 1, 1, 0, 0, 0, 0, time.Now().Location())
-		endDate = startDate.AddDate(1, 0, 0)
-		endDate = endDate.Add(-time.Millisecond)
+		filter.startDate = time.Date(year.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		filter.endDate = filter.startDate.AddDate(1, 0, -1)
 
 	}
+	return filter, nil
+}
 
-	// Build query
-	query := db.Model(&models.Event{})
-	if !startDate.IsZero() {
-		query = query.Where("event_date >= ?", startDate)
-	}
-	if !endDate.IsZero() {
-		query = query.Where("event_date <= ?", endDate)
-	}
-	if keyword != "" {
-		query = query.Where("title LIKE ?", "%"+keyword+"%")
+// Parse the calendar_id query parameter, given as repeated or comma separated ids
+func parseCalendarIDs(c *gin.Context) ([]uint64, error) {
+	var ids []uint64
+	for _, value := range c.QueryArray("calendar_id") {
+		for _, idStr := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(idStr), 10, 64)
+			if err != nil {
+				return nil, errors.New("Invalid calendar id")
+			}
+			ids = append(ids, id)
+		}
 	}
+	return ids, nil
+}
 
-	// Sort by event date and start time
-	sortDirection := "ASC"
-	if strings.ToLower(sortOrder) == "desc" {
-		sortDirection = "DESC"
+// Repository filter matching events that intersect the range, timed events by instant in the caller's time zone.
+// Recurring events starting before the range may still have occurrences in it.
+func (f *eventFilter) repositoryFilter() repositories.EventFilter {
+	filter := repositories.EventFilter{
+		Location:    f.location,
+		Keyword:     f.keyword,
+		Search:      f.search,
+		CalendarIDs: f.calendarIDs,
+		Access:      f.access,
+		Tags:        f.tags,
+		AllTags:     f.allTags,
 	}
-	query = query.Order("event_date " + sortDirection + ", start_time " + sortDirection)
-
-	// Execute query
-	if err := query.Find(&events).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+	if !f.startDate.IsZero() {
+		filter.StartDate = f.startDate.Format("2006-01-02")
 	}
-
-	for i := range events {
-		tempEventDate, _ := time.Parse(time.RFC3339, events[i].EventDate)
-		events[i].EventDate = tempEventDate.Format("2006-01-02")
+	if !f.endDate.IsZero() {
+		filter.EndDate = f.endDate.Format("2006-01-02")
 	}
+	return filter
+}
 
-	c.JSON(http.StatusOK, events)
+// Last date recurring events are expanded to
+func (f *eventFilter) expansionEnd() time.Time {
+	if !f.endDate.IsZero() {
+		return f.endDate
+	}
+	return defaultExpansionEnd(f.startDate)
 }
 
 // Update an existing event
-func UpdateEvent(c *gin.Context) {
-	db := configs.DB
-
-	// Get event ID from URL parameter
-	eventID := c.Param("id")
+func (h *Handler) UpdateEvent(c *gin.Context) {
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Check if event exists
-	var existingEvent models.Event
-	if err := db.Where("id = ?", eventID).First(&existingEvent).Error; err != nil {
+	// Check if event exists and the caller may edit it
+	existingEvent, ok := h.findEvent(c)
+	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
+	caller, ok := h.authorizeEvent(c, existingEvent, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if !matchesIfMatch(c, existingEvent) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionConflict.Error()})
+		return
+	}
 
 	// Bind JSON request body to Event struct
 	var updatedEvent models.Event
@@ -201,73 +413,183 @@ func UpdateEvent(c *gin.Context) {
 		return
 	}
 
-	// Check that end time is after start time and is a valid time
-	startTime, err := time.Parse("15:04:05-07", updatedEvent.StartTime)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start time format"})
+	if status, err := h.updateEvent(existingEvent, &updatedEvent, caller, requestAudit(c)); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
-	endTime, err := time.Parse("15:04:05-07", updatedEvent.EndTime)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end time format"})
-		return
+
+	setEventETag(c, existingEvent)
+	inCallerZone(loc, existingEvent)
+	c.JSON(http.StatusOK, existingEvent)
+}
+
+// Validate the new values of an event and save them when the event does not overlap other events in its calendar.
+// Moving the event to another calendar needs the editor role on it.
+// The status code tells whether an error comes from the event or from the database.
+func (h *Handler) updateEvent(existingEvent *models.Event, updatedEvent *models.Event, caller *access, audit models.Audit) (int, error) {
+	if err := validateEvent(updatedEvent); err != nil {
+		return http.StatusBadRequest, err
 	}
-	if endTime.Before(startTime) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "End time must be after start time"})
-		return
+
+	// Check that the calendar exists, events stay in their calendar unless another one is given
+	if updatedEvent.CalendarID == 0 || updatedEvent.CalendarID == existingEvent.CalendarID {
+		updatedEvent.CalendarID = existingEvent.CalendarID
+	} else if err := h.resolveCalendar(updatedEvent); err != nil {
+		if errors.Is(err, errCalendarNotFound) {
+			return http.StatusBadRequest, err
+		}
+		return http.StatusInternalServerError, errors.New("Database error")
+	} else if !caller.canAddTo(updatedEvent.CalendarID) {
+		return http.StatusForbidden, errCalendarEditor
 	}
 
-	// Check that event date is a valid date
-	_, err = time.Parse("2006-01-02", updatedEvent.EventDate)
+	// Update existing event unless any occurrence overlaps with other events in the calendar, keeping the
+	// existing overrides. The version read with it must still be the current one.
+	updatedEvent.Overrides = existingEvent.Overrides
+	err := h.writeWithoutOverlaps(updatedEvent, existingEvent.ID, func(events repositories.EventRepository) error {
+		existingEvent.CalendarID = updatedEvent.CalendarID
+		existingEvent.Title = updatedEvent.Title
+		existingEvent.Description = updatedEvent.Description
+		existingEvent.Location = updatedEvent.Location
+		existingEvent.URL = updatedEvent.URL
+		existingEvent.Color = updatedEvent.Color
+		existingEvent.Tags = updatedEvent.Tags
+		existingEvent.EventDate = updatedEvent.EventDate
+		existingEvent.EndDate = updatedEvent.EndDate
+		existingEvent.StartTime = updatedEvent.StartTime
+		existingEvent.EndTime = updatedEvent.EndTime
+		existingEvent.TimeZone = updatedEvent.TimeZone
+		existingEvent.StartAt = updatedEvent.StartAt
+		existingEvent.EndAt = updatedEvent.EndAt
+		existingEvent.AllDay = updatedEvent.AllDay
+		existingEvent.Busy = updatedEvent.Busy
+		existingEvent.RRule = updatedEvent.RRule
+		existingEvent.ExDates = updatedEvent.ExDates
+		return events.Update(existingEvent, audit)
+	})
+	if errors.Is(err, errOverlapping) {
+		return http.StatusBadRequest, err
+	}
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		return http.StatusPreconditionFailed, err
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event date format"})
-		return
+		return http.StatusInternalServerError, errors.New("Database error")
 	}
+	return http.StatusOK, nil
+}
 
-	// Check if there is any event at the same day and overlapping time
-	var count int64
-	if err := db.Model(&models.Event{}).
-		Where("event_date = ? AND start_time < ? AND end_time > ? AND id <> ?", updatedEvent.EventDate, updatedEvent.EndTime, updatedEvent.StartTime, eventID).
-		Count(&count).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+// Delete an event by ID
+func (h *Handler) DeleteEvent(c *gin.Context) {
+	// Check if event exists and the caller may edit it
+	event, ok := h.findEvent(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
 		return
 	}
-	if count > 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Event time is overlapping with existing events"})
+	if _, ok := h.authorizeEvent(c, event, models.RoleEditor); !ok {
 		return
 	}
 
-	// Update existing event
-	existingEvent.Title = updatedEvent.Title
-	existingEvent.EventDate = updatedEvent.EventDate
-	existingEvent.StartTime = updatedEvent.StartTime
-	existingEvent.EndTime = updatedEvent.EndTime
+	if !matchesIfMatch(c, event) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionConflict.Error()})
+		return
+	}
 
-	if err := db.Save(&existingEvent).Error; err != nil {
+	// Delete event, unless it changed since it was read
+	if err := h.Events.Delete(event, requestAudit(c)); err != nil {
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 		return
 	}
 
-	c.JSON(http.StatusOK, existingEvent)
+	c.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully"})
 }
 
-// Delete an event by ID
-func DeleteEvent(c *gin.Context) {
-	db := configs.DB
-	eventID := c.Param("id")
+// Validate the time, date and recurrence fields of an event
+func validateEvent(event *models.Event) error {
+	// Check the time zone of the event, events without one keep the offsets of their times
+	var loc *time.Location
+	if event.TimeZone != "" {
+		var err error
+		if loc, err = models.LoadTimeZone(event.TimeZone); err != nil {
+			return err
+		}
+	}
 
-	// Check if event exists
-	var event models.Event
-	if err := db.Where("id = ?", eventID).First(&event).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
-		return
+	// Check that start and end time are valid times, all-day events have none.
+	// Times without offset are wall clock times in the time zone of the event.
+	if event.AllDay {
+		event.StartTime, event.EndTime = "", ""
+	} else {
+		if !validEventTime(event.StartTime, loc) {
+			return errors.New("Invalid start time format")
+		}
+		if !validEventTime(event.EndTime, loc) {
+			return errors.New("Invalid end time format")
+		}
 	}
 
-	// Delete event
-	if err := db.Delete(&event).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-		return
+	// Check that event date and end date are valid dates, single-day events end on their event date
+	if _, err := time.Parse("2006-01-02", event.EventDate); err != nil {
+		return errors.New("Invalid event date format")
+	}
+	if event.EndDate == "" {
+		event.EndDate = event.EventDate
+	}
+	if _, err := time.Parse("2006-01-02", event.EndDate); err != nil {
+		return errors.New("Invalid end date format")
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Event deleted successfully"})
+	// Write the times in the offsets the time zone has on the dates of the event, keeping their instants
+	if event.AllDay {
+		event.StartAt, event.EndAt = nil, nil
+	} else {
+		event.SetSpan(eventInstant(event.GetEventDate(), event.StartTime, loc), eventInstant(event.GetEndDate(), event.EndTime, loc))
+	}
+
+	// Check that the event ends after it starts, possibly on a later day
+	if event.AllDay && event.GetEndDate().Before(event.GetEventDate()) {
+		return errors.New("End date must not be before event date")
+	}
+	if event.GetEndAt().Before(event.GetStartAt()) {
+		return errors.New("End time must be after start time")
+	}
+
+	// All-day events do not block other events unless marked as busy
+	if event.Busy == nil {
+		busy := event.IsBusy()
+		event.Busy = &busy
+	}
+
+	// Check the recurrence rule and its exception dates
+	if event.RRule != "" {
+		rule, err := models.ParseRecurrenceRule(event.RRule)
+		if err != nil {
+			return fmt.Errorf("Invalid recurrence rule: %s", err)
+		}
+		if err := rule.CheckStart(event.GetEventDate()); err != nil {
+			return fmt.Errorf("Invalid recurrence rule: %s", err)
+		}
+	}
+	for _, exDate := range event.ExDates {
+		if _, err := time.Parse("2006-01-02", exDate); err != nil {
+			return errors.New("Invalid exception date format")
+		}
+	}
+	if len(event.ExDates) > 0 && event.RRule == "" {
+		return errors.New("Exception dates require a recurrence rule")
+	}
+
+	// Tags are compared by their normalized names, colors are stored in lower case
+	tags, err := models.NormalizeTags(event.Tags)
+	if err != nil {
+		return err
+	}
+	event.Tags = tags
+	event.Color = strings.ToLower(event.Color)
+	return nil
 }
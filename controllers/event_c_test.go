@@ -7,22 +7,46 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/thunthup/aimet-test/configs"
 	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
 	"gotest.tools/v3/assert"
 )
 
 func init() {
-	var path = "../.env"
-	configs.LoadEnvVar(&path)
-	configs.ConnectPostgresDB()
 	gin.SetMode(gin.TestMode)
 }
 
+// Create a handler storing events in memory, starting with only the default calendar
+func newTestHandler() *Handler {
+	events, calendars, grants, webhooks, reminders := repositories.NewMemoryRepositories()
+	return NewHandler(events, calendars, repositories.NewMemoryUserRepository(), grants, webhooks, reminders)
+}
+
+// Admin making the requests of the test routers
+var testAdmin = &models.User{ID: 1, Name: "admin", Admin: true}
+
+// Router whose requests are made by testAdmin, in place of Authenticate
+func newTestRouter() *gin.Engine {
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		c.Set(userKey, testAdmin)
+	})
+	return r
+}
+
+// Delete the events whose title contains the keyword
+func deleteEvents(h *Handler, keyword string) {
+	events, _ := h.Events.List(repositories.EventFilter{Keyword: keyword})
+	for i := range events {
+		h.Events.Delete(&events[i], models.Audit{})
+	}
+}
+
 // Unmarshal response body
 type EventResp struct {
 	Title     string `json:"title"`
@@ -33,9 +57,9 @@ type EventResp struct {
 
 func TestCreateEvent(t *testing.T) {
 	// Setup
-	r := gin.Default()
-	r.POST("/events", CreateEvent)
-	db := configs.DB
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/events", h.CreateEvent)
 	// Test case 1: valid input
 	requestBody := []byte(`{"title": "Test Event 9835-5dc547a01713", "event_date": "4000-05-15", "start_time": "15:00:00+07", "end_time": "16:00:00+07"}`)
 	req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
@@ -58,7 +82,7 @@ func TestCreateEvent(t *testing.T) {
 	}
 	assert.DeepEqual(t, expectedEvent, event)
 
-	db.Delete(&models.Event{}, "title = ?", "Test Event 9835-5dc547a01713")
+	deleteEvents(h, "Test Event 9835-5dc547a01713")
 
 	// Test case 2: invalid input (end time before start time)
 	requestBody = []byte(`{"title": "Test Event 9835-5dc547a01713", "event_date": "4000-05-15", "start_time": "16:00:00+07", "end_time": "15:00:00+07"}`)
@@ -118,8 +142,8 @@ func TestCreateEvent(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	resp = httptest.NewRecorder()
 	r.ServeHTTP(resp, req)
-	db.Delete(&models.Event{}, "title = ?", "Test Event 9835-5dc547a01713")
-	db.Delete(&models.Event{}, "title = ?", "Test Event overlapped 9835-5dc547a01713")
+	deleteEvents(h, "Test Event 9835-5dc547a01713")
+	deleteEvents(h, "Test Event overlapped 9835-5dc547a01713")
 	assert.Equal(t, http.StatusBadRequest, resp.Code)
 	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
 
@@ -127,9 +151,9 @@ func TestCreateEvent(t *testing.T) {
 
 func TestGetEventById(t *testing.T) {
 	// Setup
-	r := gin.Default()
-	r.GET("/events/:id", GetEventById)
-	db := configs.DB
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events/:id", h.GetEventById)
 
 	// Create test event
 	event := models.Event{
@@ -138,7 +162,7 @@ func TestGetEventById(t *testing.T) {
 		StartTime: "15:00:00+07",
 		EndTime:   "16:00:00+07",
 	}
-	db.Create(&event)
+	h.Events.Create(&event, models.Audit{})
 
 	// Test case 1: valid event ID
 	req, _ := http.NewRequest("GET", "/events/"+strconv.Itoa(int(event.ID)), nil)
@@ -162,7 +186,7 @@ func TestGetEventById(t *testing.T) {
 	invalidID := strconv.Itoa(int(event.ID))
 
 	// Cleanup
-	db.Delete(&event)
+	h.Events.Delete(&event, models.Audit{})
 
 	// Test case 2: invalid event ID
 	req, _ = http.NewRequest("GET", "/events/"+invalidID, nil)
@@ -175,9 +199,9 @@ func TestGetEventById(t *testing.T) {
 
 func TestUpdateEvent(t *testing.T) {
 	// Setup
-	r := gin.Default()
-	r.PUT("/events/:id", UpdateEvent)
-	db := configs.DB
+	h := newTestHandler()
+	r := newTestRouter()
+	r.PUT("/events/:id", h.UpdateEvent)
 
 	// Create an event to update
 	event := models.Event{
@@ -198,14 +222,11 @@ func TestUpdateEvent(t *testing.T) {
 		StartTime: "15:00:00+07",
 		EndTime:   "16:00:00+07",
 	}
-	db.Create(&event)
-	db.Create(&overlappedEvent)
-	db.Create(&nonExistEvent)
+	h.Events.Create(&event, models.Audit{})
+	h.Events.Create(&overlappedEvent, models.Audit{})
+	h.Events.Create(&nonExistEvent, models.Audit{})
 	nonExistEventID := nonExistEvent.ID
-	db.Delete(&nonExistEvent)
-	//tear down
-	defer db.Delete(&event)
-	defer db.Delete(&overlappedEvent)
+	h.Events.Delete(&nonExistEvent, models.Audit{})
 
 	// Test case 1: valid input
 	requestBody := []byte(`{"title": "Updated Event 9835-5dc547a01713", "event_date": "9999-05-16", "start_time": "17:00:00+07", "end_time": "18:00:00+07"}`)
@@ -216,24 +237,28 @@ func TestUpdateEvent(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, resp.Code)
 
-	var updatedEvent models.Event
-	db.Find(&updatedEvent, event.ID)
-	tempEventDate, _ := time.Parse(time.RFC3339, updatedEvent.EventDate)
-
-	updatedEvent.EventDate = tempEventDate.Format("2006-01-02")
+	updatedEvent, err := h.Events.Get(event.ID)
+	assert.NilError(t, err)
 
 	// Assert updated event
+	busy := true
+	startAt, endAt := time.Date(9999, 5, 16, 10, 0, 0, 0, time.UTC), time.Date(9999, 5, 16, 11, 0, 0, 0, time.UTC)
 	expectedEvent := models.Event{
 		ID:        event.ID,
 		Title:     "Updated Event 9835-5dc547a01713",
 		EventDate: "9999-05-16",
+		EndDate:   "9999-05-16",
 		StartTime: "17:00:00+07",
 		EndTime:   "18:00:00+07",
+		StartAt:   &startAt,
+		EndAt:     &endAt,
+		Busy:      &busy,
+		Version:   2,
 		CreatedAt: updatedEvent.CreatedAt,
 		UpdatedAt: updatedEvent.UpdatedAt,
 		DeletedAt: updatedEvent.DeletedAt,
 	}
-	assert.DeepEqual(t, expectedEvent, updatedEvent)
+	assert.DeepEqual(t, expectedEvent, *updatedEvent)
 
 	// Test case 2: invalid input (end time before start time)
 	requestBody = []byte(`{"title": "Invalid Event 9835-5dc547a01713", "event_date": "9999-05-17", "start_time": "18:00:00+07", "end_time": "17:00:00+07"}`)
@@ -304,9 +329,9 @@ func TestUpdateEvent(t *testing.T) {
 
 func TestListEvents(t *testing.T) {
 	// Setup
-	r := gin.Default()
-	r.GET("/events", ListEvents)
-	db := configs.DB
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
 
 	// Create test events
 	event1 := models.Event{
@@ -339,18 +364,11 @@ func TestListEvents(t *testing.T) {
 		StartTime: "18:00:00+07",
 		EndTime:   "19:00:00+07",
 	}
-	db.Create(&event1)
-	db.Create(&event2)
-	db.Create(&event3)
-	db.Create(&event4)
-	db.Create(&event5)
-
-	//tear down
-	defer db.Delete(&event1)
-	defer db.Delete(&event2)
-	defer db.Delete(&event3)
-	defer db.Delete(&event4)
-	defer db.Delete(&event5)
+	h.Events.Create(&event1, models.Audit{})
+	h.Events.Create(&event2, models.Audit{})
+	h.Events.Create(&event3, models.Audit{})
+	h.Events.Create(&event4, models.Audit{})
+	h.Events.Create(&event5, models.Audit{})
 
 	// Test case 1: list all events with keyword, date range, sort order = desc
 	req, _ := http.NewRequest("GET", "/events?keyword=9835-5dc547a01713&start_date=9998-04-08&end_date=9999-06-17&sort_order=desc", nil)
@@ -449,9 +467,9 @@ func TestListEvents(t *testing.T) {
 
 func TestDeleteEvent(t *testing.T) {
 	// Setup
-	r := gin.Default()
-	r.DELETE("/events/:id", DeleteEvent)
-	db := configs.DB
+	h := newTestHandler()
+	r := newTestRouter()
+	r.DELETE("/events/:id", h.DeleteEvent)
 
 	// Create an event to delete
 	event := models.Event{
@@ -460,7 +478,7 @@ func TestDeleteEvent(t *testing.T) {
 		StartTime: "15:00:00+07",
 		EndTime:   "16:00:00+07",
 	}
-	db.Create(&event)
+	h.Events.Create(&event, models.Audit{})
 	eventID := event.ID
 
 	// Test case 1: valid input
@@ -472,8 +490,7 @@ func TestDeleteEvent(t *testing.T) {
 	assert.Equal(t, `{"message":"Event deleted successfully"}`, resp.Body.String())
 
 	// Verify that the event has been deleted
-	var deletedEvent models.Event
-	if err := db.Where("id = ?", eventID).First(&deletedEvent).Error; err == nil {
+	if deletedEvent, err := h.Events.Get(eventID); err == nil {
 		t.Errorf("Expected event with ID %d to be deleted, but found %+v", eventID, deletedEvent)
 	}
 
@@ -485,3 +502,459 @@ func TestDeleteEvent(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, resp.Code)
 	assert.Equal(t, `{"error":"Event not found"}`, resp.Body.String())
 }
+
+func TestMultiDayEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
+	r.POST("/events", h.CreateEvent)
+
+	// Test case 1: overnight event ending on the next day
+	requestBody := []byte(`{"title": "Test Multi-day Overnight 9835-5dc547a01713", "event_date": "9996-03-10", "end_date": "9996-03-11", "start_time": "22:00:00+07", "end_time": "02:00:00+07"}`)
+	req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// Test case 2: end before start across days
+	requestBody = []byte(`{"title": "Test Multi-day Invalid 9835-5dc547a01713", "event_date": "9996-03-11", "end_date": "9996-03-10", "start_time": "22:00:00+07", "end_time": "23:00:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"End time must be after start time"}`, resp.Body.String())
+
+	// Test case 3: invalid end date
+	requestBody = []byte(`{"title": "Test Multi-day Invalid 9835-5dc547a01713", "event_date": "9996-03-11", "end_date": "9996-03-41", "start_time": "22:00:00+07", "end_time": "23:00:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Invalid end date format"}`, resp.Body.String())
+
+	// Test case 4: event in the early morning overlapping the overnight event
+	requestBody = []byte(`{"title": "Test Multi-day Morning 9835-5dc547a01713", "event_date": "9996-03-11", "start_time": "01:00:00+07", "end_time": "03:00:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 5: three-day conference around the overnight event
+	requestBody = []byte(`{"title": "Test Multi-day Conference 9835-5dc547a01713", "event_date": "9996-03-09", "end_date": "9996-03-12", "start_time": "09:00:00+07", "end_time": "17:00:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 6: list events intersecting a range that starts after the event starts
+	req, _ = http.NewRequest("GET", "/events?keyword=Multi-day&start_date=9996-03-11&end_date=9996-03-11", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var eventsResp []models.Event
+	err := json.Unmarshal(resp.Body.Bytes(), &eventsResp)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(eventsResp))
+	assert.Equal(t, "9996-03-10", eventsResp[0].EventDate)
+	assert.Equal(t, "9996-03-11", eventsResp[0].EndDate)
+}
+
+func TestAllDayEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
+	r.POST("/events", h.CreateEvent)
+
+	// Test case 1: all-day event without times
+	requestBody := []byte(`{"title": "Test All-day Holiday 9835-5dc547a01713", "event_date": "9995-04-13", "end_date": "9995-04-15", "all_day": true}`)
+	req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var holiday models.Event
+	err := json.Unmarshal(resp.Body.Bytes(), &holiday)
+	assert.NilError(t, err)
+	assert.Equal(t, models.TimeOfDay(""), holiday.StartTime)
+	assert.Equal(t, false, *holiday.Busy)
+
+	// Test case 2: timed event during the holiday is not blocked
+	requestBody = []byte(`{"title": "Test All-day Meeting 9835-5dc547a01713", "event_date": "9995-04-14", "start_time": "10:00:00+07", "end_time": "11:00:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// Test case 3: busy all-day event blocks the timed event
+	requestBody = []byte(`{"title": "Test All-day Leave 9835-5dc547a01713", "event_date": "9995-04-14", "all_day": true, "busy": true}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 4: end date before event date
+	requestBody = []byte(`{"title": "Test All-day Invalid 9835-5dc547a01713", "event_date": "9995-04-14", "end_date": "9995-04-12", "all_day": true}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"End date must not be before event date"}`, resp.Body.String())
+
+	// Test case 5: all-day events are listed ahead of timed events on the same date
+	requestBody = []byte(`{"title": "Test All-day Deadline 9835-5dc547a01713", "event_date": "9995-04-14", "all_day": true}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	req, _ = http.NewRequest("GET", "/events?keyword=All-day&start_date=9995-04-14&end_date=9995-04-14", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var eventsResp []models.Event
+	err = json.Unmarshal(resp.Body.Bytes(), &eventsResp)
+	assert.NilError(t, err)
+	titles := []string{}
+	for _, e := range eventsResp {
+		titles = append(titles, e.Title)
+	}
+	assert.DeepEqual(t, []string{"Test All-day Holiday 9835-5dc547a01713", "Test All-day Deadline 9835-5dc547a01713", "Test All-day Meeting 9835-5dc547a01713"}, titles)
+}
+
+func TestListEventsPagination(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
+	r.POST("/events", h.CreateEvent)
+
+	for _, body := range []string{
+		`{"title": "Test Page 1 9835-5dc547a01713", "event_date": "9993-02-01", "all_day": true}`,
+		`{"title": "Test Page 2 9835-5dc547a01713", "event_date": "9993-02-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`,
+		`{"title": "Test Page Series 9835-5dc547a01713", "event_date": "9993-02-01", "start_time": "11:00:00+07", "end_time": "12:00:00+07", "rrule": "FREQ=DAILY;COUNT=3"}`,
+		`{"title": "Test Page 3 9835-5dc547a01713", "event_date": "9993-02-02", "start_time": "08:00:00+07", "end_time": "09:00:00+07"}`,
+		`{"title": "Test Page 4 9835-5dc547a01713", "event_date": "9993-02-03", "start_time": "13:00:00+07", "end_time": "14:00:00+07"}`,
+	} {
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+	}
+
+	// Read every page following the given cursor header, repeating the filters
+	readPages := func(filters string, cursor string, header string) ([][]string, string) {
+		pages := [][]string{}
+		var other string
+		for {
+			query := filters
+			if cursor != "" {
+				query += "&cursor=" + cursor
+			}
+			req, _ := http.NewRequest("GET", "/events?keyword=Page&limit=2&"+query, nil)
+			resp := httptest.NewRecorder()
+			r.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+
+			var eventsResp []models.Event
+			err := json.Unmarshal(resp.Body.Bytes(), &eventsResp)
+			assert.NilError(t, err)
+			page := []string{}
+			for _, e := range eventsResp {
+				page = append(page, e.EventDate+" "+e.Title[len("Test Page "):len(e.Title)-len(" 9835-5dc547a01713")])
+			}
+			pages = append(pages, page)
+			other = resp.Header().Get(map[string]string{"X-Next-Cursor": "X-Prev-Cursor", "X-Prev-Cursor": "X-Next-Cursor"}[header])
+			if cursor = resp.Header().Get(header); cursor == "" {
+				return pages, other
+			}
+		}
+	}
+
+	// Test case 1: forward pages mix single events and occurrences
+	pages, prev := readPages("start_date=9993-02-01&end_date=9993-02-28", "", "X-Next-Cursor")
+	assert.DeepEqual(t, [][]string{
+		{"9993-02-01 1", "9993-02-01 2"},
+		{"9993-02-01 Series", "9993-02-02 3"},
+		{"9993-02-02 Series", "9993-02-03 Series"},
+		{"9993-02-03 4"},
+	}, pages)
+
+	// Test case 2: backward pages from the last page
+	pages, _ = readPages("start_date=9993-02-01&end_date=9993-02-28", prev, "X-Prev-Cursor")
+	assert.DeepEqual(t, [][]string{
+		{"9993-02-02 Series", "9993-02-03 Series"},
+		{"9993-02-01 Series", "9993-02-02 3"},
+		{"9993-02-01 1", "9993-02-01 2"},
+	}, pages)
+
+	// Test case 3: descending order
+	pages, _ = readPages("start_date=9993-02-01&end_date=9993-02-28&sort_order=desc", "", "X-Next-Cursor")
+	assert.DeepEqual(t, [][]string{
+		{"9993-02-03 4", "9993-02-03 Series"},
+		{"9993-02-02 Series", "9993-02-02 3"},
+		{"9993-02-01 1", "9993-02-01 Series"},
+		{"9993-02-01 2"},
+	}, pages)
+
+	// Test case 4: invalid parameters
+	for query, message := range map[string]string{
+		"limit=0":         "Invalid limit",
+		"limit=501":       "Invalid limit",
+		"cursor=invalid!": "Invalid cursor",
+	} {
+		req, _ := http.NewRequest("GET", "/events?"+query, nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, `{"error":"`+message+`"}`, resp.Body.String())
+	}
+}
+
+func TestPatchEvent(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/events", h.CreateEvent)
+	r.PATCH("/events/:id", h.PatchEvent)
+
+	var created []models.Event
+	for _, body := range []string{
+		`{"title": "Test Patch 9835-5dc547a01713", "event_date": "9992-07-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`,
+		`{"title": "Test Patch Other 9835-5dc547a01713", "event_date": "9992-07-01", "start_time": "11:00:00+07", "end_time": "12:00:00+07"}`,
+	} {
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+
+		var event models.Event
+		err := json.Unmarshal(resp.Body.Bytes(), &event)
+		assert.NilError(t, err)
+		created = append(created, event)
+	}
+	path := fmt.Sprintf("/events/%d", created[0].ID)
+	patch := func(contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", path, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", contentType)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test case 1: merge patch of the title keeps the other fields
+	resp := patch("application/merge-patch+json", `{"title": "Test Patch Renamed 9835-5dc547a01713"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var patched models.Event
+	err := json.Unmarshal(resp.Body.Bytes(), &patched)
+	assert.NilError(t, err)
+	assert.Equal(t, "Test Patch Renamed 9835-5dc547a01713", patched.Title)
+	assert.Equal(t, "9992-07-01", patched.EventDate)
+	assert.Equal(t, models.TimeOfDay("09:00:00+07"), patched.StartTime)
+	assert.Equal(t, models.TimeOfDay("10:00:00+07"), patched.EndTime)
+
+	// Test case 2: JSON Patch of the end time
+	resp = patch("application/json-patch+json", `[{"op": "test", "path": "/end_time", "value": "10:00:00+07"}, {"op": "replace", "path": "/end_time", "value": "10:30:00+07"}]`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	err = json.Unmarshal(resp.Body.Bytes(), &patched)
+	assert.NilError(t, err)
+	assert.Equal(t, models.TimeOfDay("10:30:00+07"), patched.EndTime)
+	assert.Equal(t, "Test Patch Renamed 9835-5dc547a01713", patched.Title)
+
+	// Test case 3: the merged event is validated
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		code        int
+		message     string
+	}{
+		{"application/merge-patch+json", `{"end_time": "08:00:00+07"}`, http.StatusBadRequest, "End time must be after start time"},
+		{"application/merge-patch+json", `{"start_time": "9am"}`, http.StatusBadRequest, "Invalid start time format"},
+		{"application/merge-patch+json", `{"end_time": "11:30:00+07"}`, http.StatusBadRequest, "Event time is overlapping with existing events"},
+		{"application/merge-patch+json", `{"title": null}`, http.StatusBadRequest, "Key: 'Event.Title' Error:Field validation for 'Title' failed on the 'required' tag"},
+		{"application/merge-patch+json", `not json`, http.StatusBadRequest, "Invalid merge patch"},
+		{"application/json-patch+json", `{"op": "replace"}`, http.StatusBadRequest, "Invalid JSON Patch"},
+		{"application/json-patch+json", `[{"op": "test", "path": "/title", "value": "Stale"}]`, http.StatusUnprocessableEntity, "JSON Patch cannot be applied: testing value /title failed: test failed"},
+		{"text/plain", `{}`, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json or application/json-patch+json"},
+	} {
+		resp = patch(tc.contentType, tc.body)
+		assert.Equal(t, tc.code, resp.Code, tc.body)
+		var errResp map[string]string
+		err = json.Unmarshal(resp.Body.Bytes(), &errResp)
+		assert.NilError(t, err)
+		assert.Equal(t, tc.message, errResp["error"], tc.body)
+	}
+
+	// Test case 4: event not found
+	path = "/events/0"
+	resp = patch("application/merge-patch+json", `{"title": "Test Patch Missing 9835-5dc547a01713"}`)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"error":"Event not found"}`, resp.Body.String())
+}
+
+func TestEventVersions(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events/:id", h.GetEventById)
+	r.POST("/events", h.CreateEvent)
+	r.PUT("/events/:id", h.UpdateEvent)
+	r.PATCH("/events/:id", h.PatchEvent)
+	r.DELETE("/events/:id", h.DeleteEvent)
+	send := func(method, path, ifMatch, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test case 1: created events start at version 1
+	resp := send("POST", "/events", "", `{"title": "Test Version 9835-5dc547a01713", "event_date": "9991-08-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.Equal(t, `"1"`, resp.Header().Get("ETag"))
+	var event models.Event
+	err := json.Unmarshal(resp.Body.Bytes(), &event)
+	assert.NilError(t, err)
+	assert.Equal(t, uint(1), event.Version)
+	path := fmt.Sprintf("/events/%d", event.ID)
+
+	resp = send("GET", path, "", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"1"`, resp.Header().Get("ETag"))
+
+	// Test case 2: updates with the current version move to the next version
+	resp = send("PUT", path, `"1"`, `{"title": "Test Version Updated 9835-5dc547a01713", "event_date": "9991-08-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+
+	resp = send("PATCH", path, `"0", "2"`, `{"title": "Test Version Patched 9835-5dc547a01713"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"3"`, resp.Header().Get("ETag"))
+
+	// Test case 3: stale versions are rejected
+	resp = send("PUT", path, `"2"`, `{"title": "Test Version Stale 9835-5dc547a01713", "event_date": "9991-08-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	assert.Equal(t, `{"error":"Event has been modified by another request"}`, resp.Body.String())
+
+	resp = send("PATCH", path, `W/"3"`, `{"title": "Test Version Stale 9835-5dc547a01713"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	resp = send("DELETE", path, `"1"`, "")
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+
+	// Test case 4: the version check is enforced by the update itself
+	stale, _ := h.Events.Get(event.ID)
+	current, _ := h.Events.Get(event.ID)
+	h.Events.Update(current, models.Audit{})
+	stale.Title = "Test Version Lost 9835-5dc547a01713"
+	status, err := h.updateEvent(stale, &models.Event{Title: stale.Title, EventDate: "9991-08-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}, &access{models.NewRoles(testAdmin, nil, nil)}, models.Audit{})
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+	assert.Equal(t, repositories.ErrVersionConflict, err)
+
+	// Test case 5: delete with the current version
+	resp = send("DELETE", path, `"4"`, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
+
+func TestEventDetails(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
+	r.GET("/events/:id", h.GetEventById)
+	r.POST("/events", h.CreateEvent)
+	r.PATCH("/events/:id", h.PatchEvent)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+	titles := func(query string) []string {
+		resp := send("GET", "/events?keyword=Details&"+query, "")
+		assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		var events []models.Event
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &events))
+		result := []string{}
+		for _, e := range events {
+			result = append(result, e.Title[len("Test Details "):len(e.Title)-len(" 9835-5dc547a01713")])
+		}
+		return result
+	}
+
+	// Test case 1: details round-trip, tags are normalized and sorted
+	resp := send("POST", "/events", `{"title": "Test Details Standup 9835-5dc547a01713", "event_date": "9990-05-01", "start_time": "09:00:00+07", "end_time": "09:15:00+07",
+		"description": "Yesterday, today, blockers", "location": "Room 2", "url": "https://meet.example.com/abc", "color": "#1E90FF", "tags": [" Team ", "work", "team"]}`)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var event models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &event))
+	resp = send("GET", fmt.Sprintf("/events/%d", event.ID), "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var stored map[string]interface{}
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &stored))
+	assert.Equal(t, "Yesterday, today, blockers", stored["description"])
+	assert.Equal(t, "Room 2", stored["location"])
+	assert.Equal(t, "https://meet.example.com/abc", stored["url"])
+	assert.Equal(t, "#1e90ff", stored["color"])
+	assert.DeepEqual(t, []interface{}{"team", "work"}, stored["tags"])
+
+	resp = send("POST", "/events", `{"title": "Test Details Trip 9835-5dc547a01713", "event_date": "9990-05-02", "all_day": true, "tags": ["travel", "work"]}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	resp = send("POST", "/events", `{"title": "Test Details Lunch 9835-5dc547a01713", "event_date": "9990-05-03", "start_time": "12:00:00+07", "end_time": "13:00:00+07"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// Test case 2: filter by any or all of the tags
+	assert.DeepEqual(t, []string{"Standup", "Trip"}, titles("tag=work"))
+	assert.DeepEqual(t, []string{"Standup", "Trip"}, titles("tag=team,travel"))
+	assert.DeepEqual(t, []string{"Trip"}, titles("tag=Travel&tag=work&tag_match=all"))
+	assert.DeepEqual(t, []string{}, titles("tag=team&tag=travel&tag_match=all"))
+
+	// Test case 3: patches change the tags like the other fields
+	resp = send("PATCH", fmt.Sprintf("/events/%d", event.ID), `{"tags": ["team"], "location": null}`)
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	var patched models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &patched))
+	assert.DeepEqual(t, []string{"team"}, patched.TagNames())
+	assert.Equal(t, "", patched.Location)
+	assert.DeepEqual(t, []string{"Trip"}, titles("tag=work"))
+
+	// Test case 4: invalid details
+	for body, message := range map[string]string{
+		`"color": "blue"`:     "Color",
+		`"url": "not a link"`: "URL",
+		`"tags": [" "]`:       models.ErrInvalidTag.Error(),
+		`"tags": ["a,b"]`:     models.ErrInvalidTag.Error(),
+		`"tags": "work"`:      "cannot unmarshal",
+		`"location": "` + strings.Repeat("x", 501) + `"`: "Location",
+	} {
+		resp = send("POST", "/events", `{"title": "Test Details Invalid 9835-5dc547a01713", "event_date": "9990-05-04", "all_day": true, `+body+`}`)
+		assert.Equal(t, http.StatusBadRequest, resp.Code, body)
+		assert.Assert(t, strings.Contains(resp.Body.String(), message), resp.Body.String())
+	}
+	resp = send("GET", "/events?tag=work&tag_match=some", "")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Tag match must be any or all"}`, resp.Body.String())
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/ical"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Longest time window accepted by free/busy queries
+const maxFreeBusyWindow = 366 * 24 * time.Hour
+
+// Get the merged busy intervals of a time window, as JSON or as an iCalendar VFREEBUSY
+func (h *Handler) GetFreeBusy(c *gin.Context) {
+	from, to, err := parseTimeWindow(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	calendarIDs, err := parseCalendarIDs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Only the events the caller has a role on are counted, the free/busy role is enough
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	busy, err := h.busyIntervals(from, to, calendarIDs, caller.filter(models.RoleFreeBusy))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	if c.Query("format") == "ics" || strings.Contains(c.GetHeader("Accept"), "text/calendar") {
+		var buf bytes.Buffer
+		if err := newFreeBusyCalendar(from, to, busy).Encode(&buf); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+		return
+	}
+
+	// Times are returned in the time zone of the caller, or else in the offset of the requested start
+	if loc == nil {
+		loc = from.Location()
+	}
+	from, to = from.In(loc), to.In(loc)
+	for i := range busy {
+		busy[i].Start = busy[i].Start.In(loc)
+		busy[i].End = busy[i].End.In(loc)
+	}
+	c.JSON(http.StatusOK, gin.H{"start": from, "end": to, "busy": busy})
+}
+
+// Parse the start and end query parameters as RFC 3339 date-times
+func parseTimeWindow(c *gin.Context) (time.Time, time.Time, error) {
+	from, err := parseInstant(c.Query("start"))
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid start")
+	}
+	to, err := parseInstant(c.Query("end"))
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("Invalid end")
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("End must be after start")
+	}
+	if to.Sub(from) > maxFreeBusyWindow {
+		return time.Time{}, time.Time{}, errors.New("Time window must not be longer than 366 days")
+	}
+	return from, to, nil
+}
+
+// Parse an RFC 3339 date-time, a "+" offset left unescaped in a query string reads as a space
+func parseInstant(s string) (time.Time, error) {
+	return time.Parse(time.RFC3339, strings.Replace(s, " ", "+", 1))
+}
+
+// Busy time of the calendars inside a time window, all calendars when none is given, limited to the events of the access.
+// Occurrences are clipped to the window and merged when they overlap or touch.
+func (h *Handler) busyIntervals(from, to time.Time, calendarIDs []uint64, access *repositories.Access) ([]models.Interval, error) {
+	// Dates are widened by a day for time zone offsets
+	fromDate, toDate := from.AddDate(0, 0, -1), to.AddDate(0, 0, 1)
+	events, err := h.Events.List(repositories.EventFilter{
+		StartDate:   fromDate.Format("2006-01-02"),
+		EndDate:     toDate.Format("2006-01-02"),
+		CalendarIDs: calendarIDs,
+		Access:      access,
+		BusyOnly:    true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var intervals []models.Interval
+	for i := range events {
+		occurrences := []models.Event{events[i]}
+		if events[i].IsRecurring() {
+			var err error
+			if occurrences, err = events[i].Occurrences(fromDate, toDate); err != nil {
+				return nil, err
+			}
+		}
+		for j := range occurrences {
+			start, end := occurrences[j].GetStartAt(), occurrences[j].GetEndAt()
+			if !start.Before(to) || !end.After(from) {
+				continue
+			}
+			if start.Before(from) {
+				start = from
+			}
+			if end.After(to) {
+				end = to
+			}
+			intervals = append(intervals, models.Interval{Start: start, End: end})
+		}
+	}
+	return models.MergeIntervals(intervals), nil
+}
+
+// Build a VCALENDAR holding a VFREEBUSY of the busy intervals
+func newFreeBusyCalendar(from, to time.Time, busy []models.Interval) *ical.Component {
+	calendar := ical.NewComponent("VCALENDAR")
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", icalProductID)
+	calendar.Add("METHOD", "PUBLISH")
+
+	freebusy := ical.NewComponent("VFREEBUSY")
+	freebusy.Add("UID", fmt.Sprintf("freebusy-%s-%s@aimet-test", ical.FormatUTC(from), ical.FormatUTC(to)))
+	freebusy.Add("DTSTAMP", ical.FormatUTC(time.Now()))
+	freebusy.Add("DTSTART", ical.FormatUTC(from))
+	freebusy.Add("DTEND", ical.FormatUTC(to))
+	for _, interval := range busy {
+		freebusy.Add("FREEBUSY", ical.FormatUTC(interval.Start)+"/"+ical.FormatUTC(interval.End), ical.Param{Name: "FBTYPE", Value: "BUSY"})
+	}
+	calendar.AddComponent(freebusy)
+	return calendar
+}
+
+// Default and largest number of slots returned by FindSlots
+const (
+	defaultSlotLimit = 5
+	maxSlotLimit     = 50
+)
+
+// Find the first free slots of a duration that would pass the overlap check of CreateEvent
+func (h *Handler) FindSlots(c *gin.Context) {
+	from, to, err := parseTimeWindow(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseSlotOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Events are only checked for overlaps in their own calendar, the default one when none is given
+	calendarIDs, err := parseCalendarIDs(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(calendarIDs) == 0 {
+		var event models.Event
+		if err := h.resolveCalendar(&event); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		calendarIDs = append(calendarIDs, uint64(event.CalendarID))
+	}
+
+	// Every event of the calendars is counted like the overlap check does, so the caller needs a role on the calendars
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	for _, id := range calendarIDs {
+		if id > math.MaxUint32 || caller.CalendarRole(uint(id)) == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+			return
+		}
+	}
+
+	busy, err := h.busyIntervals(from, to, calendarIDs, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Working hours follow the clock of the caller's time zone, daylight saving time included
+	if loc != nil {
+		from, to = from.In(loc), to.In(loc)
+	}
+	c.JSON(http.StatusOK, gin.H{"slots": models.FreeSlots(busy, from, to, *opts)})
+}
+
+// Parse the duration, granularity, working hours and limit query parameters of FindSlots
+func parseSlotOptions(c *gin.Context) (*models.SlotOptions, error) {
+	opts := &models.SlotOptions{Granularity: 15 * time.Minute, Limit: defaultSlotLimit}
+
+	var err error
+	if opts.Duration, err = time.ParseDuration(c.Query("duration")); err != nil || opts.Duration <= 0 {
+		return nil, errors.New("Invalid duration")
+	}
+	if value := c.Query("granularity"); value != "" {
+		if opts.Granularity, err = time.ParseDuration(value); err != nil || opts.Granularity < time.Minute {
+			return nil, errors.New("Invalid granularity")
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		if opts.Limit, err = strconv.Atoi(value); err != nil || opts.Limit < 1 || opts.Limit > maxSlotLimit {
+			return nil, fmt.Errorf("Limit must be between 1 and %d", maxSlotLimit)
+		}
+	}
+
+	// Working hours are wall clock times in the time zone of the caller, or else in the offset of the window start
+	if value := c.Query("work_start"); value != "" {
+		if opts.WorkStart, err = parseClock(value); err != nil {
+			return nil, errors.New("Invalid work start")
+		}
+	}
+	if value := c.Query("work_end"); value != "" {
+		if opts.WorkEnd, err = parseClock(value); err != nil || opts.WorkEnd == 0 {
+			return nil, errors.New("Invalid work end")
+		}
+	}
+	workEnd := opts.WorkEnd
+	if workEnd == 0 {
+		workEnd = 24 * time.Hour
+	}
+	if workEnd <= opts.WorkStart {
+		return nil, errors.New("Work end must be after work start")
+	}
+	if opts.Duration > workEnd-opts.WorkStart {
+		return nil, errors.New("Duration must fit in the working hours")
+	}
+	return opts, nil
+}
+
+// Parse a wall clock time such as 09:30 as the time since midnight, 24:00 is the end of the day
+func parseClock(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestGetFreeBusy(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/events", h.CreateEvent)
+	r.GET("/freebusy", h.GetFreeBusy)
+
+	for _, body := range []string{
+		`{"title": "Test FreeBusy A 9835-5dc547a01713", "event_date": "9994-05-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`,
+		`{"title": "Test FreeBusy B 9835-5dc547a01713", "event_date": "9994-05-01", "start_time": "10:00:00+07", "end_time": "11:00:00+07"}`,
+		`{"title": "Test FreeBusy C 9835-5dc547a01713", "event_date": "9994-05-01", "start_time": "13:00:00+07", "end_time": "14:00:00+07", "rrule": "FREQ=DAILY;COUNT=2"}`,
+		`{"title": "Test FreeBusy Free 9835-5dc547a01713", "event_date": "9994-05-01", "start_time": "15:00:00+07", "end_time": "16:00:00+07", "busy": false}`,
+	} {
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+	}
+
+	// Test case 1: adjacent events are merged and the window clips the last occurrence
+	req, _ := http.NewRequest("GET", "/freebusy?start=9994-05-01T00:00:00%2B07:00&end=9994-05-02T13:30:00%2B07:00", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var freeBusy struct {
+		Busy []models.Interval `json:"busy"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &freeBusy)
+	assert.NilError(t, err)
+	busy := []string{}
+	for _, interval := range freeBusy.Busy {
+		busy = append(busy, interval.Start.Format(time.RFC3339)+"/"+interval.End.Format(time.RFC3339))
+	}
+	assert.DeepEqual(t, []string{
+		"9994-05-01T09:00:00+07:00/9994-05-01T11:00:00+07:00",
+		"9994-05-01T13:00:00+07:00/9994-05-01T14:00:00+07:00",
+		"9994-05-02T13:00:00+07:00/9994-05-02T13:30:00+07:00",
+	}, busy)
+
+	// Test case 2: VFREEBUSY output
+	req, _ = http.NewRequest("GET", "/freebusy?start=9994-05-01T00:00:00Z&end=9994-05-02T00:00:00Z&format=ics", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", resp.Header().Get("Content-Type"))
+	body := resp.Body.String()
+	assert.Assert(t, strings.Contains(body, "\r\nBEGIN:VFREEBUSY\r\n"))
+	assert.Assert(t, strings.Contains(body, "\r\nDTSTART:99940501T000000Z\r\n"))
+	assert.Assert(t, strings.Contains(body, "\r\nFREEBUSY;FBTYPE=BUSY:99940501T020000Z/99940501T040000Z\r\n"))
+	assert.Assert(t, strings.Contains(body, "\r\nFREEBUSY;FBTYPE=BUSY:99940501T060000Z/99940501T070000Z\r\n"))
+	assert.Assert(t, !strings.Contains(body, "99940501T080000Z/"))
+
+	// Test case 3: invalid windows
+	for query, message := range map[string]string{
+		"start=invalid&end=9994-05-02T00:00:00Z":              "Invalid start",
+		"start=9994-05-01T00:00:00Z":                          "Invalid end",
+		"start=9994-05-01T00:00:00Z&end=9994-05-01T00:00:00Z": "End must be after start",
+		"start=9994-05-01T00:00:00Z&end=9996-05-01T00:00:00Z": "Time window must not be longer than 366 days",
+	} {
+		req, _ = http.NewRequest("GET", "/freebusy?"+query, nil)
+		resp = httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, `{"error":"`+message+`"}`, resp.Body.String())
+	}
+}
+
+func TestFindSlots(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/events", h.CreateEvent)
+	r.GET("/slots", h.FindSlots)
+
+	requestBody := []byte(`{"title": "Test Slots Busy 9835-5dc547a01713", "event_date": "9994-06-01", "start_time": "09:00:00+07", "end_time": "10:30:00+07"}`)
+	req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// Test case 1: the first slots after the busy event inside working hours
+	req, _ = http.NewRequest("GET", "/slots?start=9994-06-01T08:00:00%2B07:00&end=9994-06-03T00:00:00%2B07:00&duration=1h&granularity=30m&work_start=09:00&work_end=12:00&limit=3", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var slotsResp struct {
+		Slots []models.Interval `json:"slots"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &slotsResp)
+	assert.NilError(t, err)
+	slots := []string{}
+	for _, slot := range slotsResp.Slots {
+		slots = append(slots, slot.Start.Format(time.RFC3339))
+	}
+	assert.DeepEqual(t, []string{"9994-06-01T10:30:00+07:00", "9994-06-02T09:00:00+07:00", "9994-06-02T10:00:00+07:00"}, slots)
+
+	// Test case 2: a returned slot passes the overlap check
+	requestBody = []byte(`{"title": "Test Slots Booked 9835-5dc547a01713", "event_date": "9994-06-01", "start_time": "10:30:00+07", "end_time": "11:30:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// Test case 3: invalid options
+	for query, message := range map[string]string{
+		"duration=0s":                                 "Invalid duration",
+		"duration=1h&granularity=1s":                  "Invalid granularity",
+		"duration=1h&limit=100":                       "Limit must be between 1 and 50",
+		"duration=1h&work_start=9am":                  "Invalid work start",
+		"duration=1h&work_start=12:00&work_end=09:00": "Work end must be after work start",
+		"duration=4h&work_start=09:00&work_end=12:00": "Duration must fit in the working hours",
+	} {
+		req, _ = http.NewRequest("GET", "/slots?start=9994-06-01T00:00:00Z&end=9994-06-02T00:00:00Z&"+query, nil)
+		resp = httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, `{"error":"`+message+`"}`, resp.Body.String())
+	}
+
+	// Test case 4: working hours stay on the clock of the caller's time zone when daylight saving time starts
+	req, _ = http.NewRequest("GET", "/slots?start=9994-03-01T00:00:00Z&end=9994-03-31T00:00:00Z&duration=1h&work_start=09:00&work_end=10:00&limit=40&tz=America/New_York", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	slotsResp.Slots = nil
+	err = json.Unmarshal(resp.Body.Bytes(), &slotsResp)
+	assert.NilError(t, err)
+	assert.Equal(t, 30, len(slotsResp.Slots))
+	offsets := map[string]bool{}
+	for _, slot := range slotsResp.Slots {
+		assert.Equal(t, "09:00", slot.Start.Format("15:04"), slot.Start.Format(time.RFC3339))
+		offsets[slot.Start.Format("-07:00")] = true
+	}
+	assert.DeepEqual(t, map[string]bool{"-05:00": true, "-04:00": true}, offsets)
+}
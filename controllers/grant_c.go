@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// grantRequest gives a user a role on a calendar or an event
+type grantRequest struct {
+	UserID uint        `json:"user_id" binding:"required"`
+	Role   models.Role `json:"role" binding:"required,oneof=owner editor viewer freebusy"`
+}
+
+// List the grants on a calendar
+func (h *Handler) ListCalendarGrants(c *gin.Context) {
+	target, ok := h.calendarGrant(c)
+	if !ok {
+		return
+	}
+
+	grants, err := h.Grants.ListForCalendar(*target.CalendarID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, grants)
+}
+
+// Give a user a role on a calendar and all its events, or change the role they have
+func (h *Handler) GrantCalendar(c *gin.Context) {
+	if target, ok := h.calendarGrant(c); ok {
+		h.saveGrant(c, target)
+	}
+}
+
+// Take back the role of a user on a calendar
+func (h *Handler) RevokeCalendarGrant(c *gin.Context) {
+	if target, ok := h.calendarGrant(c); ok {
+		h.deleteGrant(c, target)
+	}
+}
+
+// List the grants on an event
+func (h *Handler) ListEventGrants(c *gin.Context) {
+	target, ok := h.eventGrant(c)
+	if !ok {
+		return
+	}
+
+	grants, err := h.Grants.ListForEvent(*target.EventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, grants)
+}
+
+// Give a user a role on a single event, or change the role they have
+func (h *Handler) GrantEvent(c *gin.Context) {
+	if target, ok := h.eventGrant(c); ok {
+		h.saveGrant(c, target)
+	}
+}
+
+// Take back the role of a user on an event
+func (h *Handler) RevokeEventGrant(c *gin.Context) {
+	if target, ok := h.eventGrant(c); ok {
+		h.deleteGrant(c, target)
+	}
+}
+
+// Grant on the calendar of the URL parameter, whose grants are managed by its owners
+func (h *Handler) calendarGrant(c *gin.Context) (*models.Grant, bool) {
+	calendar, ok := h.findCalendar(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return nil, false
+	}
+	if !h.authorizeCalendar(c, calendar, models.RoleOwner) {
+		return nil, false
+	}
+	return &models.Grant{CalendarID: &calendar.ID}, true
+}
+
+// Grant on the event of the URL parameter, whose grants are managed by its owners
+func (h *Handler) eventGrant(c *gin.Context) (*models.Grant, bool) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, false
+	}
+	if _, ok := h.authorizeEvent(c, event, models.RoleOwner); !ok {
+		return nil, false
+	}
+	return &models.Grant{EventID: &event.ID}, true
+}
+
+// Save the grant of the user and role of the request body on the target
+func (h *Handler) saveGrant(c *gin.Context, grant *models.Grant) {
+	var request grantRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := h.Users.Get(request.UserID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	grant.UserID, grant.Role = request.UserID, request.Role
+	if user := currentUser(c); user != nil {
+		grant.GrantedBy = user.ID
+	}
+
+	if err := h.Grants.Save(grant); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	// A lower role may no longer view the events
+	if err := h.dropReminders(grant.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, grant)
+}
+
+// Delete the grant of the user of the URL parameter on the target
+func (h *Handler) deleteGrant(c *gin.Context, grant *models.Grant) {
+	userID, err := strconv.ParseUint(c.Param("user"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Grant not found"})
+		return
+	}
+	grant.UserID = uint(userID)
+
+	if err := h.Grants.Delete(grant); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Grant not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if err := h.dropReminders(grant.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Grant deleted successfully"})
+}
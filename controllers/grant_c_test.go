@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestSharing(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := gin.Default()
+	api := r.Group("/api", h.Authenticate)
+	api.GET("/events", h.ListEvents)
+	api.GET("/events/:id", h.GetEventById)
+	api.POST("/events", h.CreateEvent)
+	api.PUT("/events/:id", h.UpdateEvent)
+	api.DELETE("/events/:id", h.DeleteEvent)
+	api.DELETE("/events/trash/:id", h.PurgeEvent)
+	api.GET("/events/:id/grants", h.ListEventGrants)
+	api.POST("/events/:id/grants", h.GrantEvent)
+	api.GET("/calendars", h.ListCalendars)
+	api.POST("/calendars", h.CreateCalendar)
+	api.GET("/calendars/:id/grants", h.ListCalendarGrants)
+	api.POST("/calendars/:id/grants", h.GrantCalendar)
+	api.DELETE("/calendars/:id/grants/:user", h.RevokeCalendarGrant)
+	api.GET("/freebusy", h.GetFreeBusy)
+	api.GET("/slots", h.FindSlots)
+
+	secrets := map[string]string{}
+	users := map[string]*models.User{}
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		user := &models.User{Name: name}
+		assert.NilError(t, h.Users.Create(user))
+		key := models.APIKey{UserID: user.ID}
+		secret, err := key.NewAPIKeySecret()
+		assert.NilError(t, err)
+		secrets[name] = secret
+		assert.NilError(t, h.Users.CreateKey(&key))
+		users[name] = user
+	}
+	send := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+secrets[user])
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+	decode := func(resp *httptest.ResponseRecorder, code int, value interface{}) {
+		assert.Equal(t, code, resp.Code, resp.Body.String())
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), value))
+	}
+	body := func(title, start string, calendarID uint) string {
+		return fmt.Sprintf(`{"title": "%s", "event_date": "2024-06-03", "start_time": "%s:00:00+07", "end_time": "%s:30:00+07", "calendar_id": %d}`, title, start, start, calendarID)
+	}
+	grant := func(userID uint, role models.Role) string {
+		return fmt.Sprintf(`{"user_id": %d, "role": "%s"}`, userID, role)
+	}
+	const events = "/api/events?start_date=2024-06-03&end_date=2024-06-03"
+	const freebusy = "/api/freebusy?start=2024-06-03T00:00:00%2B07:00&end=2024-06-04T00:00:00%2B07:00"
+
+	var calendar models.Calendar
+	decode(send("POST", "/api/calendars", "alice", `{"name": "Project"}`), http.StatusCreated, &calendar)
+	grants := fmt.Sprintf("/api/calendars/%d/grants", calendar.ID)
+	var kickoff models.Event
+	decode(send("POST", "/api/events", "alice", body("Kickoff", "09", calendar.ID)), http.StatusCreated, &kickoff)
+	path := fmt.Sprintf("/api/events/%d", kickoff.ID)
+
+	// Test case 1: only the owner of a calendar shares it, with users who exist
+	resp := send("POST", "/api/events", "bob", body("Planning", "11", calendar.ID))
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, `{"error":"The editor role is required on the calendar"}`, resp.Body.String())
+	assert.Equal(t, http.StatusNotFound, send("POST", grants, "bob", grant(users["bob"].ID, models.RoleOwner)).Code)
+	resp = send("POST", grants, "alice", grant(99, models.RoleViewer))
+	assert.Equal(t, `{"error":"User not found"}`, resp.Body.String())
+	assert.Equal(t, http.StatusBadRequest, send("POST", grants, "alice", `{"user_id": 2, "role": "admin"}`).Code)
+	for name, role := range map[string]models.Role{"bob": models.RoleViewer, "carol": models.RoleViewer, "dave": models.RoleFreeBusy} {
+		assert.Equal(t, http.StatusOK, send("POST", grants, "alice", grant(users[name].ID, role)).Code)
+	}
+	resp = send("POST", grants, "alice", grant(users["bob"].ID, models.RoleEditor))
+	var saved models.Grant
+	decode(resp, http.StatusOK, &saved)
+	assert.Equal(t, models.RoleEditor, saved.Role)
+	assert.Equal(t, users["alice"].ID, saved.GrantedBy)
+	var list []models.Grant
+	decode(send("GET", grants, "alice", ""), http.StatusOK, &list)
+	assert.Equal(t, 3, len(list))
+	resp = send("GET", grants, "carol", "")
+	assert.Equal(t, `{"error":"The owner role is required on the calendar"}`, resp.Body.String())
+
+	// Test case 2: editors add and change the events of the calendar, but only owners purge or share them
+	assert.Equal(t, http.StatusCreated, send("POST", "/api/events", "bob", body("Planning", "11", calendar.ID)).Code)
+	assert.Equal(t, http.StatusOK, send("PUT", path, "bob", body("Kickoff Moved", "10", calendar.ID)).Code)
+	resp = send("POST", path+"/grants", "bob", grant(users["erin"].ID, models.RoleViewer))
+	assert.Equal(t, `{"error":"The owner role is required on the event"}`, resp.Body.String())
+
+	// Test case 3: viewers see the events but cannot change them
+	var listed []models.Event
+	decode(send("GET", events, "carol", ""), http.StatusOK, &listed)
+	assert.Equal(t, 2, len(listed))
+	resp = send("PUT", path, "carol", body("Taken Over", "10", calendar.ID))
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, `{"error":"The editor role is required on the event"}`, resp.Body.String())
+
+	// Test case 4: free/busy users only see when the events are busy, other users see nothing of them
+	decode(send("GET", events, "dave", ""), http.StatusOK, &listed)
+	assert.Equal(t, 0, len(listed))
+	assert.Equal(t, http.StatusNotFound, send("GET", path, "dave", "").Code)
+	var busy struct {
+		Busy []models.Interval `json:"busy"`
+	}
+	decode(send("GET", freebusy, "dave", ""), http.StatusOK, &busy)
+	assert.Equal(t, 2, len(busy.Busy))
+	decode(send("GET", freebusy, "erin", ""), http.StatusOK, &busy)
+	assert.Equal(t, 0, len(busy.Busy))
+	slots := fmt.Sprintf("/api/slots?start=2024-06-03T09:00:00%%2B07:00&end=2024-06-03T12:00:00%%2B07:00&duration=1h&calendar_id=%d", calendar.ID)
+	assert.Equal(t, http.StatusOK, send("GET", slots, "dave", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", slots, "erin", "").Code)
+	var calendars []models.Calendar
+	decode(send("GET", "/api/calendars", "erin", ""), http.StatusOK, &calendars)
+	assert.Equal(t, 1, len(calendars))
+	assert.Equal(t, true, calendars[0].IsDefault)
+
+	// Test case 5: single events are shared apart from their calendar
+	assert.Equal(t, http.StatusOK, send("POST", path+"/grants", "alice", grant(users["erin"].ID, models.RoleViewer)).Code)
+	assert.Equal(t, http.StatusOK, send("GET", path, "erin", "").Code)
+	decode(send("GET", events, "erin", ""), http.StatusOK, &listed)
+	assert.Equal(t, 1, len(listed))
+	decode(send("GET", path+"/grants", "alice", ""), http.StatusOK, &list)
+	assert.Equal(t, 1, len(list))
+
+	// Test case 6: revoked users lose their access
+	revoke := fmt.Sprintf("%s/%d", grants, users["carol"].ID)
+	assert.Equal(t, http.StatusOK, send("DELETE", revoke, "alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("GET", path, "carol", "").Code)
+	resp = send("DELETE", revoke, "alice", "")
+	assert.Equal(t, `{"error":"Grant not found"}`, resp.Body.String())
+
+	// Test case 7: deleted events are purged by their owners only
+	assert.Equal(t, http.StatusOK, send("DELETE", path, "bob", "").Code)
+	resp = send("DELETE", "/api/events/trash/"+fmt.Sprint(kickoff.ID), "bob", "")
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+	assert.Equal(t, http.StatusOK, send("DELETE", "/api/events/trash/"+fmt.Sprint(kickoff.ID), "alice", "").Code)
+}
@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"github.com/thunthup/aimet-test/feed"
+	"github.com/thunthup/aimet-test/jwt"
+	"github.com/thunthup/aimet-test/notify"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Handler serves the API from the repositories it is given
+type Handler struct {
+	Events    repositories.EventRepository
+	Calendars repositories.CalendarRepository
+	Users     repositories.UserRepository
+	Grants    repositories.GrantRepository
+	Webhooks  repositories.WebhookRepository
+	Reminders repositories.ReminderRepository
+	// Notifiers are the reminder methods which reminders may be created with
+	Notifiers notify.Notifiers
+	// Changes feeds StreamEvents, which is unavailable when it is nil
+	Changes *feed.Feed
+	// Tokens verifies the JWTs of Authenticate, which then only accepts API keys when it is nil
+	Tokens *jwt.Verifier
+}
+
+// NewHandler creates a handler storing events, calendars, users, grants, webhooks and reminders in the repositories
+func NewHandler(events repositories.EventRepository, calendars repositories.CalendarRepository, users repositories.UserRepository, grants repositories.GrantRepository, webhooks repositories.WebhookRepository, reminders repositories.ReminderRepository) *Handler {
+	return &Handler{Events: events, Calendars: calendars, Users: users, Grants: grants, Webhooks: webhooks, Reminders: reminders}
+}
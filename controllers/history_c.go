@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// List the revisions of an event, oldest first. Deleted and purged events keep their history.
+func (h *Handler) GetEventHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	// The history of events the caller cannot view is hidden, purged events no longer have an owner and only admins see theirs
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	event, err := h.Events.Get(uint(id))
+	if errors.Is(err, repositories.ErrNotFound) {
+		event, err = h.Events.GetDeleted(uint(id))
+	}
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if event == nil && !caller.Admin() || event != nil && !caller.EventRole(event).Includes(models.RoleViewer) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	revisions, err := h.Events.ListRevisions(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if len(revisions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// Bring an event back to the fields it had at a revision. The revert is an update like any other:
+// the event must fit in its calendar again and the revert is recorded as a new revision.
+// Overridden occurrences are kept as they are, deleted events must be restored first.
+func (h *Handler) RevertEvent(c *gin.Context) {
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existingEvent, ok := h.findEvent(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	caller, ok := h.authorizeEvent(c, existingEvent, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if !matchesIfMatch(c, existingEvent) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionConflict.Error()})
+		return
+	}
+
+	revisionID, err := strconv.ParseUint(c.Param("revision"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	revision, err := h.Events.GetRevision(existingEvent.ID, uint(revisionID))
+	if errors.Is(err, repositories.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var updatedEvent models.Event
+	revision.Snapshot.Apply(&updatedEvent)
+	audit := requestAudit(c)
+	audit.RevertedTo = &revision.ID
+	if status, err := h.updateEvent(existingEvent, &updatedEvent, caller, audit); err != nil {
+		// Errors of the database are not shown to the caller
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	setEventETag(c, existingEvent)
+	inCallerZone(loc, existingEvent)
+	c.JSON(http.StatusOK, existingEvent)
+}
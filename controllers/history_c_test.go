@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+func TestEventHistory(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/events", h.CreateEvent)
+	r.PUT("/events/:id", h.UpdateEvent)
+	r.DELETE("/events/:id", h.DeleteEvent)
+	r.GET("/events/:id/history", h.GetEventHistory)
+	r.POST("/events/:id/history/:revision/revert", h.RevertEvent)
+
+	send := func(method, url, ifMatch, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "alice")
+		req.Header.Set("User-Agent", "history-test")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+	history := func(id uint) []models.EventRevision {
+		resp := send("GET", fmt.Sprintf("/events/%d/history", id), "", "")
+		assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		var revisions []models.EventRevision
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &revisions))
+		return revisions
+	}
+
+	// Test case 1: creating and updating an event records who changed which fields, the X-Actor header
+	// only names the actor of requests without an authenticated user
+	resp := send("POST", "/events", "", `{"title": "History Event 9835-5dc547a01713", "event_date": "4001-02-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.Assert(t, resp.Header().Get("X-Request-ID") != "")
+	var event models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &event))
+	path := fmt.Sprintf("/events/%d", event.ID)
+	resp = send("PUT", path, "", `{"title": "History Event Moved 9835-5dc547a01713", "event_date": "4001-02-01", "start_time": "11:00:00+07", "end_time": "12:00:00+07"}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	revisions := history(event.ID)
+	assert.Equal(t, 2, len(revisions))
+	assert.Equal(t, "create", revisions[0].Action)
+	assert.Equal(t, testAdmin.Name, revisions[0].Actor)
+	assert.Equal(t, "POST", revisions[0].Method)
+	assert.Equal(t, "/events", revisions[0].Path)
+	assert.Equal(t, "history-test", revisions[0].UserAgent)
+	assert.Equal(t, "update", revisions[1].Action)
+	assert.Equal(t, uint(2), revisions[1].Version)
+	assert.Equal(t, 3, len(revisions[1].Changes))
+	assert.Equal(t, `"09:00:00+07"`, string(revisions[1].Changes["start_time"].Old))
+	assert.Equal(t, `"11:00:00+07"`, string(revisions[1].Changes["start_time"].New))
+
+	resp = send("GET", "/events/999999/history", "", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	// Test case 2: a revert goes through the overlap check
+	resp = send("POST", "/events", "", `{"title": "History Blocker 9835-5dc547a01713", "event_date": "4001-02-01", "start_time": "09:30:00+07", "end_time": "10:30:00+07"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	var blocker models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &blocker))
+	revert := fmt.Sprintf("/events/%d/history/%d/revert", event.ID, revisions[0].ID)
+	resp = send("POST", revert, "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 3: revert to the first revision once its time is free again
+	resp = send("DELETE", fmt.Sprintf("/events/%d", blocker.ID), "", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = send("POST", revert, `"1"`, "")
+	assert.Equal(t, http.StatusPreconditionFailed, resp.Code)
+	resp = send("POST", revert, `"2"`, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"3"`, resp.Header().Get("ETag"))
+	var reverted models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &reverted))
+	assert.Equal(t, "History Event 9835-5dc547a01713", reverted.Title)
+	assert.Equal(t, models.TimeOfDay("09:00:00+07"), reverted.StartTime)
+
+	revisions = history(event.ID)
+	assert.Equal(t, 3, len(revisions))
+	assert.Equal(t, "revert", revisions[2].Action)
+	assert.Equal(t, revisions[0].ID, *revisions[2].RevertedTo)
+	assert.DeepEqual(t, revisions[0].Snapshot, revisions[2].Snapshot)
+
+	// Test case 4: unknown revisions and revisions of other events are not found
+	resp = send("POST", fmt.Sprintf("/events/%d/history/999999/revert", event.ID), "", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"error":"Revision not found"}`, resp.Body.String())
+	resp = send("POST", fmt.Sprintf("/events/%d/history/%d/revert", event.ID, history(blocker.ID)[0].ID), "", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	// Test case 5: deleted events keep their history
+	resp = send("DELETE", path, "", "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	revisions = history(event.ID)
+	assert.Equal(t, 4, len(revisions))
+	assert.Equal(t, "delete", revisions[3].Action)
+	resp = send("POST", revert, "", "")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+// Event repository failing the reads of revisions and the writes while their errors are set, like a database that went away
+type failingEvents struct {
+	repositories.EventRepository
+	readErr  error
+	writeErr error
+}
+
+func (f *failingEvents) GetRevision(eventID, revisionID uint) (*models.EventRevision, error) {
+	if f.readErr != nil {
+		return nil, f.readErr
+	}
+	return f.EventRepository.GetRevision(eventID, revisionID)
+}
+
+func (f *failingEvents) Transaction(fn func(events repositories.EventRepository, calendars repositories.CalendarRepository) error) error {
+	if f.writeErr != nil {
+		return f.writeErr
+	}
+	return f.EventRepository.Transaction(fn)
+}
+
+func TestRevertEventDatabaseError(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	event := models.Event{CalendarID: 1, Title: "Revert Failure 9835-5dc547a01713", EventDate: "4001-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, h.Events.Create(&event, models.Audit{}))
+	revisions, err := h.Events.ListRevisions(event.ID)
+	assert.NilError(t, err)
+	failing := &failingEvents{EventRepository: h.Events}
+	h.Events = failing
+	r := newTestRouter()
+	r.POST("/events/:id/history/:revision/revert", h.RevertEvent)
+	revert := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/events/%d/history/%d/revert", event.ID, revisions[0].ID), nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+	databaseErr := errors.New("dial tcp 10.0.0.5:5432: connection refused")
+
+	// Test case 1: revisions that cannot be read are a database error, not a missing revision
+	failing.readErr = databaseErr
+	resp := revert()
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, `{"error":"Database error"}`, resp.Body.String())
+
+	// Test case 2: failed writes do not show the error of the database
+	failing.readErr, failing.writeErr = nil, databaseErr
+	resp = revert()
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, `{"error":"Database error"}`, resp.Body.String())
+
+	// Test case 3: the revert goes through once the database is back
+	failing.writeErr = nil
+	resp = revert()
+	assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
@@ -0,0 +1,570 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/thunthup/aimet-test/ical"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Product identifier written to exported calendars
+const icalProductID = "-//AIMET//Calendar API//EN"
+
+// Export events as an iCalendar document, accepting the same filters as ListEvents
+func (h *Handler) ExportEvents(c *gin.Context) {
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	filter, err := parseEventFilter(c, caller)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.Events.List(filter.repositoryFilter())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Recurring events are exported as a whole series when any occurrence is in range
+	var exported []models.Event
+	for i := range events {
+		if events[i].IsRecurring() {
+			occurrences, err := events[i].Occurrences(filter.startDate, filter.expansionEnd())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+				return
+			}
+			if len(occurrences) == 0 {
+				continue
+			}
+		}
+		exported = append(exported, events[i])
+	}
+
+	var buf bytes.Buffer
+	if err := newCalendar(exported).Encode(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="events.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", buf.Bytes())
+}
+
+// Build a VCALENDAR holding the events and the time zones they use
+func newCalendar(events []models.Event) *ical.Component {
+	calendar := ical.NewComponent("VCALENDAR")
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", icalProductID)
+	calendar.Add("CALSCALE", "GREGORIAN")
+	calendar.Add("METHOD", "PUBLISH")
+
+	var vevents []*ical.Component
+	offsets := map[int]bool{}
+	zones := map[string]*zoneRange{}
+	for i := range events {
+		vevents = append(vevents, eventComponents(&events[i])...)
+		if loc := events[i].Zone(); loc != nil && !events[i].AllDay {
+			zones[loc.String()] = zones[loc.String()].extend(&events[i], loc)
+		} else if !events[i].AllDay {
+			offsets[zoneOffset(events[i].GetStartTime())] = true
+			offsets[zoneOffset(events[i].GetEndTime())] = true
+			for j := range events[i].Overrides {
+				override := eventOverrideOccurrence(&events[i], &events[i].Overrides[j])
+				offsets[zoneOffset(override.GetStartTime())] = true
+				offsets[zoneOffset(override.GetEndTime())] = true
+			}
+		}
+	}
+
+	// Time zones are defined before the events that reference them
+	names := make([]string, 0, len(zones))
+	for name := range zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		zone := zones[name]
+		calendar.AddComponent(ical.LocationTimeZone(zone.loc, zone.from, zone.to))
+	}
+	sortedOffsets := make([]int, 0, len(offsets))
+	for offset := range offsets {
+		sortedOffsets = append(sortedOffsets, offset)
+	}
+	sort.Ints(sortedOffsets)
+	for _, offset := range sortedOffsets {
+		calendar.AddComponent(ical.FixedTimeZone(offset))
+	}
+	for _, vevent := range vevents {
+		calendar.AddComponent(vevent)
+	}
+	return calendar
+}
+
+// zoneRange is a time zone of exported events with the days they occur on
+type zoneRange struct {
+	loc      *time.Location
+	from, to time.Time
+}
+
+// Extend the range of a time zone to the days an event and its overridden occurrences occur on
+func (z *zoneRange) extend(event *models.Event, loc *time.Location) *zoneRange {
+	from, to, err := event.Span()
+	if err != nil {
+		from, to = event.GetEventDate(), event.GetEndDate()
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day()-1, 0, 0, 0, 0, loc)
+	to = time.Date(to.Year(), to.Month(), to.Day()+2, 0, 0, 0, 0, loc)
+	if z == nil {
+		return &zoneRange{loc: loc, from: from, to: to}
+	}
+	if from.Before(z.from) {
+		z.from = from
+	}
+	if to.After(z.to) {
+		z.to = to
+	}
+	return z
+}
+
+// Build the VEVENT of an event, followed by one VEVENT per overridden occurrence
+func eventComponents(event *models.Event) []*ical.Component {
+	uid := eventUID(event)
+	vevent := ical.NewComponent("VEVENT")
+	addEventProperties(vevent, event, uid)
+
+	if event.IsRecurring() {
+		if rule, err := models.ParseRecurrenceRule(event.RRule); err == nil {
+			// UNTIL must be a UTC date-time when DTSTART has a time zone
+			if !event.AllDay && !rule.Until.IsZero() {
+				endOfDay := combineClock(rule.Until, 23, 59, 59, zoneOffset(event.GetStartTime()))
+				if loc := event.Zone(); loc != nil {
+					endOfDay = time.Date(rule.Until.Year(), rule.Until.Month(), rule.Until.Day(), 23, 59, 59, 0, loc)
+				}
+				rule.Until = endOfDay.UTC()
+			}
+			vevent.Add("RRULE", rule.String())
+		}
+		for _, exDate := range event.ExDates {
+			if date, err := models.ParseDate(exDate); err == nil {
+				addOccurrenceDate(vevent, "EXDATE", event, date)
+			}
+		}
+	}
+	components := []*ical.Component{vevent}
+
+	for i := range event.Overrides {
+		occurrence := eventOverrideOccurrence(event, &event.Overrides[i])
+		override := ical.NewComponent("VEVENT")
+		addEventProperties(override, &occurrence, uid)
+		if date, err := models.ParseDate(event.Overrides[i].RecurrenceDate); err == nil {
+			addOccurrenceDate(override, "RECURRENCE-ID", event, date)
+		}
+		components = append(components, override)
+	}
+	return components
+}
+
+// Add the properties shared by an event and its overridden occurrences
+func addEventProperties(vevent *ical.Component, event *models.Event, uid string) {
+	dtstamp := event.UpdatedAt
+	if dtstamp.IsZero() {
+		dtstamp = time.Now()
+	}
+	vevent.Add("UID", uid)
+	vevent.Add("DTSTAMP", ical.FormatUTC(dtstamp))
+	if !event.CreatedAt.IsZero() {
+		vevent.Add("CREATED", ical.FormatUTC(event.CreatedAt))
+		vevent.Add("LAST-MODIFIED", ical.FormatUTC(event.UpdatedAt))
+	}
+	vevent.AddText("SUMMARY", event.Title)
+	if event.Description != "" {
+		vevent.AddText("DESCRIPTION", event.Description)
+	}
+	if event.Location != "" {
+		vevent.AddText("LOCATION", event.Location)
+	}
+	if event.URL != "" {
+		vevent.Add("URL", event.URL)
+	}
+	if len(event.Tags) > 0 {
+		vevent.AddTextList("CATEGORIES", event.TagNames())
+	}
+
+	if event.AllDay {
+		vevent.Add("DTSTART", event.GetEventDate().Format(ical.DateFormat), ical.Param{Name: "VALUE", Value: "DATE"})
+		vevent.Add("DTEND", event.GetEndDate().AddDate(0, 0, 1).Format(ical.DateFormat), ical.Param{Name: "VALUE", Value: "DATE"})
+	} else {
+		addEventDateTime(vevent, "DTSTART", event, event.GetStartAt())
+		addEventDateTime(vevent, "DTEND", event, event.GetEndAt())
+	}
+
+	if event.IsBusy() {
+		vevent.Add("TRANSP", "OPAQUE")
+	} else {
+		vevent.Add("TRANSP", "TRANSPARENT")
+	}
+}
+
+// Add a date-time in the time zone of the event, or else in its fixed offset time zone
+func addEventDateTime(vevent *ical.Component, name string, event *models.Event, t time.Time) {
+	if loc := event.Zone(); loc != nil {
+		vevent.Add(name, t.In(loc).Format(ical.DateTimeFormat), ical.Param{Name: "TZID", Value: loc.String()})
+		return
+	}
+	addZonedDateTime(vevent, name, t)
+}
+
+// Add a date-time in its fixed offset time zone
+func addZonedDateTime(vevent *ical.Component, name string, t time.Time) {
+	zone := ical.FixedZoneID(zoneOffset(t))
+	vevent.Add(name, t.Format(ical.DateTimeFormat), ical.Param{Name: "TZID", Value: zone})
+}
+
+// Add an EXDATE or RECURRENCE-ID for an occurrence date of a recurring event
+func addOccurrenceDate(vevent *ical.Component, name string, event *models.Event, date time.Time) {
+	if event.AllDay {
+		vevent.Add(name, date.Format(ical.DateFormat), ical.Param{Name: "VALUE", Value: "DATE"})
+		return
+	}
+	start := event.GetStartTime()
+	if loc := event.Zone(); loc != nil {
+		addEventDateTime(vevent, name, event, time.Date(date.Year(), date.Month(), date.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc))
+		return
+	}
+	addZonedDateTime(vevent, name, combineClock(date, start.Hour(), start.Minute(), start.Second(), zoneOffset(start)))
+}
+
+// Stable identifier of an event in exported calendars, imported events keep their own UID
+func eventUID(event *models.Event) string {
+	if event.UID != "" {
+		return event.UID
+	}
+	return fmt.Sprintf("event-%d@aimet-test", event.ID)
+}
+
+// Apply an override to a copy of its event
+func eventOverrideOccurrence(event *models.Event, override *models.EventOverride) models.Event {
+	occurrence := *event
+	occurrence.Overrides = nil
+	occurrence.RRule = ""
+	occurrence.ExDates = nil
+	override.Apply(&occurrence)
+	return occurrence
+}
+
+func combineClock(date time.Time, hour, min, sec, offset int) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, min, sec, 0, time.FixedZone("", offset))
+}
+
+func zoneOffset(t time.Time) int {
+	_, offset := t.Zone()
+	return offset
+}
+
+// Largest iCalendar file accepted by ImportEvents
+const maxImportSize = 10 << 20
+
+// Result of importing one VEVENT
+type importItem struct {
+	UID    string `json:"uid"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	ID     uint   `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Import the events of an iCalendar file, given as the "file" form field or as the request body.
+// Events whose UID already exists are skipped, invalid or overlapping events are rejected.
+func (h *Handler) ImportEvents(c *gin.Context) {
+	var calendarID uint64
+	if value := c.Query("calendar_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar id"})
+			return
+		}
+		calendarID = id
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	var body io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	calendar, err := ical.Decode(body)
+	if err != nil || calendar.Name != "VCALENDAR" {
+		if err == nil {
+			err = errors.New("no VCALENDAR found")
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid iCalendar file: %s", err)})
+		return
+	}
+	zones := ical.TimeZones(calendar)
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	audit := requestAudit(c)
+
+	// Overridden occurrences are imported with the event they belong to
+	var vevents []*ical.Component
+	overrides := map[string][]*ical.Component{}
+	for _, vevent := range calendar.Children("VEVENT") {
+		uid := vevent.Text("UID")
+		if vevent.Get("RECURRENCE-ID") != nil && uid != "" {
+			overrides[uid] = append(overrides[uid], vevent)
+			continue
+		}
+		vevents = append(vevents, vevent)
+	}
+
+	items := []importItem{}
+	counts := map[string]int{}
+	seen := map[string]bool{}
+	for _, vevent := range vevents {
+		item := importItem{UID: vevent.Text("UID"), Title: vevent.Text("SUMMARY")}
+
+		// Skip events that were already imported or exported from this calendar
+		existing, err := h.findEventByUID(item.UID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		switch {
+		case existing != nil:
+			item.Status, item.Error = "skipped", "Event already exists"
+			if caller.EventRole(existing).Includes(models.RoleViewer) {
+				item.ID = existing.ID
+			}
+		case item.UID != "" && seen[item.UID]:
+			item.Status, item.Error = "skipped", "Duplicate event in file"
+		default:
+			event, err := eventFromComponent(vevent, overrides[item.UID], zones)
+			if err != nil {
+				item.Status, item.Error = "rejected", err.Error()
+				break
+			}
+			event.CalendarID = uint(calendarID)
+			status, err := h.createEvent(event, caller, audit)
+			if status == http.StatusInternalServerError {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				item.Status, item.Error = "rejected", err.Error()
+				break
+			}
+			item.Status, item.ID = "created", event.ID
+		}
+		seen[item.UID] = true
+		counts[item.Status]++
+		items = append(items, item)
+	}
+
+	// Overrides without their recurring event in the file cannot be imported
+	for _, vevent := range calendar.Children("VEVENT") {
+		uid := vevent.Text("UID")
+		if vevent.Get("RECURRENCE-ID") != nil && uid != "" && !seen[uid] {
+			items = append(items, importItem{UID: uid, Title: vevent.Text("SUMMARY"), Status: "rejected", Error: "Recurring event not found"})
+			counts["rejected"]++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"created":  counts["created"],
+		"skipped":  counts["skipped"],
+		"rejected": counts["rejected"],
+		"items":    items,
+	})
+}
+
+// Find the event with a UID, including the UIDs given to events by ExportEvents
+func (h *Handler) findEventByUID(uid string) (*models.Event, error) {
+	if uid == "" {
+		return nil, nil
+	}
+	event, err := h.Events.FindByUID(uid)
+	if errors.Is(err, repositories.ErrNotFound) {
+		var id uint
+		if _, scanErr := fmt.Sscanf(uid, "event-%d@aimet-test", &id); scanErr == nil && eventUID(&models.Event{ID: id}) == uid {
+			event, err = h.Events.Get(id)
+		}
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil
+	}
+	return event, err
+}
+
+// Convert a VEVENT and its overridden occurrences to an event
+func eventFromComponent(vevent *ical.Component, overrides []*ical.Component, zones map[string]*ical.TimeZone) (*models.Event, error) {
+	event := &models.Event{UID: vevent.Text("UID"), Title: vevent.Text("SUMMARY")}
+	if event.Title == "" {
+		return nil, errors.New("Missing SUMMARY")
+	}
+	event.Description = vevent.Text("DESCRIPTION")
+	event.Location = vevent.Text("LOCATION")
+	if url := vevent.Get("URL"); url != nil {
+		event.URL = url.Value
+	}
+	for _, name := range vevent.TextList("CATEGORIES") {
+		event.Tags = append(event.Tags, models.Tag{Name: name})
+	}
+
+	start, end, allDay, err := componentSpan(vevent, zones)
+	if err != nil {
+		return nil, err
+	}
+	event.TimeZone = ianaTimeZone(vevent.Get("DTSTART"))
+	setEventSpan(event, start, end, allDay)
+
+	if transp := vevent.Get("TRANSP"); transp != nil {
+		busy := transp.Value != "TRANSPARENT"
+		event.Busy = &busy
+	}
+
+	if rrule := vevent.Get("RRULE"); rrule != nil {
+		event.RRule = localUntil(rrule.Value, start.Location())
+	}
+	for _, exdate := range vevent.GetAll("EXDATE") {
+		dates, _, err := exdate.DateTimes(zones)
+		if err != nil {
+			return nil, err
+		}
+		for _, date := range dates {
+			event.ExDates = append(event.ExDates, date.In(start.Location()).Format("2006-01-02"))
+		}
+	}
+
+	if err := binding.Validator.ValidateStruct(event); err != nil {
+		return nil, err
+	}
+	if err := validateEvent(event); err != nil {
+		return nil, err
+	}
+
+	for _, override := range overrides {
+		recurrenceID, _, err := override.Get("RECURRENCE-ID").DateTime(zones)
+		if err != nil {
+			return nil, err
+		}
+		recurrenceDate := recurrenceID.In(start.Location()).Format("2006-01-02")
+		if _, ok := findOccurrenceDate(event, recurrenceDate); !ok {
+			return nil, fmt.Errorf("Occurrence %s not found", recurrenceDate)
+		}
+
+		overrideStart, overrideEnd, overrideAllDay, err := componentSpan(override, zones)
+		if err != nil {
+			return nil, err
+		}
+		if overrideAllDay != allDay {
+			return nil, fmt.Errorf("Occurrence %s must keep the all-day setting of its event", recurrenceDate)
+		}
+		var occurrence models.Event
+		setEventSpan(&occurrence, overrideStart, overrideEnd, allDay)
+		event.Overrides = append(event.Overrides, models.EventOverride{
+			RecurrenceDate: recurrenceDate,
+			Title:          override.Text("SUMMARY"),
+			EventDate:      occurrence.EventDate,
+			EndDate:        occurrence.EndDate,
+			StartTime:      occurrence.StartTime,
+			EndTime:        occurrence.EndTime,
+		})
+	}
+	return event, nil
+}
+
+// Read the start and end of a VEVENT from DTSTART and either DTEND or DURATION.
+// The end of an all-day event is exclusive.
+func componentSpan(vevent *ical.Component, zones map[string]*ical.TimeZone) (start, end time.Time, allDay bool, err error) {
+	dtstart := vevent.Get("DTSTART")
+	if dtstart == nil {
+		return start, end, false, errors.New("Missing DTSTART")
+	}
+	if start, allDay, err = dtstart.DateTime(zones); err != nil {
+		return start, end, false, err
+	}
+
+	switch dtend, duration := vevent.Get("DTEND"), vevent.Get("DURATION"); {
+	case dtend != nil:
+		var endIsDate bool
+		if end, endIsDate, err = dtend.DateTime(zones); err != nil {
+			return start, end, false, err
+		}
+		if endIsDate != allDay {
+			return start, end, false, errors.New("DTEND must have the same value type as DTSTART")
+		}
+	case duration != nil:
+		days, d, err := ical.ParseDuration(duration.Value)
+		if err != nil {
+			return start, end, false, err
+		}
+		end = start.AddDate(0, 0, days).Add(d)
+	case allDay:
+		end = start.AddDate(0, 0, 1)
+	default:
+		end = start
+	}
+	return start, end, allDay, nil
+}
+
+// Set the dates and times of an event from its start and end.
+// Times are kept in their own offset, or in the time zone of the event when it has one.
+func setEventSpan(event *models.Event, start, end time.Time, allDay bool) {
+	event.AllDay = allDay
+	if allDay {
+		event.SetEventDate(start)
+		event.SetEndDate(end.AddDate(0, 0, -1))
+		return
+	}
+	event.SetSpan(start, end)
+}
+
+// IANA name of the time zone of a date-time, empty for UTC, fixed offsets and the zones only defined by the file
+func ianaTimeZone(property *ical.Property) string {
+	tzid := strings.TrimPrefix(property.Param("TZID"), "/")
+	if tzid == "" || tzid == "UTC" {
+		return ""
+	}
+	if _, err := models.LoadTimeZone(tzid); err != nil {
+		return ""
+	}
+	return tzid
+}
+
+// Replace a UTC date-time UNTIL of a recurrence rule with the date it falls on in the event's time zone
+func localUntil(rrule string, loc *time.Location) string {
+	parts := strings.Split(strings.TrimPrefix(rrule, "RRULE:"), ";")
+	for i, part := range parts {
+		value, ok := strings.CutPrefix(part, "UNTIL=")
+		if !ok || !strings.HasSuffix(value, "Z") {
+			continue
+		}
+		if until, err := time.Parse(ical.UTCDateTimeFormat, value); err == nil {
+			parts[i] = "UNTIL=" + until.In(loc).Format(ical.DateFormat)
+		}
+	}
+	return strings.Join(parts, ";")
+}
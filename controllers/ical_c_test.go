@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestExportEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/events", h.CreateEvent)
+	r.GET("/events/export", h.ExportEvents)
+	r.DELETE("/events/:id/occurrences/:date", h.CancelOccurrence)
+
+	requests := []string{
+		`{"title": "Test Export Series 9835-5dc547a01713", "event_date": "9996-03-01", "start_time": "09:00:00+07", "end_time": "09:30:00+07", "rrule": "FREQ=DAILY;UNTIL=99960305"}`,
+		`{"title": "Test Export Holiday, long 9835-5dc547a01713", "event_date": "9996-03-02", "end_date": "9996-03-03", "all_day": true, "description": "Beach; bring sunscreen", "location": "Hua Hin", "url": "https://example.com/trip", "tags": ["family", "travel"]}`,
+	}
+	var created []models.Event
+	for _, body := range requests {
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+
+		var event models.Event
+		err := json.Unmarshal(resp.Body.Bytes(), &event)
+		assert.NilError(t, err)
+		created = append(created, event)
+	}
+
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/events/%d/occurrences/9996-03-03", created[0].ID), nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	// Test case 1: export the events of a month
+	req, _ = http.NewRequest("GET", "/events/export?keyword=Export&year=9996&month=03", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", resp.Header().Get("Content-Type"))
+
+	body := resp.Body.String()
+	assert.Assert(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.Assert(t, strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+	assert.Equal(t, 2, strings.Count(body, "BEGIN:VEVENT\r\n"))
+	assert.Equal(t, 1, strings.Count(body, "BEGIN:VTIMEZONE\r\n"))
+	for _, line := range []string{
+		"TZID:UTC+0700",
+		fmt.Sprintf("UID:event-%d@aimet-test", created[0].ID),
+		"DTSTART;TZID=UTC+0700:99960301T090000",
+		"DTEND;TZID=UTC+0700:99960301T093000",
+		"RRULE:FREQ=DAILY;UNTIL=99960305T165959Z",
+		"EXDATE;TZID=UTC+0700:99960303T090000",
+		fmt.Sprintf("UID:event-%d@aimet-test", created[1].ID),
+		`SUMMARY:Test Export Holiday\, long 9835-5dc547a01713`,
+		"DTSTART;VALUE=DATE:99960302",
+		"DTEND;VALUE=DATE:99960304",
+		"TRANSP:TRANSPARENT",
+		`DESCRIPTION:Beach\; bring sunscreen`,
+		"LOCATION:Hua Hin",
+		"URL:https://example.com/trip",
+		"CATEGORIES:family,travel",
+	} {
+		assert.Assert(t, strings.Contains(body, "\r\n"+line+"\r\n"), line)
+	}
+
+	// Test case 2: no event in range
+	req, _ = http.NewRequest("GET", "/events/export?keyword=Export&year=9996&month=04", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, 0, strings.Count(resp.Body.String(), "BEGIN:VEVENT"))
+
+	// Test case 3: invalid filter
+	req, _ = http.NewRequest("GET", "/events/export?start_date=invalid", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Invalid start date"}`, resp.Body.String())
+
+	// Test case 4: events with a time zone are exported in it, with its daylight saving time
+	req, _ = http.NewRequest("POST", "/events", strings.NewReader(`{"title": "Test Export Zoned 9835-5dc547a01713", "time_zone": "Europe/Berlin", "event_date": "9996-05-06", "start_time": "09:00:00", "end_time": "10:00:00"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	req, _ = http.NewRequest("GET", "/events/export?keyword=Zoned&year=9996&month=05", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	body = resp.Body.String()
+	for _, line := range []string{
+		"TZID:Europe/Berlin",
+		"BEGIN:DAYLIGHT",
+		"TZOFFSETTO:+0200",
+		"DTSTART;TZID=Europe/Berlin:99960506T090000",
+		"DTEND;TZID=Europe/Berlin:99960506T100000",
+	} {
+		assert.Assert(t, strings.Contains(body, "\r\n"+line+"\r\n"), line)
+	}
+}
+
+func TestImportEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/events/import", h.ImportEvents)
+	r.GET("/events", h.ListEvents)
+
+	doc := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:import-1-9835-5dc547a01713@example.com\r\n" +
+		"SUMMARY:Test Import Meeting 9835-5dc547a01713\r\n" +
+		"DTSTART;TZID=Asia/Bangkok:99950301T090000\r\n" +
+		"DTEND;TZID=Asia/Bangkok:99950301T100000\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:import-2-9835-5dc547a01713@example.com\r\n" +
+		"SUMMARY:Test Import Holiday 9835-5dc547a01713\r\n" +
+		"LOCATION:Home\r\n" +
+		"CATEGORIES:Family,Rest\r\n" +
+		"DTSTART;VALUE=DATE:99950302\r\n" +
+		"DTEND;VALUE=DATE:99950304\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:import-3-9835-5dc547a01713@example.com\r\n" +
+		"SUMMARY:Test Import Call 9835-5dc547a01713\r\n" +
+		"DTSTART:99950301T040000Z\r\n" +
+		"DURATION:PT30M\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:import-4-9835-5dc547a01713@example.com\r\n" +
+		"SUMMARY:Test Import Overlap 9835-5dc547a01713\r\n" +
+		"DTSTART;TZID=UTC+0700:99950301T093000\r\n" +
+		"DURATION:PT1H\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:import-1-9835-5dc547a01713@example.com\r\n" +
+		"SUMMARY:Test Import Meeting again 9835-5dc547a01713\r\n" +
+		"DTSTART;TZID=UTC+0700:99950305T090000\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	// Test case 1: import events from the request body
+	req, _ := http.NewRequest("POST", "/events/import", strings.NewReader(doc))
+	req.Header.Set("Content-Type", "text/calendar")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var report struct {
+		Created  int          `json:"created"`
+		Skipped  int          `json:"skipped"`
+		Rejected int          `json:"rejected"`
+		Items    []importItem `json:"items"`
+	}
+	err := json.Unmarshal(resp.Body.Bytes(), &report)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, report.Created)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 1, report.Rejected)
+	statuses := []string{}
+	for _, item := range report.Items {
+		statuses = append(statuses, item.Status)
+	}
+	assert.DeepEqual(t, []string{"created", "created", "created", "rejected", "skipped"}, statuses)
+	assert.Equal(t, "Event time is overlapping with existing events", report.Items[3].Error)
+
+	req, _ = http.NewRequest("GET", "/events?keyword=Import&year=9995&month=03", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	var eventsResp []models.Event
+	err = json.Unmarshal(resp.Body.Bytes(), &eventsResp)
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(eventsResp))
+	assert.Equal(t, "import-1-9835-5dc547a01713@example.com", eventsResp[0].UID)
+	assert.Equal(t, models.TimeOfDay("09:00:00+07"), eventsResp[0].StartTime)
+	assert.Equal(t, "Asia/Bangkok", eventsResp[0].TimeZone)
+	assert.Equal(t, "", eventsResp[1].TimeZone)
+	assert.Equal(t, models.TimeOfDay("04:00:00+00"), eventsResp[1].StartTime)
+	assert.Equal(t, models.TimeOfDay("04:30:00+00"), eventsResp[1].EndTime)
+	assert.Equal(t, "Test Import Holiday 9835-5dc547a01713", eventsResp[2].Title)
+	assert.Assert(t, eventsResp[2].AllDay)
+	assert.Equal(t, "9995-03-03", eventsResp[2].EndDate)
+	assert.Equal(t, "Home", eventsResp[2].Location)
+	assert.DeepEqual(t, []string{"family", "rest"}, eventsResp[2].TagNames())
+
+	// Test case 2: importing the same file again as an upload skips every event
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "events.ics")
+	part.Write([]byte(doc))
+	writer.Close()
+	req, _ = http.NewRequest("POST", "/events/import", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	err = json.Unmarshal(resp.Body.Bytes(), &report)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, report.Created)
+	assert.Equal(t, 4, report.Skipped)
+	assert.Equal(t, 1, report.Rejected)
+	assert.Equal(t, eventsResp[0].ID, report.Items[0].ID)
+
+	// Test case 3: invalid file
+	req, _ = http.NewRequest("POST", "/events/import", strings.NewReader("BEGIN:VCALENDAR\r\n"))
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Invalid iCalendar file: missing END:VCALENDAR"}`, resp.Body.String())
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Replace one occurrence of a recurring event with new details
+func (h *Handler) UpdateOccurrence(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if _, ok := h.authorizeEvent(c, event, models.RoleEditor); !ok {
+		return
+	}
+	if !matchesIfMatch(c, event) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionConflict.Error()})
+		return
+	}
+	recurrenceDate, ok := findOccurrenceDate(event, c.Param("date"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Occurrence not found"})
+		return
+	}
+
+	// Bind JSON request body to EventOverride struct
+	var override models.EventOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	occurrence := models.Event{
+		CalendarID: event.CalendarID,
+		Title:      override.Title,
+		EventDate:  override.EventDate,
+		EndDate:    override.EndDate,
+		StartTime:  override.StartTime,
+		EndTime:    override.EndTime,
+		TimeZone:   event.TimeZone,
+		AllDay:     event.AllDay,
+		Busy:       event.Busy,
+	}
+	if err := validateEvent(&occurrence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	override.EventDate, override.EndDate = occurrence.EventDate, occurrence.EndDate
+	override.StartTime, override.EndTime = occurrence.StartTime, occurrence.EndTime
+
+	// Check the moved occurrence against the rest of its own series
+	siblings, err := event.Occurrences(occurrence.GetEventDate(), occurrence.GetEndDate())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	for i := range siblings {
+		if siblings[i].RecurrenceDate != recurrenceDate && siblings[i].OverlapsWith(&occurrence) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errOverlapping.Error()})
+			return
+		}
+	}
+
+	// Create or replace the override of this occurrence, unless it overlaps other events
+	override.RecurrenceDate = recurrenceDate
+	err = h.writeWithoutOverlaps(&occurrence, event.ID, func(events repositories.EventRepository) error {
+		return events.SaveOverride(event, &override, requestAudit(c))
+	})
+	if errors.Is(err, errOverlapping) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, override)
+}
+
+// Cancel one occurrence of a recurring event by adding it to the exception dates
+func (h *Handler) CancelOccurrence(c *gin.Context) {
+	event, ok := h.findEvent(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	if _, ok := h.authorizeEvent(c, event, models.RoleEditor); !ok {
+		return
+	}
+	if !matchesIfMatch(c, event) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionConflict.Error()})
+		return
+	}
+	recurrenceDate, ok := findOccurrenceDate(event, c.Param("date"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Occurrence not found"})
+		return
+	}
+
+	err := h.Events.CancelOccurrence(event, recurrenceDate, requestAudit(c))
+	if errors.Is(err, repositories.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Occurrence cancelled successfully"})
+}
+
+// Check that the date is generated by the event's recurrence rule and has not been cancelled
+func findOccurrenceDate(event *models.Event, date string) (string, bool) {
+	if !event.IsRecurring() {
+		return "", false
+	}
+	recurrenceDate, err := time.Parse("2006-01-02", date)
+	if err != nil || event.ExDates.Contains(recurrenceDate) {
+		return "", false
+	}
+	rule, err := models.ParseRecurrenceRule(event.RRule)
+	if err != nil {
+		return "", false
+	}
+	if len(rule.Dates(event.GetEventDate(), recurrenceDate, recurrenceDate)) == 0 {
+		return "", false
+	}
+	return recurrenceDate.Format("2006-01-02"), true
+}
+
+// Last date recurring events are expanded to when ListEvents has no end date
+func defaultExpansionEnd(startDate time.Time) time.Time {
+	from := time.Now()
+	if startDate.After(from) {
+		from = startDate
+	}
+	return from.AddDate(models.RecurrenceHorizonYears, 0, 0)
+}
+
+// Days the dates of occurrences in their own offsets can be away from the days of the caller's time zone
+const zoneSlackDays = 2
+
+// Expand the occurrences of a series inside [from, to] that come after the position in the order of the
+// filter, or all of them without a position. The range is walked from the position in windows growing
+// twice as long each time, and the walk stops once limit occurrences are found whose place in the order
+// later windows cannot change, so that pages do not expand the whole range.
+func pageOccurrences(series *models.Event, from, to time.Time, loc *time.Location, order *repositories.EventFilter, position *models.Event, limit int) ([]models.Event, error) {
+	// The next occurrences are later ones, unless the order goes back in time
+	forward := order.Desc == order.Reverse
+	if position != nil {
+		date := position.GetEventDate()
+		if start := date.AddDate(0, 0, -zoneSlackDays); forward && start.After(from) {
+			from = start
+		}
+		if end := date.AddDate(0, 0, zoneSlackDays); !forward && end.Before(to) {
+			to = end
+		}
+	}
+	// Occurrences start on the first date of the series, or the date an override moved one to
+	earliest := series.GetEventDate()
+	for _, o := range series.Overrides {
+		if date, err := models.ParseDate(o.EventDate); err == nil && date.Before(earliest) {
+			earliest = date
+		}
+	}
+	if start := earliest.AddDate(0, 0, -zoneSlackDays); start.After(from) {
+		from = start
+	}
+
+	occurrences := []models.Event{}
+	seen := map[string]bool{}
+	for days := 31; !to.Before(from); days *= 2 {
+		windowFrom, windowTo := from, to
+		if forward && from.AddDate(0, 0, days).Before(to) {
+			windowTo = from.AddDate(0, 0, days)
+		}
+		if !forward && to.AddDate(0, 0, -days).After(from) {
+			windowFrom = to.AddDate(0, 0, -days)
+		}
+		expanded, err := series.OccurrencesIn(windowFrom, windowTo, loc)
+		if err != nil {
+			return nil, err
+		}
+		for i := range expanded {
+			if seen[expanded[i].RecurrenceDate] {
+				continue
+			}
+			seen[expanded[i].RecurrenceDate] = true
+			if position == nil || order.Compare(&expanded[i], position) > 0 {
+				occurrences = append(occurrences, expanded[i])
+			}
+		}
+
+		// Occurrences dated well inside the walked range keep their place, later windows only add ones after them
+		settled := 0
+		for i := range occurrences {
+			date := occurrences[i].GetEventDate()
+			if forward && !date.After(windowTo.AddDate(0, 0, -zoneSlackDays)) || !forward && !date.Before(windowFrom.AddDate(0, 0, zoneSlackDays)) {
+				settled++
+			}
+		}
+		if settled >= limit {
+			break
+		}
+		if forward {
+			from = windowTo.AddDate(0, 0, 1)
+		} else {
+			to = windowFrom.AddDate(0, 0, -1)
+		}
+	}
+	return occurrences, nil
+}
+
+// Sort expanded occurrences in the order of a filter, all-day events come first on each date
+func sortOccurrences(events []models.Event, filter *repositories.EventFilter) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return filter.Compare(&events[i], &events[j]) < 0
+	})
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+func TestRecurringEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
+	r.POST("/events", h.CreateEvent)
+	r.PUT("/events/:id/occurrences/:date", h.UpdateOccurrence)
+	r.DELETE("/events/:id/occurrences/:date", h.CancelOccurrence)
+
+	// Test case 1: create a weekly event on Mondays and Wednesdays
+	requestBody := []byte(`{"title": "Test Recurring 9835-5dc547a01713", "event_date": "9997-01-01", "start_time": "09:00:00+07", "end_time": "09:30:00+07", "rrule": "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=6"}`)
+	req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	var series models.Event
+	err := json.Unmarshal(resp.Body.Bytes(), &series)
+	assert.NilError(t, err)
+
+	// Test case 2: occurrences are expanded in ListEvents
+	req, _ = http.NewRequest("GET", "/events?keyword=Recurring%209835-5dc547a01713&start_date=9997-01-05&end_date=9997-01-31", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	var eventsResp []models.Event
+	err = json.Unmarshal(resp.Body.Bytes(), &eventsResp)
+	assert.NilError(t, err)
+	dates := []string{}
+	for _, e := range eventsResp {
+		assert.Equal(t, series.ID, e.ID)
+		assert.Equal(t, e.EventDate, e.RecurrenceDate)
+		dates = append(dates, e.EventDate)
+	}
+	assert.DeepEqual(t, []string{"9997-01-06", "9997-01-08", "9997-01-13", "9997-01-15", "9997-01-20"}, dates)
+
+	// Test case 3: a single event overlapping one occurrence is rejected
+	requestBody = []byte(`{"title": "Test Recurring Single 9835-5dc547a01713", "event_date": "9997-01-13", "start_time": "09:15:00+07", "end_time": "10:00:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 4: a recurring event overlapping a later occurrence is rejected
+	requestBody = []byte(`{"title": "Test Recurring Daily 9835-5dc547a01713", "event_date": "9997-01-09", "start_time": "09:20:00+07", "end_time": "09:40:00+07", "rrule": "FREQ=DAILY;COUNT=5"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 5: invalid recurrence rule
+	requestBody = []byte(`{"title": "Test Recurring Invalid 9835-5dc547a01713", "event_date": "9997-01-09", "start_time": "09:20:00+07", "end_time": "09:40:00+07", "rrule": "FREQ=HOURLY"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Invalid recurrence rule: unsupported FREQ \"HOURLY\""}`, resp.Body.String())
+
+	// Test case 6: cancel an occurrence, the slot becomes free
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/events/%d/occurrences/9997-01-13", series.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	requestBody = []byte(`{"title": "Test Recurring Single 9835-5dc547a01713", "event_date": "9997-01-13", "start_time": "09:15:00+07", "end_time": "10:00:00+07"}`)
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+
+	// Test case 7: move an occurrence onto the single event
+	requestBody = []byte(`{"event_date": "9997-01-13", "start_time": "09:45:00+07", "end_time": "10:30:00+07"}`)
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/events/%d/occurrences/9997-01-15", series.ID), bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 8: move an occurrence to a free slot
+	requestBody = []byte(`{"title": "Test Recurring Moved 9835-5dc547a01713", "event_date": "9997-01-16", "start_time": "14:00:00+07", "end_time": "14:30:00+07"}`)
+	req, _ = http.NewRequest("PUT", fmt.Sprintf("/events/%d/occurrences/9997-01-15", series.ID), bytes.NewBuffer(requestBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	req, _ = http.NewRequest("GET", "/events?keyword=Recurring&start_date=9997-01-16&end_date=9997-01-16", nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	err = json.Unmarshal(resp.Body.Bytes(), &eventsResp)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(eventsResp))
+	assert.Equal(t, "Test Recurring Moved 9835-5dc547a01713", eventsResp[0].Title)
+	assert.Equal(t, "9997-01-15", eventsResp[0].RecurrenceDate)
+
+	// Test case 9: occurrence that is not generated by the rule
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/events/%d/occurrences/9997-01-14", series.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"error":"Occurrence not found"}`, resp.Body.String())
+}
+
+func TestPageOccurrences(t *testing.T) {
+	// Setup
+	series := models.Event{ID: 1, Title: "Standup", EventDate: "2024-01-01", StartTime: "09:00:00+07", EndTime: "09:15:00+07", RRule: "FREQ=DAILY"}
+	from, _ := models.ParseDate("2024-01-01")
+	to, _ := models.ParseDate("2025-12-31")
+	asc := &repositories.EventFilter{}
+	desc := &repositories.EventFilter{Desc: true}
+	all, err := series.Occurrences(from, to)
+	assert.NilError(t, err)
+	dates := func(events []models.Event, filter *repositories.EventFilter, n int) []string {
+		sortOccurrences(events, filter)
+		result := []string{}
+		for _, e := range events[:n] {
+			result = append(result, e.EventDate+" "+string(e.StartTime))
+		}
+		return result
+	}
+
+	// Test case 1: the first page expands only the start of the range
+	page, err := pageOccurrences(&series, from, to, nil, asc, nil, 3)
+	assert.NilError(t, err)
+	assert.Assert(t, len(page) < 40, "expanded %d occurrences", len(page))
+	assert.DeepEqual(t, []string{"2024-01-01 09:00:00+07", "2024-01-02 09:00:00+07", "2024-01-03 09:00:00+07"}, dates(page, asc, 3))
+
+	// Test case 2: later pages start the expansion at the cursor
+	position := all[400]
+	page, err = pageOccurrences(&series, from, to, nil, asc, &position, 3)
+	assert.NilError(t, err)
+	assert.Assert(t, len(page) < 40, "expanded %d occurrences", len(page))
+	assert.DeepEqual(t, []string{"2025-02-05 09:00:00+07", "2025-02-06 09:00:00+07", "2025-02-07 09:00:00+07"}, dates(page, asc, 3))
+
+	// Test case 3: descending pages walk back from the cursor
+	page, err = pageOccurrences(&series, from, to, nil, desc, &position, 3)
+	assert.NilError(t, err)
+	assert.Assert(t, len(page) < 40, "expanded %d occurrences", len(page))
+	assert.DeepEqual(t, []string{"2025-02-03 09:00:00+07", "2025-02-02 09:00:00+07", "2025-02-01 09:00:00+07"}, dates(page, desc, 3))
+
+	page, err = pageOccurrences(&series, from, to, nil, desc, nil, 1)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"2025-12-31 09:00:00+07"}, dates(page, desc, 1))
+
+	// Test case 4: an occurrence moved from the start of the series next to the cursor keeps its place
+	series.Overrides = []models.EventOverride{{RecurrenceDate: "2024-01-02", EventDate: "2025-02-05", StartTime: "09:30:00+07", EndTime: "09:45:00+07"}}
+	page, err = pageOccurrences(&series, from, to, nil, asc, &position, 3)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"2025-02-05 09:00:00+07", "2025-02-05 09:30:00+07", "2025-02-06 09:00:00+07"}, dates(page, asc, 3))
+
+	// Test case 5: pages in another time zone are expanded the same way
+	bangkok, _ := time.LoadLocation("Asia/Bangkok")
+	page, err = pageOccurrences(&series, from, to, bangkok, asc, &position, 2)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"2025-02-05 09:00:00+07", "2025-02-05 09:30:00+07"}, dates(page, asc, 2))
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Media types of the patch documents accepted by PatchEvent
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// Update some fields of an event with a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902).
+// The patched event is validated like a full update.
+func (h *Handler) PatchEvent(c *gin.Context) {
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Check if event exists and the caller may edit it
+	existingEvent, ok := h.findEvent(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+	caller, ok := h.authorizeEvent(c, existingEvent, models.RoleEditor)
+	if !ok {
+		return
+	}
+	if !matchesIfMatch(c, existingEvent) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionConflict.Error()})
+		return
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	document, err := json.Marshal(existingEvent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	// Apply the patch to the JSON representation of the event
+	switch c.ContentType() {
+	case jsonPatchType:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON Patch"})
+			return
+		}
+		if document, err = operations.Apply(document); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("JSON Patch cannot be applied: %s", err)})
+			return
+		}
+	case mergePatchType, binding.MIMEJSON:
+		if document, err = jsonpatch.MergePatch(document, patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid merge patch"})
+			return
+		}
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("Content-Type must be %s or %s", mergePatchType, jsonPatchType)})
+		return
+	}
+
+	// The patched event goes through the same checks as the body of UpdateEvent
+	var updatedEvent models.Event
+	if err := json.Unmarshal(document, &updatedEvent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := binding.Validator.ValidateStruct(&updatedEvent); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := h.updateEvent(existingEvent, &updatedEvent, caller, requestAudit(c)); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	setEventETag(c, existingEvent)
+	inCallerZone(loc, existingEvent)
+	c.JSON(http.StatusOK, existingEvent)
+}
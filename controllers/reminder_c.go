@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// firingLogLimit is the most firings listed for a reminder
+const firingLogLimit = 100
+
+// List the reminders of the caller on an event, or all of them for admins
+func (h *Handler) ListReminders(c *gin.Context) {
+	event, caller, ok := h.reminderEvent(c)
+	if !ok {
+		return
+	}
+
+	reminders, err := h.Reminders.ListForEvent(event.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	visible := []models.Reminder{}
+	for _, reminder := range reminders {
+		if caller.Admin || reminder.UserID == caller.ID {
+			visible = append(visible, reminder)
+		}
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// Set a reminder of the caller on an event, which fires before each of its occurrences.
+// Reminders are logged unless they are given another configured method.
+func (h *Handler) CreateReminder(c *gin.Context) {
+	event, caller, ok := h.reminderEvent(c)
+	if !ok {
+		return
+	}
+
+	var reminder models.Reminder
+	if err := c.ShouldBindJSON(&reminder); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if reminder.Method == "" {
+		reminder.Method = models.ReminderLog
+	}
+	if err := reminder.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := h.Notifiers[reminder.Method]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Reminder method " + reminder.Method + " is not configured"})
+		return
+	}
+	reminder.ID, reminder.EventID, reminder.UserID = 0, event.ID, caller.ID
+	reminder.Schedule(event, time.Now())
+
+	if err := h.Reminders.Create(&reminder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reminder)
+}
+
+// Delete a reminder with its firings
+func (h *Handler) DeleteReminder(c *gin.Context) {
+	reminder, ok := h.findReminder(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Reminders.Delete(reminder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reminder deleted successfully"})
+}
+
+// List the latest firings of a reminder, newest first
+func (h *Handler) ListReminderFirings(c *gin.Context) {
+	reminder, ok := h.findReminder(c)
+	if !ok {
+		return
+	}
+
+	firings, err := h.Reminders.ListFirings(reminder.ID, firingLogLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, firings)
+}
+
+// Event of the URL parameter, on which callers who can view it set their reminders.
+// Events the caller cannot view are hidden.
+func (h *Handler) reminderEvent(c *gin.Context) (*models.Event, *models.User, bool) {
+	user := currentUser(c)
+	if user == nil {
+		unauthorized(c, "Reminders need a user")
+		return nil, nil, false
+	}
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return nil, nil, false
+	}
+	event, ok := h.findEvent(c)
+	if !ok || !caller.EventRole(event).Includes(models.RoleViewer) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return nil, nil, false
+	}
+	return event, user, true
+}
+
+// Find the reminder of the URL parameters, responding 404 unless it is set by the caller or the caller is an admin
+func (h *Handler) findReminder(c *gin.Context) (*models.Reminder, bool) {
+	event, caller, ok := h.reminderEvent(c)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("reminder"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+		return nil, false
+	}
+
+	reminder, err := h.Reminders.Get(event.ID, uint(id))
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	if !caller.Admin && reminder.UserID != caller.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+		return nil, false
+	}
+	return reminder, true
+}
+
+// Delete the reminders of a user on the events they can no longer view, once their grants changed.
+// Reminders on deleted events are kept for when the events are restored.
+func (h *Handler) dropReminders(userID uint) error {
+	user, err := h.Users.Get(userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		user = nil
+	} else if err != nil {
+		return err
+	}
+	roles, err := h.loadAccess(user)
+	if err != nil {
+		return err
+	}
+
+	reminders, err := h.Reminders.ListForUser(userID)
+	if err != nil {
+		return err
+	}
+	for i := range reminders {
+		event, err := h.Events.Get(reminders[i].EventID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !roles.EventRole(event).Includes(models.RoleViewer) {
+			if err := h.Reminders.Delete(&reminders[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
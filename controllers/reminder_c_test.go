@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/notify"
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+func TestReminders(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	h.Notifiers = notify.Notifiers{models.ReminderLog: &notify.LogNotifier{}, models.ReminderEmail: &notify.SMTPNotifier{}}
+	r := gin.Default()
+	api := r.Group("/api", h.Authenticate)
+	api.POST("/events", h.CreateEvent)
+	api.PUT("/events/:id", h.UpdateEvent)
+	api.GET("/events/:id/reminders", h.ListReminders)
+	api.POST("/events/:id/reminders", h.CreateReminder)
+	api.DELETE("/events/:id/reminders/:reminder", h.DeleteReminder)
+	api.GET("/events/:id/reminders/:reminder/firings", h.ListReminderFirings)
+	api.POST("/events/:id/grants", h.GrantEvent)
+	api.DELETE("/events/:id/grants/:user", h.RevokeEventGrant)
+
+	secrets := map[string]string{}
+	ids := map[string]uint{}
+	for _, name := range []string{"admin", "alice", "bob"} {
+		user := &models.User{Name: name, Admin: name == "admin"}
+		assert.NilError(t, h.Users.Create(user))
+		ids[name] = user.ID
+		key := models.APIKey{UserID: user.ID}
+		secret, err := key.NewAPIKeySecret()
+		assert.NilError(t, err)
+		secrets[name] = secret
+		assert.NilError(t, h.Users.CreateKey(&key))
+	}
+	send := func(method, path, user, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+secrets[user])
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+	decode := func(resp *httptest.ResponseRecorder, code int, value interface{}) {
+		assert.Equal(t, code, resp.Code, resp.Body.String())
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), value))
+	}
+
+	var event models.Event
+	body := `{"title": "Review", "event_date": "2099-03-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07", "time_zone": "Asia/Bangkok"}`
+	decode(send("POST", "/api/events", "alice", body), http.StatusCreated, &event)
+	path := fmt.Sprintf("/api/events/%d/reminders", event.ID)
+
+	// Test case 1: reminders are scheduled before the event, days before at a time of day in its time zone
+	var reminder models.Reminder
+	decode(send("POST", path, "alice", `{"days_before": 1, "at": "18:00"}`), http.StatusCreated, &reminder)
+	assert.Equal(t, models.ReminderLog, reminder.Method)
+	assert.Assert(t, reminder.NextFireAt.Equal(time.Date(2099, 2, 28, 11, 0, 0, 0, time.UTC)))
+	assert.Assert(t, reminder.OccurrenceAt.Equal(time.Date(2099, 3, 1, 2, 0, 0, 0, time.UTC)))
+	var minutes models.Reminder
+	decode(send("POST", path, "admin", `{"minutes_before": 10}`), http.StatusCreated, &minutes)
+	assert.Assert(t, minutes.NextFireAt.Equal(time.Date(2099, 3, 1, 1, 50, 0, 0, time.UTC)))
+
+	// Test case 2: reminders need one offset, and a configured method
+	assert.Equal(t, http.StatusBadRequest, send("POST", path, "alice", `{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", path, "alice", `{"minutes_before": 10, "days_before": 1, "at": "09:00"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", path, "alice", `{"days_before": 1}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", path, "alice", `{"minutes_before": 10, "method": "email"}`).Code)
+	resp := send("POST", path, "alice", `{"minutes_before": 10, "method": "webhook"}`)
+	assert.Equal(t, `{"error":"Reminder method webhook is not configured"}`, resp.Body.String())
+
+	// Test case 3: reminders follow the event when it is rescheduled
+	body = `{"title": "Review", "event_date": "2099-03-02", "start_time": "09:00:00+07", "end_time": "10:00:00+07", "time_zone": "Asia/Bangkok"}`
+	assert.Equal(t, http.StatusOK, send("PUT", fmt.Sprintf("/api/events/%d", event.ID), "alice", body).Code)
+	var reminders []models.Reminder
+	decode(send("GET", path, "alice", ""), http.StatusOK, &reminders)
+	assert.Equal(t, 1, len(reminders))
+	assert.Assert(t, reminders[0].NextFireAt.Equal(time.Date(2099, 3, 1, 11, 0, 0, 0, time.UTC)))
+
+	// Test case 4: users only see and delete their own reminders, on events they can view
+	decode(send("GET", path, "admin", ""), http.StatusOK, &reminders)
+	assert.Equal(t, 2, len(reminders))
+	assert.Equal(t, http.StatusNotFound, send("GET", path, "bob", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("POST", path, "bob", `{"minutes_before": 10}`).Code)
+	own := fmt.Sprintf("%s/%d", path, reminder.ID)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", fmt.Sprintf("%s/%d", path, minutes.ID), "alice", "").Code)
+	var firings []models.ReminderFiring
+	decode(send("GET", own+"/firings", "alice", ""), http.StatusOK, &firings)
+	assert.Equal(t, 0, len(firings))
+	assert.Equal(t, http.StatusOK, send("DELETE", own, "alice", "").Code)
+	assert.Equal(t, http.StatusNotFound, send("DELETE", own, "alice", "").Code)
+
+	// Test case 5: reminders are deleted once the user can no longer view the event
+	grants := fmt.Sprintf("/api/events/%d/grants", event.ID)
+	assert.Equal(t, http.StatusOK, send("POST", grants, "alice", fmt.Sprintf(`{"user_id": %d, "role": "viewer"}`, ids["bob"])).Code)
+	var shared models.Reminder
+	decode(send("POST", path, "bob", `{"minutes_before": 10}`), http.StatusCreated, &shared)
+	assert.Equal(t, http.StatusOK, send("POST", grants, "alice", fmt.Sprintf(`{"user_id": %d, "role": "freebusy"}`, ids["bob"])).Code)
+	_, err := h.Reminders.Get(event.ID, shared.ID)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+	assert.Equal(t, http.StatusOK, send("POST", grants, "alice", fmt.Sprintf(`{"user_id": %d, "role": "viewer"}`, ids["bob"])).Code)
+	decode(send("POST", path, "bob", `{"minutes_before": 10}`), http.StatusCreated, &shared)
+	assert.Equal(t, http.StatusOK, send("DELETE", fmt.Sprintf("%s/%d", grants, ids["bob"]), "alice", "").Code)
+	_, err = h.Reminders.Get(event.ID, shared.ID)
+	assert.ErrorIs(t, err, repositories.ErrNotFound)
+	decode(send("GET", path, "admin", ""), http.StatusOK, &reminders)
+	assert.Equal(t, 1, len(reminders))
+}
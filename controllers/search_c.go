@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+)
+
+// rankedOccurrence is an occurrence with the relevance of its title to the search
+type rankedOccurrence struct {
+	event *models.Event
+	rank  float64
+}
+
+// Compare two ranked occurrences, more relevant first and then by date like ListEvents
+func compareRanked(a, b rankedOccurrence) int {
+	if a.rank != b.rank {
+		if a.rank > b.rank {
+			return -1
+		}
+		return 1
+	}
+	return models.CompareOccurrences(a.event, b.event, false)
+}
+
+// List the events matching the search of ListEvents sorted by relevance. The ranking is done here,
+// so every matching occurrence in the range is loaded and the page is cut afterwards.
+func (h *Handler) listEventsByRelevance(c *gin.Context, filter *eventFilter, page *eventPage) {
+	single, recurring := false, true
+	singles := filter.repositoryFilter()
+	singles.Recurring = &single
+	events, err := h.Events.List(singles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	seriesFilter := filter.repositoryFilter()
+	seriesFilter.Recurring = &recurring
+	series, err := h.Events.List(seriesFilter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	occurrences := append([]models.Event{}, events...)
+	for i := range series {
+		expanded, err := series[i].OccurrencesIn(filter.startDate, filter.expansionEnd(), filter.location)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+		occurrences = append(occurrences, expanded...)
+	}
+
+	ranked := make([]rankedOccurrence, len(occurrences))
+	for i := range occurrences {
+		ranked[i] = rankedOccurrence{event: &occurrences[i], rank: filter.search.Rank(occurrences[i].Title)}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return compareRanked(ranked[i], ranked[j]) < 0
+	})
+
+	// Cut the page around the cursor position
+	start, end := 0, len(ranked)
+	if page.cursor != nil {
+		event := page.cursor.event()
+		position := rankedOccurrence{event: &event, rank: page.cursor.Rank}
+		if page.cursor.Backward {
+			end = sort.Search(len(ranked), func(i int) bool {
+				return compareRanked(ranked[i], position) >= 0
+			})
+			if start = end - page.limit; start < 0 {
+				start = 0
+			}
+		} else {
+			start = sort.Search(len(ranked), func(i int) bool {
+				return compareRanked(ranked[i], position) > 0
+			})
+		}
+	}
+	if end > start+page.limit {
+		end = start + page.limit
+	}
+
+	result := make([]models.Event, 0, end-start)
+	for _, occurrence := range ranked[start:end] {
+		result = append(result, *occurrence.event)
+	}
+	if len(result) > 0 {
+		if end < len(ranked) {
+			c.Header("X-Next-Cursor", rankedCursor(ranked[end-1], false))
+		}
+		if start > 0 {
+			c.Header("X-Prev-Cursor", rankedCursor(ranked[start], true))
+		}
+	}
+
+	for i := range result {
+		inCallerZone(filter.location, &result[i])
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Encode the position of a ranked occurrence as an opaque cursor
+func rankedCursor(occurrence rankedOccurrence, backward bool) string {
+	cursor := newEventCursor(occurrence.event, backward)
+	cursor.Rank = occurrence.rank
+	return cursor.encode()
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestSearchEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
+	r.POST("/events", h.CreateEvent)
+
+	for _, body := range []string{
+		`{"title": "Réunion d'équipe", "event_date": "9994-03-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`,
+		`{"title": "Meeting with the team about planning", "event_date": "9994-03-01", "start_time": "11:00:00+07", "end_time": "12:00:00+07"}`,
+		`{"title": "Team meeting", "event_date": "9994-03-02", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`,
+		`{"title": "Lunch", "event_date": "9994-03-02", "start_time": "12:00:00+07", "end_time": "13:00:00+07"}`,
+		`{"title": "Weekly Team Sync", "event_date": "9994-03-03", "start_time": "09:00:00+07", "end_time": "10:00:00+07", "rrule": "FREQ=WEEKLY;COUNT=2"}`,
+	} {
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+	}
+
+	// Search in March 9994 and return the titles with the dates of recurring events, and the cursors
+	search := func(q string, query string) ([]string, string, string) {
+		req, _ := http.NewRequest("GET", "/events?start_date=9994-03-01&end_date=9994-03-31&q="+url.QueryEscape(q)+"&"+query, nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		var events []models.Event
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &events))
+		titles := []string{}
+		for _, e := range events {
+			title := e.Title
+			if e.RecurrenceDate != "" {
+				title += " " + e.RecurrenceDate
+			}
+			titles = append(titles, title)
+		}
+		return titles, resp.Header().Get("X-Next-Cursor"), resp.Header().Get("X-Prev-Cursor")
+	}
+
+	// Test case 1: searches ignore case and accents and match inside words
+	for _, q := range []string{"reunion", "RÉUNION", "equip"} {
+		titles, _, _ := search(q, "")
+		assert.DeepEqual(t, []string{"Réunion d'équipe"}, titles)
+	}
+
+	// Test case 2: all the words must match, in any order
+	titles, _, _ := search("team meeting", "")
+	assert.DeepEqual(t, []string{"Meeting with the team about planning", "Team meeting"}, titles)
+
+	// Test case 3: a phrase matches its words next to each other
+	titles, _, _ = search(`"team meeting"`, "")
+	assert.DeepEqual(t, []string{"Team meeting"}, titles)
+
+	// Test case 4: OR matches either side, occurrences of recurring events are listed
+	titles, _, _ = search("lunch OR sync", "")
+	assert.DeepEqual(t, []string{"Lunch", "Weekly Team Sync 9994-03-03", "Weekly Team Sync 9994-03-10"}, titles)
+
+	// Test case 5: relevance sort puts whole words of short titles first, pages go both ways
+	titles, next, prev := search("team", "sort_order=relevance&limit=2")
+	assert.DeepEqual(t, []string{"Team meeting", "Weekly Team Sync 9994-03-03"}, titles)
+	assert.Equal(t, "", prev)
+	titles, next, prev = search("team", "sort_order=relevance&limit=2&cursor="+next)
+	assert.DeepEqual(t, []string{"Weekly Team Sync 9994-03-10", "Meeting with the team about planning"}, titles)
+	assert.Equal(t, "", next)
+	titles, _, _ = search("team", "sort_order=relevance&limit=2&cursor="+prev)
+	assert.DeepEqual(t, []string{"Team meeting", "Weekly Team Sync 9994-03-03"}, titles)
+
+	// Test case 6: invalid searches
+	for query, message := range map[string]string{
+		"q=OR":                 "Invalid search",
+		"q=%22%22":             "Invalid search",
+		"sort_order=relevance": "Relevance sort needs a search",
+	} {
+		req, _ := http.NewRequest("GET", "/events?"+query, nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, `{"error":"`+message+`"}`, resp.Body.String())
+	}
+}
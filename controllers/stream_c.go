@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/feed"
+)
+
+// changeEventRemoved is streamed instead of the change of an event that left the filters or the
+// access of the stream, and carries its ID only
+const changeEventRemoved = "event.removed"
+
+// streamHeartbeat is how often an idle stream sends a comment, which keeps proxies from closing it
+var streamHeartbeat = 15 * time.Second
+
+// Stream the changes of the events matching the filters of ListEvents as Server-Sent Events.
+// Clients reconnecting with a Last-Event-ID header get the changes they missed, or a reset
+// event telling them to read the events again when the changes are no longer kept.
+func (h *Handler) StreamEvents(c *gin.Context) {
+	if h.Changes == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Change stream is not available"})
+		return
+	}
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	eventFilter, err := parseEventFilter(c, caller)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := eventFilter.repositoryFilter()
+	var lastID *uint
+	if value := c.GetHeader("Last-Event-ID"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		last := uint(id)
+		lastID = &last
+	}
+
+	sub, replay, ok := h.Changes.Subscribe(lastID)
+	defer h.Changes.Unsubscribe(sub)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	if !ok {
+		sse.Encode(c.Writer, sse.Event{Event: "reset", Data: gin.H{"message": "Missed changes are no longer kept"}})
+	}
+
+	// Changes are sent once and in order, those of the replay may be read again
+	var sent uint
+	if lastID != nil {
+		sent = *lastID
+	}
+	send := func(change feed.Change) {
+		if change.ID <= sent {
+			return
+		}
+		sent = change.ID
+		id := strconv.FormatUint(uint64(change.ID), 10)
+		if !filter.Matches(change.Event) {
+			if change.Previous != nil && filter.Matches(change.Previous) {
+				// The event may have moved where the caller cannot see it
+				sse.Encode(c.Writer, sse.Event{Id: id, Event: changeEventRemoved,
+					Data: gin.H{"id": change.ID, "type": changeEventRemoved, "event_id": change.EventID}})
+			}
+			return
+		}
+		event := *change.Event
+		inCallerZone(eventFilter.location, &event)
+		change.Event = &event
+		sse.Encode(c.Writer, sse.Event{Id: id, Event: change.Type, Data: change})
+	}
+	for _, change := range replay {
+		send(change)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case change, open := <-sub.C:
+			if !open {
+				return
+			}
+			send(change)
+		case <-heartbeat.C:
+			c.Writer.WriteString(": heartbeat\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
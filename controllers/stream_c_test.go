@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/feed"
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+// Server-Sent Event read from a stream
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+// Read the next event of a stream, skipping comments
+func readStreamEvent(t *testing.T, r *bufio.Reader) streamEvent {
+	var e streamEvent
+	for {
+		line, err := r.ReadString('\n')
+		assert.NilError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && e.event != "":
+			return e
+		case strings.HasPrefix(line, "id:"):
+			e.id = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			e.event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			e.data = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	h.Changes = feed.New(h.Events, 2)
+	assert.NilError(t, h.Changes.Start())
+	heartbeat := streamHeartbeat
+	streamHeartbeat = 50 * time.Millisecond
+	defer func() { streamHeartbeat = heartbeat }()
+	r := newTestRouter()
+	r.GET("/api/events/stream", h.StreamEvents)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	open := func(query, lastID string) (*bufio.Reader, func()) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events/stream"+query, nil)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NilError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewReader(resp.Body), func() {
+			cancel()
+			resp.Body.Close()
+		}
+	}
+	create := func(title, date string) *models.Event {
+		event := &models.Event{CalendarID: 1, Title: title, EventDate: date, StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, h.Events.Create(event, models.Audit{}))
+		_, err := h.Changes.Poll(time.Now())
+		assert.NilError(t, err)
+		return event
+	}
+
+	// Test case 1: changes matching the date range and keyword are streamed
+	stream, stop := open("?start_date=2024-03-01&end_date=2024-03-31&keyword=Review", "")
+	defer stop()
+	create("Review", "2024-04-01")
+	create("Standup", "2024-03-02")
+	review := create("Review", "2024-03-02")
+	e := readStreamEvent(t, stream)
+	assert.Equal(t, models.ChangeEventCreated, e.event)
+	var change feed.Change
+	assert.NilError(t, json.Unmarshal([]byte(e.data), &change))
+	assert.Equal(t, review.ID, change.EventID)
+	assert.Equal(t, "3", e.id)
+
+	// Test case 2: events moved out of the range are streamed as removed
+	review.EventDate = "2024-04-02"
+	assert.NilError(t, h.Events.Update(review, models.Audit{}))
+	_, err := h.Changes.Poll(time.Now())
+	assert.NilError(t, err)
+	e = readStreamEvent(t, stream)
+	assert.Equal(t, changeEventRemoved, e.event)
+	assert.Equal(t, fmt.Sprintf(`{"event_id":%d,"id":4,"type":"event.removed"}`, review.ID), e.data)
+
+	// Test case 3: idle streams send heartbeats
+	line, err := stream.ReadString('\n')
+	assert.NilError(t, err)
+	assert.Equal(t, ": heartbeat\n", line)
+
+	// Test case 4: reconnecting clients get the changes they missed
+	replayed, stopReplay := open("", "3")
+	defer stopReplay()
+	e = readStreamEvent(t, replayed)
+	assert.Equal(t, "4", e.id)
+
+	// Test case 5: clients are told to reset when the changes they missed are no longer kept
+	reset, stopReset := open("", "1")
+	defer stopReset()
+	e = readStreamEvent(t, reset)
+	assert.Equal(t, "reset", e.event)
+	e = readStreamEvent(t, reset)
+	assert.Equal(t, "3", e.id)
+
+	// Test case 6: invalid filters and event IDs are rejected
+	resp, err := http.Get(server.URL + "/api/events/stream?start_date=march")
+	assert.NilError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	req, _ := http.NewRequest("GET", server.URL+"/api/events/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Test case 7: events moved to a calendar the viewer cannot see are streamed as removed, without their details
+	viewer := &models.User{ID: 2, Name: "viewer"}
+	shared, err := h.Calendars.GetDefault()
+	assert.NilError(t, err)
+	assert.NilError(t, h.Grants.Save(&models.Grant{CalendarID: &shared.ID, UserID: viewer.ID, Role: models.RoleViewer}))
+	private := &models.Calendar{Name: "Private"}
+	assert.NilError(t, h.Calendars.Create(private))
+	viewerRouter := gin.New()
+	viewerRouter.Use(func(c *gin.Context) { c.Set(userKey, viewer) })
+	viewerRouter.GET("/api/events/stream", h.StreamEvents)
+	viewerServer := httptest.NewServer(viewerRouter)
+	defer viewerServer.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "GET", viewerServer.URL+"/api/events/stream", nil)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	defer resp.Body.Close()
+	viewed := bufio.NewReader(resp.Body)
+	planning := create("Planning", "2024-03-05")
+	e = readStreamEvent(t, viewed)
+	assert.Equal(t, models.ChangeEventCreated, e.event)
+	planning.CalendarID, planning.Title = private.ID, "Layoffs"
+	assert.NilError(t, h.Events.Update(planning, models.Audit{}))
+	_, err = h.Changes.Poll(time.Now())
+	assert.NilError(t, err)
+	e = readStreamEvent(t, viewed)
+	assert.Equal(t, changeEventRemoved, e.event)
+	assert.Assert(t, !strings.Contains(e.data, "Layoffs"), e.data)
+	var removed feed.Change
+	assert.NilError(t, json.Unmarshal([]byte(e.data), &removed))
+	assert.Equal(t, planning.ID, removed.EventID)
+	assert.Assert(t, removed.Event == nil)
+}
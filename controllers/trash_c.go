@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// trashedEvent is a deleted event with the time it was deleted
+type trashedEvent struct {
+	models.Event
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// List the deleted events, last deleted first
+func (h *Handler) ListTrash(c *gin.Context) {
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	filter, err := parseTrashFilter(c, caller.filter(models.RoleEditor))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	events, err := h.Events.ListDeleted(*filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	trash := make([]trashedEvent, len(events))
+	for i := range events {
+		inCallerZone(loc, &events[i])
+		trash[i] = trashedEvent{Event: events[i], DeletedAt: events[i].DeletedAt.Time}
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+// Parse the keyword, calendar_id and deleted_before query parameters of the trash endpoints.
+// The events are limited to the access of the caller.
+func parseTrashFilter(c *gin.Context, access *repositories.Access) (*repositories.TrashFilter, error) {
+	filter := &repositories.TrashFilter{Keyword: c.Query("keyword"), Access: access}
+	calendarIDs, err := parseCalendarIDs(c)
+	if err != nil {
+		return nil, err
+	}
+	filter.CalendarIDs = calendarIDs
+	if deletedBefore := c.Query("deleted_before"); deletedBefore != "" {
+		if filter.DeletedBefore, err = parseInstant(deletedBefore); err != nil {
+			return nil, errors.New("Invalid deleted before")
+		}
+	}
+	return filter, nil
+}
+
+// Find the deleted event with the ID of the URL parameter
+func (h *Handler) findDeletedEvent(c *gin.Context) (*models.Event, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	event, err := h.Events.GetDeleted(uint(id))
+	if err != nil {
+		return nil, false
+	}
+	return event, true
+}
+
+// Restore a deleted event. The event must still fit in its calendar,
+// its time may have been taken by another event since it was deleted.
+func (h *Handler) RestoreEvent(c *gin.Context) {
+	loc, err := callerZone(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, ok := h.findDeletedEvent(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found in trash"})
+		return
+	}
+	if _, ok := h.authorizeEvent(c, event, models.RoleEditor); !ok {
+		return
+	}
+	if !matchesIfMatch(c, event) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionConflict.Error()})
+		return
+	}
+
+	// Check that the calendar was not deleted with the event
+	if err := h.resolveCalendar(event); err != nil {
+		if errors.Is(err, errCalendarNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Restore event, unless any occurrence overlaps with the events of the calendar or it changed since it was read
+	err = h.writeWithoutOverlaps(event, event.ID, func(events repositories.EventRepository) error {
+		return events.Restore(event, requestAudit(c))
+	})
+	if err != nil {
+		if errors.Is(err, errOverlapping) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, repositories.ErrVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	setEventETag(c, event)
+	inCallerZone(loc, event)
+	c.JSON(http.StatusOK, event)
+}
+
+// Permanently delete a deleted event
+func (h *Handler) PurgeEvent(c *gin.Context) {
+	event, ok := h.findDeletedEvent(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found in trash"})
+		return
+	}
+	if _, ok := h.authorizeEvent(c, event, models.RoleOwner); !ok {
+		return
+	}
+
+	if _, err := h.Events.Purge(repositories.TrashFilter{IDs: []uint{event.ID}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Event purged successfully"})
+}
+
+// Permanently delete the deleted events matching the filters, the whole trash without filters.
+// Only the events the caller owns are purged.
+func (h *Handler) PurgeTrash(c *gin.Context) {
+	caller, ok := h.callerAccess(c)
+	if !ok {
+		return
+	}
+	filter, err := parseTrashFilter(c, caller.filter(models.RoleOwner))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	purged, err := h.Events.Purge(*filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestTrash(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events/:id", h.GetEventById)
+	r.POST("/events", h.CreateEvent)
+	r.DELETE("/events/:id", h.DeleteEvent)
+	r.GET("/events/trash", h.ListTrash)
+	r.DELETE("/events/trash", h.PurgeTrash)
+	r.POST("/events/trash/:id/restore", h.RestoreEvent)
+	r.DELETE("/events/trash/:id", h.PurgeEvent)
+
+	create := func(body string) models.Event {
+		req, _ := http.NewRequest("POST", "/events", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+		var event models.Event
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &event))
+		return event
+	}
+	send := func(method, url string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, url, nil)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test case 1: deleted events are listed in the trash
+	event := create(`{"title": "Trash Event 9835-5dc547a01713", "event_date": "4000-05-15", "start_time": "15:00:00+07", "end_time": "16:00:00+07"}`)
+	resp := send("DELETE", fmt.Sprintf("/events/%d", event.ID))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = send("GET", fmt.Sprintf("/events/%d", event.ID))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = send("GET", "/events/trash?keyword=Trash%20Event")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var trash []struct {
+		ID        uint   `json:"id"`
+		Title     string `json:"title"`
+		DeletedAt string `json:"deleted_at"`
+	}
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &trash))
+	assert.Equal(t, 1, len(trash))
+	assert.Equal(t, event.ID, trash[0].ID)
+	assert.Assert(t, trash[0].DeletedAt != "")
+
+	resp = send("GET", "/events/trash?deleted_before=2000-01-01T00:00:00Z")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `[]`, resp.Body.String())
+	resp = send("GET", "/events/trash?deleted_before=yesterday")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Invalid deleted before"}`, resp.Body.String())
+
+	// Test case 2: an event cannot be restored over an event created in its place
+	other := create(`{"title": "Other Event 9835-5dc547a01713", "event_date": "4000-05-15", "start_time": "15:30:00+07", "end_time": "16:30:00+07"}`)
+	resp = send("POST", fmt.Sprintf("/events/trash/%d/restore", event.ID))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Event time is overlapping with existing events"}`, resp.Body.String())
+
+	// Test case 3: restore once the time is free again
+	send("DELETE", fmt.Sprintf("/events/%d", other.ID))
+	resp = send("POST", fmt.Sprintf("/events/trash/%d/restore", event.ID))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `"2"`, resp.Header().Get("ETag"))
+	resp = send("GET", fmt.Sprintf("/events/%d", event.ID))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = send("POST", fmt.Sprintf("/events/trash/%d/restore", event.ID))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, `{"error":"Event not found in trash"}`, resp.Body.String())
+
+	// Test case 4: purge one event
+	resp = send("DELETE", fmt.Sprintf("/events/trash/%d", event.ID))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	send("DELETE", fmt.Sprintf("/events/%d", event.ID))
+	resp = send("DELETE", fmt.Sprintf("/events/trash/%d", event.ID))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	resp = send("POST", fmt.Sprintf("/events/trash/%d/restore", event.ID))
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	// Test case 5: purge the events matching the filters
+	resp = send("DELETE", "/events/trash?keyword=Trash")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"purged":0}`, resp.Body.String())
+	resp = send("DELETE", "/events/trash?keyword=Other")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, `{"purged":1}`, resp.Body.String())
+	resp = send("GET", "/events/trash")
+	assert.Equal(t, `[]`, resp.Body.String())
+}
+
+func TestRestoreEventOfDeletedCalendar(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.POST("/calendars", h.CreateCalendar)
+	r.DELETE("/calendars/:id", h.DeleteCalendar)
+	r.POST("/events", h.CreateEvent)
+	r.POST("/events/trash/:id/restore", h.RestoreEvent)
+
+	req, _ := http.NewRequest("POST", "/calendars", bytes.NewBufferString(`{"name": "Trash Calendar 9835-5dc547a01713"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	var calendar struct {
+		ID uint `json:"id"`
+	}
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &calendar))
+
+	req, _ = http.NewRequest("POST", "/events", bytes.NewBufferString(fmt.Sprintf(`{"calendar_id": %d, "title": "Trash Event 9835-5dc547a01713", "event_date": "4000-05-15", "start_time": "15:00:00+07", "end_time": "16:00:00+07"}`, calendar.ID)))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	var event models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &event))
+
+	// The events of a deleted calendar go to the trash but cannot be restored without it
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/calendars/%d", calendar.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/events/trash/%d/restore", event.ID), nil)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Equal(t, `{"error":"Calendar not found"}`, resp.Body.String())
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// createdAPIKey is an API key with its secret, which is only returned when the key is created
+type createdAPIKey struct {
+	models.APIKey
+	Secret string `json:"secret"`
+}
+
+// Get the user making the request
+func (h *Handler) GetCurrentUser(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		unauthorized(c, "Missing bearer token")
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// Get all users
+func (h *Handler) ListUsers(c *gin.Context) {
+	users, err := h.Users.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// Get a user by ID
+func (h *Handler) GetUserById(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// Find the user with the ID of the URL parameter
+func (h *Handler) findUser(c *gin.Context) (*models.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	user, err := h.Users.Get(uint(id))
+	if err != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+// Create a new user, who needs an API key or a JWT to make requests
+func (h *Handler) CreateUser(c *gin.Context) {
+	var user models.User
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Users.Create(&user); err != nil {
+		if errors.Is(err, repositories.ErrNameTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// Delete a user with their API keys. Their events are kept and only admins can see them.
+func (h *Handler) DeleteUser(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if caller := currentUser(c); caller != nil && caller.ID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Users cannot delete themselves"})
+		return
+	}
+
+	if err := h.Users.Delete(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// List the API keys of a user, without their secrets
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	keys, err := h.Users.ListKeys(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// Create an API key for a user. The secret is only returned in this response.
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	// The name and expiry time are optional, as is the body
+	var key models.APIKey
+	if err := c.ShouldBindJSON(&key); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry time must be in the future"})
+		return
+	}
+	key.ID, key.UserID = 0, user.ID
+	secret, err := key.NewAPIKeySecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if err := h.Users.CreateKey(&key); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createdAPIKey{APIKey: key, Secret: secret})
+}
+
+// Revoke an API key of a user
+func (h *Handler) DeleteAPIKey(c *gin.Context) {
+	user, ok := h.findUser(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	keyID, err := strconv.ParseUint(c.Param("key"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	if err := h.Users.DeleteKey(user.ID, uint(keyID)); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
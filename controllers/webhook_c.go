@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// deliveryLogLimit is the most deliveries listed for a webhook
+const deliveryLogLimit = 100
+
+// createdWebhook is a webhook with its secret, which is only returned when the webhook is created
+type createdWebhook struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+// webhookUpdate changes the fields of a webhook that are set
+type webhookUpdate struct {
+	URL      *string             `json:"url" binding:"omitempty,url,max=2048"`
+	Types    *models.ChangeTypes `json:"types" binding:"omitempty,dive,oneof=event.created event.updated event.deleted event.restored"`
+	Disabled *bool               `json:"disabled"`
+}
+
+// Get all webhooks
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.Webhooks.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// Get a webhook by ID
+func (h *Handler) GetWebhookById(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// Find the webhook with the ID of the URL parameter
+func (h *Handler) findWebhook(c *gin.Context) (*models.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	webhook, err := h.Webhooks.Get(uint(id))
+	if err != nil {
+		return nil, false
+	}
+	return webhook, true
+}
+
+// Subscribe a URL to the changes of events. The secret signing the payloads is only returned in this response.
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var webhook models.Webhook
+	if err := c.ShouldBindJSON(&webhook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	webhook.ID = 0
+	secret, err := webhook.NewWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if err := h.Webhooks.Create(&webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, createdWebhook{Webhook: webhook, Secret: secret})
+}
+
+// Change the URL or change types of a webhook, or disable it. The deliveries of
+// a disabled webhook wait until it is enabled again.
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	var update webhookUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if update.URL != nil {
+		webhook.URL = *update.URL
+	}
+	if update.Types != nil {
+		webhook.Types = *update.Types
+	}
+	if update.Disabled != nil {
+		webhook.Disabled = *update.Disabled
+	}
+
+	if err := h.Webhooks.Update(webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// Delete a webhook with its deliveries
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	if err := h.Webhooks.Delete(webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// List the latest deliveries of a webhook, newest first
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	deliveries, err := h.Webhooks.ListDeliveries(webhook.ID, deliveryLogLimit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Get a delivery of a webhook with its payload and the outcome of its last attempt
+func (h *Handler) GetWebhookDelivery(c *gin.Context) {
+	if delivery, ok := h.findDelivery(c); ok {
+		c.JSON(http.StatusOK, delivery)
+	}
+}
+
+// Send the payload of a delivery again, as a new delivery which is attempted as soon as possible
+func (h *Handler) RedeliverWebhook(c *gin.Context) {
+	delivery, ok := h.findDelivery(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	redelivery := models.WebhookDelivery{
+		WebhookID:     delivery.WebhookID,
+		RevisionID:    delivery.RevisionID,
+		Type:          delivery.Type,
+		Payload:       delivery.Payload,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &now,
+		RedeliveryOf:  &delivery.ID,
+	}
+	if err := h.Webhooks.CreateDelivery(&redelivery); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, redelivery)
+}
+
+// Find the delivery of the URL parameters, responding 404 when the webhook or the delivery does not exist
+func (h *Handler) findDelivery(c *gin.Context) (*models.WebhookDelivery, bool) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+	id, err := strconv.ParseUint(c.Param("delivery"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return nil, false
+	}
+
+	delivery, err := h.Webhooks.GetDelivery(webhook.ID, uint(id))
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return nil, false
+	}
+	return delivery, true
+}
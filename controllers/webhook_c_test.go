@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestWebhooks(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/api/webhooks/:id", h.GetWebhookById)
+	r.POST("/api/webhooks", h.CreateWebhook)
+	r.PATCH("/api/webhooks/:id", h.UpdateWebhook)
+	r.POST("/api/events", h.CreateEvent)
+	r.GET("/api/webhooks/:id/deliveries", h.ListWebhookDeliveries)
+	r.POST("/api/webhooks/:id/deliveries/:delivery/redeliver", h.RedeliverWebhook)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// Test case 1: the secret is only returned when the webhook is created
+	resp := send("POST", "/api/webhooks", `{"url": "http://localhost:9000/hooks", "types": ["event.created"]}`)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var created struct {
+		models.Webhook
+		Secret string `json:"secret"`
+	}
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &created))
+	assert.Equal(t, "whsec_", created.Secret[:6])
+	path := fmt.Sprintf("/api/webhooks/%d", created.ID)
+	resp = send("GET", path, "")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Assert(t, !bytes.Contains(resp.Body.Bytes(), []byte(created.Secret)))
+
+	// Test case 2: webhooks need a URL and known change types
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/api/webhooks", `{"url": "not a url"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/api/webhooks", `{"url": "http://localhost", "types": ["event.moved"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send("PATCH", path, `{"types": ["event.moved"]}`).Code)
+
+	// Test case 3: changes of events are logged as deliveries, which are sent again on request
+	event := `{"title": "Review", "event_date": "2024-03-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`
+	assert.Equal(t, http.StatusCreated, send("POST", "/api/events", event).Code)
+	var deliveries []models.WebhookDelivery
+	resp = send("GET", path+"/deliveries", "")
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &deliveries))
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, models.ChangeEventCreated, deliveries[0].Type)
+	resp = send("POST", fmt.Sprintf("%s/deliveries/%d/redeliver", path, deliveries[0].ID), "")
+	assert.Equal(t, http.StatusAccepted, resp.Code)
+	var redelivery models.WebhookDelivery
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &redelivery))
+	assert.Equal(t, deliveries[0].ID, *redelivery.RedeliveryOf)
+	assert.Equal(t, models.DeliveryPending, redelivery.Status)
+	assert.Equal(t, http.StatusNotFound, send("POST", path+"/deliveries/99/redeliver", "").Code)
+
+	// Test case 4: disabled webhooks are not sent new changes
+	resp = send("PATCH", path, `{"disabled": true}`)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	event = `{"title": "Retro", "event_date": "2024-03-02", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`
+	assert.Equal(t, http.StatusCreated, send("POST", "/api/events", event).Code)
+	resp = send("GET", path+"/deliveries", "")
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &deliveries))
+	assert.Equal(t, 2, len(deliveries))
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/models"
+)
+
+// Time zone of the caller, given by the tz query parameter or the Time-Zone header as an IANA name.
+// Nil when the caller gives none, events are then returned in their own offsets.
+func callerZone(c *gin.Context) (*time.Location, error) {
+	name := c.Query("tz")
+	if name == "" {
+		name = c.GetHeader("Time-Zone")
+	}
+	if name == "" {
+		return nil, nil
+	}
+	return models.LoadTimeZone(name)
+}
+
+// Write the dates and times of events in the time zone of the caller, when there is one
+func inCallerZone(loc *time.Location, events ...*models.Event) {
+	if loc == nil {
+		return
+	}
+	for _, event := range events {
+		event.In(loc)
+	}
+}
+
+// Check a start or end time, times without offset are wall clock times in the time zone of the event
+func validEventTime(clock models.TimeOfDay, loc *time.Location) bool {
+	if _, err := models.ParseTimeOfDay(string(clock)); err == nil {
+		return true
+	}
+	_, err := models.ParseLocalTimeOfDay(string(clock))
+	return err == nil && loc != nil
+}
+
+// Instant a start or end time is at on its date
+func eventInstant(date time.Time, clock models.TimeOfDay, loc *time.Location) time.Time {
+	if t, err := models.ParseTimeOfDay(string(clock)); err == nil {
+		return models.CombineDateTime(date, t)
+	}
+	t, _ := models.ParseLocalTimeOfDay(string(clock))
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
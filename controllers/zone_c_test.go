@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+func TestTimeZones(t *testing.T) {
+	// Setup
+	h := newTestHandler()
+	r := newTestRouter()
+	r.GET("/events", h.ListEvents)
+	r.GET("/events/:id", h.GetEventById)
+	r.POST("/events", h.CreateEvent)
+
+	send := func(method, path, zone, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if zone != "" {
+			req.Header.Set("Time-Zone", zone)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+	list := func(query, zone string) []models.Event {
+		resp := send("GET", "/events?"+query, zone, "")
+		assert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		var events []models.Event
+		assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &events))
+		return events
+	}
+
+	// Test case 1: times without offset are wall clock times in the time zone of the event
+	resp := send("POST", "/events", "", `{"title": "Sync", "time_zone": "Europe/Berlin", "event_date": "2024-03-25", "start_time": "09:00:00", "end_time": "10:00:00", "rrule": "FREQ=WEEKLY;COUNT=3"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var sync models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &sync))
+	assert.Equal(t, models.TimeOfDay("09:00:00+01"), sync.StartTime)
+	assert.Equal(t, time.Date(2024, 3, 25, 8, 0, 0, 0, time.UTC), sync.StartAt.UTC())
+
+	// Test case 2: occurrences keep 09:00 in Berlin after daylight saving time starts, and are listed in the caller's zone
+	events := list("keyword=Sync&start_date=2024-04-01&end_date=2024-04-01&tz=Asia/Bangkok", "")
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "2024-04-01", events[0].RecurrenceDate)
+	assert.Equal(t, models.TimeOfDay("14:00:00+07"), events[0].StartTime)
+	assert.Equal(t, "Europe/Berlin", events[0].TimeZone)
+	events = list("keyword=Sync&start_date=2024-04-01&end_date=2024-04-01", "")
+	assert.Equal(t, models.TimeOfDay("09:00:00+02"), events[0].StartTime)
+
+	// Test case 3: times with an offset are moved to the time zone of the event
+	resp = send("POST", "/events", "", `{"title": "Standup", "time_zone": "Asia/Kolkata", "event_date": "2024-04-01", "start_time": "10:00:00+07", "end_time": "10:15:00+07"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	var standup models.Event
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &standup))
+	assert.Equal(t, models.TimeOfDay("08:30:00+05:30"), standup.StartTime)
+	resp = send("GET", fmt.Sprintf("/events/%d", standup.ID), "America/New_York", "")
+	assert.NilError(t, json.Unmarshal(resp.Body.Bytes(), &standup))
+	assert.Equal(t, "2024-03-31", standup.EventDate)
+	assert.Equal(t, models.TimeOfDay("23:00:00-04"), standup.StartTime)
+
+	// Test case 4: the days of the year and month filters are those of the caller's zone
+	resp = send("POST", "/events", "", `{"title": "Late Call", "event_date": "2024-04-30", "start_time": "20:00:00+00", "end_time": "21:00:00+00"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	assert.Equal(t, 1, len(list("keyword=Late&year=2024&month=04", "")))
+	assert.Equal(t, 0, len(list("keyword=Late&year=2024&month=04", "Asia/Bangkok")))
+	events = list("keyword=Late&year=2024&month=05", "Asia/Bangkok")
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, "2024-05-01", events[0].EventDate)
+	assert.Equal(t, models.TimeOfDay("03:00:00+07"), events[0].StartTime)
+
+	// Test case 5: invalid time zones
+	for _, test := range []struct{ path, zone, body, message string }{
+		{"/events", "", `{"title": "Bad", "time_zone": "Mars/Base", "event_date": "2024-04-01", "start_time": "09:00:00", "end_time": "10:00:00"}`, "Invalid time zone"},
+		{"/events", "", `{"title": "Bad", "event_date": "2024-04-01", "start_time": "09:00:00", "end_time": "10:00:00"}`, "Invalid start time format"},
+		{"/events", "Local", `{"title": "Bad", "event_date": "2024-04-01", "start_time": "09:00:00+07", "end_time": "10:00:00+07"}`, "Invalid time zone"},
+	} {
+		resp := send("POST", test.path, test.zone, test.body)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, `{"error":"`+test.message+`"}`, resp.Body.String())
+	}
+	resp = send("GET", "/events?tz=Nowhere", "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
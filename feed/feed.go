@@ -0,0 +1,210 @@
+// Package feed follows the revisions of events and fans the changes out to the streams subscribed to them.
+package feed
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// Change is a change of an event, identified by the ID of its revision
+type Change struct {
+	ID         uint      `json:"id"`
+	Type       string    `json:"type"`
+	EventID    uint      `json:"event_id"`
+	Version    uint      `json:"version"`
+	Actor      string    `json:"actor"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// Event is the event after the change, or when it was deleted, without its overridden occurrences
+	Event *models.Event `json:"event"`
+	// Previous is the event before an update, which streams match too to see events leaving their filter
+	Previous *models.Event `json:"-"`
+}
+
+// Subscription receives the changes read by a feed after it subscribed.
+// C is closed when the subscriber falls too far behind, it then resumes from the last change it received.
+type Subscription struct {
+	C <-chan Change
+	c chan Change
+}
+
+// Feed reads the revisions of events in the order of their IDs and keeps the last of them to replay
+type Feed struct {
+	events repositories.EventRepository
+	size   int
+	// GapTimeout is how long a missing revision ID is waited for, since a revision may commit after
+	// a higher one. Its transaction was rolled back, or the revision is skipped, after that.
+	GapTimeout time.Duration
+	// BatchSize is the most revisions read at once
+	BatchSize int
+
+	mu      sync.Mutex
+	started bool
+	lastID  uint
+	// floor is the ID of the last change dropped from the buffer, later changes can be replayed
+	floor       uint
+	buffer      []Change
+	subscribers map[*Subscription]bool
+}
+
+// errNotStarted is returned by Poll before Start
+var errNotStarted = errors.New("feed not started")
+
+// New creates a feed of the event revisions, replaying up to size changes
+func New(events repositories.EventRepository, size int) *Feed {
+	return &Feed{
+		events:      events,
+		size:        size,
+		GapTimeout:  5 * time.Second,
+		BatchSize:   100,
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+// Start follows the revisions made after the last stored one
+func (f *Feed) Start() error {
+	lastID, err := f.events.LastRevisionID()
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.started, f.lastID, f.floor = true, lastID, lastID
+	return nil
+}
+
+// Poll reads the revisions made since the last poll, sends them to the subscribers
+// and returns how many were read
+func (f *Feed) Poll(now time.Time) (int, error) {
+	f.mu.Lock()
+	started, lastID := f.started, f.lastID
+	f.mu.Unlock()
+	if !started {
+		return 0, errNotStarted
+	}
+
+	read := 0
+	for {
+		revisions, err := f.events.ListRevisionsAfter(lastID, f.BatchSize)
+		if err != nil {
+			return read, err
+		}
+		changes := []Change{}
+		for i := range revisions {
+			// Wait for a missing revision, unless the ones after it are old enough to give up on it
+			revision := &revisions[i]
+			if revision.ID != lastID+1 && now.Sub(revision.CreatedAt) < f.GapTimeout {
+				break
+			}
+			change, err := f.change(revision)
+			if err != nil {
+				return read, err
+			}
+			changes = append(changes, *change)
+			lastID = revision.ID
+		}
+
+		f.publish(changes, lastID)
+		read += len(changes)
+		if len(changes) < f.BatchSize {
+			return read, nil
+		}
+	}
+}
+
+// Describe the change recorded by a revision
+func (f *Feed) change(revision *models.EventRevision) (*Change, error) {
+	change := &Change{
+		ID:         revision.ID,
+		Type:       models.ChangeType(revision.Action),
+		EventID:    revision.EventID,
+		Version:    revision.Version,
+		Actor:      revision.Actor,
+		OccurredAt: revision.CreatedAt,
+	}
+
+	// Revisions do not keep the owner, which never changes, so it is read from the event
+	ownerID, err := f.owner(revision.EventID)
+	if err != nil {
+		return nil, err
+	}
+	change.Event = &models.Event{ID: revision.EventID, OwnerID: ownerID, Version: revision.Version}
+	revision.Snapshot.Apply(change.Event)
+	change.Event.SetInstants()
+	if previous := revision.PreviousSnapshot(); previous != nil && change.Type == models.ChangeEventUpdated {
+		change.Previous = &models.Event{ID: revision.EventID, OwnerID: ownerID}
+		previous.Apply(change.Previous)
+		change.Previous.SetInstants()
+	}
+	return change, nil
+}
+
+// Owner of an event, deleted or not. Purged events have none and only admins see their changes.
+func (f *Feed) owner(eventID uint) (*uint, error) {
+	event, err := f.events.Get(eventID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		event, err = f.events.GetDeleted(eventID)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return event.OwnerID, nil
+}
+
+// Keep the changes for replays and send them to the subscribers, dropping the ones that fell behind
+func (f *Feed) publish(changes []Change, lastID uint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastID = lastID
+	for _, change := range changes {
+		f.buffer = append(f.buffer, change)
+		if len(f.buffer) > f.size {
+			f.floor = f.buffer[0].ID
+			f.buffer = f.buffer[1:]
+		}
+		for sub := range f.subscribers {
+			select {
+			case sub.c <- change:
+			default:
+				delete(f.subscribers, sub)
+				close(sub.c)
+			}
+		}
+	}
+}
+
+// Subscribe to the changes read from now on. With a lastID, the kept changes after it are
+// returned to be replayed first, and ok is false when some of them were already dropped.
+func (f *Feed) Subscribe(lastID *uint) (sub *Subscription, replay []Change, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := make(chan Change, f.size)
+	sub = &Subscription{C: c, c: c}
+	f.subscribers[sub] = true
+
+	if lastID == nil {
+		return sub, nil, true
+	}
+	for _, change := range f.buffer {
+		if change.ID > *lastID {
+			replay = append(replay, change)
+		}
+	}
+	return sub, replay, *lastID >= f.floor
+}
+
+// Unsubscribe stops sending changes to a subscription
+func (f *Feed) Unsubscribe(sub *Subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subscribers[sub] {
+		delete(f.subscribers, sub)
+		close(sub.c)
+	}
+}
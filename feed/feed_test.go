@@ -0,0 +1,130 @@
+package feed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+// Event repository hiding a revision, as if its transaction had not committed yet
+type uncommitted struct {
+	repositories.EventRepository
+	hidden uint
+}
+
+func (r *uncommitted) ListRevisionsAfter(afterID uint, limit int) ([]models.EventRevision, error) {
+	revisions, err := r.EventRepository.ListRevisionsAfter(afterID, limit)
+	kept := []models.EventRevision{}
+	for _, revision := range revisions {
+		if revision.ID != r.hidden {
+			kept = append(kept, revision)
+		}
+	}
+	return kept, err
+}
+
+func TestFeed(t *testing.T) {
+	events, _, _, _, _ := repositories.NewMemoryRepositories()
+	review := models.Event{CalendarID: 1, Title: "Review", EventDate: "2024-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, events.Create(&review, models.Audit{}))
+	f := New(events, 3)
+	assert.NilError(t, f.Start())
+	sub, _, _ := f.Subscribe(nil)
+
+	// Test case 1: changes made before the feed started are not read
+	read, err := f.Poll(time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, 0, read)
+
+	// Test case 2: subscribers receive the changes in order, updates with the event before them
+	review.Title = "Design Review"
+	assert.NilError(t, events.Update(&review, models.Audit{Actor: "alice"}))
+	assert.NilError(t, events.Delete(&review, models.Audit{}))
+	read, err = f.Poll(time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, 2, read)
+	updated := <-sub.C
+	assert.Equal(t, models.ChangeEventUpdated, updated.Type)
+	assert.Equal(t, "alice", updated.Actor)
+	assert.Equal(t, "Design Review", updated.Event.Title)
+	assert.Equal(t, "Review", updated.Previous.Title)
+	assert.Assert(t, updated.Event.StartAt != nil)
+	deleted := <-sub.C
+	assert.Equal(t, models.ChangeEventDeleted, deleted.Type)
+	assert.Equal(t, "Design Review", deleted.Event.Title)
+	assert.Assert(t, deleted.Previous == nil)
+
+	// Test case 3: the kept changes after the last one received are replayed
+	_, replay, ok := f.Subscribe(&updated.ID)
+	assert.Assert(t, ok)
+	assert.Equal(t, 1, len(replay))
+	assert.Equal(t, deleted.ID, replay[0].ID)
+
+	// Test case 4: resuming after dropped changes is not possible
+	for _, title := range []string{"Standup", "Retro", "Planning"} {
+		event := models.Event{CalendarID: 1, Title: title, EventDate: "2024-03-02", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, events.Create(&event, models.Audit{}))
+	}
+	_, err = f.Poll(time.Now())
+	assert.NilError(t, err)
+	_, replay, ok = f.Subscribe(&updated.ID)
+	assert.Assert(t, !ok)
+	assert.Equal(t, 3, len(replay))
+	_, _, ok = f.Subscribe(&deleted.ID)
+	assert.Assert(t, ok)
+
+	// Test case 5: subscribers falling behind are closed
+	for range replay {
+		<-sub.C
+	}
+	for _, title := range []string{"Demo", "Lunch", "Sync", "Wrap-up"} {
+		event := models.Event{CalendarID: 1, Title: title, EventDate: "2024-03-03", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, events.Create(&event, models.Audit{}))
+	}
+	_, err = f.Poll(time.Now())
+	assert.NilError(t, err)
+	received := 0
+	for range sub.C {
+		received++
+	}
+	assert.Equal(t, 3, received)
+}
+
+func TestFeedGap(t *testing.T) {
+	events, _, _, _, _ := repositories.NewMemoryRepositories()
+	hiding := &uncommitted{EventRepository: events}
+	f := New(hiding, 10)
+	assert.NilError(t, f.Start())
+	for _, title := range []string{"Standup", "Retro", "Planning"} {
+		event := models.Event{CalendarID: 1, Title: title, EventDate: "2024-03-02", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, events.Create(&event, models.Audit{}))
+	}
+	hiding.hidden = 2
+
+	// Test case 1: the changes after a missing revision wait for it
+	read, err := f.Poll(time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, 1, read)
+
+	// Test case 2: the missing revision is read once committed
+	hiding.hidden = 0
+	read, err = f.Poll(time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, 2, read)
+
+	// Test case 3: missing revisions are skipped after the gap timeout
+	event := models.Event{CalendarID: 1, Title: "Demo", EventDate: "2024-03-03", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, events.Create(&event, models.Audit{}))
+	event = models.Event{CalendarID: 1, Title: "Lunch", EventDate: "2024-03-04", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, events.Create(&event, models.Audit{}))
+	hiding.hidden = 4
+	read, err = f.Poll(time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, 0, read)
+	read, err = f.Poll(time.Now().Add(f.GapTimeout))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, read)
+}
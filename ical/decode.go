@@ -0,0 +1,361 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Decode reads the first component of an iCalendar document, unfolding its lines
+func Decode(r io.Reader) (*Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var stack []*Component
+	var root *Component
+	for i, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err)
+		}
+		switch prop.Name {
+		case "BEGIN":
+			c := NewComponent(strings.ToUpper(prop.Value))
+			if len(stack) > 0 {
+				stack[len(stack)-1].AddComponent(c)
+			} else if root == nil {
+				root = c
+			} else {
+				return nil, fmt.Errorf("line %d: unexpected BEGIN:%s after the end of the document", i+1, prop.Value)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: property %s outside of a component", i+1, prop.Name)
+			}
+			current := stack[len(stack)-1]
+			current.Properties = append(current.Properties, prop)
+		}
+	}
+	if root == nil {
+		return nil, errors.New("no calendar component found")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// unfold joins continuation lines, which start with a space or a tab, to the line before them
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its name, parameters and value
+func parseLine(line string) (Property, error) {
+	var prop Property
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, errors.New("invalid content line")
+	}
+	prop.Name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		line = line[i+1:]
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return prop, errors.New("invalid property parameter")
+		}
+		param := Param{Name: strings.ToUpper(line[:eq])}
+		line = line[eq+1:]
+
+		if strings.HasPrefix(line, `"`) {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return prop, errors.New("unterminated quoted parameter value")
+			}
+			param.Value = line[1 : end+1]
+			line = line[end+2:]
+			i = 0
+			if line == "" || (line[0] != ';' && line[0] != ':') {
+				return prop, errors.New("invalid property parameter")
+			}
+		} else {
+			i = strings.IndexAny(line, ";:")
+			if i < 0 {
+				return prop, errors.New("invalid content line")
+			}
+			param.Value = line[:i]
+		}
+		prop.Params = append(prop.Params, param)
+	}
+	prop.Value = line[i+1:]
+	return prop, nil
+}
+
+// Get returns the first property with the name, or nil
+func (c *Component) Get(name string) *Property {
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// GetAll returns every property with the name
+func (c *Component) GetAll(name string) []Property {
+	var props []Property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			props = append(props, p)
+		}
+	}
+	return props
+}
+
+// Text returns the unescaped TEXT value of the first property with the name
+func (c *Component) Text(name string) string {
+	if p := c.Get(name); p != nil {
+		return UnescapeText(p.Value)
+	}
+	return ""
+}
+
+// TextList returns the unescaped values of the TEXT lists of every property with the name
+func (c *Component) TextList(name string) []string {
+	var values []string
+	for _, p := range c.GetAll(name) {
+		start := 0
+		for i := 0; i < len(p.Value); i++ {
+			switch p.Value[i] {
+			case '\\':
+				i++
+			case ',':
+				values = append(values, UnescapeText(p.Value[start:i]))
+				start = i + 1
+			}
+		}
+		values = append(values, UnescapeText(p.Value[start:]))
+	}
+	return values
+}
+
+// Children returns the sub-components with the name
+func (c *Component) Children(name string) []*Component {
+	var children []*Component
+	for _, sub := range c.Components {
+		if sub.Name == name {
+			children = append(children, sub)
+		}
+	}
+	return children
+}
+
+// Param returns the value of a parameter, or an empty string
+func (p *Property) Param(name string) string {
+	for _, param := range p.Params {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+// UnescapeText reverses EscapeText
+func UnescapeText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// DateTime parses a DATE or DATE-TIME property.
+// isDate reports a DATE value, which is returned at midnight UTC.
+// Times with a TZID are resolved using the VTIMEZONEs of the document, then the IANA database.
+// Floating times without a time zone are read in the server's time zone.
+func (p *Property) DateTime(zones map[string]*TimeZone) (t time.Time, isDate bool, err error) {
+	times, isDate, err := p.DateTimes(zones)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if len(times) != 1 {
+		return time.Time{}, false, fmt.Errorf("%s must have a single value", p.Name)
+	}
+	return times[0], isDate, nil
+}
+
+// DateTimes parses a property holding a list of DATE or DATE-TIME values such as EXDATE
+func (p *Property) DateTimes(zones map[string]*TimeZone) (times []time.Time, isDate bool, err error) {
+	isDate = p.Param("VALUE") == "DATE"
+	for _, value := range strings.Split(p.Value, ",") {
+		if isDate || len(value) == len(DateFormat) {
+			t, err := time.Parse(DateFormat, value)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid %s date %q", p.Name, value)
+			}
+			times = append(times, t)
+			isDate = true
+			continue
+		}
+		if strings.HasSuffix(value, "Z") {
+			t, err := time.Parse(UTCDateTimeFormat, value)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid %s date-time %q", p.Name, value)
+			}
+			times = append(times, t)
+			continue
+		}
+		local, err := time.Parse(DateTimeFormat, value)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid %s date-time %q", p.Name, value)
+		}
+		t, err := inTimeZone(local, p.Param("TZID"), zones)
+		if err != nil {
+			return nil, false, err
+		}
+		times = append(times, t)
+	}
+	return times, isDate, nil
+}
+
+// inTimeZone reads a wall clock time in the time zone with the identifier
+func inTimeZone(local time.Time, tzid string, zones map[string]*TimeZone) (time.Time, error) {
+	if tzid == "" {
+		return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.Local), nil
+	}
+	if tz, ok := zones[tzid]; ok {
+		return tz.In(local), nil
+	}
+	if offset, ok := parseFixedZoneID(tzid); ok {
+		return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.FixedZone("", offset)), nil
+	}
+	loc, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown time zone %q", tzid)
+	}
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, loc), nil
+}
+
+// parseFixedZoneID reads the offset of a time zone named by FixedZoneID
+func parseFixedZoneID(tzid string) (int, bool) {
+	if tzid == "UTC" {
+		return 0, true
+	}
+	if !strings.HasPrefix(tzid, "UTC") {
+		return 0, false
+	}
+	offset, err := ParseOffset(strings.TrimPrefix(tzid, "UTC"))
+	return offset, err == nil
+}
+
+// ParseOffset reads a UTC offset such as +0700 or -033000 in seconds
+func ParseOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	hours, errH := strconv.Atoi(s[1:3])
+	minutes, errM := strconv.Atoi(s[3:5])
+	seconds := 0
+	var errS error
+	if len(s) == 7 {
+		seconds, errS = strconv.Atoi(s[5:7])
+	}
+	if errH != nil || errM != nil || errS != nil || minutes > 59 || seconds > 59 {
+		return 0, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	offset := hours*3600 + minutes*60 + seconds
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// ParseDuration reads a DURATION value such as P1D, PT1H30M or P2W.
+// Days are returned apart from the exact duration because a day is not always 24 hours long.
+func ParseDuration(s string) (days int, d time.Duration, err error) {
+	invalid := fmt.Errorf("invalid duration %q", s)
+	sign := 1
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = -1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, 0, invalid
+	}
+	s = s[1:]
+
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, 0, invalid
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+		if end <= 0 {
+			return 0, 0, invalid
+		}
+		n, err := strconv.Atoi(s[:end])
+		if err != nil {
+			return 0, 0, invalid
+		}
+		switch unit := s[end]; {
+		case unit == 'W' && !inTime:
+			days += 7 * n
+		case unit == 'D' && !inTime:
+			days += n
+		case unit == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case unit == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case unit == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, 0, invalid
+		}
+		s = s[end+1:]
+	}
+	return sign * days, time.Duration(sign) * d, nil
+}
@@ -0,0 +1,152 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+const newYork = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Eastern Standard Time\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:-0400\r\n" +
+	"TZOFFSETTO:-0500\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=1SU;BYMONTH=11\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:16010101T020000\r\n" +
+	"TZOFFSETFROM:-0500\r\n" +
+	"TZOFFSETTO:-0400\r\n" +
+	"RRULE:FREQ=YEARLY;BYDAY=2SU;BYMONTH=3\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"END:VTIMEZONE\r\n"
+
+func TestDecode(t *testing.T) {
+	doc := newYork +
+		"BEGIN:VEVENT\n" +
+		"UID:abc@example.com\n" +
+		`SUMMARY:Planning\; budget\, Q3\nRoom \\ 2 with a very long title that ` + "\n" +
+		" has been folded\n" +
+		"X-TEST;X-PARAM=\"a:b\";VALUE=TEXT:v:w\n" +
+		"DTSTART;TZID=Eastern Standard Time:20240710T090000\n" +
+		"DTEND;TZID=Eastern Standard Time:20240110T090000\n" +
+		"EXDATE;VALUE=DATE:20240101,20240102\n" +
+		`CATEGORIES:work,budget\, Q3` + "\n" +
+		"CATEGORIES:travel\n" +
+		"END:VEVENT\n" +
+		"END:VCALENDAR\n"
+
+	// Test case 1: components, unfolding, escaping and parameters
+	calendar, err := Decode(strings.NewReader(doc))
+	assert.NilError(t, err)
+	assert.Equal(t, "VCALENDAR", calendar.Name)
+	events := calendar.Children("VEVENT")
+	assert.Equal(t, 1, len(events))
+	event := events[0]
+	assert.Equal(t, "Planning; budget, Q3\nRoom \\ 2 with a very long title that has been folded", event.Text("SUMMARY"))
+	prop := event.Get("X-TEST")
+	assert.Equal(t, "a:b", prop.Param("X-PARAM"))
+	assert.Equal(t, "TEXT", prop.Param("VALUE"))
+	assert.Equal(t, "v:w", prop.Value)
+	assert.DeepEqual(t, []string{"work", "budget, Q3", "travel"}, event.TextList("CATEGORIES"))
+
+	// Test case 2: times in a VTIMEZONE with daylight saving time
+	zones := TimeZones(calendar)
+	start, isDate, err := event.Get("DTSTART").DateTime(zones)
+	assert.NilError(t, err)
+	assert.Assert(t, !isDate)
+	assert.Equal(t, "2024-07-10T09:00:00-04:00", start.Format(time.RFC3339))
+	end, _, err := event.Get("DTEND").DateTime(zones)
+	assert.NilError(t, err)
+	assert.Equal(t, "2024-01-10T09:00:00-05:00", end.Format(time.RFC3339))
+
+	// Test case 3: date lists
+	dates, isDate, err := event.Get("EXDATE").DateTimes(zones)
+	assert.NilError(t, err)
+	assert.Assert(t, isDate)
+	assert.Equal(t, 2, len(dates))
+	assert.Equal(t, "2024-01-02", dates[1].Format("2006-01-02"))
+
+	// Test case 4: round trip through Encode
+	var b strings.Builder
+	assert.NilError(t, calendar.Encode(&b))
+	decoded, err := Decode(strings.NewReader(b.String()))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, calendar, decoded)
+
+	// Test case 5: invalid documents
+	for _, doc := range []string{
+		"",
+		"SUMMARY:outside\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\n",
+		"BEGIN:VCALENDAR\nno colon\nEND:VCALENDAR\n",
+		"BEGIN:VCALENDAR\nX;P=\"open:v\nEND:VCALENDAR\n",
+	} {
+		_, err := Decode(strings.NewReader(doc))
+		assert.Assert(t, err != nil, doc)
+	}
+}
+
+func TestTimeZones(t *testing.T) {
+	calendar, err := Decode(strings.NewReader(newYork + "END:VCALENDAR\r\n"))
+	assert.NilError(t, err)
+	tz := TimeZones(calendar)["Eastern Standard Time"]
+
+	cases := map[string]int{
+		"2024-03-10T01:59:00": -5 * 3600,
+		"2024-03-10T02:00:00": -4 * 3600,
+		"2024-11-03T01:59:00": -4 * 3600,
+		"2024-11-03T02:00:00": -5 * 3600,
+		"2024-12-31T23:00:00": -5 * 3600,
+	}
+	for local, offset := range cases {
+		wall, _ := time.Parse("2006-01-02T15:04:05", local)
+		assert.Equal(t, offset, tz.Offset(wall), local)
+	}
+
+	// Fixed offset and IANA time zones do not need a VTIMEZONE
+	prop := Property{Name: "DTSTART", Params: []Param{{Name: "TZID", Value: "UTC+0700"}}, Value: "20240101T090000"}
+	start, _, err := prop.DateTime(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, "2024-01-01T09:00:00+07:00", start.Format(time.RFC3339))
+	prop = Property{Name: "DTSTART", Params: []Param{{Name: "TZID", Value: "Asia/Bangkok"}}, Value: "20240101T090000"}
+	start, _, err = prop.DateTime(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, "2024-01-01T09:00:00+07:00", start.Format(time.RFC3339))
+	prop = Property{Name: "DTSTART", Value: "20240101T020000Z"}
+	start, _, err = prop.DateTime(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, time.UTC, start.Location())
+	prop = Property{Name: "DTSTART", Params: []Param{{Name: "TZID", Value: "Nowhere"}}, Value: "20240101T090000"}
+	_, _, err = prop.DateTime(nil)
+	assert.Error(t, err, `unknown time zone "Nowhere"`)
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := []struct {
+		value string
+		days  int
+		d     time.Duration
+	}{
+		{"P1D", 1, 0},
+		{"PT1H30M", 0, 90 * time.Minute},
+		{"P2W", 14, 0},
+		{"P1DT12H", 1, 12 * time.Hour},
+		{"-PT15M", 0, -15 * time.Minute},
+	}
+	for _, tc := range cases {
+		days, d, err := ParseDuration(tc.value)
+		assert.NilError(t, err)
+		assert.Equal(t, tc.days, days, tc.value)
+		assert.Equal(t, tc.d, d, tc.value)
+	}
+	for _, value := range []string{"", "P", "PT", "1D", "P1H", "PT1D", "P1DT"} {
+		_, _, err := ParseDuration(value)
+		assert.Assert(t, err != nil, value)
+	}
+}
@@ -0,0 +1,204 @@
+// Package ical reads and writes the subset of RFC 5545 iCalendar used by the API.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Date and date-time value formats
+const (
+	DateFormat        = "20060102"
+	DateTimeFormat    = "20060102T150405"
+	UTCDateTimeFormat = "20060102T150405Z"
+)
+
+// maxLineOctets is the longest content line allowed before folding
+const maxLineOctets = 75
+
+// Component is a calendar component such as VCALENDAR, VEVENT or VTIMEZONE
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []*Component
+}
+
+// Property is a content line of a component
+type Property struct {
+	Name   string
+	Params []Param
+	Value  string
+}
+
+// Param is a property parameter such as TZID or VALUE
+type Param struct {
+	Name  string
+	Value string
+}
+
+// NewComponent creates an empty component
+func NewComponent(name string) *Component {
+	return &Component{Name: name}
+}
+
+// Add appends a property with a raw value
+func (c *Component) Add(name, value string, params ...Param) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// AddText appends a property with a TEXT value, escaping it
+func (c *Component) AddText(name, value string, params ...Param) {
+	c.Add(name, EscapeText(value), params...)
+}
+
+// AddTextList appends a property with a list of TEXT values such as CATEGORIES, escaping each of them
+func (c *Component) AddTextList(name string, values []string, params ...Param) {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = EscapeText(value)
+	}
+	c.Add(name, strings.Join(escaped, ","), params...)
+}
+
+// AddComponent appends a sub-component
+func (c *Component) AddComponent(sub *Component) {
+	c.Components = append(c.Components, sub)
+}
+
+// Encode writes the component with CRLF line endings and folded lines
+func (c *Component) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	c.encode(bw)
+	return bw.Flush()
+}
+
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		writeLine(w, p.String())
+	}
+	for _, sub := range c.Components {
+		sub.encode(w)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+// String formats the property as an unfolded content line
+func (p Property) String() string {
+	var b strings.Builder
+	b.WriteString(p.Name)
+	for _, param := range p.Params {
+		b.WriteString(";")
+		b.WriteString(param.Name)
+		b.WriteString("=")
+		if strings.ContainsAny(param.Value, ":;,") {
+			b.WriteString(`"` + param.Value + `"`)
+		} else {
+			b.WriteString(param.Value)
+		}
+	}
+	b.WriteString(":")
+	b.WriteString(p.Value)
+	return b.String()
+}
+
+// writeLine folds a content line at 75 octets without splitting UTF-8 characters
+func writeLine(w *bufio.Writer, line string) {
+	length := 0
+	for _, r := range line {
+		size := len(string(r))
+		if length+size > maxLineOctets {
+			w.WriteString("\r\n ")
+			length = 1
+		}
+		w.WriteRune(r)
+		length += size
+	}
+	w.WriteString("\r\n")
+}
+
+// EscapeText escapes a TEXT value
+func EscapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// FormatOffset formats a UTC offset in seconds as +hhmm
+func FormatOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset%3600/60)
+}
+
+// FixedZoneID names the time zone of a fixed UTC offset, such as UTC+0700
+func FixedZoneID(offset int) string {
+	if offset == 0 {
+		return "UTC"
+	}
+	return "UTC" + FormatOffset(offset)
+}
+
+// FixedTimeZone builds a VTIMEZONE for a fixed UTC offset without daylight saving time
+func FixedTimeZone(offset int) *Component {
+	standard := NewComponent("STANDARD")
+	standard.Add("DTSTART", "19700101T000000")
+	standard.Add("TZOFFSETFROM", FormatOffset(offset))
+	standard.Add("TZOFFSETTO", FormatOffset(offset))
+	standard.Add("TZNAME", FixedZoneID(offset))
+
+	tz := NewComponent("VTIMEZONE")
+	tz.Add("TZID", FixedZoneID(offset))
+	tz.AddComponent(standard)
+	return tz
+}
+
+// LocationTimeZone builds a VTIMEZONE for a time zone of the IANA database, named by its TZID.
+// Each change of offset between from and to is written as an observance of its own,
+// the first one being the period in effect at from.
+func LocationTimeZone(loc *time.Location, from, to time.Time) *Component {
+	tz := NewComponent("VTIMEZONE")
+	tz.Add("TZID", loc.String())
+	t := from.In(loc)
+	for {
+		start, end := t.ZoneBounds()
+		name, offset := t.Zone()
+		offsetFrom := offset
+		if start.IsZero() {
+			start = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+		} else {
+			_, offsetFrom = start.Add(-time.Second).Zone()
+		}
+
+		kind := "STANDARD"
+		if t.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		observance := NewComponent(kind)
+		observance.Add("DTSTART", start.In(time.FixedZone("", offsetFrom)).Format(DateTimeFormat))
+		observance.Add("TZOFFSETFROM", FormatOffset(offsetFrom))
+		observance.Add("TZOFFSETTO", FormatOffset(offset))
+		observance.Add("TZNAME", name)
+		tz.AddComponent(observance)
+
+		if end.IsZero() || end.After(to) {
+			return tz
+		}
+		t = end
+	}
+}
+
+// FormatUTC formats an instant as a UTC date-time
+func FormatUTC(t time.Time) string {
+	return t.UTC().Format(UTCDateTimeFormat)
+}
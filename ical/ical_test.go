@@ -0,0 +1,77 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestEncode(t *testing.T) {
+	event := NewComponent("VEVENT")
+	event.Add("UID", "event-1@aimet-test")
+	event.AddText("SUMMARY", "Planning; budget, Q3\nRoom \\ 2")
+	event.Add("DTSTART", "20240101T150000", Param{Name: "TZID", Value: "UTC+0700"})
+	event.Add("X-TEST", "v", Param{Name: "X-PARAM", Value: "a:b"})
+	event.AddText("DESCRIPTION", strings.Repeat("ก", 40))
+	event.AddTextList("CATEGORIES", []string{"work", "budget, Q3"})
+
+	calendar := NewComponent("VCALENDAR")
+	calendar.Add("VERSION", "2.0")
+	calendar.AddComponent(FixedTimeZone(7 * 3600))
+	calendar.AddComponent(event)
+
+	var buf bytes.Buffer
+	assert.NilError(t, calendar.Encode(&buf))
+	out := buf.String()
+
+	// Test case 1: structure and escaping
+	assert.Assert(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTIMEZONE\r\nTZID:UTC+0700\r\n"))
+	assert.Assert(t, strings.HasSuffix(out, "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	assert.Assert(t, strings.Contains(out, "\r\nSUMMARY:"+`Planning\; budget\, Q3\nRoom \\ 2`+"\r\n"))
+	assert.Assert(t, strings.Contains(out, "\r\nTZOFFSETTO:+0700\r\n"))
+	assert.Assert(t, strings.Contains(out, "\r\nDTSTART;TZID=UTC+0700:20240101T150000\r\n"))
+	assert.Assert(t, strings.Contains(out, "\r\nX-TEST;X-PARAM=\"a:b\":v\r\n"))
+	assert.Assert(t, strings.Contains(out, "\r\nCATEGORIES:work,budget\\, Q3\r\n"))
+
+	// Test case 2: long lines are folded at 75 octets without splitting characters
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		assert.Assert(t, len(line) <= 75, line)
+		assert.Assert(t, !strings.ContainsRune(line, '�'), line)
+	}
+	assert.Assert(t, strings.Contains(out, "\r\nDESCRIPTION:"+strings.Repeat("ก", 21)+"\r\n "+strings.Repeat("ก", 19)+"\r\n"))
+}
+
+func TestFixedZoneID(t *testing.T) {
+	assert.Equal(t, "UTC", FixedZoneID(0))
+	assert.Equal(t, "UTC+0530", FixedZoneID(5*3600+30*60))
+	assert.Equal(t, "UTC-0330", FixedZoneID(-(3*3600 + 30*60)))
+}
+
+func TestLocationTimeZone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NilError(t, err)
+	vtimezone := LocationTimeZone(berlin, time.Date(2024, 1, 1, 0, 0, 0, 0, berlin), time.Date(2024, 12, 31, 0, 0, 0, 0, berlin))
+
+	// Test case 1: winter time, summer time from March 31 and winter time again from October 27
+	var kinds []string
+	for _, observance := range vtimezone.Components {
+		kinds = append(kinds, observance.Name+" "+observance.Get("DTSTART").Value+" "+observance.Get("TZOFFSETTO").Value)
+	}
+	assert.DeepEqual(t, []string{
+		"STANDARD 20231029T030000 +0100",
+		"DAYLIGHT 20240331T020000 +0200",
+		"STANDARD 20241027T030000 +0100",
+	}, kinds)
+
+	// Test case 2: the decoder reads the offsets back
+	calendar := NewComponent("VCALENDAR")
+	calendar.AddComponent(vtimezone)
+	tz := TimeZones(calendar)["Europe/Berlin"]
+	assert.Assert(t, tz != nil)
+	assert.Equal(t, 3600, tz.Offset(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 7200, tz.Offset(time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 3600, tz.Offset(time.Date(2024, 11, 1, 9, 0, 0, 0, time.UTC)))
+}
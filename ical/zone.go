@@ -0,0 +1,169 @@
+package ical
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeZone is a time zone defined by a VTIMEZONE component
+type TimeZone struct {
+	ID          string
+	observances []observance
+}
+
+// observance is a STANDARD or DAYLIGHT period of a VTIMEZONE
+type observance struct {
+	start      time.Time
+	offsetFrom int
+	offsetTo   int
+	// Yearly rule, a zero month means the observance starts once at start
+	month    time.Month
+	monthDay int
+	week     int
+	weekday  time.Weekday
+}
+
+// TimeZones reads the VTIMEZONE components of a calendar by TZID
+func TimeZones(calendar *Component) map[string]*TimeZone {
+	zones := map[string]*TimeZone{}
+	for _, vtimezone := range calendar.Children("VTIMEZONE") {
+		tzid := vtimezone.Get("TZID")
+		if tzid == nil {
+			continue
+		}
+		tz := &TimeZone{ID: tzid.Value}
+		for _, sub := range vtimezone.Components {
+			if sub.Name != "STANDARD" && sub.Name != "DAYLIGHT" {
+				continue
+			}
+			if o, ok := parseObservance(sub); ok {
+				tz.observances = append(tz.observances, o)
+			}
+		}
+		if len(tz.observances) > 0 {
+			zones[tz.ID] = tz
+		}
+	}
+	return zones
+}
+
+// parseObservance reads the onset and offsets of an observance.
+// Only the yearly rules used by common calendar applications are understood.
+func parseObservance(c *Component) (observance, bool) {
+	var o observance
+	dtstart, from, to := c.Get("DTSTART"), c.Get("TZOFFSETFROM"), c.Get("TZOFFSETTO")
+	if dtstart == nil || from == nil || to == nil {
+		return o, false
+	}
+	var err error
+	if o.start, err = time.Parse(DateTimeFormat, dtstart.Value); err != nil {
+		return o, false
+	}
+	if o.offsetFrom, err = ParseOffset(from.Value); err != nil {
+		return o, false
+	}
+	if o.offsetTo, err = ParseOffset(to.Value); err != nil {
+		return o, false
+	}
+
+	rrule := c.Get("RRULE")
+	if rrule == nil {
+		return o, true
+	}
+	for _, part := range strings.Split(rrule.Value, ";") {
+		name, value, _ := strings.Cut(part, "=")
+		switch name {
+		case "BYMONTH":
+			month, err := strconv.Atoi(value)
+			if err != nil || month < 1 || month > 12 {
+				return o, false
+			}
+			o.month = time.Month(month)
+		case "BYMONTHDAY":
+			if o.monthDay, err = strconv.Atoi(value); err != nil {
+				return o, false
+			}
+		case "BYDAY":
+			if len(value) < 2 {
+				return o, false
+			}
+			code := value[len(value)-2:]
+			weekday, ok := map[string]time.Weekday{"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday,
+				"WE": time.Wednesday, "TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday}[code]
+			if !ok {
+				return o, false
+			}
+			o.weekday = weekday
+			if o.week, err = strconv.Atoi(strings.TrimSuffix(value, code)); err != nil {
+				return o, false
+			}
+		}
+	}
+	if o.month == 0 {
+		o.month = o.start.Month()
+	}
+	if o.monthDay == 0 && o.week == 0 {
+		o.monthDay = o.start.Day()
+	}
+	return o, true
+}
+
+// onset returns the wall clock time the observance starts in a year
+func (o *observance) onset(year int) (time.Time, bool) {
+	switch {
+	case o.month == 0:
+		return o.start, true
+	case year < o.start.Year():
+		return time.Time{}, false
+	case year == o.start.Year():
+		return o.start, true
+	}
+
+	hour, min, sec := o.start.Clock()
+	if o.week == 0 {
+		return time.Date(year, o.month, o.monthDay, hour, min, sec, 0, time.UTC), true
+	}
+	if o.week > 0 {
+		first := time.Date(year, o.month, 1, hour, min, sec, 0, time.UTC)
+		shift := (int(o.weekday) - int(first.Weekday()) + 7) % 7
+		return first.AddDate(0, 0, shift+7*(o.week-1)), true
+	}
+	last := time.Date(year, o.month+1, 0, hour, min, sec, 0, time.UTC)
+	shift := (int(last.Weekday()) - int(o.weekday) + 7) % 7
+	return last.AddDate(0, 0, -shift+7*(o.week+1)), true
+}
+
+// Offset returns the UTC offset in effect at a wall clock time
+func (tz *TimeZone) Offset(local time.Time) int {
+	type onset struct {
+		at     time.Time
+		offset int
+	}
+	var onsets []onset
+	for i := range tz.observances {
+		o := &tz.observances[i]
+		for _, year := range []int{local.Year() - 1, local.Year()} {
+			if at, ok := o.onset(year); ok {
+				onsets = append(onsets, onset{at, o.offsetTo})
+			}
+		}
+	}
+	sort.Slice(onsets, func(i, j int) bool { return onsets[i].at.Before(onsets[j].at) })
+
+	wall := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.UTC)
+	offset := tz.observances[0].offsetFrom
+	for _, o := range onsets {
+		if o.at.After(wall) {
+			break
+		}
+		offset = o.offset
+	}
+	return offset
+}
+
+// In reads a wall clock time in the time zone
+func (tz *TimeZone) In(local time.Time) time.Time {
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), 0, time.FixedZone(tz.ID, tz.Offset(local)))
+}
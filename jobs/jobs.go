@@ -0,0 +1,34 @@
+// Package jobs runs the background work of the server.
+package jobs
+
+import "time"
+
+// Every runs fn now and then at each interval until the returned stop function is called
+func Every(interval time.Duration, fn func()) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		fn()
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// Clock of a batch run at now, moving on with the time the batch has taken so far,
+// so that the work done late in a batch is not timed as if it was done at its start
+func batchClock(now time.Time) func() time.Time {
+	started := time.Now()
+	return func() time.Time {
+		return now.Add(time.Since(started))
+	}
+}
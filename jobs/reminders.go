@@ -0,0 +1,241 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/notify"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// ReminderScheduler fires the due reminders of events and sends their notifications, retrying the
+// failed ones with exponential backoff. Schedulers of several instances share the work: each occurrence
+// is fired by one of them only, and each firing is sent by the one claiming it. Firings are only sent
+// to users who can still view the event, whose grants may have been revoked or whose event may have
+// moved to another calendar since they set the reminder.
+type ReminderScheduler struct {
+	Reminders repositories.ReminderRepository
+	Events    repositories.EventRepository
+	Users     repositories.UserRepository
+	Calendars repositories.CalendarRepository
+	Grants    repositories.GrantRepository
+	Notifiers notify.Notifiers
+	// Backoff is the wait after the first failed attempt, which doubles after each following one
+	Backoff time.Duration
+	// MaxAttempts is the number of attempts after which a firing fails
+	MaxAttempts int
+	// Lease is how long a claimed firing is kept from the other schedulers while it is sent
+	Lease time.Duration
+	// Timeout is how long sending a notification may take, well under the lease so that its outcome
+	// is saved before another scheduler can claim the firing again
+	Timeout time.Duration
+	// BatchSize is the most reminders fired, and firings sent, by one call to Run
+	BatchSize int
+}
+
+// NewReminderScheduler sends the reminders of the repository through the notifiers with a timeout of 10 seconds,
+// attempting each firing up to 5 times and waiting from 30 seconds to 4 minutes between the attempts
+func NewReminderScheduler(reminders repositories.ReminderRepository, events repositories.EventRepository, users repositories.UserRepository,
+	calendars repositories.CalendarRepository, grants repositories.GrantRepository, notifiers notify.Notifiers) *ReminderScheduler {
+	return &ReminderScheduler{
+		Reminders:   reminders,
+		Events:      events,
+		Users:       users,
+		Calendars:   calendars,
+		Grants:      grants,
+		Notifiers:   notifiers,
+		Backoff:     30 * time.Second,
+		MaxAttempts: 5,
+		Lease:       time.Minute,
+		Timeout:     10 * time.Second,
+		BatchSize:   100,
+	}
+}
+
+// Run fires the reminders due at now, sends the firings due at now and returns how many were sent
+func (s *ReminderScheduler) Run(now time.Time) (int, error) {
+	if err := s.fireDue(now); err != nil {
+		return 0, err
+	}
+	return s.sendDue(now)
+}
+
+// Queue a firing for each due reminder and move the reminder to the next occurrence of its event
+func (s *ReminderScheduler) fireDue(now time.Time) error {
+	reminders, err := s.Reminders.DueReminders(now, s.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for i := range reminders {
+		reminder := &reminders[i]
+		firing := &models.ReminderFiring{
+			ReminderID:    reminder.ID,
+			EventID:       reminder.EventID,
+			OccurrenceAt:  *reminder.OccurrenceAt,
+			FireAt:        *reminder.NextFireAt,
+			Status:        models.FiringPending,
+			NextAttemptAt: &now,
+		}
+
+		// Occurrences are skipped once they started, after the scheduler did not run for a while
+		next := *reminder
+		after := firing.OccurrenceAt
+		if now.After(after) {
+			after = now
+			firing.Status, firing.NextAttemptAt = models.FiringCanceled, nil
+			firing.Error = "Occurrence started before the reminder fired"
+		}
+		event, err := s.Events.Get(reminder.EventID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			next.NextFireAt, next.OccurrenceAt = nil, nil
+			firing.Status, firing.NextAttemptAt = models.FiringCanceled, nil
+			firing.Error = "Event was deleted"
+		} else if err != nil {
+			return err
+		} else {
+			next.Schedule(event, after)
+		}
+
+		// Another scheduler may have fired the occurrence, or the event changed, since it was listed
+		if _, err := s.Reminders.Fire(&next, firing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send the notifications of the due firings. Each firing is claimed at the time it is reached in the batch,
+// and its outcome is only saved while the claim holds.
+func (s *ReminderScheduler) sendDue(now time.Time) (int, error) {
+	firings, err := s.Reminders.DueFirings(now, s.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	clock := batchClock(now)
+	sent := 0
+	for i := range firings {
+		firing := &firings[i]
+		claimedAt := clock()
+		claimed, err := s.Reminders.ClaimFiring(firing, claimedAt, claimedAt.Add(s.Lease))
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+		lease := *firing.NextAttemptAt
+		reminder, err := s.Reminders.Get(firing.EventID, firing.ReminderID)
+		if errors.Is(err, repositories.ErrNotFound) {
+			// The reminder was deleted with its firings
+			continue
+		}
+		if err != nil {
+			return sent, err
+		}
+		event, err := s.Events.Get(firing.EventID)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return sent, err
+		}
+
+		if event == nil || !event.HasOccurrence(firing.OccurrenceAt) {
+			// The event was deleted or moved since the firing was queued
+			firing.Status, firing.NextAttemptAt = models.FiringCanceled, nil
+			firing.Error = "Occurrence no longer takes place"
+		} else if viewer, err := s.canView(reminder.UserID, event); err != nil {
+			return sent, err
+		} else if !viewer {
+			firing.Status, firing.NextAttemptAt = models.FiringCanceled, nil
+			firing.Error = "User can no longer view the event"
+		} else {
+			s.attempt(reminder, event, firing, clock)
+		}
+		saved, err := s.Reminders.SaveFiring(firing, lease)
+		if err != nil {
+			return sent, err
+		}
+		if saved && firing.Status == models.FiringSent {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// Check whether a user still has the viewer role on an event, which deleted users do not
+func (s *ReminderScheduler) canView(userID uint, event *models.Event) (bool, error) {
+	user, err := s.Users.Get(userID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if user.Admin {
+		return true, nil
+	}
+
+	calendars, err := s.Calendars.List()
+	if err != nil {
+		return false, err
+	}
+	grants, err := s.Grants.ListForUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+	return models.NewRoles(user, calendars, grants).EventRole(event).Includes(models.RoleViewer), nil
+}
+
+// Notify the user of a firing and update it with the outcome of the attempt
+func (s *ReminderScheduler) attempt(reminder *models.Reminder, event *models.Event, firing *models.ReminderFiring, clock func() time.Time) {
+	firing.Attempts++
+	firing.Error = ""
+
+	err := s.notify(reminder, event, firing)
+	now := clock()
+	if err != nil {
+		firing.Error = err.Error()
+	} else {
+		firing.Status = models.FiringSent
+		firing.SentAt = &now
+		firing.NextAttemptAt = nil
+		return
+	}
+
+	if firing.Attempts >= s.MaxAttempts {
+		firing.Status = models.FiringFailed
+		firing.NextAttemptAt = nil
+		return
+	}
+	next := now.Add(s.Backoff << (firing.Attempts - 1))
+	firing.NextAttemptAt = &next
+}
+
+// Send the notification of a firing through the notifier of its reminder's method,
+// giving up after the timeout
+func (s *ReminderScheduler) notify(reminder *models.Reminder, event *models.Event, firing *models.ReminderFiring) error {
+	notifier, ok := s.Notifiers[reminder.Method]
+	if !ok {
+		return fmt.Errorf("Reminder method %s is not configured", reminder.Method)
+	}
+	zone := event.Zone()
+	if zone == nil {
+		zone = event.GetStartAt().Location()
+	}
+	event.SetInstants()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+	return notifier.Notify(ctx, &notify.Notification{
+		FiringID:     firing.ID,
+		ReminderID:   reminder.ID,
+		UserID:       reminder.UserID,
+		Email:        reminder.Email,
+		Event:        event,
+		OccurrenceAt: firing.OccurrenceAt.In(zone),
+		FireAt:       firing.FireAt,
+	})
+}
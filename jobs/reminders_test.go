@@ -0,0 +1,215 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/notify"
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+// Notifier keeping the notifications it receives after the delay, failing while err is set.
+// It calls during, when set, while it sends each notification.
+type stubNotifier struct {
+	mu     sync.Mutex
+	sent   []notify.Notification
+	err    error
+	delay  time.Duration
+	during func(n *notify.Notification)
+}
+
+func (s *stubNotifier) Notify(ctx context.Context, n *notify.Notification) error {
+	time.Sleep(s.delay)
+	if s.during != nil {
+		s.during(n)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, *n)
+	return nil
+}
+
+// Check that a time of a job is at most a second after the time its batch was run at, plus the wait
+func assertShortlyAfter(t *testing.T, expected, actual time.Time) {
+	t.Helper()
+	assert.Assert(t, !actual.Before(expected) && actual.Before(expected.Add(time.Second)), "%s is not shortly after %s", actual, expected)
+}
+
+func TestReminderScheduler(t *testing.T) {
+	events, calendars, grants, _, reminders := repositories.NewMemoryRepositories()
+	users := repositories.NewMemoryUserRepository()
+	stub := &stubNotifier{}
+	notifiers := notify.Notifiers{models.ReminderLog: stub}
+	scheduler := NewReminderScheduler(reminders, events, users, calendars, grants, notifiers)
+	scheduler.Backoff = time.Minute
+	scheduler.MaxAttempts = 2
+	other := NewReminderScheduler(reminders, events, users, calendars, grants, notifiers)
+	minutes := 10
+	created := time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	// The reminders are set by a viewer of the default calendar
+	user := models.User{Name: "alice"}
+	assert.NilError(t, users.Create(&user))
+	shared, err := calendars.GetDefault()
+	assert.NilError(t, err)
+	grant := models.Grant{CalendarID: &shared.ID, UserID: user.ID, Role: models.RoleViewer}
+	assert.NilError(t, grants.Save(&grant))
+
+	event := models.Event{CalendarID: 1, Title: "Review", EventDate: "2099-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, events.Create(&event, models.Audit{}))
+	reminder := models.Reminder{EventID: event.ID, UserID: user.ID, MinutesBefore: &minutes, Method: models.ReminderLog}
+	reminder.Schedule(&event, created)
+	assert.NilError(t, reminders.Create(&reminder))
+	fireAt := time.Date(2099, 3, 1, 1, 50, 0, 0, time.UTC)
+
+	// Test case 1: reminders do not fire before their time
+	sent, err := scheduler.Run(fireAt.Add(-time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// Test case 2: reminders fire once, however many schedulers run
+	sent, err = scheduler.Run(fireAt)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, sent)
+	sent, err = other.Run(fireAt.Add(time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Equal(t, 1, len(stub.sent))
+	assert.Equal(t, "Review starts at 2099-03-01 09:00 +07:00", stub.sent[0].Subject())
+	firings, err := reminders.ListFirings(reminder.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(firings))
+	assert.Equal(t, models.FiringSent, firings[0].Status)
+
+	// Test case 3: reminders follow their event when it is rescheduled
+	event.StartTime, event.EndTime = "11:00:00+07", "12:00:00+07"
+	assert.NilError(t, events.Update(&event, models.Audit{}))
+	movedAt := fireAt.Add(2 * time.Hour)
+	sent, err = scheduler.Run(movedAt.Add(-time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+	sent, err = scheduler.Run(movedAt)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, "Review starts at 2099-03-01 11:00 +07:00", stub.sent[1].Subject())
+
+	// Test case 4: failed notifications are retried after a backoff
+	weekly := models.Event{CalendarID: 1, Title: "Standup", EventDate: "2099-03-02", StartTime: "09:00:00+07", EndTime: "09:15:00+07", RRule: "FREQ=WEEKLY"}
+	assert.NilError(t, events.Create(&weekly, models.Audit{}))
+	days := 1
+	daily := models.Reminder{EventID: weekly.ID, UserID: user.ID, DaysBefore: &days, At: "18:00", Method: models.ReminderLog}
+	daily.Schedule(&weekly, created)
+	assert.NilError(t, reminders.Create(&daily))
+	assert.Assert(t, daily.NextFireAt.Equal(time.Date(2099, 3, 1, 11, 0, 0, 0, time.UTC)))
+	stub.err = errors.New("unavailable")
+	sent, err = scheduler.Run(*daily.NextFireAt)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+	firings, err = reminders.ListFirings(daily.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, firings[0].Attempts)
+	assert.Equal(t, "unavailable", firings[0].Error)
+	assertShortlyAfter(t, daily.NextFireAt.Add(time.Minute), *firings[0].NextAttemptAt)
+
+	// Test case 5: recurring reminders move on to the next occurrence once fired
+	stored, err := reminders.Get(weekly.ID, daily.ID)
+	assert.NilError(t, err)
+	assert.Assert(t, stored.OccurrenceAt.Equal(time.Date(2099, 3, 9, 2, 0, 0, 0, time.UTC)))
+
+	// Test case 6: firings of deleted events are canceled instead of retried
+	assert.NilError(t, events.Delete(&weekly, models.Audit{}))
+	stub.err = nil
+	sent, err = scheduler.Run(daily.NextFireAt.Add(2 * time.Minute))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+	firings, err = reminders.ListFirings(daily.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(firings))
+	assert.Equal(t, models.FiringCanceled, firings[0].Status)
+	stored, err = reminders.Get(weekly.ID, daily.ID)
+	assert.NilError(t, err)
+	assert.Assert(t, stored.NextFireAt == nil)
+	assert.Equal(t, 2, len(stub.sent))
+
+	// Test case 7: occurrences that started while no scheduler ran are skipped
+	late := models.Event{CalendarID: 1, Title: "Retro", EventDate: "2099-03-03", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, events.Create(&late, models.Audit{}))
+	missed := models.Reminder{EventID: late.ID, UserID: user.ID, MinutesBefore: &minutes, Method: models.ReminderLog}
+	missed.Schedule(&late, created)
+	assert.NilError(t, reminders.Create(&missed))
+	sent, err = scheduler.Run(time.Date(2099, 3, 3, 3, 0, 0, 0, time.UTC))
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+	firings, err = reminders.ListFirings(missed.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, models.FiringCanceled, firings[0].Status)
+
+	// Test case 8: firings are canceled once the event moved to a calendar the user cannot view
+	private := models.Calendar{Name: "Private"}
+	assert.NilError(t, calendars.Create(&private))
+	moved := models.Event{CalendarID: shared.ID, Title: "Planning", EventDate: "2099-03-04", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, events.Create(&moved, models.Audit{}))
+	hidden := models.Reminder{EventID: moved.ID, UserID: user.ID, MinutesBefore: &minutes, Method: models.ReminderLog}
+	hidden.Schedule(&moved, created)
+	assert.NilError(t, reminders.Create(&hidden))
+	moved.CalendarID = private.ID
+	assert.NilError(t, events.Update(&moved, models.Audit{}))
+	sent, err = scheduler.Run(*hidden.NextFireAt)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+	firings, err = reminders.ListFirings(hidden.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, models.FiringCanceled, firings[0].Status)
+	assert.Equal(t, "User can no longer view the event", firings[0].Error)
+
+	// Test case 9: firings are canceled once the grant of the user is revoked
+	revoked := models.Event{CalendarID: shared.ID, Title: "Demo", EventDate: "2099-03-05", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, events.Create(&revoked, models.Audit{}))
+	demo := models.Reminder{EventID: revoked.ID, UserID: user.ID, MinutesBefore: &minutes, Method: models.ReminderLog}
+	demo.Schedule(&revoked, created)
+	assert.NilError(t, reminders.Create(&demo))
+	assert.NilError(t, grants.Delete(&grant))
+	sent, err = scheduler.Run(*demo.NextFireAt)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+	firings, err = reminders.ListFirings(demo.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, models.FiringCanceled, firings[0].Status)
+	assert.Equal(t, 2, len(stub.sent))
+
+	// Test case 10: firings reached late in a slow batch are claimed for a whole lease from then
+	grant.ID = 0
+	assert.NilError(t, grants.Save(&grant))
+	scheduler.Lease = 150 * time.Millisecond
+	stub.delay = 100 * time.Millisecond
+	var slow []models.Reminder
+	for _, title := range []string{"Sync", "Wrap-up"} {
+		event := models.Event{CalendarID: shared.ID, Title: title, EventDate: "2099-03-06", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, events.Create(&event, models.Audit{}))
+		reminder := models.Reminder{EventID: event.ID, UserID: user.ID, MinutesBefore: &minutes, Method: models.ReminderLog}
+		reminder.Schedule(&event, created)
+		assert.NilError(t, reminders.Create(&reminder))
+		slow = append(slow, reminder)
+	}
+	// The other scheduler runs while the last firing is sent, after the lease of a claim made at the start of the batch
+	otherSent := -1
+	stub.during = func(n *notify.Notification) {
+		if n.ReminderID == slow[1].ID && otherSent < 0 {
+			otherSent, err = other.Run(slow[0].NextFireAt.Add(200 * time.Millisecond))
+			assert.NilError(t, err)
+		}
+	}
+	sent, err = scheduler.Run(*slow[0].NextFireAt)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, 0, otherSent)
+	assert.Equal(t, 4, len(stub.sent))
+}
@@ -0,0 +1,16 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// PurgeTrash permanently removes the events deleted more than retention before now.
+// A retention of zero keeps deleted events until they are purged through the API.
+func PurgeTrash(events repositories.EventRepository, retention time.Duration, now time.Time) (int64, error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	return events.Purge(repositories.TrashFilter{DeletedBefore: now.Add(-retention)})
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	events, _, _, _, _ := repositories.NewMemoryRepositories()
+	kept := models.Event{CalendarID: 1, Title: "Kept", EventDate: "2024-03-01", StartTime: "15:00:00+07", EndTime: "16:00:00+07"}
+	deleted := models.Event{CalendarID: 1, Title: "Deleted", EventDate: "2024-03-02", StartTime: "15:00:00+07", EndTime: "16:00:00+07"}
+	assert.NilError(t, events.Create(&kept, models.Audit{}))
+	assert.NilError(t, events.Create(&deleted, models.Audit{}))
+	assert.NilError(t, events.Delete(&deleted, models.Audit{}))
+
+	// Deleted events are kept during the retention period
+	purged, err := PurgeTrash(events, time.Hour, time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, int64(0), purged)
+
+	// A retention of zero turns purging off
+	purged, err = PurgeTrash(events, 0, time.Now().Add(2*time.Hour))
+	assert.NilError(t, err)
+	assert.Equal(t, int64(0), purged)
+	_, err = events.GetDeleted(deleted.ID)
+	assert.NilError(t, err)
+
+	purged, err = PurgeTrash(events, time.Hour, time.Now().Add(2*time.Hour))
+	assert.NilError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = events.GetDeleted(deleted.ID)
+	assert.Equal(t, repositories.ErrNotFound, err)
+	_, err = events.Get(kept.ID)
+	assert.NilError(t, err)
+}
@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+// WebhookSender POSTs the due deliveries of webhooks, retrying the failed ones with exponential backoff
+type WebhookSender struct {
+	Webhooks repositories.WebhookRepository
+	Client   *http.Client
+	// Backoff is the wait after the first failed attempt, which doubles after each following one
+	Backoff time.Duration
+	// MaxAttempts is the number of attempts after which a delivery fails
+	MaxAttempts int
+	// Lease is how long a claimed delivery is kept from the other senders while it is sent
+	Lease time.Duration
+	// BatchSize is the most deliveries sent by one call to SendDue
+	BatchSize int
+}
+
+// NewWebhookSender sends the deliveries of the repository with a timeout of 10 seconds, attempting
+// each of them up to 8 times and waiting from 30 seconds to 32 minutes between the attempts
+func NewWebhookSender(webhooks repositories.WebhookRepository) *WebhookSender {
+	return &WebhookSender{
+		Webhooks:    webhooks,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Backoff:     30 * time.Second,
+		MaxAttempts: 8,
+		Lease:       time.Minute,
+		BatchSize:   100,
+	}
+}
+
+// SendDue attempts the deliveries due at now and returns how many of them succeeded. Each delivery is
+// claimed and attempted at the time it is reached in the batch, and its outcome is only saved while the claim holds.
+func (s *WebhookSender) SendDue(now time.Time) (int, error) {
+	deliveries, err := s.Webhooks.DueDeliveries(now, s.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	clock := batchClock(now)
+	succeeded := 0
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		// Another sender may have claimed the delivery since it was listed
+		claimedAt := clock()
+		claimed, err := s.Webhooks.ClaimDelivery(delivery, claimedAt, claimedAt.Add(s.Lease))
+		if err != nil {
+			return succeeded, err
+		}
+		if !claimed {
+			continue
+		}
+		lease := *delivery.NextAttemptAt
+		webhook, err := s.Webhooks.Get(delivery.WebhookID)
+		if err != nil {
+			return succeeded, err
+		}
+
+		s.attempt(webhook, delivery, clock)
+		saved, err := s.Webhooks.SaveDelivery(delivery, lease)
+		if err != nil {
+			return succeeded, err
+		}
+		if saved && delivery.Status == models.DeliverySucceeded {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+// Send the payload of a delivery and update it with the outcome of the attempt
+func (s *WebhookSender) attempt(webhook *models.Webhook, delivery *models.WebhookDelivery, clock func() time.Time) {
+	attemptedAt := clock()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &attemptedAt
+	delivery.ResponseStatus = 0
+	delivery.Error = ""
+
+	err := s.post(webhook, delivery, attemptedAt)
+	now := clock()
+	if err != nil {
+		delivery.Error = err.Error()
+	} else if delivery.ResponseStatus < 200 || delivery.ResponseStatus > 299 {
+		delivery.Error = fmt.Sprintf("Unexpected response status %d", delivery.ResponseStatus)
+	} else {
+		delivery.Status = models.DeliverySucceeded
+		delivery.NextAttemptAt = nil
+		return
+	}
+
+	if delivery.Attempts >= s.MaxAttempts {
+		delivery.Status = models.DeliveryFailed
+		delivery.NextAttemptAt = nil
+		return
+	}
+	next := now.Add(s.Backoff << (delivery.Attempts - 1))
+	delivery.NextAttemptAt = &next
+}
+
+// POST the signed payload of a delivery to its webhook, recording the response status
+func (s *WebhookSender) post(webhook *models.Webhook, delivery *models.WebhookDelivery, now time.Time) error {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "aimet-webhooks")
+	req.Header.Set("X-Aimet-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Aimet-Event", delivery.Type)
+	req.Header.Set("X-Aimet-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Aimet-Signature", webhook.Sign(timestamp, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Reading the body lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	delivery.ResponseStatus = resp.StatusCode
+	return nil
+}
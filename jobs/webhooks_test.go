@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+	"gotest.tools/v3/assert"
+)
+
+func TestWebhookSender(t *testing.T) {
+	var mu sync.Mutex
+	status := http.StatusOK
+	var received []*http.Request
+	var bodies [][]byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+	respond := func(code int) {
+		mu.Lock()
+		defer mu.Unlock()
+		status = code
+	}
+
+	events, _, _, webhooks, _ := repositories.NewMemoryRepositories()
+	webhook := models.Webhook{URL: receiver.URL}
+	_, err := webhook.NewWebhookSecret()
+	assert.NilError(t, err)
+	assert.NilError(t, webhooks.Create(&webhook))
+	sender := NewWebhookSender(webhooks)
+	sender.Backoff = time.Minute
+	sender.MaxAttempts = 3
+	event := models.Event{CalendarID: 1, Title: "Review", EventDate: "2024-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+	assert.NilError(t, events.Create(&event, models.Audit{}))
+	now := time.Now().Add(time.Second)
+
+	// Test case 1: payloads are POSTed with a signature of their timestamp and body
+	sent, err := sender.SendDue(now)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 1, len(received))
+	assert.Equal(t, models.ChangeEventCreated, received[0].Header.Get("X-Aimet-Event"))
+	timestamp, err := strconv.ParseInt(received[0].Header.Get("X-Aimet-Timestamp"), 10, 64)
+	assert.NilError(t, err)
+	assert.Equal(t, webhook.Sign(timestamp, bodies[0]), received[0].Header.Get("X-Aimet-Signature"))
+	deliveries, err := webhooks.ListDeliveries(webhook.ID, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, http.StatusOK, deliveries[0].ResponseStatus)
+	assert.Equal(t, string(deliveries[0].Payload), string(bodies[0]))
+
+	// Test case 2: failed attempts are retried after a backoff doubling each time
+	respond(http.StatusInternalServerError)
+	assert.NilError(t, events.Delete(&event, models.Audit{}))
+	sent, err = sender.SendDue(now)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, sent)
+	deliveries, err = webhooks.ListDeliveries(webhook.ID, 10)
+	assert.NilError(t, err)
+	failing := deliveries[0]
+	assert.Equal(t, models.DeliveryPending, failing.Status)
+	assert.Equal(t, 1, failing.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, failing.ResponseStatus)
+	assert.Equal(t, "Unexpected response status 500", failing.Error)
+	assertShortlyAfter(t, now.Add(time.Minute), *failing.NextAttemptAt)
+	_, err = sender.SendDue(now.Add(59 * time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, 2, len(received))
+	_, err = sender.SendDue(now.Add(time.Minute + time.Second))
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(received))
+	retried, err := webhooks.GetDelivery(webhook.ID, failing.ID)
+	assert.NilError(t, err)
+	assertShortlyAfter(t, now.Add(3*time.Minute+time.Second), *retried.NextAttemptAt)
+
+	// Test case 3: deliveries fail once they run out of attempts
+	_, err = sender.SendDue(now.Add(4 * time.Minute))
+	assert.NilError(t, err)
+	failed, err := webhooks.GetDelivery(webhook.ID, failing.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, models.DeliveryFailed, failed.Status)
+	assert.Equal(t, 3, failed.Attempts)
+	assert.Assert(t, failed.NextAttemptAt == nil)
+	_, err = sender.SendDue(now.Add(time.Hour))
+	assert.NilError(t, err)
+	assert.Equal(t, 4, len(received))
+
+	// Test case 4: redeliveries send the same payload again
+	respond(http.StatusNoContent)
+	redeliveredAt := now.Add(time.Hour)
+	redelivery := models.WebhookDelivery{WebhookID: webhook.ID, RevisionID: failed.RevisionID, Type: failed.Type, Payload: failed.Payload, Status: models.DeliveryPending, NextAttemptAt: &redeliveredAt, RedeliveryOf: &failed.ID}
+	assert.NilError(t, webhooks.CreateDelivery(&redelivery))
+	sent, err = sender.SendDue(redeliveredAt)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, string(bodies[1]), string(bodies[4]))
+	assert.Equal(t, strconv.FormatUint(uint64(redelivery.ID), 10), received[4].Header.Get("X-Aimet-Delivery"))
+
+	// Test case 5: unreachable webhooks are retried with the error of the attempt
+	receiver.Close()
+	deleted, err := events.GetDeleted(event.ID)
+	assert.NilError(t, err)
+	assert.NilError(t, events.Restore(deleted, models.Audit{}))
+	_, err = sender.SendDue(redeliveredAt)
+	assert.NilError(t, err)
+	deliveries, err = webhooks.ListDeliveries(webhook.ID, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, models.DeliveryPending, deliveries[0].Status)
+	assert.Equal(t, 0, deliveries[0].ResponseStatus)
+	assert.Assert(t, deliveries[0].Error != "")
+
+	// Test case 6: deliveries reached late in a slow batch are claimed for a whole lease from then
+	webhook.Disabled = true
+	assert.NilError(t, webhooks.Update(&webhook))
+	other := NewWebhookSender(webhooks)
+	batchAt := time.Now().Add(time.Second)
+	attempts, otherSent := 0, -1
+	var otherErr error
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		attempts++
+		second := attempts == 2
+		mu.Unlock()
+		// The other sender runs while the last delivery is sent, after the lease of a claim made at the start of the batch
+		if second {
+			sent, err := other.SendDue(batchAt.Add(200 * time.Millisecond))
+			mu.Lock()
+			otherSent, otherErr = sent, err
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer slow.Close()
+	slowWebhook := models.Webhook{URL: slow.URL}
+	_, err = slowWebhook.NewWebhookSecret()
+	assert.NilError(t, err)
+	assert.NilError(t, webhooks.Create(&slowWebhook))
+	for _, title := range []string{"Sync", "Wrap-up"} {
+		event := models.Event{CalendarID: 1, Title: title, EventDate: "2024-03-02", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, events.Create(&event, models.Audit{}))
+	}
+	sender.Lease = 150 * time.Millisecond
+	sent, err = sender.SendDue(batchAt)
+	assert.NilError(t, err)
+	assert.Equal(t, 2, sent)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.NilError(t, otherErr)
+	assert.Equal(t, 0, otherSent)
+	assert.Equal(t, 2, attempts)
+}
@@ -0,0 +1,156 @@
+// Package jwt signs and verifies the JSON Web Tokens (RFC 7519) accepted by the API.
+// Only HS256 tokens signed with a shared key are supported.
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Errors returned by Verify
+var (
+	ErrMalformed    = errors.New("Malformed token")
+	ErrAlgorithm    = errors.New("Unsupported token algorithm")
+	ErrSignature    = errors.New("Invalid token signature")
+	ErrExpired      = errors.New("Token has expired")
+	ErrNotYetValid  = errors.New("Token is not valid yet")
+	ErrInvalidClaim = errors.New("Invalid token issuer or audience")
+)
+
+// Claims are the registered claims read from a token. Times are in seconds since the Unix epoch.
+type Claims struct {
+	Subject   string   `json:"sub,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+// Audience holds the aud claim, which is either a single string or an array of strings
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var several []string
+	if err := json.Unmarshal(data, &several); err != nil {
+		return err
+	}
+	*a = several
+	return nil
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// Header of the tokens, which must name the HS256 algorithm
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// Verifier checks the signature and the claims of tokens
+type Verifier struct {
+	// Key the tokens are signed with
+	Key []byte
+	// Issuer and Audience are checked when they are set
+	Issuer   string
+	Audience string
+	// Leeway allowed for the clock skew between the issuer and the server
+	Leeway time.Duration
+}
+
+// Verify checks a token at the time now and returns its claims.
+// Tokens must have an expiry time.
+func (v *Verifier) Verify(token string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	if h.Algorithm != "HS256" {
+		return nil, ErrAlgorithm
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if !hmac.Equal(signature, sign(parts[0]+"."+parts[1], v.Key)) {
+		return nil, ErrSignature
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if claims.ExpiresAt == 0 || !now.Before(time.Unix(claims.ExpiresAt, 0).Add(v.Leeway)) {
+		return nil, ErrExpired
+	}
+	if claims.NotBefore != 0 && now.Add(v.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrNotYetValid
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return nil, ErrInvalidClaim
+	}
+	if v.Audience != "" && !claims.Audience.contains(v.Audience) {
+		return nil, ErrInvalidClaim
+	}
+	return &claims, nil
+}
+
+// Sign creates an HS256 token holding the claims
+func Sign(claims Claims, key []byte) (string, error) {
+	h, err := json.Marshal(header{Algorithm: "HS256", Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sign(signingInput, key)), nil
+}
+
+// HMAC-SHA256 of the signing input
+func sign(signingInput string, key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+// Decode a base64url JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Check whether the audience names the recipient
+func (a Audience) contains(recipient string) bool {
+	for _, name := range a {
+		if name == recipient {
+			return true
+		}
+	}
+	return false
+}
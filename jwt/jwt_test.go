@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestVerify(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	verifier := &Verifier{Key: key, Issuer: "https://id.example.com", Audience: "aimet", Leeway: time.Minute}
+	valid := Claims{Subject: "7", Issuer: "https://id.example.com", Audience: Audience{"aimet"}, ExpiresAt: now.Add(time.Hour).Unix()}
+
+	// Test case 1: a signed token gives its claims, with a single audience written as a string
+	token, err := Sign(valid, key)
+	assert.NilError(t, err)
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(payload), `"aud":"aimet"`))
+	claims, err := verifier.Verify(token, now)
+	assert.NilError(t, err)
+	assert.Equal(t, "7", claims.Subject)
+
+	// Test case 2: audiences may be arrays
+	several := valid
+	several.Audience = Audience{"other", "aimet"}
+	token, _ = Sign(several, key)
+	_, err = verifier.Verify(token, now)
+	assert.NilError(t, err)
+
+	// Test case 3: rejected tokens
+	sign := func(claims Claims, key []byte) string {
+		token, err := Sign(claims, key)
+		assert.NilError(t, err)
+		return token
+	}
+	expired, noExpiry, early, issuer, audience := valid, valid, valid, valid, valid
+	expired.ExpiresAt = now.Add(-2 * time.Minute).Unix()
+	noExpiry.ExpiresAt = 0
+	early.NotBefore = now.Add(5 * time.Minute).Unix()
+	issuer.Issuer = "https://evil.example.com"
+	audience.Audience = Audience{"other"}
+	token = sign(valid, key)
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + strings.Split(token, ".")[1] + "."
+	for _, test := range []struct {
+		token string
+		err   error
+	}{
+		{sign(valid, []byte("another key")), ErrSignature},
+		{token[:len(token)-2] + "xx", ErrSignature},
+		{unsigned, ErrAlgorithm},
+		{sign(expired, key), ErrExpired},
+		{sign(noExpiry, key), ErrExpired},
+		{sign(early, key), ErrNotYetValid},
+		{sign(issuer, key), ErrInvalidClaim},
+		{sign(audience, key), ErrInvalidClaim},
+		{"not-a-token", ErrMalformed},
+	} {
+		_, err := verifier.Verify(test.token, now)
+		assert.Equal(t, test.err, err, test.token)
+	}
+
+	// Test case 4: the leeway allows for clock skew
+	expired.ExpiresAt = now.Add(-30 * time.Second).Unix()
+	_, err = verifier.Verify(sign(expired, key), now)
+	assert.NilError(t, err)
+}
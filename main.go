@@ -2,22 +2,102 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"os"
+	"time"
+	// Events name IANA time zones, which must load on hosts without a time zone database
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"github.com/thunthup/aimet-test/configs"
+	"github.com/thunthup/aimet-test/controllers"
+	"github.com/thunthup/aimet-test/feed"
+	"github.com/thunthup/aimet-test/jobs"
+	"github.com/thunthup/aimet-test/migrations"
+	"github.com/thunthup/aimet-test/repositories"
 	"github.com/thunthup/aimet-test/routers"
 )
 
 func init() {
 	configs.LoadEnvVar(nil)
-	configs.ConnectPostgresDB()
+	configs.ConnectDB()
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "users" {
+		runUsers(os.Args[2:])
+		return
+	}
+
+	// Instances starting together wait for each other to apply the pending migrations
+	migrator, err := migrations.New(configs.DB)
+	if err != nil {
+		log.Fatalf("Error while loading migrations %s", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		log.Fatalf("Error while migrating database %s", err)
+	}
+
+	h := controllers.NewHandler(
+		repositories.NewGormEventRepository(configs.DB),
+		repositories.NewGormCalendarRepository(configs.DB),
+		repositories.NewGormUserRepository(configs.DB),
+		repositories.NewGormGrantRepository(configs.DB),
+		repositories.NewGormWebhookRepository(configs.DB),
+		repositories.NewGormReminderRepository(configs.DB),
+	)
+	h.Tokens = configs.JWTVerifier()
+	h.Notifiers = configs.Notifiers()
+
+	// Deleted events are purged once they are older than the retention period
+	if retention := configs.TrashRetention(); retention > 0 {
+		jobs.Every(time.Hour, func() {
+			if _, err := jobs.PurgeTrash(h.Events, retention, time.Now()); err != nil {
+				log.Printf("Error while purging trash %s", err)
+			}
+		})
+	}
+
+	// Changes of events are sent to the webhooks subscribed to them
+	sender := jobs.NewWebhookSender(h.Webhooks)
+	jobs.Every(5*time.Second, func() {
+		if _, err := sender.SendDue(time.Now()); err != nil {
+			log.Printf("Error while sending webhooks %s", err)
+		}
+	})
+
+	// Due reminders are fired once per occurrence and sent through the notifiers of their methods
+	scheduler := jobs.NewReminderScheduler(h.Reminders, h.Events, h.Users, h.Calendars, h.Grants, h.Notifiers)
+	jobs.Every(15*time.Second, func() {
+		if _, err := scheduler.Run(time.Now()); err != nil {
+			log.Printf("Error while sending reminders %s", err)
+		}
+	})
+
+	// Changes of events are streamed to the clients of /api/events/stream, the last 1000 are replayed
+	h.Changes = feed.New(h.Events, 1000)
+	if err := h.Changes.Start(); err != nil {
+		log.Fatalf("Error while starting change feed %s", err)
+	}
+	jobs.Every(time.Second, func() {
+		if _, err := h.Changes.Poll(time.Now()); err != nil {
+			log.Printf("Error while reading changes %s", err)
+		}
+	})
+
 	router := gin.New()
 	routers.HealthCheckRoute(router)
-	routers.EventRoute(router)
+	// Every API route needs a bearer token
+	api := router.Group("", h.Authenticate)
+	routers.EventRoute(api, h)
+	routers.CalendarRoute(api, h)
+	routers.FreeBusyRoute(api, h)
+	routers.UserRoute(api, h)
+	routers.WebhookRoute(api, h)
 	fmt.Println("server is running on", os.Getenv("PORT"))
 	router.Run()
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/thunthup/aimet-test/configs"
+	"github.com/thunthup/aimet-test/migrations"
+)
+
+const migrateUsage = `usage: aimet-test migrate <command>
+
+commands:
+  up         apply the pending migrations
+  down [n]   roll back the last n applied migrations, 1 by default
+  status     list the migrations and when they were applied`
+
+// Run the migrate command, which applies, rolls back or reports the schema migrations
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	migrator, err := migrations.New(configs.DB)
+	if err != nil {
+		log.Fatalf("Error while loading migrations %s", err)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error while migrating database %s", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatalf("Invalid number of migrations %s", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatalf("Error while rolling back database %s", err)
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("no applied migrations")
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatalf("Error while reading migrations %s", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
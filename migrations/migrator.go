@@ -0,0 +1,271 @@
+// Package migrations applies the versioned schema migrations embedded in the binary.
+// Each migration is a pair of files in the directory of its database, such as
+// postgres/0001_initial.up.sql and postgres/0001_initial.down.sql.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// lockKey identifies the advisory lock held by the migrator in PostgreSQL
+const lockKey = 7340014
+
+// Migration is a versioned change of the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration with the time it was applied, nil while it is pending
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// appliedMigration is a row of the migration history
+type appliedMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrator applies and rolls back the migrations of a database
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New creates a migrator with the migrations of the database, postgres or sqlite
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Read the migrations of a database sorted by version
+func load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for database %s", dialect)
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		// 0001_initial.up.sql is the up migration of version 1 named initial
+		base := strings.TrimSuffix(entry.Name(), ".sql")
+		direction := path.Ext(base)
+		base = strings.TrimSuffix(base, direction)
+		prefix, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !found || err != nil || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		content, err := fs.ReadFile(files, path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		} else if migration.Name != name {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, name)
+		}
+		if direction == ".up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d %s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Up applies the pending migrations in order and returns them
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	err := m.locked(func(conn *gorm.DB) error {
+		history, err := m.history(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := history[migration.Version]; ok {
+				continue
+			}
+			migration := migration
+			if err := m.step(conn, func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Up).Error; err != nil {
+					return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+				}
+				return tx.Create(&appliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+			}); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations, latest first, and returns them
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := m.locked(func(conn *gorm.DB) error {
+		var history []appliedMigration
+		if err := m.ensureHistory(conn); err != nil {
+			return err
+		}
+		if err := conn.Order("version DESC").Limit(steps).Find(&history).Error; err != nil {
+			return err
+		}
+		for _, row := range history {
+			migration, ok := m.find(row.Version)
+			if !ok {
+				return fmt.Errorf("migration %d %s is unknown to this version of the server", row.Version, row.Name)
+			}
+			if err := m.step(conn, func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.Down).Error; err != nil {
+					return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+				}
+				return tx.Delete(&appliedMigration{}, migration.Version).Error
+			}); err != nil {
+				return err
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// Status lists the known migrations, and the applied ones unknown to this version of the server
+func (m *Migrator) Status() ([]Status, error) {
+	history, err := m.history(m.db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := []Status{}
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := history[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(history, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range history {
+		row := row
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, AppliedAt: &row.AppliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Find a known migration by version
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// Create the migration history table when it does not exist
+func (m *Migrator) ensureHistory(conn *gorm.DB) error {
+	return conn.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)").Error
+}
+
+// Read the applied migrations by version
+func (m *Migrator) history(conn *gorm.DB) (map[int]appliedMigration, error) {
+	if err := m.ensureHistory(conn); err != nil {
+		return nil, err
+	}
+	var rows []appliedMigration
+	if err := conn.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	history := map[int]appliedMigration{}
+	for _, row := range rows {
+		history[row.Version] = row
+	}
+	return history, nil
+}
+
+// Run fn on a single connection while holding the migration lock, so concurrent instances
+// apply each migration once. PostgreSQL holds an advisory lock for the session. SQLite has
+// none and holds the write lock of the database file in an immediate transaction instead.
+func (m *Migrator) locked(fn func(conn *gorm.DB) error) error {
+	return m.db.Session(&gorm.Session{SkipDefaultTransaction: true}).Connection(func(conn *gorm.DB) error {
+		// A new session keeps the conditions of one query out of the next
+		conn = conn.Session(&gorm.Session{})
+		if m.db.Dialector.Name() == "sqlite" {
+			if err := conn.Exec("BEGIN IMMEDIATE").Error; err != nil {
+				return err
+			}
+			// A failed migration is rolled back by step, the ones before it are kept
+			err := fn(conn)
+			if commitErr := conn.Exec("COMMIT").Error; err == nil {
+				err = commitErr
+			}
+			return err
+		}
+
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		return fn(conn)
+	})
+}
+
+// Run one migration in its own transaction, a savepoint in the transaction of the lock for SQLite
+func (m *Migrator) step(conn *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if m.db.Dialector.Name() != "sqlite" {
+		return conn.Transaction(fn)
+	}
+	if err := conn.Exec("SAVEPOINT migration").Error; err != nil {
+		return err
+	}
+	if err := fn(conn); err != nil {
+		conn.Exec("ROLLBACK TO migration")
+		conn.Exec("RELEASE migration")
+		return err
+	}
+	return conn.Exec("RELEASE migration").Error
+}
@@ -0,0 +1,283 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/configs"
+	"github.com/thunthup/aimet-test/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gotest.tools/v3/assert"
+)
+
+func TestLoad(t *testing.T) {
+	postgres, err := load("postgres")
+	assert.NilError(t, err)
+	sqlite, err := load("sqlite")
+	assert.NilError(t, err)
+
+	// Both databases have the same migrations
+	assert.Equal(t, len(postgres), len(sqlite))
+	for i := range postgres {
+		assert.Equal(t, i+1, postgres[i].Version)
+		assert.Equal(t, postgres[i].Version, sqlite[i].Version)
+		assert.Equal(t, postgres[i].Name, sqlite[i].Name)
+	}
+
+	_, err = load("mysql")
+	assert.ErrorContains(t, err, "no migrations for database mysql")
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db, err := configs.OpenSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	assert.NilError(t, err)
+	migrator, err := New(db)
+	assert.NilError(t, err)
+	count := len(migrator.migrations)
+
+	statuses, err := migrator.Status()
+	assert.NilError(t, err)
+	assert.Equal(t, count, len(statuses))
+	assert.Assert(t, statuses[0].AppliedAt == nil)
+
+	applied, err := migrator.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, count, len(applied))
+	assert.Assert(t, db.Migrator().HasTable("events"))
+	statuses, err = migrator.Status()
+	assert.NilError(t, err)
+	for _, status := range statuses {
+		assert.Assert(t, status.AppliedAt != nil, status.Name)
+	}
+
+	// Applying again does nothing
+	applied, err = migrator.Up()
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(applied))
+
+	// The initial migration creates the default calendar and the overlap guard
+	var calendar models.Calendar
+	assert.NilError(t, db.Where("is_default = ?", true).First(&calendar).Error)
+	event := models.Event{CalendarID: calendar.ID, Title: "Meeting", EventDate: "2024-03-01", StartTime: "15:00:00+07", EndTime: "16:00:00+07"}
+	assert.NilError(t, db.Create(&event).Error)
+	overlapping := models.Event{CalendarID: calendar.ID, Title: "Overlapping", EventDate: "2024-03-01", StartTime: "08:30:00+00", EndTime: "09:30:00+00"}
+	assert.ErrorContains(t, db.Create(&overlapping).Error, "Event overlaps with another event in the same calendar")
+	reversed := models.Event{CalendarID: calendar.ID, Title: "Reversed", EventDate: "2024-03-02", StartTime: "16:00:00+07", EndTime: "15:00:00+07"}
+	assert.ErrorContains(t, db.Create(&reversed).Error, "event_times_valid")
+
+	// Revisions cannot be changed once recorded
+	revision := models.NewEventRevision(models.RevisionCreate, nil, &event, models.Audit{})
+	assert.NilError(t, db.Create(revision).Error)
+	assert.ErrorContains(t, db.Model(revision).Update("action", "update").Error, "Event revisions cannot be changed")
+	assert.ErrorContains(t, db.Delete(revision).Error, "Event revisions cannot be changed")
+
+	rolledBack, err := migrator.Down(count + 1)
+	assert.NilError(t, err)
+	assert.Equal(t, count, len(rolledBack))
+	assert.Equal(t, 1, rolledBack[len(rolledBack)-1].Version)
+	assert.Assert(t, !db.Migrator().HasTable("events"))
+	statuses, err = migrator.Status()
+	assert.NilError(t, err)
+	for _, status := range statuses {
+		assert.Assert(t, status.AppliedAt == nil, status.Name)
+	}
+}
+
+// Events as AutoMigrate created them before the versioned migrations, which add the later columns
+type autoMigratedEvent struct {
+	ID         uint             `gorm:"primaryKey"`
+	CalendarID uint             `gorm:"index;not null;default:0"`
+	UID        string           `gorm:"column:uid;index;not null;default:''"`
+	Title      string           `gorm:"index"`
+	EventDate  string           `gorm:"type:date"`
+	EndDate    string           `gorm:"type:date;index"`
+	StartTime  models.TimeOfDay `gorm:"type:timetz"`
+	EndTime    models.TimeOfDay `gorm:"type:timetz"`
+	AllDay     bool             `gorm:"not null;default:false"`
+	Busy       *bool            `gorm:"not null;default:true"`
+	RRule      string           `gorm:"column:rrule;not null;default:''"`
+	ExDates    models.DateList  `gorm:"column:exdates;type:text;not null;default:''"`
+	Version    uint             `gorm:"not null;default:1"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+func (autoMigratedEvent) TableName() string {
+	return "events"
+}
+
+// Calendars as AutoMigrate created them before the versioned migrations
+type autoMigratedCalendar struct {
+	ID          uint   `gorm:"primaryKey"`
+	Name        string `gorm:"index"`
+	Description string
+	IsDefault   bool `gorm:"not null;default:false"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+func (autoMigratedCalendar) TableName() string {
+	return "calendars"
+}
+
+func TestUpAfterAutoMigrate(t *testing.T) {
+	db, err := configs.OpenSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	assert.NilError(t, err)
+	// Databases of earlier versions were created by AutoMigrate, without a default calendar
+	assert.NilError(t, db.AutoMigrate(&autoMigratedCalendar{}, &autoMigratedEvent{}, &models.EventOverride{}))
+	assert.NilError(t, db.Exec("INSERT INTO events (title, event_date, start_time, end_time, busy) VALUES ('Old', '2024-03-01', '15:00:00+07', '16:00:00+07', true)").Error)
+
+	migrator, err := New(db)
+	assert.NilError(t, err)
+	_, err = migrator.Up()
+	assert.NilError(t, err)
+
+	var event models.Event
+	assert.NilError(t, db.Where("title = ?", "Old").First(&event).Error)
+	var calendar models.Calendar
+	assert.NilError(t, db.Where("is_default = ?", true).First(&calendar).Error)
+	assert.Equal(t, calendar.ID, event.CalendarID)
+	event.FormatDates()
+	assert.Equal(t, "2024-03-01", event.EndDate)
+	assert.Assert(t, event.StartAt != nil && event.EndAt != nil)
+	assert.Equal(t, time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), event.StartAt.UTC())
+	assert.Equal(t, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), event.EndAt.UTC())
+}
+
+// Schema of init.sql, which created the PostgreSQL databases of the first versions
+const baselineSchema = `
+CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+
+CREATE TABLE events (
+  id SERIAL PRIMARY KEY,
+  title VARCHAR,
+  event_date DATE,
+  start_time TIME WITH TIME ZONE,
+  end_time TIME WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP WITH TIME ZONE
+  CONSTRAINT event_times_valid CHECK (end_time > start_time)
+);
+
+CREATE INDEX idx_events_event_date ON events (event_date);
+CREATE INDEX idx_events_deleted_at ON events (deleted_at);
+CREATE INDEX idx_events_title ON events (title);
+
+CREATE OR REPLACE FUNCTION check_overlapping_events() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM events e
+        WHERE e.event_date = NEW.event_date AND e.deleted_at IS NULL
+            AND (
+                (e.start_time < NEW.start_time AND e.end_time > NEW.start_time)
+                OR (e.start_time >= NEW.start_time AND e.start_time < NEW.end_time)
+            )
+            AND e.id <> NEW.id
+    ) THEN
+        RAISE EXCEPTION 'Event overlaps with another event on the same day';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER check_overlapping_events
+BEFORE INSERT OR UPDATE ON events
+FOR EACH ROW
+EXECUTE FUNCTION check_overlapping_events();
+`
+
+// Runs against the PostgreSQL database of TEST_POSTGRES_DSN, in a schema of its own
+func TestUpFromBaselinePostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{SkipDefaultTransaction: true})
+	assert.NilError(t, err)
+	sqlDB, err := db.DB()
+	assert.NilError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	// One connection keeps the search path of the schema
+	sqlDB.SetMaxOpenConns(1)
+	schema := fmt.Sprintf("migrations_test_%d", time.Now().UnixNano())
+	assert.NilError(t, db.Exec("CREATE SCHEMA "+schema).Error)
+	t.Cleanup(func() { db.Exec("DROP SCHEMA " + schema + " CASCADE") })
+	assert.NilError(t, db.Exec("SET search_path TO "+schema+", public").Error)
+
+	assert.NilError(t, db.Exec(baselineSchema).Error)
+	assert.NilError(t, db.Exec("INSERT INTO events (title, event_date, start_time, end_time) VALUES ('Old', '2024-03-01', '15:00:00+07', '16:00:00+07')").Error)
+	// Versions before the versioned migrations ran AutoMigrate on the tables of init.sql when they started
+	assert.NilError(t, db.AutoMigrate(&autoMigratedCalendar{}, &autoMigratedEvent{}, &models.EventOverride{}))
+
+	migrator, err := New(db)
+	assert.NilError(t, err)
+	_, err = migrator.Up()
+	assert.NilError(t, err)
+
+	// Test case 1: events of the baseline move to the default calendar and keep their times
+	var event models.Event
+	assert.NilError(t, db.Where("title = ?", "Old").First(&event).Error)
+	var calendar models.Calendar
+	assert.NilError(t, db.Where("is_default = ?", true).First(&calendar).Error)
+	assert.Equal(t, calendar.ID, event.CalendarID)
+	assert.Assert(t, event.StartAt != nil)
+	assert.Equal(t, time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), event.StartAt.UTC())
+
+	// Test case 2: the check of the baseline on times only is replaced, overnight events are accepted
+	overnight := models.Event{CalendarID: calendar.ID, Title: "Overnight", EventDate: "2024-03-02", EndDate: "2024-03-03", StartTime: "22:00:00+07", EndTime: "02:00:00+07"}
+	assert.NilError(t, db.Create(&overnight).Error)
+	reversed := models.Event{CalendarID: calendar.ID, Title: "Reversed", EventDate: "2024-03-04", EndDate: "2024-03-04", StartTime: "16:00:00+07", EndTime: "15:00:00+07"}
+	assert.ErrorContains(t, db.Create(&reversed).Error, "event_times_valid")
+
+	// Test case 3: rolling the replacement back restores the check on times only
+	rolledBack, err := migrator.Down(1)
+	assert.NilError(t, err)
+	assert.Equal(t, 11, rolledBack[0].Version)
+	overnight = models.Event{CalendarID: calendar.ID, Title: "Overnight again", EventDate: "2024-03-05", EndDate: "2024-03-06", StartTime: "22:00:00+07", EndTime: "02:00:00+07"}
+	assert.ErrorContains(t, db.Create(&overnight).Error, "event_times_valid")
+}
+
+func TestConcurrentUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// Instances starting together apply each migration once
+	var wg sync.WaitGroup
+	applied := make([]int, 4)
+	errs := make([]error, 4)
+	for i := range applied {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, err := configs.OpenSQLiteDB(path)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			migrator, err := New(db)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			migrations, err := migrator.Up()
+			applied[i], errs[i] = len(migrations), err
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i := range applied {
+		assert.NilError(t, errs[i])
+		total += applied[i]
+	}
+	expected, err := load("sqlite")
+	assert.NilError(t, err)
+	assert.Equal(t, len(expected), total)
+}
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS check_overlapping_events ON events;
+DROP FUNCTION IF EXISTS check_overlapping_events();
+DROP TABLE IF EXISTS event_overrides;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS calendars;
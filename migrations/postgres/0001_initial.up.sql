@@ -0,0 +1,96 @@
+-- Tables of init.sql, kept as they are in databases created by init.sql or AutoMigrate
+CREATE TABLE IF NOT EXISTS calendars (
+  id SERIAL PRIMARY KEY,
+  name VARCHAR,
+  description VARCHAR,
+  is_default BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendars_name ON calendars (name);
+CREATE INDEX IF NOT EXISTS idx_calendars_deleted_at ON calendars (deleted_at);
+
+CREATE TABLE IF NOT EXISTS events (
+  id SERIAL PRIMARY KEY,
+  calendar_id INTEGER NOT NULL DEFAULT 0,
+  uid VARCHAR NOT NULL DEFAULT '',
+  title VARCHAR,
+  event_date DATE,
+  end_date DATE,
+  start_time TIME WITH TIME ZONE,
+  end_time TIME WITH TIME ZONE,
+  all_day BOOLEAN NOT NULL DEFAULT FALSE,
+  busy BOOLEAN NOT NULL DEFAULT TRUE,
+  rrule VARCHAR NOT NULL DEFAULT '',
+  exdates TEXT NOT NULL DEFAULT '',
+  version INTEGER NOT NULL DEFAULT 1,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_calendar_id ON events (calendar_id);
+CREATE INDEX IF NOT EXISTS idx_events_uid ON events (uid);
+CREATE INDEX IF NOT EXISTS idx_events_event_date ON events (event_date);
+CREATE INDEX IF NOT EXISTS idx_events_end_date ON events (end_date);
+CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_events_title ON events (title);
+
+CREATE TABLE IF NOT EXISTS event_overrides (
+  id SERIAL PRIMARY KEY,
+  event_id INTEGER REFERENCES events (id),
+  recurrence_date DATE,
+  title VARCHAR,
+  event_date DATE,
+  end_date DATE,
+  start_time TIME WITH TIME ZONE,
+  end_time TIME WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_overrides_occurrence ON event_overrides (event_id, recurrence_date);
+
+-- Events created before calendars existed belong to the default calendar
+INSERT INTO calendars (name, is_default)
+SELECT 'Default', TRUE WHERE NOT EXISTS (SELECT 1 FROM calendars WHERE is_default AND deleted_at IS NULL);
+UPDATE events SET calendar_id = (SELECT MIN(id) FROM calendars WHERE is_default AND deleted_at IS NULL)
+WHERE calendar_id IS NULL OR calendar_id = 0;
+
+-- Events created before end_date existed end on their event date
+UPDATE events SET end_date = event_date WHERE end_date IS NULL;
+UPDATE event_overrides SET end_date = event_date WHERE end_date IS NULL;
+
+-- Databases created by AutoMigrate have neither the check nor the trigger, existing rows are not checked
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'event_times_valid') THEN
+        ALTER TABLE events ADD CONSTRAINT event_times_valid CHECK (end_date + end_time > event_date + start_time) NOT VALID;
+    END IF;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION check_overlapping_events() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM events e
+        WHERE NEW.deleted_at IS NULL AND e.calendar_id = NEW.calendar_id
+            AND NEW.busy AND e.busy AND NOT NEW.all_day AND NOT e.all_day
+            AND e.event_date <= NEW.end_date AND e.end_date >= NEW.event_date AND e.deleted_at IS NULL
+            AND e.event_date + e.start_time < NEW.end_date + NEW.end_time
+            AND e.end_date + e.end_time > NEW.event_date + NEW.start_time
+            AND e.id <> NEW.id
+    ) THEN
+        RAISE EXCEPTION 'Event overlaps with another event in the same calendar';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS check_overlapping_events ON events;
+CREATE TRIGGER check_overlapping_events
+BEFORE INSERT OR UPDATE ON events
+FOR EACH ROW
+EXECUTE FUNCTION check_overlapping_events();
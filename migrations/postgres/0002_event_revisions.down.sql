@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS reject_event_revision_changes ON event_revisions;
+DROP FUNCTION IF EXISTS reject_event_revision_changes();
+DROP TABLE IF EXISTS event_revisions;
@@ -0,0 +1,32 @@
+-- Revisions outlive their events, which can be purged, so event_id has no foreign key
+CREATE TABLE IF NOT EXISTS event_revisions (
+  id SERIAL PRIMARY KEY,
+  event_id INTEGER NOT NULL,
+  version INTEGER NOT NULL,
+  action VARCHAR NOT NULL,
+  changes TEXT NOT NULL,
+  snapshot TEXT NOT NULL,
+  actor VARCHAR NOT NULL DEFAULT '',
+  request_id VARCHAR NOT NULL DEFAULT '',
+  method VARCHAR NOT NULL DEFAULT '',
+  path VARCHAR NOT NULL DEFAULT '',
+  remote_addr VARCHAR NOT NULL DEFAULT '',
+  user_agent VARCHAR NOT NULL DEFAULT '',
+  reverted_to INTEGER,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_revisions_event_id ON event_revisions (event_id);
+
+-- Revisions are immutable
+CREATE OR REPLACE FUNCTION reject_event_revision_changes() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'Event revisions cannot be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS reject_event_revision_changes ON event_revisions;
+CREATE TRIGGER reject_event_revision_changes
+BEFORE UPDATE OR DELETE ON event_revisions
+FOR EACH ROW
+EXECUTE FUNCTION reject_event_revision_changes();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_title_trgm;
+DROP INDEX IF EXISTS idx_events_search;
+DROP FUNCTION IF EXISTS event_search_fold(text);
+DROP TEXT SEARCH CONFIGURATION IF EXISTS event_search;
@@ -0,0 +1,21 @@
+-- Search ignores case and accents. Words are matched by their English stem,
+-- and any part of a title through its trigrams.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'event_search') THEN
+        CREATE TEXT SEARCH CONFIGURATION event_search (COPY = english);
+        ALTER TEXT SEARCH CONFIGURATION event_search ALTER MAPPING FOR hword, hword_part, word WITH unaccent, english_stem;
+    END IF;
+END;
+$$;
+
+-- unaccent is only stable, indexes need an immutable function
+CREATE OR REPLACE FUNCTION event_search_fold(text) RETURNS text AS $$
+    SELECT lower(public.unaccent('public.unaccent'::regdictionary, $1));
+$$ LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE;
+
+CREATE INDEX IF NOT EXISTS idx_events_search ON events USING GIN (to_tsvector('event_search', coalesce(title, '')));
+CREATE INDEX IF NOT EXISTS idx_events_title_trgm ON events USING GIN (event_search_fold(title) gin_trgm_ops);
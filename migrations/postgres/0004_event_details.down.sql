@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS event_tags;
+DROP TABLE IF EXISTS tags;
+ALTER TABLE events DROP COLUMN IF EXISTS color;
+ALTER TABLE events DROP COLUMN IF EXISTS url;
+ALTER TABLE events DROP COLUMN IF EXISTS location;
+ALTER TABLE events DROP COLUMN IF EXISTS description;
@@ -0,0 +1,21 @@
+CREATE OR REPLACE FUNCTION check_overlapping_events() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM events e
+        WHERE NEW.deleted_at IS NULL AND e.calendar_id = NEW.calendar_id
+            AND NEW.busy AND e.busy AND NOT NEW.all_day AND NOT e.all_day
+            AND e.event_date <= NEW.end_date AND e.end_date >= NEW.event_date AND e.deleted_at IS NULL
+            AND e.event_date + e.start_time < NEW.end_date + NEW.end_time
+            AND e.end_date + e.end_time > NEW.event_date + NEW.start_time
+            AND e.id <> NEW.id
+    ) THEN
+        RAISE EXCEPTION 'Event overlaps with another event in the same calendar';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_events_start_at;
+ALTER TABLE events DROP COLUMN IF EXISTS end_at;
+ALTER TABLE events DROP COLUMN IF EXISTS start_at;
+ALTER TABLE events DROP COLUMN IF EXISTS time_zone;
@@ -0,0 +1,25 @@
+-- Timed events store the instants they start and end at, and the IANA time zone their times are written in
+ALTER TABLE events ADD COLUMN IF NOT EXISTS time_zone VARCHAR NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS start_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS end_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE events SET start_at = event_date + start_time, end_at = end_date + end_time
+WHERE NOT all_day AND start_time IS NOT NULL AND end_time IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_events_start_at ON events (start_at);
+
+-- Overlaps are checked on the instants, whatever the offsets of the times
+CREATE OR REPLACE FUNCTION check_overlapping_events() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM events e
+        WHERE NEW.deleted_at IS NULL AND e.calendar_id = NEW.calendar_id
+            AND NEW.busy AND e.busy AND NOT NEW.all_day AND NOT e.all_day AND e.deleted_at IS NULL
+            AND e.start_at < NEW.end_at AND e.end_at > NEW.start_at
+            AND e.id <> NEW.id
+    ) THEN
+        RAISE EXCEPTION 'Event overlaps with another event in the same calendar';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
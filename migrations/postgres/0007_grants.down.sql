@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS grants;
+DROP INDEX IF EXISTS idx_calendars_owner_id;
+ALTER TABLE calendars DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,39 @@
+-- Reminders are scheduled on the next occurrence of their event, which every change of the event recomputes
+CREATE TABLE IF NOT EXISTS reminders (
+  id SERIAL PRIMARY KEY,
+  event_id INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  minutes_before INTEGER,
+  days_before INTEGER,
+  at VARCHAR NOT NULL DEFAULT '',
+  method VARCHAR NOT NULL,
+  email VARCHAR NOT NULL DEFAULT '',
+  next_fire_at TIMESTAMP WITH TIME ZONE,
+  occurrence_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT reminder_offset CHECK ((minutes_before IS NULL) <> (days_before IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminders_event_id ON reminders (event_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_due ON reminders (next_fire_at) WHERE next_fire_at IS NOT NULL;
+
+-- A reminder fires once for each occurrence, whichever instance queues it first
+CREATE TABLE IF NOT EXISTS reminder_firings (
+  id SERIAL PRIMARY KEY,
+  reminder_id INTEGER NOT NULL REFERENCES reminders (id) ON DELETE CASCADE,
+  event_id INTEGER NOT NULL,
+  occurrence_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  fire_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  status VARCHAR NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP WITH TIME ZONE,
+  sent_at TIMESTAMP WITH TIME ZONE,
+  error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CONSTRAINT firing_status CHECK (status IN ('pending', 'sent', 'failed', 'canceled'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reminder_firings_occurrence ON reminder_firings (reminder_id, occurrence_at);
+CREATE INDEX IF NOT EXISTS idx_reminder_firings_due ON reminder_firings (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,17 @@
+-- Recurring events are compared occurrence by occurrence by the application, their base row
+-- may not even occur when its first date is an exception, so the trigger only compares single events
+CREATE OR REPLACE FUNCTION check_overlapping_events() RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM events e
+        WHERE NEW.deleted_at IS NULL AND e.calendar_id = NEW.calendar_id
+            AND NEW.busy AND e.busy AND NOT NEW.all_day AND NOT e.all_day AND e.deleted_at IS NULL
+            AND NEW.rrule = '' AND e.rrule = ''
+            AND e.start_at < NEW.end_at AND e.end_at > NEW.start_at
+            AND e.id <> NEW.id
+    ) THEN
+        RAISE EXCEPTION 'Event overlaps with another event in the same calendar';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,13 @@
+-- The check on times only comes back where it was replaced, existing overnight events are not checked
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'event_times_valid' AND conrelid = 'events'::regclass
+            AND obj_description(oid, 'pg_constraint') = 'Replaced CHECK (end_time > start_time)'
+    ) THEN
+        ALTER TABLE events DROP CONSTRAINT event_times_valid;
+        ALTER TABLE events ADD CONSTRAINT event_times_valid CHECK (end_time > start_time) NOT VALID;
+    END IF;
+END;
+$$;
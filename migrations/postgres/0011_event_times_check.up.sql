@@ -0,0 +1,16 @@
+-- Databases created by init.sql before overnight events existed kept CHECK (end_time > start_time),
+-- which rejects overnight and multi-day events. Events end after they start, dates included.
+-- The replaced check is marked so that the down migration restores it on these databases only.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'event_times_valid' AND conrelid = 'events'::regclass
+            AND pg_get_constraintdef(oid) NOT LIKE '%end_date%'
+    ) THEN
+        ALTER TABLE events DROP CONSTRAINT event_times_valid;
+        ALTER TABLE events ADD CONSTRAINT event_times_valid CHECK (end_date + end_time > event_date + start_time) NOT VALID;
+        COMMENT ON CONSTRAINT event_times_valid ON events IS 'Replaced CHECK (end_time > start_time)';
+    END IF;
+END;
+$$;
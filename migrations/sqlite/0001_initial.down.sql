@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS check_event_times_insert;
+DROP TRIGGER IF EXISTS check_event_times_update;
+DROP TRIGGER IF EXISTS check_overlapping_events_insert;
+DROP TRIGGER IF EXISTS check_overlapping_events_update;
+DROP TABLE IF EXISTS event_overrides;
+DROP TABLE IF EXISTS events;
+DROP TABLE IF EXISTS calendars;
@@ -0,0 +1,105 @@
+-- Tables as created by AutoMigrate, whose column types the driver reads dates and times from
+CREATE TABLE IF NOT EXISTS calendars (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT,
+  description TEXT,
+  is_default NUMERIC NOT NULL DEFAULT false,
+  created_at DATETIME,
+  updated_at DATETIME,
+  deleted_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_calendars_name ON calendars (name);
+CREATE INDEX IF NOT EXISTS idx_calendars_deleted_at ON calendars (deleted_at);
+
+CREATE TABLE IF NOT EXISTS events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  calendar_id INTEGER NOT NULL DEFAULT 0,
+  uid TEXT NOT NULL DEFAULT '',
+  title TEXT,
+  event_date DATE,
+  end_date DATE,
+  start_time TIMETZ,
+  end_time TIMETZ,
+  all_day NUMERIC NOT NULL DEFAULT false,
+  busy NUMERIC NOT NULL DEFAULT true,
+  rrule TEXT NOT NULL DEFAULT '',
+  exdates TEXT NOT NULL DEFAULT '',
+  version INTEGER NOT NULL DEFAULT 1,
+  created_at DATETIME,
+  updated_at DATETIME,
+  deleted_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_calendar_id ON events (calendar_id);
+CREATE INDEX IF NOT EXISTS idx_events_uid ON events (uid);
+CREATE INDEX IF NOT EXISTS idx_events_event_date ON events (event_date);
+CREATE INDEX IF NOT EXISTS idx_events_end_date ON events (end_date);
+CREATE INDEX IF NOT EXISTS idx_events_deleted_at ON events (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_events_title ON events (title);
+
+CREATE TABLE IF NOT EXISTS event_overrides (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER REFERENCES events (id),
+  recurrence_date DATE,
+  title TEXT,
+  event_date DATE,
+  end_date DATE,
+  start_time TIMETZ,
+  end_time TIMETZ,
+  created_at DATETIME,
+  updated_at DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_overrides_occurrence ON event_overrides (event_id, recurrence_date);
+
+-- Events created before calendars existed belong to the default calendar
+INSERT INTO calendars (name, is_default, created_at, updated_at)
+SELECT 'Default', true, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+WHERE NOT EXISTS (SELECT 1 FROM calendars WHERE is_default AND deleted_at IS NULL);
+UPDATE events SET calendar_id = (SELECT MIN(id) FROM calendars WHERE is_default AND deleted_at IS NULL)
+WHERE calendar_id IS NULL OR calendar_id = 0;
+
+-- Events created before end_date existed end on their event date
+UPDATE events SET end_date = event_date WHERE end_date IS NULL;
+UPDATE event_overrides SET end_date = event_date WHERE end_date IS NULL;
+
+-- SQLite cannot add a check to an existing table nor add a date to a time with offset.
+-- Triggers guard the times and overlaps instead, reading times such as 15:00:00+07 completed to +07:00.
+CREATE TRIGGER IF NOT EXISTS check_event_times_insert BEFORE INSERT ON events FOR EACH ROW
+WHEN datetime(NEW.end_date || ' ' || NEW.end_time || ':00') <= datetime(NEW.event_date || ' ' || NEW.start_time || ':00')
+BEGIN
+    SELECT RAISE(ABORT, 'new row for relation "events" violates check constraint "event_times_valid"');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_event_times_update BEFORE UPDATE ON events FOR EACH ROW
+WHEN datetime(NEW.end_date || ' ' || NEW.end_time || ':00') <= datetime(NEW.event_date || ' ' || NEW.start_time || ':00')
+BEGIN
+    SELECT RAISE(ABORT, 'new row for relation "events" violates check constraint "event_times_valid"');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_overlapping_events_insert BEFORE INSERT ON events FOR EACH ROW
+WHEN NEW.deleted_at IS NULL AND NEW.busy AND NOT NEW.all_day AND EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.calendar_id = NEW.calendar_id AND e.busy AND NOT e.all_day
+        AND e.event_date <= NEW.end_date AND e.end_date >= NEW.event_date AND e.deleted_at IS NULL
+        AND datetime(e.event_date || ' ' || e.start_time || ':00') < datetime(NEW.end_date || ' ' || NEW.end_time || ':00')
+        AND datetime(e.end_date || ' ' || e.end_time || ':00') > datetime(NEW.event_date || ' ' || NEW.start_time || ':00')
+        AND e.id IS NOT NEW.id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Event overlaps with another event in the same calendar');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_overlapping_events_update BEFORE UPDATE ON events FOR EACH ROW
+WHEN NEW.deleted_at IS NULL AND NEW.busy AND NOT NEW.all_day AND EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.calendar_id = NEW.calendar_id AND e.busy AND NOT e.all_day
+        AND e.event_date <= NEW.end_date AND e.end_date >= NEW.event_date AND e.deleted_at IS NULL
+        AND datetime(e.event_date || ' ' || e.start_time || ':00') < datetime(NEW.end_date || ' ' || NEW.end_time || ':00')
+        AND datetime(e.end_date || ' ' || e.end_time || ':00') > datetime(NEW.event_date || ' ' || NEW.start_time || ':00')
+        AND e.id IS NOT NEW.id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Event overlaps with another event in the same calendar');
+END;
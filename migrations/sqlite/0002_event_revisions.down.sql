@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS reject_event_revision_updates;
+DROP TRIGGER IF EXISTS reject_event_revision_deletes;
+DROP TABLE IF EXISTS event_revisions;
@@ -0,0 +1,30 @@
+-- Revisions outlive their events, which can be purged, so event_id has no foreign key
+CREATE TABLE IF NOT EXISTS event_revisions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER NOT NULL,
+  version INTEGER NOT NULL,
+  action TEXT NOT NULL,
+  changes TEXT NOT NULL,
+  snapshot TEXT NOT NULL,
+  actor TEXT NOT NULL DEFAULT '',
+  request_id TEXT NOT NULL DEFAULT '',
+  method TEXT NOT NULL DEFAULT '',
+  path TEXT NOT NULL DEFAULT '',
+  remote_addr TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  reverted_to INTEGER,
+  created_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_revisions_event_id ON event_revisions (event_id);
+
+-- Revisions are immutable
+CREATE TRIGGER IF NOT EXISTS reject_event_revision_updates BEFORE UPDATE ON event_revisions FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Event revisions cannot be changed');
+END;
+
+CREATE TRIGGER IF NOT EXISTS reject_event_revision_deletes BEFORE DELETE ON event_revisions FOR EACH ROW
+BEGIN
+    SELECT RAISE(ABORT, 'Event revisions cannot be changed');
+END;
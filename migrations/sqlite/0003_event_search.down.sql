@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS events_search_insert;
+DROP TRIGGER IF EXISTS events_search_delete;
+DROP TRIGGER IF EXISTS events_search_update;
+DROP TABLE IF EXISTS events_search;
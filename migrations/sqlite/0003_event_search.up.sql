@@ -0,0 +1,26 @@
+-- Search ignores case and accents. Words are matched by their stem in an FTS5 index of the titles,
+-- and any part of a title with event_search_fold, a function registered by the server.
+CREATE VIRTUAL TABLE IF NOT EXISTS events_search USING fts5(
+  title,
+  content = 'events',
+  content_rowid = 'id',
+  tokenize = 'porter unicode61 remove_diacritics 2'
+);
+
+INSERT INTO events_search (events_search) VALUES ('rebuild');
+
+CREATE TRIGGER IF NOT EXISTS events_search_insert AFTER INSERT ON events FOR EACH ROW
+BEGIN
+    INSERT INTO events_search (rowid, title) VALUES (NEW.id, NEW.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_search_delete AFTER DELETE ON events FOR EACH ROW
+BEGIN
+    INSERT INTO events_search (events_search, rowid, title) VALUES ('delete', OLD.id, OLD.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS events_search_update AFTER UPDATE OF title ON events FOR EACH ROW
+BEGIN
+    INSERT INTO events_search (events_search, rowid, title) VALUES ('delete', OLD.id, OLD.title);
+    INSERT INTO events_search (rowid, title) VALUES (NEW.id, NEW.title);
+END;
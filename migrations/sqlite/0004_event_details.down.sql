@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS event_tags;
+DROP TABLE IF EXISTS tags;
+ALTER TABLE events DROP COLUMN color;
+ALTER TABLE events DROP COLUMN url;
+ALTER TABLE events DROP COLUMN location;
+ALTER TABLE events DROP COLUMN description;
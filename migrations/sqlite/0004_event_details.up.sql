@@ -0,0 +1,21 @@
+ALTER TABLE events ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN location TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN url TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN color TEXT NOT NULL DEFAULT '';
+
+-- Tags are shared by events and kept when no event uses them anymore
+CREATE TABLE IF NOT EXISTS tags (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  created_at DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (name);
+
+CREATE TABLE IF NOT EXISTS event_tags (
+  event_id INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+  PRIMARY KEY (event_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_tags_tag_id ON event_tags (tag_id);
@@ -0,0 +1,46 @@
+DROP TRIGGER IF EXISTS check_event_times_insert;
+DROP TRIGGER IF EXISTS check_event_times_update;
+DROP TRIGGER IF EXISTS check_overlapping_events_insert;
+DROP TRIGGER IF EXISTS check_overlapping_events_update;
+DROP INDEX IF EXISTS idx_events_start_at;
+ALTER TABLE events DROP COLUMN end_at;
+ALTER TABLE events DROP COLUMN start_at;
+ALTER TABLE events DROP COLUMN time_zone;
+
+CREATE TRIGGER IF NOT EXISTS check_event_times_insert BEFORE INSERT ON events FOR EACH ROW
+WHEN datetime(NEW.end_date || ' ' || NEW.end_time || ':00') <= datetime(NEW.event_date || ' ' || NEW.start_time || ':00')
+BEGIN
+    SELECT RAISE(ABORT, 'new row for relation "events" violates check constraint "event_times_valid"');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_event_times_update BEFORE UPDATE ON events FOR EACH ROW
+WHEN datetime(NEW.end_date || ' ' || NEW.end_time || ':00') <= datetime(NEW.event_date || ' ' || NEW.start_time || ':00')
+BEGIN
+    SELECT RAISE(ABORT, 'new row for relation "events" violates check constraint "event_times_valid"');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_overlapping_events_insert BEFORE INSERT ON events FOR EACH ROW
+WHEN NEW.deleted_at IS NULL AND NEW.busy AND NOT NEW.all_day AND EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.calendar_id = NEW.calendar_id AND e.busy AND NOT e.all_day
+        AND e.event_date <= NEW.end_date AND e.end_date >= NEW.event_date AND e.deleted_at IS NULL
+        AND datetime(e.event_date || ' ' || e.start_time || ':00') < datetime(NEW.end_date || ' ' || NEW.end_time || ':00')
+        AND datetime(e.end_date || ' ' || e.end_time || ':00') > datetime(NEW.event_date || ' ' || NEW.start_time || ':00')
+        AND e.id IS NOT NEW.id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Event overlaps with another event in the same calendar');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_overlapping_events_update BEFORE UPDATE ON events FOR EACH ROW
+WHEN NEW.deleted_at IS NULL AND NEW.busy AND NOT NEW.all_day AND EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.calendar_id = NEW.calendar_id AND e.busy AND NOT e.all_day
+        AND e.event_date <= NEW.end_date AND e.end_date >= NEW.event_date AND e.deleted_at IS NULL
+        AND datetime(e.event_date || ' ' || e.start_time || ':00') < datetime(NEW.end_date || ' ' || NEW.end_time || ':00')
+        AND datetime(e.end_date || ' ' || e.end_time || ':00') > datetime(NEW.event_date || ' ' || NEW.start_time || ':00')
+        AND e.id IS NOT NEW.id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Event overlaps with another event in the same calendar');
+END;
@@ -0,0 +1,54 @@
+-- Timed events store the instants they start and end at, and the IANA time zone their times are written in
+ALTER TABLE events ADD COLUMN time_zone TEXT NOT NULL DEFAULT '';
+ALTER TABLE events ADD COLUMN start_at DATETIME;
+ALTER TABLE events ADD COLUMN end_at DATETIME;
+
+DROP TRIGGER IF EXISTS check_event_times_insert;
+DROP TRIGGER IF EXISTS check_event_times_update;
+DROP TRIGGER IF EXISTS check_overlapping_events_insert;
+DROP TRIGGER IF EXISTS check_overlapping_events_update;
+
+UPDATE events SET
+    start_at = datetime(event_date || ' ' || substr(start_time || ':00', 1, 14)),
+    end_at = datetime(end_date || ' ' || substr(end_time || ':00', 1, 14))
+WHERE NOT all_day AND start_time IS NOT NULL AND end_time IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_events_start_at ON events (start_at);
+
+-- Times and overlaps are checked on the instants, read as UTC by datetime.
+-- Dates in local time are at most a day away from the dates in UTC.
+CREATE TRIGGER IF NOT EXISTS check_event_times_insert BEFORE INSERT ON events FOR EACH ROW
+WHEN datetime(NEW.end_at) <= datetime(NEW.start_at)
+BEGIN
+    SELECT RAISE(ABORT, 'new row for relation "events" violates check constraint "event_times_valid"');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_event_times_update BEFORE UPDATE ON events FOR EACH ROW
+WHEN datetime(NEW.end_at) <= datetime(NEW.start_at)
+BEGIN
+    SELECT RAISE(ABORT, 'new row for relation "events" violates check constraint "event_times_valid"');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_overlapping_events_insert BEFORE INSERT ON events FOR EACH ROW
+WHEN NEW.deleted_at IS NULL AND NEW.busy AND NOT NEW.all_day AND EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.calendar_id = NEW.calendar_id AND e.busy AND NOT e.all_day AND e.deleted_at IS NULL
+        AND e.event_date <= date(NEW.end_at, '+1 day') AND e.end_date >= date(NEW.start_at, '-1 day')
+        AND datetime(e.start_at) < datetime(NEW.end_at) AND datetime(e.end_at) > datetime(NEW.start_at)
+        AND e.id IS NOT NEW.id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Event overlaps with another event in the same calendar');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_overlapping_events_update BEFORE UPDATE ON events FOR EACH ROW
+WHEN NEW.deleted_at IS NULL AND NEW.busy AND NOT NEW.all_day AND EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.calendar_id = NEW.calendar_id AND e.busy AND NOT e.all_day AND e.deleted_at IS NULL
+        AND e.event_date <= date(NEW.end_at, '+1 day') AND e.end_date >= date(NEW.start_at, '-1 day')
+        AND datetime(e.start_at) < datetime(NEW.end_at) AND datetime(e.end_at) > datetime(NEW.start_at)
+        AND e.id IS NOT NEW.id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Event overlaps with another event in the same calendar');
+END;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_events_owner_id;
+ALTER TABLE events DROP COLUMN owner_id;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name TEXT NOT NULL,
+  admin NUMERIC NOT NULL DEFAULT false,
+  created_at DATETIME,
+  updated_at DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_name ON users (name);
+
+-- Only the SHA-256 hashes of the secrets are stored
+CREATE TABLE IF NOT EXISTS api_keys (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  name TEXT NOT NULL DEFAULT '',
+  prefix TEXT NOT NULL,
+  hash TEXT NOT NULL,
+  expires_at DATETIME,
+  created_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_hash ON api_keys (hash);
+
+-- Events keep their owner when the user is deleted, existing events have none and only admins see them
+ALTER TABLE events ADD COLUMN owner_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_events_owner_id ON events (owner_id);
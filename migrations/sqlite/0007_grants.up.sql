@@ -0,0 +1,22 @@
+-- Calendars created before sharing have no owner and only admins manage them
+ALTER TABLE calendars ADD COLUMN owner_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_calendars_owner_id ON calendars (owner_id);
+
+-- A grant is on a calendar or on an event, and a user has one role on each
+CREATE TABLE IF NOT EXISTS grants (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  calendar_id INTEGER REFERENCES calendars (id) ON DELETE CASCADE,
+  event_id INTEGER REFERENCES events (id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  role TEXT NOT NULL,
+  granted_by INTEGER NOT NULL DEFAULT 0,
+  created_at DATETIME,
+  updated_at DATETIME,
+  CONSTRAINT grant_target CHECK ((calendar_id IS NULL) <> (event_id IS NULL)),
+  CONSTRAINT grant_role CHECK (role IN ('owner', 'editor', 'viewer', 'freebusy'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_grants_calendar_user ON grants (calendar_id, user_id) WHERE calendar_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_grants_event_user ON grants (event_id, user_id) WHERE event_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_grants_user_id ON grants (user_id);
@@ -0,0 +1,32 @@
+-- Secrets are kept in clear since they sign the payloads
+CREATE TABLE IF NOT EXISTS webhooks (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  url TEXT NOT NULL,
+  types TEXT NOT NULL DEFAULT '',
+  secret TEXT NOT NULL,
+  disabled NUMERIC NOT NULL DEFAULT false,
+  created_at DATETIME,
+  updated_at DATETIME
+);
+
+-- Deliveries are queued with the revision of the change they send
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+  revision_id INTEGER NOT NULL REFERENCES event_revisions (id),
+  type TEXT NOT NULL,
+  payload TEXT NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME,
+  last_attempt_at DATETIME,
+  response_status INTEGER NOT NULL DEFAULT 0,
+  error TEXT NOT NULL DEFAULT '',
+  redelivery_of INTEGER,
+  created_at DATETIME,
+  updated_at DATETIME,
+  CONSTRAINT delivery_status CHECK (status IN ('pending', 'succeeded', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reminder_firings;
+DROP TABLE IF EXISTS reminders;
@@ -0,0 +1,39 @@
+-- Reminders are scheduled on the next occurrence of their event, which every change of the event recomputes
+CREATE TABLE IF NOT EXISTS reminders (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_id INTEGER NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  minutes_before INTEGER,
+  days_before INTEGER,
+  at TEXT NOT NULL DEFAULT '',
+  method TEXT NOT NULL,
+  email TEXT NOT NULL DEFAULT '',
+  next_fire_at DATETIME,
+  occurrence_at DATETIME,
+  created_at DATETIME,
+  updated_at DATETIME,
+  CONSTRAINT reminder_offset CHECK ((minutes_before IS NULL) <> (days_before IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminders_event_id ON reminders (event_id);
+CREATE INDEX IF NOT EXISTS idx_reminders_due ON reminders (next_fire_at) WHERE next_fire_at IS NOT NULL;
+
+-- A reminder fires once for each occurrence, whichever instance queues it first
+CREATE TABLE IF NOT EXISTS reminder_firings (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  reminder_id INTEGER NOT NULL REFERENCES reminders (id) ON DELETE CASCADE,
+  event_id INTEGER NOT NULL,
+  occurrence_at DATETIME NOT NULL,
+  fire_at DATETIME NOT NULL,
+  status TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME,
+  sent_at DATETIME,
+  error TEXT NOT NULL DEFAULT '',
+  created_at DATETIME,
+  updated_at DATETIME,
+  CONSTRAINT firing_status CHECK (status IN ('pending', 'sent', 'failed', 'canceled'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reminder_firings_occurrence ON reminder_firings (reminder_id, occurrence_at);
+CREATE INDEX IF NOT EXISTS idx_reminder_firings_due ON reminder_firings (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,28 @@
+-- Recurring events are compared occurrence by occurrence by the application, their base row
+-- may not even occur when its first date is an exception, so the triggers only compare single events
+DROP TRIGGER IF EXISTS check_overlapping_events_insert;
+DROP TRIGGER IF EXISTS check_overlapping_events_update;
+
+CREATE TRIGGER IF NOT EXISTS check_overlapping_events_insert BEFORE INSERT ON events FOR EACH ROW
+WHEN NEW.deleted_at IS NULL AND NEW.busy AND NOT NEW.all_day AND NEW.rrule = '' AND EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.calendar_id = NEW.calendar_id AND e.busy AND NOT e.all_day AND e.deleted_at IS NULL AND e.rrule = ''
+        AND e.event_date <= date(NEW.end_at, '+1 day') AND e.end_date >= date(NEW.start_at, '-1 day')
+        AND datetime(e.start_at) < datetime(NEW.end_at) AND datetime(e.end_at) > datetime(NEW.start_at)
+        AND e.id IS NOT NEW.id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Event overlaps with another event in the same calendar');
+END;
+
+CREATE TRIGGER IF NOT EXISTS check_overlapping_events_update BEFORE UPDATE ON events FOR EACH ROW
+WHEN NEW.deleted_at IS NULL AND NEW.busy AND NOT NEW.all_day AND NEW.rrule = '' AND EXISTS (
+    SELECT 1 FROM events e
+    WHERE e.calendar_id = NEW.calendar_id AND e.busy AND NOT e.all_day AND e.deleted_at IS NULL AND e.rrule = ''
+        AND e.event_date <= date(NEW.end_at, '+1 day') AND e.end_date >= date(NEW.start_at, '-1 day')
+        AND datetime(e.start_at) < datetime(NEW.end_at) AND datetime(e.end_at) > datetime(NEW.start_at)
+        AND e.id IS NOT NEW.id
+)
+BEGIN
+    SELECT RAISE(ABORT, 'Event overlaps with another event in the same calendar');
+END;
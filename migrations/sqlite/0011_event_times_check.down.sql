@@ -0,0 +1,2 @@
+-- SQLite databases were always checked on dates and times, by the triggers of 0005 on the instants
+SELECT 1;
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Calendar struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"index" json:"name" binding:"required"`
+	Description string         `json:"description"`
+	IsDefault   bool           `gorm:"not null;default:false" json:"is_default"`
+	OwnerID     *uint          `gorm:"index" json:"owner_id,omitempty"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"-"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (Calendar) TableName() string {
+	return "calendars"
+}
@@ -1,20 +1,43 @@
 package models
 
 import (
+	"database/sql/driver"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Event struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Title     string         `gorm:"index" json:"title" binding:"required"`
-	EventDate string         `gorm:"type:date" json:"event_date" binding:"required"`
-	StartTime string         `gorm:"type:timetz" json:"start_time" binding:"required"`
-	EndTime   string         `gorm:"type:timetz" json:"end_time" binding:"required"`
-	CreatedAt time.Time      `gorm:"autoCreateTime" json:"-" `
-	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"-"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	CalendarID  uint            `gorm:"index;not null;default:0" json:"calendar_id"`
+	OwnerID     *uint           `gorm:"index" json:"owner_id,omitempty"`
+	UID         string          `gorm:"column:uid;index;not null;default:''" json:"uid,omitempty"`
+	Title       string          `gorm:"index" json:"title" binding:"required"`
+	Description string          `gorm:"type:text;not null;default:''" json:"description,omitempty" binding:"max=10000"`
+	Location    string          `gorm:"not null;default:''" json:"location,omitempty" binding:"max=500"`
+	URL         string          `gorm:"column:url;not null;default:''" json:"url,omitempty" binding:"omitempty,url,max=2048"`
+	Color       string          `gorm:"not null;default:''" json:"color,omitempty" binding:"omitempty,hexcolor"`
+	Tags        []Tag           `gorm:"many2many:event_tags" json:"tags,omitempty"`
+	EventDate   string          `gorm:"type:date" json:"event_date" binding:"required"`
+	EndDate     string          `gorm:"type:date;index" json:"end_date"`
+	StartTime   TimeOfDay       `gorm:"type:timetz" json:"start_time,omitempty" binding:"required_unless=AllDay true"`
+	EndTime     TimeOfDay       `gorm:"type:timetz" json:"end_time,omitempty" binding:"required_unless=AllDay true"`
+	TimeZone    string          `gorm:"not null;default:''" json:"time_zone,omitempty"`
+	StartAt     *time.Time      `gorm:"index" json:"start_at,omitempty"`
+	EndAt       *time.Time      `json:"end_at,omitempty"`
+	AllDay      bool            `gorm:"not null;default:false" json:"all_day"`
+	Busy        *bool           `gorm:"not null;default:true" json:"busy"`
+	RRule       string          `gorm:"column:rrule;not null;default:''" json:"rrule,omitempty"`
+	ExDates     DateList        `gorm:"column:exdates;type:text;not null;default:''" json:"exdates,omitempty"`
+	Overrides   []EventOverride `gorm:"foreignKey:EventID" json:"overrides,omitempty"`
+	Version     uint            `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"-" `
+	UpdatedAt   time.Time       `gorm:"autoUpdateTime" json:"-"`
+	DeletedAt   gorm.DeletedAt  `gorm:"index" json:"-"`
+
+	// RecurrenceDate is the original date of an expanded occurrence of a recurring event
+	RecurrenceDate string `gorm:"-" json:"recurrence_date,omitempty"`
 }
 
 func (Event) TableName() string {
@@ -22,7 +45,7 @@ func (Event) TableName() string {
 }
 
 func (e *Event) GetStartTime() time.Time {
-	t, err := time.Parse("15:04:05-07", e.StartTime)
+	t, err := ParseTimeOfDay(string(e.StartTime))
 	if err != nil {
 		return time.Time{}
 	}
@@ -30,11 +53,11 @@ func (e *Event) GetStartTime() time.Time {
 }
 
 func (e *Event) SetStartTime(t time.Time) {
-	e.StartTime = t.Format("15:04:05-07")
+	e.StartTime = FormatTimeOfDay(t)
 }
 
 func (e *Event) GetEndTime() time.Time {
-	t, err := time.Parse("15:04:05-07", e.EndTime)
+	t, err := ParseTimeOfDay(string(e.EndTime))
 	if err != nil {
 		return time.Time{}
 	}
@@ -42,11 +65,11 @@ func (e *Event) GetEndTime() time.Time {
 }
 
 func (e *Event) SetEndTime(t time.Time) {
-	e.EndTime = t.Format("15:04:05-07")
+	e.EndTime = FormatTimeOfDay(t)
 }
 
 func (e *Event) GetEventDate() time.Time {
-	t, err := time.Parse("2023-12-05", e.EventDate)
+	t, err := ParseDate(e.EventDate)
 	if err != nil {
 		return time.Time{}
 	}
@@ -54,5 +77,286 @@ func (e *Event) GetEventDate() time.Time {
 }
 
 func (e *Event) SetEventDate(t time.Time) {
-	e.EventDate = t.Format("2023-12-05")
+	e.EventDate = t.Format("2006-01-02")
+}
+
+// GetEndDate returns the date the event ends on, single-day events end on their event date
+func (e *Event) GetEndDate() time.Time {
+	if e.EndDate == "" {
+		return e.GetEventDate()
+	}
+	t, err := ParseDate(e.EndDate)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func (e *Event) SetEndDate(t time.Time) {
+	e.EndDate = t.Format("2006-01-02")
+}
+
+// GetStartAt combines the event date and start time.
+// All-day events start at midnight in their time zone, or else in the server's.
+func (e *Event) GetStartAt() time.Time {
+	if e.AllDay {
+		date := e.GetEventDate()
+		return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, e.dayZone())
+	}
+	return CombineDateTime(e.GetEventDate(), e.GetStartTime())
+}
+
+// GetEndAt combines the end date and end time.
+// All-day events end at midnight after their end date in their time zone, or else in the server's.
+func (e *Event) GetEndAt() time.Time {
+	if e.AllDay {
+		date := e.GetEndDate()
+		return time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, e.dayZone())
+	}
+	return CombineDateTime(e.GetEndDate(), e.GetEndTime())
+}
+
+// IsBusy reports whether the event takes part in overlap detection.
+// Timed events are busy and all-day events are free unless set otherwise.
+func (e *Event) IsBusy() bool {
+	if e.Busy == nil {
+		return !e.AllDay
+	}
+	return *e.Busy
+}
+
+// CombineDateTime places a time of day on a date, keeping the fixed offset of the time
+func CombineDateTime(date time.Time, clock time.Time) time.Time {
+	_, offset := clock.Zone()
+	return time.Date(date.Year(), date.Month(), date.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.FixedZone("", offset))
+}
+
+// BeforeSave defaults the end date of single-day events, whether the event is busy and its first version,
+// and stores the instants of timed events
+func (e *Event) BeforeSave(tx *gorm.DB) error {
+	if e.Version == 0 {
+		e.Version = 1
+	}
+	if e.EndDate == "" {
+		e.EndDate = e.EventDate
+	}
+	if e.Busy == nil {
+		busy := e.IsBusy()
+		e.Busy = &busy
+	}
+	e.SetInstants()
+	return nil
+}
+
+// TimeOfDay is a time with offset such as 15:00:00+07 or 15:00:00+05:30 stored as timetz.
+// It is empty, and NULL in the database, for all-day events.
+type TimeOfDay string
+
+func (t TimeOfDay) Value() (driver.Value, error) {
+	if t == "" {
+		return nil, nil
+	}
+	return string(t), nil
+}
+
+func (t *TimeOfDay) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = ""
+	case string:
+		*t = TimeOfDay(v)
+	case []byte:
+		*t = TimeOfDay(v)
+	default:
+		return fmt.Errorf("cannot scan %T into TimeOfDay", value)
+	}
+	return nil
+}
+
+// OverlapsWith reports whether the time ranges of two single occurrences intersect
+func (e *Event) OverlapsWith(other *Event) bool {
+	return e.GetStartAt().Before(other.GetEndAt()) && e.GetEndAt().After(other.GetStartAt())
+}
+
+// Intersects reports whether the event touches any day within [from, to]
+func (e *Event) Intersects(from, to time.Time) bool {
+	return !e.GetEventDate().After(truncateDate(to)) && !e.GetEndDate().Before(truncateDate(from))
+}
+
+// IsRecurring reports whether the event has a recurrence rule
+func (e *Event) IsRecurring() bool {
+	return e.RRule != ""
+}
+
+// Occurrences expands the event into the occurrences that touch any day within
+// [from, to], applying EXDATE exceptions and per-occurrence overrides.
+// Occurrences of events with a time zone keep their wall clock time, in the offset the zone has on their date.
+// A non-recurring event is returned as is when it is in range.
+func (e *Event) Occurrences(from, to time.Time) ([]Event, error) {
+	from, to = truncateDate(from), truncateDate(to)
+	eventDate, err := ParseDate(e.EventDate)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsRecurring() {
+		if !e.Intersects(from, to) {
+			return nil, nil
+		}
+		return []Event{*e}, nil
+	}
+
+	rule, err := ParseRecurrenceRule(e.RRule)
+	if err != nil {
+		return nil, err
+	}
+
+	// Widen the expansion window so occurrences moved into range by an override are found
+	overrides := map[time.Time]EventOverride{}
+	var shift time.Duration
+	for _, o := range e.Overrides {
+		recurrenceDate, err := ParseDate(o.RecurrenceDate)
+		if err != nil {
+			continue
+		}
+		overrides[recurrenceDate] = o
+		if date, err := ParseDate(o.EventDate); err == nil {
+			d := date.Sub(recurrenceDate)
+			if d < 0 {
+				d = -d
+			}
+			if d > shift {
+				shift = d
+			}
+		}
+	}
+
+	// Multi-day occurrences starting before the window can still reach into it
+	span := e.GetEndDate().Sub(eventDate)
+	if span < 0 {
+		span = 0
+	}
+
+	loc := e.Zone()
+	var occurrences []Event
+	for _, date := range rule.Dates(eventDate, from.Add(-shift-span), to.Add(shift)) {
+		if e.ExDates.Contains(date) {
+			continue
+		}
+		occurrence := *e
+		occurrence.Overrides = nil
+		occurrence.ExDates = nil
+		occurrence.RecurrenceDate = date.Format("2006-01-02")
+		occurrence.EventDate = occurrence.RecurrenceDate
+		occurrence.SetEndDate(date.Add(span))
+		if loc != nil && !occurrence.AllDay {
+			occurrence.localizeTimes(loc)
+		}
+		if o, ok := overrides[date]; ok {
+			o.Apply(&occurrence)
+		}
+		occurrence.SetInstants()
+		if !occurrence.Intersects(from, to) {
+			continue
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, nil
+}
+
+// RecurrenceHorizonYears is how many years ahead open-ended recurring events are expanded
+const RecurrenceHorizonYears = 2
+
+// Span returns the first and last date the event can occur on. Series stop at the horizon
+// after their first date, which bounds the occurrences compared for overlaps.
+func (e *Event) Span() (time.Time, time.Time, error) {
+	from, err := ParseDate(e.EventDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !e.IsRecurring() {
+		return from, e.GetEndDate(), nil
+	}
+	rule, err := ParseRecurrenceRule(e.RRule)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	to := from.AddDate(RecurrenceHorizonYears, 0, 0)
+	if !rule.IsInfinite() {
+		if last, ok := rule.Last(from, to); ok {
+			to = last
+		}
+	}
+
+	// The last occurrence may last several days
+	to = to.Add(e.GetEndDate().Sub(from))
+
+	// Overrides can move an occurrence outside of the span of the rule
+	for _, o := range e.Overrides {
+		if date, err := ParseDate(o.EventDate); err == nil && date.Before(from) {
+			from = date
+		}
+		if date, err := ParseDate(o.EndDate); err == nil && date.After(to) {
+			to = date
+		}
+	}
+	return from, to, nil
+}
+
+// CompareOccurrences compares the position of two occurrences in the event list.
+// Occurrences are sorted by event date, all-day first, start time, id and recurrence date.
+// Start times are compared like timetz values in the database, by instant and then with the larger offset first.
+func CompareOccurrences(a, b *Event, desc bool) int {
+	order := func(less bool) int {
+		if less != desc {
+			return -1
+		}
+		return 1
+	}
+	if !a.GetEventDate().Equal(b.GetEventDate()) {
+		return order(a.GetEventDate().Before(b.GetEventDate()))
+	}
+	if a.AllDay != b.AllDay {
+		if a.AllDay {
+			return -1
+		}
+		return 1
+	}
+	if !a.AllDay {
+		startA, startB := a.GetStartTime(), b.GetStartTime()
+		if !startA.Equal(startB) {
+			return order(startA.Before(startB))
+		}
+		_, offsetA := startA.Zone()
+		_, offsetB := startB.Zone()
+		if offsetA != offsetB {
+			return order(offsetA > offsetB)
+		}
+	}
+	if a.ID != b.ID {
+		return order(a.ID < b.ID)
+	}
+	if a.RecurrenceDate != b.RecurrenceDate {
+		return order(a.RecurrenceDate < b.RecurrenceDate)
+	}
+	return 0
+}
+
+// FormatDates formats the dates of the event and its overrides as YYYY-MM-DD,
+// the database returns them as date-times
+func (e *Event) FormatDates() {
+	e.SetEventDate(e.GetEventDate())
+	e.SetEndDate(e.GetEndDate())
+	for i := range e.Overrides {
+		o := &e.Overrides[i]
+		if date, err := ParseDate(o.RecurrenceDate); err == nil {
+			o.RecurrenceDate = date.Format("2006-01-02")
+		}
+		if date, err := ParseDate(o.EventDate); err == nil {
+			o.EventDate = date.Format("2006-01-02")
+		}
+		if date, err := ParseDate(o.EndDate); err == nil {
+			o.EndDate = date.Format("2006-01-02")
+		}
+	}
 }
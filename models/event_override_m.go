@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EventOverride replaces the details of one occurrence of a recurring event.
+// RecurrenceDate identifies the occurrence as generated by the rule, the
+// other fields hold its new values.
+type EventOverride struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	EventID        uint      `gorm:"uniqueIndex:idx_event_overrides_occurrence" json:"event_id"`
+	RecurrenceDate string    `gorm:"type:date;uniqueIndex:idx_event_overrides_occurrence" json:"recurrence_date"`
+	Title          string    `json:"title"`
+	EventDate      string    `gorm:"type:date" json:"event_date" binding:"required"`
+	EndDate        string    `gorm:"type:date" json:"end_date"`
+	StartTime      TimeOfDay `gorm:"type:timetz" json:"start_time,omitempty"`
+	EndTime        TimeOfDay `gorm:"type:timetz" json:"end_time,omitempty"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+func (EventOverride) TableName() string {
+	return "event_overrides"
+}
+
+// BeforeSave defaults the end date of single-day occurrences
+func (o *EventOverride) BeforeSave(tx *gorm.DB) error {
+	if o.EndDate == "" {
+		o.EndDate = o.EventDate
+	}
+	return nil
+}
+
+// Apply copies the overridden values onto an expanded occurrence
+func (o *EventOverride) Apply(occurrence *Event) {
+	if o.Title != "" {
+		occurrence.Title = o.Title
+	}
+	if date, err := ParseDate(o.EventDate); err == nil {
+		occurrence.SetEventDate(date)
+		occurrence.SetEndDate(date)
+	}
+	if date, err := ParseDate(o.EndDate); err == nil {
+		occurrence.SetEndDate(date)
+	}
+	if !occurrence.AllDay {
+		occurrence.StartTime = o.StartTime
+		occurrence.EndTime = o.EndTime
+	}
+}
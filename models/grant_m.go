@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// Role is the access a user has to a calendar or an event. Each role includes the ones before it:
+// freebusy only sees when events are busy, viewer sees them, editor changes them and owner also
+// deletes them for good and shares them.
+type Role string
+
+const (
+	RoleFreeBusy Role = "freebusy"
+	RoleViewer   Role = "viewer"
+	RoleEditor   Role = "editor"
+	RoleOwner    Role = "owner"
+)
+
+// Rank of the roles, zero for no role
+var roleRanks = map[Role]int{RoleFreeBusy: 1, RoleViewer: 2, RoleEditor: 3, RoleOwner: 4}
+
+// Includes reports whether the role gives the access of the other role
+func (r Role) Includes(other Role) bool {
+	return roleRanks[r] > 0 && roleRanks[r] >= roleRanks[other]
+}
+
+// Max returns the role giving the most access of the two
+func (r Role) Max(other Role) Role {
+	if roleRanks[other] > roleRanks[r] {
+		return other
+	}
+	return r
+}
+
+// Grant gives a user a role on a calendar and all its events, or on a single event
+type Grant struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CalendarID *uint     `gorm:"index" json:"calendar_id,omitempty"`
+	EventID    *uint     `gorm:"index" json:"event_id,omitempty"`
+	UserID     uint      `gorm:"index;not null" json:"user_id" binding:"required"`
+	Role       Role      `gorm:"not null" json:"role" binding:"required,oneof=owner editor viewer freebusy"`
+	GrantedBy  uint      `gorm:"not null;default:0" json:"granted_by"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+func (Grant) TableName() string {
+	return "grants"
+}
+
+// Roles holds the roles of a user: owner of the calendars and events they created, and the roles
+// of the grants they were given. Every user sees when the events of the default calendar are busy.
+// Admins have every role, nil users have none.
+type Roles struct {
+	User            *User
+	Calendars       map[uint]Role
+	Events          map[uint]Role
+	DefaultCalendar uint
+}
+
+// NewRoles reads the roles of a user from the owners of the calendars and the grants of the user
+func NewRoles(user *User, calendars []Calendar, grants []Grant) *Roles {
+	roles := &Roles{User: user, Calendars: map[uint]Role{}, Events: map[uint]Role{}}
+	if user == nil {
+		return roles
+	}
+	for _, calendar := range calendars {
+		if calendar.IsDefault {
+			roles.DefaultCalendar = calendar.ID
+			roles.Calendars[calendar.ID] = RoleFreeBusy
+		}
+		if calendar.OwnerID != nil && *calendar.OwnerID == user.ID {
+			roles.Calendars[calendar.ID] = RoleOwner
+		}
+	}
+	for _, grant := range grants {
+		if grant.UserID != user.ID {
+			continue
+		}
+		if grant.CalendarID != nil {
+			roles.Calendars[*grant.CalendarID] = roles.Calendars[*grant.CalendarID].Max(grant.Role)
+		}
+		if grant.EventID != nil {
+			roles.Events[*grant.EventID] = roles.Events[*grant.EventID].Max(grant.Role)
+		}
+	}
+	return roles
+}
+
+// Admin reports whether the user is an admin
+func (r *Roles) Admin() bool {
+	return r.User != nil && r.User.Admin
+}
+
+// CalendarRole returns the role of the user on a calendar, empty for none
+func (r *Roles) CalendarRole(calendarID uint) Role {
+	if r.Admin() {
+		return RoleOwner
+	}
+	return r.Calendars[calendarID]
+}
+
+// EventRole returns the role of the user on an event: owner of their own events, else the best
+// role they have on the event and its calendar
+func (r *Roles) EventRole(event *Event) Role {
+	if r.User == nil {
+		return ""
+	}
+	if r.User.Admin || event.OwnerID != nil && *event.OwnerID == r.User.ID {
+		return RoleOwner
+	}
+	return r.Events[event.ID].Max(r.Calendars[event.CalendarID])
+}
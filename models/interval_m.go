@@ -0,0 +1,111 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Interval is a period of time from Start up to End
+type Interval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// MergeIntervals sorts intervals and coalesces the ones that overlap or touch
+func MergeIntervals(intervals []Interval) []Interval {
+	sorted := make([]Interval, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	merged := []Interval{}
+	for _, interval := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && !interval.Start.After(merged[last].End) {
+			if interval.End.After(merged[last].End) {
+				merged[last].End = interval.End
+			}
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// SlotOptions describes the free slots searched by FreeSlots
+type SlotOptions struct {
+	Duration    time.Duration
+	Granularity time.Duration
+	// Working hours as times of day on the clock, a zero WorkEnd means the end of the day
+	WorkStart time.Duration
+	WorkEnd   time.Duration
+	Limit     int
+}
+
+// FreeSlots finds up to Limit slots of Duration inside from and to that do not overlap the busy intervals.
+// A slot overlaps an interval when it starts before the interval ends and ends after the interval starts.
+// Slots start on multiples of Granularity after midnight on the clock of the location of from,
+// lie inside the working hours of a single day, and do not overlap each other. Working hours keep
+// their times of day on the days daylight saving time starts or ends.
+func FreeSlots(busy []Interval, from, to time.Time, opts SlotOptions) []Interval {
+	busy = MergeIntervals(busy)
+	loc := from.Location()
+	workEnd := opts.WorkEnd
+	if workEnd == 0 {
+		workEnd = 24 * time.Hour
+	}
+
+	slots := []Interval{}
+	next := 0
+	start := alignTime(from.In(loc), opts.Granularity)
+	for len(slots) < opts.Limit {
+		// Keep the slot inside the working hours of its day
+		if dayStart := atClock(start, opts.WorkStart); start.Before(dayStart) {
+			start = alignTime(dayStart, opts.Granularity)
+		}
+		if start.Add(opts.Duration).After(atClock(start, workEnd)) {
+			nextDay := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, loc)
+			start = alignTime(atClock(nextDay, opts.WorkStart), opts.Granularity)
+			if !start.Before(to) {
+				break
+			}
+			continue
+		}
+		end := start.Add(opts.Duration)
+		if end.After(to) {
+			break
+		}
+
+		// Skip the busy intervals that end before the slot, then move past the first one it overlaps
+		for next < len(busy) && !busy[next].End.After(start) {
+			next++
+		}
+		if next < len(busy) && busy[next].Start.Before(end) {
+			start = alignTime(busy[next].End.In(loc), opts.Granularity)
+			continue
+		}
+
+		slots = append(slots, Interval{Start: start, End: end})
+		start = alignTime(end, opts.Granularity)
+	}
+	return slots
+}
+
+// alignTime rounds a time up to a multiple of the granularity after its midnight on the clock
+func alignTime(t time.Time, granularity time.Duration) time.Time {
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	if rest := clock % granularity; rest != 0 {
+		clock += granularity - rest
+	}
+	aligned := atClock(t, clock)
+	// Times of day repeated when daylight saving time ends are read as the first of them
+	for aligned.Before(t) {
+		aligned = aligned.Add(granularity)
+	}
+	return aligned
+}
+
+// atClock returns the time of day on the clock, as an offset from midnight, on the day of t in its location
+func atClock(t time.Time, clock time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, int(clock%time.Minute), t.Location())
+}
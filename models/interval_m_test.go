@@ -0,0 +1,106 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestMergeIntervals(t *testing.T) {
+	at := func(s string) time.Time {
+		t, _ := time.Parse("2006-01-02T15:04", s)
+		return t
+	}
+	intervals := []Interval{
+		{at("2024-01-01T13:00"), at("2024-01-01T14:00")},
+		{at("2024-01-01T09:00"), at("2024-01-01T10:00")},
+		// Overlapping
+		{at("2024-01-01T09:30"), at("2024-01-01T11:00")},
+		// Adjacent
+		{at("2024-01-01T11:00"), at("2024-01-01T11:30")},
+		// Contained
+		{at("2024-01-01T13:15"), at("2024-01-01T13:45")},
+		{at("2024-01-01T23:00"), at("2024-01-02T01:00")},
+	}
+
+	merged := MergeIntervals(intervals)
+	assert.DeepEqual(t, []Interval{
+		{at("2024-01-01T09:00"), at("2024-01-01T11:30")},
+		{at("2024-01-01T13:00"), at("2024-01-01T14:00")},
+		{at("2024-01-01T23:00"), at("2024-01-02T01:00")},
+	}, merged)
+
+	// The input is left unchanged
+	assert.Equal(t, at("2024-01-01T13:00"), intervals[0].Start)
+	assert.DeepEqual(t, []Interval{}, MergeIntervals(nil))
+}
+
+func TestFreeSlots(t *testing.T) {
+	loc := time.FixedZone("", 7*3600)
+	at := func(s string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02T15:04", s, loc)
+		return t
+	}
+	format := func(slots []Interval) []string {
+		formatted := []string{}
+		for _, slot := range slots {
+			formatted = append(formatted, slot.Start.Format("01-02T15:04")+"/"+slot.End.Format("15:04"))
+		}
+		return formatted
+	}
+	busy := []Interval{
+		{at("2024-01-01T09:00"), at("2024-01-01T10:00")},
+		{at("2024-01-01T10:40"), at("2024-01-01T12:00")},
+		{at("2024-01-01T16:00"), at("2024-01-02T10:00")},
+	}
+	opts := SlotOptions{
+		Duration:    time.Hour,
+		Granularity: 30 * time.Minute,
+		WorkStart:   9 * time.Hour,
+		WorkEnd:     17 * time.Hour,
+		Limit:       5,
+	}
+
+	// Test case 1: slots skip busy intervals, keep to working hours and touch busy intervals
+	slots := FreeSlots(busy, at("2024-01-01T08:10"), at("2024-01-03T00:00"), opts)
+	assert.DeepEqual(t, []string{
+		"01-01T12:00/13:00",
+		"01-01T13:00/14:00",
+		"01-01T14:00/15:00",
+		"01-01T15:00/16:00",
+		"01-02T10:00/11:00",
+	}, format(slots))
+
+	// Test case 2: the start is rounded up to the granularity and the window end is respected
+	opts.Granularity = 15 * time.Minute
+	slots = FreeSlots(busy, at("2024-01-01T12:05"), at("2024-01-01T14:30"), opts)
+	assert.DeepEqual(t, []string{"01-01T12:15/13:15", "01-01T13:15/14:15"}, format(slots))
+
+	// Test case 3: a slot that does not fit before the end of the working day moves to the next day
+	opts.Duration = 2 * time.Hour
+	slots = FreeSlots(nil, at("2024-01-01T15:30"), at("2024-01-03T00:00"), opts)
+	assert.DeepEqual(t, []string{
+		"01-02T09:00/11:00",
+		"01-02T11:00/13:00",
+		"01-02T13:00/15:00",
+		"01-02T15:00/17:00",
+	}, format(slots))
+
+	// Test case 4: working hours keep their times of day when daylight saving time starts and ends
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NilError(t, err)
+	opts.Duration, opts.Granularity, opts.Limit = time.Hour, 30*time.Minute, 2
+	for _, day := range []string{"2024-03-10", "2024-11-03"} {
+		from, err := time.ParseInLocation("2006-01-02T15:04", day+"T00:00", newYork)
+		assert.NilError(t, err)
+		slots = FreeSlots(nil, from, from.AddDate(0, 0, 1), opts)
+		assert.Equal(t, 2, len(slots), day)
+		assert.Equal(t, day+"T09:00", slots[0].Start.Format("2006-01-02T15:04"))
+		late, err := time.ParseInLocation("2006-01-02T15:04", day+"T16:00", newYork)
+		assert.NilError(t, err)
+		slots = FreeSlots(nil, late, from.AddDate(0, 0, 1), opts)
+		assert.Equal(t, 1, len(slots), day)
+		assert.Equal(t, day+"T17:00", slots[0].End.Format("2006-01-02T15:04"))
+	}
+}
@@ -0,0 +1,418 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported RRULE frequencies
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// WeekdayNum is a BYDAY entry such as MO, 1MO or -1FR.
+// N is zero when the entry has no ordinal.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// MaxRecurrenceCount is the largest COUNT accepted in a rule
+const MaxRecurrenceCount = 1000
+
+// MaxRecurrenceYears is how many years after its first date a series may end with UNTIL
+const MaxRecurrenceYears = 100
+
+// maxRecurrenceUntil is the first UNTIL date no longer accepted in a rule, keeping dates clear of year 10000
+var maxRecurrenceUntil = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// RecurrenceRule is the subset of an RFC 5545 RRULE supported by the API:
+// FREQ, INTERVAL, BYDAY, COUNT and UNTIL.
+type RecurrenceRule struct {
+	Freq     string
+	Interval int
+	ByDay    []WeekdayNum
+	Count    int
+	Until    time.Time
+}
+
+// ParseRecurrenceRule parses an RRULE value like "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// A leading "RRULE:" prefix is accepted.
+func ParseRecurrenceRule(s string) (*RecurrenceRule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("empty rule")
+	}
+
+	rule := &RecurrenceRule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed part %q", part)
+		}
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(value)
+			switch rule.Freq {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			rule.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", value)
+			}
+			if count > MaxRecurrenceCount {
+				return nil, fmt.Errorf("COUNT must be at most %d", MaxRecurrenceCount)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseRuleDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", value)
+			}
+			if !until.Before(maxRecurrenceUntil) {
+				return nil, fmt.Errorf("UNTIL must be before %s", maxRecurrenceUntil.Format("2006-01-02"))
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				wd, err := parseWeekdayNum(day)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		case "WKST":
+			// weeks always start on Monday
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	for _, wd := range rule.ByDay {
+		if wd.N == 0 {
+			continue
+		}
+		if rule.Freq != FreqMonthly {
+			return nil, fmt.Errorf("BYDAY ordinals are only supported with FREQ=%s", FreqMonthly)
+		}
+	}
+	if rule.Freq == FreqYearly && len(rule.ByDay) > 0 {
+		return nil, fmt.Errorf("BYDAY is not supported with FREQ=%s", FreqYearly)
+	}
+	return rule, nil
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+	weekday, ok := weekdayCodes[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+	}
+	wd := WeekdayNum{Weekday: weekday}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n > 5 || n < -5 {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %q", s)
+		}
+		wd.N = n
+	}
+	return wd, nil
+}
+
+// parseRuleDate accepts the DATE and DATE-TIME forms allowed for UNTIL.
+// Only the date part matters because occurrences are whole days.
+func parseRuleDate(s string) (time.Time, error) {
+	if len(s) >= 8 {
+		if t, err := time.Parse("20060102", s[:8]); err == nil {
+			return t, nil
+		}
+	}
+	return ParseDate(s)
+}
+
+// String formats the rule as an RRULE value. UNTIL is a DATE unless it has a
+// time of day, in which case it is written as a UTC DATE-TIME.
+func (r *RecurrenceRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = strings.ToUpper(wd.Weekday.String()[:2])
+			if wd.N != 0 {
+				days[i] = strconv.Itoa(wd.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.Until.Equal(truncateDate(r.Until)) {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// CheckStart checks that a series starting on dtstart ends within MaxRecurrenceYears of it
+func (r *RecurrenceRule) CheckStart(dtstart time.Time) error {
+	if !r.Until.IsZero() && r.Until.After(truncateDate(dtstart).AddDate(MaxRecurrenceYears, 0, 0)) {
+		return fmt.Errorf("UNTIL must be within %d years of the first date", MaxRecurrenceYears)
+	}
+	return nil
+}
+
+// IsInfinite reports whether the rule has neither COUNT nor UNTIL.
+func (r *RecurrenceRule) IsInfinite() bool {
+	return r.Count == 0 && r.Until.IsZero()
+}
+
+// Dates returns the occurrence dates of a series starting on dtstart that
+// fall within [from, to]. DTSTART is always the first occurrence, as in RFC 5545.
+// All dates are truncated to midnight UTC.
+func (r *RecurrenceRule) Dates(dtstart, from, to time.Time) []time.Time {
+	from = truncateDate(from)
+	var dates []time.Time
+	r.walk(dtstart, to, func(d time.Time) {
+		if !d.Before(from) {
+			dates = append(dates, d)
+		}
+	})
+	return dates
+}
+
+// Last returns the last occurrence date of a series starting on dtstart that falls on or before to,
+// without building the dates before it. ok is false when the series has no date by then.
+func (r *RecurrenceRule) Last(dtstart, to time.Time) (last time.Time, ok bool) {
+	r.walk(dtstart, to, func(d time.Time) {
+		last, ok = d, true
+	})
+	return last, ok
+}
+
+// walk visits the occurrence dates of a series starting on dtstart in order, up to to
+func (r *RecurrenceRule) walk(dtstart, to time.Time, visit func(time.Time)) {
+	dtstart, to = truncateDate(dtstart), truncateDate(to)
+
+	emitted := 0
+	emit := func(d time.Time) bool {
+		if !r.Until.IsZero() && d.After(r.Until) {
+			return false
+		}
+		emitted++
+		if r.Count > 0 && emitted > r.Count {
+			return false
+		}
+		if d.After(to) {
+			return false
+		}
+		visit(d)
+		return true
+	}
+
+	if !emit(dtstart) {
+		return
+	}
+	for i := 0; ; i++ {
+		periodStart, candidates := r.period(dtstart, i)
+		if periodStart.After(to) || !r.Until.IsZero() && periodStart.After(r.Until) {
+			return
+		}
+		for _, d := range candidates {
+			if !d.After(dtstart) {
+				continue
+			}
+			if !emit(d) {
+				return
+			}
+		}
+	}
+}
+
+// period returns the first day of the i-th period of the series together
+// with the sorted candidate dates generated inside it.
+func (r *RecurrenceRule) period(dtstart time.Time, i int) (time.Time, []time.Time) {
+	var start time.Time
+	var candidates []time.Time
+
+	switch r.Freq {
+	case FreqDaily:
+		start = dtstart.AddDate(0, 0, i*r.Interval)
+		if r.matchesWeekday(start.Weekday()) {
+			candidates = append(candidates, start)
+		}
+	case FreqWeekly:
+		offset := (int(dtstart.Weekday()) + 6) % 7
+		start = dtstart.AddDate(0, 0, -offset+7*i*r.Interval)
+		for d := 0; d < 7; d++ {
+			day := start.AddDate(0, 0, d)
+			if len(r.ByDay) == 0 && day.Weekday() == dtstart.Weekday() || len(r.ByDay) > 0 && r.matchesWeekday(day.Weekday()) {
+				candidates = append(candidates, day)
+			}
+		}
+	case FreqMonthly:
+		start = time.Date(dtstart.Year(), dtstart.Month()+time.Month(i*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+		if len(r.ByDay) == 0 {
+			if day, ok := validDate(start.Year(), start.Month(), dtstart.Day()); ok {
+				candidates = append(candidates, day)
+			}
+			break
+		}
+		candidates = monthlyByDay(start, r.ByDay)
+	case FreqYearly:
+		start = time.Date(dtstart.Year()+i*r.Interval, 1, 1, 0, 0, 0, 0, time.UTC)
+		if day, ok := validDate(start.Year(), dtstart.Month(), dtstart.Day()); ok {
+			candidates = append(candidates, day)
+		}
+	}
+	return start, candidates
+}
+
+func (r *RecurrenceRule) matchesWeekday(weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// monthlyByDay expands BYDAY entries inside the month starting at first.
+func monthlyByDay(first time.Time, byDay []WeekdayNum) []time.Time {
+	last := first.AddDate(0, 1, -1)
+	seen := map[int]bool{}
+	for _, wd := range byDay {
+		var matches []int
+		for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+			if d.Weekday() == wd.Weekday {
+				matches = append(matches, d.Day())
+			}
+		}
+		switch {
+		case wd.N == 0:
+			for _, day := range matches {
+				seen[day] = true
+			}
+		case wd.N > 0 && wd.N <= len(matches):
+			seen[matches[wd.N-1]] = true
+		case wd.N < 0 && -wd.N <= len(matches):
+			seen[matches[len(matches)+wd.N]] = true
+		}
+	}
+
+	days := make([]int, 0, len(seen))
+	for day := range seen {
+		days = append(days, day)
+	}
+	sort.Ints(days)
+	candidates := make([]time.Time, len(days))
+	for i, day := range days {
+		candidates[i] = time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+	}
+	return candidates
+}
+
+// validDate builds a date without normalizing overflowing days,
+// so the 31st is skipped in shorter months as RFC 5545 requires.
+func validDate(year int, month time.Month, day int) (time.Time, bool) {
+	t := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return t, t.Month() == month
+}
+
+func truncateDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ParseDate parses a date in YYYY-MM-DD form, or the RFC 3339 form
+// the database driver returns for date columns.
+func ParseDate(s string) (time.Time, error) {
+	t, err := time.Parse("2006-01-02", s)
+	if err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return truncateDate(t), nil
+	}
+	return time.Time{}, err
+}
+
+// DateList stores a list of YYYY-MM-DD dates as comma separated text
+type DateList []string
+
+func (l DateList) Value() (driver.Value, error) {
+	return strings.Join(l, ","), nil
+}
+
+func (l *DateList) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into DateList", value)
+	}
+	*l = nil
+	if s == "" {
+		return nil
+	}
+	*l = strings.Split(s, ",")
+	return nil
+}
+
+// Contains reports whether the list holds the given date
+func (l DateList) Contains(date time.Time) bool {
+	for _, s := range l {
+		if d, err := ParseDate(s); err == nil && d.Equal(truncateDate(date)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func formatDates(dates []time.Time) []string {
+	formatted := []string{}
+	for _, d := range dates {
+		formatted = append(formatted, d.Format("2006-01-02"))
+	}
+	return formatted
+}
+
+func TestParseRecurrenceRule(t *testing.T) {
+	// Test case 1: valid rule
+	rule, err := ParseRecurrenceRule("RRULE:FREQ=MONTHLY;INTERVAL=2;BYDAY=1MO,-1FR;UNTIL=20240630T000000Z")
+	assert.NilError(t, err)
+	assert.Equal(t, FreqMonthly, rule.Freq)
+	assert.Equal(t, 2, rule.Interval)
+	assert.DeepEqual(t, []WeekdayNum{{N: 1, Weekday: time.Monday}, {N: -1, Weekday: time.Friday}}, rule.ByDay)
+	assert.Equal(t, date("2024-06-30"), rule.Until)
+
+	// Test case 2: invalid rules
+	for _, s := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20240101",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=DAILY;BYHOUR=3",
+		"FREQ=DAILY;COUNT=1001",
+		"FREQ=DAILY;COUNT=1000000000",
+		"FREQ=DAILY;UNTIL=99991231",
+	} {
+		_, err := ParseRecurrenceRule(s)
+		assert.Assert(t, err != nil, s)
+	}
+
+	// Test case 3: series end within a hundred years of their first date
+	rule, err = ParseRecurrenceRule("FREQ=DAILY;UNTIL=21240101")
+	assert.NilError(t, err)
+	assert.NilError(t, rule.CheckStart(date("2024-01-01")))
+	assert.Error(t, rule.CheckStart(date("2023-12-31")), "UNTIL must be within 100 years of the first date")
+}
+
+func TestRecurrenceRuleDates(t *testing.T) {
+	cases := []struct {
+		rule     string
+		dtstart  string
+		from     string
+		to       string
+		expected []string
+	}{
+		// Daily with interval and count
+		{"FREQ=DAILY;INTERVAL=2;COUNT=3", "2024-01-01", "2024-01-01", "2024-12-31",
+			[]string{"2024-01-01", "2024-01-03", "2024-01-05"}},
+		// Weekly on several days, DTSTART counts as the first occurrence
+		{"FREQ=WEEKLY;BYDAY=MO,WE;COUNT=4", "2024-01-03", "2024-01-01", "2024-12-31",
+			[]string{"2024-01-03", "2024-01-08", "2024-01-10", "2024-01-15"}},
+		// Every other week until a date, limited to a window
+		{"FREQ=WEEKLY;INTERVAL=2;UNTIL=20240229", "2024-01-01", "2024-01-20", "2024-12-31",
+			[]string{"2024-01-29", "2024-02-12", "2024-02-26"}},
+		// Monthly on the 31st skips shorter months
+		{"FREQ=MONTHLY;COUNT=3", "2024-01-31", "2024-01-01", "2024-12-31",
+			[]string{"2024-01-31", "2024-03-31", "2024-05-31"}},
+		// Monthly on the last Friday
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", "2024-01-26", "2024-01-01", "2024-12-31",
+			[]string{"2024-01-26", "2024-02-23", "2024-03-29"}},
+		// Yearly on a leap day
+		{"FREQ=YEARLY;COUNT=2", "2024-02-29", "2024-01-01", "2040-12-31",
+			[]string{"2024-02-29", "2028-02-29"}},
+		// Open-ended rule inside a window
+		{"FREQ=DAILY;BYDAY=SA,SU", "2024-01-01", "2024-03-01", "2024-03-10",
+			[]string{"2024-03-02", "2024-03-03", "2024-03-09", "2024-03-10"}},
+	}
+
+	for _, tc := range cases {
+		rule, err := ParseRecurrenceRule(tc.rule)
+		assert.NilError(t, err)
+		dates := rule.Dates(date(tc.dtstart), date(tc.from), date(tc.to))
+		assert.DeepEqual(t, tc.expected, formatDates(dates))
+	}
+}
+
+func TestEventOccurrences(t *testing.T) {
+	event := Event{
+		ID:        1,
+		Title:     "Stand-up",
+		EventDate: "2024-01-01",
+		StartTime: "09:00:00+07",
+		EndTime:   "09:15:00+07",
+		RRule:     "FREQ=WEEKLY;BYDAY=MO,WE",
+		ExDates:   DateList{"2024-01-03"},
+		Overrides: []EventOverride{{
+			RecurrenceDate: "2024-01-08T00:00:00Z",
+			Title:          "Moved stand-up",
+			EventDate:      "2024-01-09T00:00:00Z",
+			StartTime:      "10:00:00+07",
+			EndTime:        "10:15:00+07",
+		}},
+	}
+
+	occurrences, err := event.Occurrences(date("2024-01-01"), date("2024-01-10"))
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(occurrences))
+	assert.Equal(t, "2024-01-01", occurrences[0].EventDate)
+	assert.Equal(t, "2024-01-09", occurrences[1].EventDate)
+	assert.Equal(t, "2024-01-08", occurrences[1].RecurrenceDate)
+	assert.Equal(t, "Moved stand-up", occurrences[1].Title)
+	assert.Equal(t, TimeOfDay("10:00:00+07"), occurrences[1].StartTime)
+	assert.Equal(t, "2024-01-10", occurrences[2].EventDate)
+
+	// An occurrence moved into the window from outside of it is found
+	occurrences, err = event.Occurrences(date("2024-01-09"), date("2024-01-09"))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(occurrences))
+	assert.Equal(t, "Moved stand-up", occurrences[0].Title)
+
+	// Overlap between single occurrences
+	other := Event{EventDate: "2024-01-09", StartTime: "10:10:00+07", EndTime: "11:00:00+07"}
+	assert.Assert(t, occurrences[0].OverlapsWith(&other))
+	other.StartTime = "10:15:00+07"
+	assert.Assert(t, !occurrences[0].OverlapsWith(&other))
+
+	// Overnight occurrences reach into the next day
+	overnight := Event{
+		EventDate: "2024-01-01",
+		EndDate:   "2024-01-02",
+		StartTime: "22:00:00+07",
+		EndTime:   "02:00:00+07",
+		RRule:     "FREQ=WEEKLY",
+	}
+	occurrences, err = overnight.Occurrences(date("2024-01-09"), date("2024-01-09"))
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(occurrences))
+	assert.Equal(t, "2024-01-08", occurrences[0].EventDate)
+	assert.Equal(t, "2024-01-09", occurrences[0].EndDate)
+	other = Event{EventDate: "2024-01-09", StartTime: "01:00:00+07", EndTime: "03:00:00+07"}
+	assert.Assert(t, occurrences[0].OverlapsWith(&other))
+}
+
+func TestEventSpan(t *testing.T) {
+	cases := []struct {
+		rule string
+		to   string
+	}{
+		// Test case 1: the span of a COUNT rule ends on its last date
+		{"FREQ=WEEKLY;COUNT=3", "2024-01-15"},
+		// Test case 2: the span of an UNTIL rule ends on its last date on or before UNTIL
+		{"FREQ=WEEKLY;UNTIL=20240120", "2024-01-15"},
+		// Test case 3: long series stop at the horizon
+		{"FREQ=DAILY;COUNT=1000", "2026-01-01"},
+		{"FREQ=DAILY;UNTIL=21231231", "2026-01-01"},
+		{"FREQ=DAILY", "2026-01-01"},
+	}
+	for _, c := range cases {
+		event := Event{EventDate: "2024-01-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07", RRule: c.rule}
+		from, to, err := event.Span()
+		assert.NilError(t, err, c.rule)
+		assert.Equal(t, "2024-01-01", from.Format("2006-01-02"), c.rule)
+		assert.Equal(t, c.to, to.Format("2006-01-02"), c.rule)
+	}
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Methods notifying the users of reminders
+const (
+	ReminderLog     = "log"
+	ReminderWebhook = "webhook"
+	ReminderEmail   = "email"
+)
+
+// Reminder notifies a user before each occurrence of an event starts, either a number of minutes
+// before it or a number of days before it at a time of day in the time zone of the event.
+// NextFireAt follows the event when it is rescheduled, and is nil once no occurrence is left or the event is deleted.
+type Reminder struct {
+	ID      uint `gorm:"primaryKey" json:"id"`
+	EventID uint `gorm:"index;not null" json:"event_id"`
+	// UserID is the user setting the reminder, who is notified
+	UserID        uint   `gorm:"not null" json:"user_id"`
+	MinutesBefore *int   `json:"minutes_before,omitempty" binding:"omitempty,min=0,max=40320"`
+	DaysBefore    *int   `json:"days_before,omitempty" binding:"omitempty,min=0,max=28"`
+	At            string `gorm:"not null;default:''" json:"at,omitempty"`
+	Method        string `gorm:"not null" json:"method" binding:"omitempty,oneof=log webhook email"`
+	// Email is the address email reminders are sent to
+	Email string `gorm:"not null;default:''" json:"email,omitempty" binding:"omitempty,email,max=254"`
+	// NextFireAt is when the reminder of the occurrence starting at OccurrenceAt fires
+	NextFireAt   *time.Time `json:"next_fire_at"`
+	OccurrenceAt *time.Time `json:"occurrence_at"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"-"`
+}
+
+func (Reminder) TableName() string {
+	return "reminders"
+}
+
+// Validate checks that the reminder is set either in minutes or in days at a time of day,
+// and has an address when it is sent by email
+func (r *Reminder) Validate() error {
+	if (r.MinutesBefore == nil) == (r.DaysBefore == nil) {
+		return errors.New("Either minutes_before or days_before is required")
+	}
+	if r.DaysBefore != nil {
+		if _, err := time.Parse("15:04", r.At); err != nil {
+			return errors.New("Days before need a time of day as HH:MM")
+		}
+	} else if r.At != "" {
+		return errors.New("The time of day only applies to days before")
+	}
+	if r.Method == ReminderEmail && r.Email == "" {
+		return errors.New("Email reminders need an email")
+	}
+	return nil
+}
+
+// FireAt is when the reminder of an occurrence starting at start fires
+func (r *Reminder) FireAt(start time.Time) time.Time {
+	if r.MinutesBefore != nil {
+		return start.Add(-time.Duration(*r.MinutesBefore) * time.Minute)
+	}
+	at, _ := time.Parse("15:04", r.At)
+	return time.Date(start.Year(), start.Month(), start.Day()-*r.DaysBefore, at.Hour(), at.Minute(), 0, 0, start.Location())
+}
+
+// Schedule sets the reminder on the first occurrence of the event starting after the time,
+// or clears it when the event is deleted or has no such occurrence. Reminders of occurrences
+// starting soon after the time fire right away when their time has already passed.
+func (r *Reminder) Schedule(event *Event, after time.Time) {
+	r.NextFireAt, r.OccurrenceAt = nil, nil
+	if event.DeletedAt.Valid {
+		return
+	}
+
+	var next *time.Time
+	if !event.IsRecurring() {
+		start := event.GetStartAt()
+		next = &start
+	} else {
+		// Events starting later are expanded from their start, open-ended ones up to the horizon
+		from := after
+		if start := event.GetStartAt(); start.After(from) {
+			from = start
+		}
+		occurrences, err := event.Occurrences(from.AddDate(0, 0, -2), from.AddDate(RecurrenceHorizonYears, 0, 0))
+		if err != nil {
+			return
+		}
+		for i := range occurrences {
+			start := occurrences[i].GetStartAt()
+			if start.After(after) && (next == nil || start.Before(*next)) {
+				next = &start
+			}
+		}
+	}
+	if next == nil || !next.After(after) {
+		return
+	}
+
+	// Days before are counted in the time zone of the event, across its daylight saving changes
+	if loc := event.Zone(); loc != nil {
+		start := next.In(loc)
+		next = &start
+	}
+	// Times are kept in UTC, so that the same occurrence is always stored the same way
+	fireAt, occurrenceAt := r.FireAt(*next).UTC(), next.UTC()
+	r.NextFireAt, r.OccurrenceAt = &fireAt, &occurrenceAt
+}
+
+// HasOccurrence reports whether an occurrence of the event starts at the instant
+func (e *Event) HasOccurrence(start time.Time) bool {
+	if e.DeletedAt.Valid {
+		return false
+	}
+	if !e.IsRecurring() {
+		return e.GetStartAt().Equal(start)
+	}
+	occurrences, err := e.Occurrences(start.AddDate(0, 0, -2), start.AddDate(0, 0, 2))
+	if err != nil {
+		return false
+	}
+	for i := range occurrences {
+		if occurrences[i].GetStartAt().Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// Statuses of reminder firings
+const (
+	FiringPending  = "pending"
+	FiringSent     = "sent"
+	FiringFailed   = "failed"
+	FiringCanceled = "canceled"
+)
+
+// ReminderFiring is the log of notifying a user of an occurrence. A reminder fires once for
+// each occurrence, pending firings are attempted again at NextAttemptAt until they are sent.
+type ReminderFiring struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ReminderID   uint      `gorm:"not null" json:"reminder_id"`
+	EventID      uint      `gorm:"not null" json:"event_id"`
+	OccurrenceAt time.Time `gorm:"not null" json:"occurrence_at"`
+	FireAt       time.Time `gorm:"not null" json:"fire_at"`
+	Status       string    `gorm:"not null" json:"status"`
+	Attempts     int       `gorm:"not null;default:0" json:"attempts"`
+	// NextAttemptAt is when a pending firing is sent, nil once it is no longer pending
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	Error         string     `gorm:"not null;default:''" json:"error,omitempty"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"-"`
+}
+
+func (ReminderFiring) TableName() string {
+	return "reminder_firings"
+}
+
+// Due reports whether the firing is pending and its next attempt is at or before now
+func (f *ReminderFiring) Due(now time.Time) bool {
+	return f.Status == FiringPending && f.NextAttemptAt != nil && !f.NextAttemptAt.After(now)
+}
@@ -0,0 +1,264 @@
+package models
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Actions recorded by event revisions
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+	RevisionRevert  = "revert"
+)
+
+// Audit describes who made a change and the request it came with
+type Audit struct {
+	Actor      string `gorm:"not null;default:''" json:"actor"`
+	RequestID  string `gorm:"not null;default:''" json:"request_id"`
+	Method     string `gorm:"not null;default:''" json:"method"`
+	Path       string `gorm:"not null;default:''" json:"path"`
+	RemoteAddr string `gorm:"not null;default:''" json:"remote_addr"`
+	UserAgent  string `gorm:"not null;default:''" json:"user_agent"`
+	// RevertedTo is the revision an update brings the event back to
+	RevertedTo *uint `json:"reverted_to,omitempty"`
+}
+
+// EventRevision is an immutable record of a change to an event,
+// with the fields it changed and the state of the event after it
+type EventRevision struct {
+	ID       uint          `gorm:"primaryKey" json:"id"`
+	EventID  uint          `gorm:"index;not null" json:"event_id"`
+	Version  uint          `gorm:"not null" json:"version"`
+	Action   string        `gorm:"not null" json:"action"`
+	Changes  FieldChanges  `gorm:"type:text;not null" json:"changes"`
+	Snapshot EventSnapshot `gorm:"type:text;not null" json:"snapshot"`
+	Audit    `gorm:"embedded"`
+	// CreatedAt is set by the repositories so that it is never updated
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (EventRevision) TableName() string {
+	return "event_revisions"
+}
+
+// NewEventRevision records a change of an event from before to after, before is nil for a creation.
+// Updates are recorded as reverts when the audit names the revision they revert to.
+func NewEventRevision(action string, before, after *Event, audit Audit) *EventRevision {
+	if action == RevisionUpdate && audit.RevertedTo != nil {
+		action = RevisionRevert
+	}
+	snapshot := NewEventSnapshot(after)
+	var previous *EventSnapshot
+	if before != nil {
+		s := NewEventSnapshot(before)
+		previous = &s
+	}
+	return &EventRevision{
+		EventID:   after.ID,
+		Version:   after.Version,
+		Action:    action,
+		Changes:   DiffSnapshots(previous, &snapshot),
+		Snapshot:  snapshot,
+		Audit:     audit,
+		CreatedAt: time.Now(),
+	}
+}
+
+// PreviousSnapshot is the state of the event before the revision, rebuilt from the old values
+// of its changes without the overridden occurrences. It is nil for a creation.
+func (r *EventRevision) PreviousSnapshot() *EventSnapshot {
+	if r.Action == RevisionCreate {
+		return nil
+	}
+	fields := flattenSnapshot(&r.Snapshot)
+	for field, change := range r.Changes {
+		fields[field] = change.Old
+	}
+	b, _ := json.Marshal(fields)
+	var previous EventSnapshot
+	json.Unmarshal(b, &previous)
+	previous.Overrides = []OverrideSnapshot{}
+	return &previous
+}
+
+// EventSnapshot holds the fields of an event kept by its revisions
+type EventSnapshot struct {
+	CalendarID  uint               `json:"calendar_id"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Location    string             `json:"location"`
+	URL         string             `json:"url"`
+	Color       string             `json:"color"`
+	Tags        []string           `json:"tags"`
+	EventDate   string             `json:"event_date"`
+	EndDate     string             `json:"end_date"`
+	StartTime   TimeOfDay          `json:"start_time"`
+	EndTime     TimeOfDay          `json:"end_time"`
+	TimeZone    string             `json:"time_zone"`
+	AllDay      bool               `json:"all_day"`
+	Busy        bool               `json:"busy"`
+	RRule       string             `json:"rrule"`
+	ExDates     []string           `json:"exdates"`
+	Overrides   []OverrideSnapshot `json:"overrides"`
+}
+
+// OverrideSnapshot holds the values of an overridden occurrence kept by the revisions of its event
+type OverrideSnapshot struct {
+	RecurrenceDate string    `json:"recurrence_date"`
+	Title          string    `json:"title"`
+	EventDate      string    `json:"event_date"`
+	EndDate        string    `json:"end_date"`
+	StartTime      TimeOfDay `json:"start_time"`
+	EndTime        TimeOfDay `json:"end_time"`
+}
+
+// NewEventSnapshot copies the fields of an event kept by its revisions
+func NewEventSnapshot(event *Event) EventSnapshot {
+	snapshot := EventSnapshot{
+		CalendarID:  event.CalendarID,
+		Title:       event.Title,
+		Description: event.Description,
+		Location:    event.Location,
+		URL:         event.URL,
+		Color:       event.Color,
+		Tags:        event.TagNames(),
+		EventDate:   event.EventDate,
+		EndDate:     event.EndDate,
+		StartTime:   event.StartTime,
+		EndTime:     event.EndTime,
+		TimeZone:    event.TimeZone,
+		AllDay:      event.AllDay,
+		Busy:        event.IsBusy(),
+		RRule:       event.RRule,
+		ExDates:     append([]string{}, event.ExDates...),
+		Overrides:   []OverrideSnapshot{},
+	}
+	if snapshot.EndDate == "" {
+		snapshot.EndDate = snapshot.EventDate
+	}
+	for _, o := range event.Overrides {
+		snapshot.Overrides = append(snapshot.Overrides, OverrideSnapshot{
+			RecurrenceDate: o.RecurrenceDate,
+			Title:          o.Title,
+			EventDate:      o.EventDate,
+			EndDate:        o.EndDate,
+			StartTime:      o.StartTime,
+			EndTime:        o.EndTime,
+		})
+	}
+	sort.Slice(snapshot.Overrides, func(i, j int) bool {
+		return snapshot.Overrides[i].RecurrenceDate < snapshot.Overrides[j].RecurrenceDate
+	})
+	return snapshot
+}
+
+// Apply copies the fields of the snapshot onto an event, except its overrides
+func (s *EventSnapshot) Apply(event *Event) {
+	busy := s.Busy
+	event.CalendarID = s.CalendarID
+	event.Title = s.Title
+	event.Description = s.Description
+	event.Location = s.Location
+	event.URL = s.URL
+	event.Color = s.Color
+	event.Tags = make([]Tag, len(s.Tags))
+	for i, name := range s.Tags {
+		event.Tags[i] = Tag{Name: name}
+	}
+	event.EventDate = s.EventDate
+	event.EndDate = s.EndDate
+	event.StartTime = s.StartTime
+	event.EndTime = s.EndTime
+	event.TimeZone = s.TimeZone
+	event.AllDay = s.AllDay
+	event.Busy = &busy
+	event.RRule = s.RRule
+	event.ExDates = append(DateList(nil), s.ExDates...)
+}
+
+func (s EventSnapshot) Value() (driver.Value, error) {
+	b, err := json.Marshal(s)
+	return string(b), err
+}
+
+func (s *EventSnapshot) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+// FieldChange is the value of a field before and after a change, null when it did not exist
+type FieldChange struct {
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+}
+
+// FieldChanges are the changed fields of an event by name.
+// Overridden occurrences are named overrides[YYYY-MM-DD] after their recurrence date.
+type FieldChanges map[string]FieldChange
+
+// DiffSnapshots lists the fields that differ between two snapshots, before is nil for a creation
+func DiffSnapshots(before, after *EventSnapshot) FieldChanges {
+	previous, current := flattenSnapshot(before), flattenSnapshot(after)
+	changes := FieldChanges{}
+	for field, value := range current {
+		if !bytes.Equal(previous[field], value) {
+			changes[field] = FieldChange{Old: nullable(previous[field]), New: value}
+		}
+	}
+	for field, value := range previous {
+		if _, ok := current[field]; !ok {
+			changes[field] = FieldChange{Old: value, New: nullable(nil)}
+		}
+	}
+	return changes
+}
+
+// Map the JSON fields of a snapshot, with one entry per override
+func flattenSnapshot(snapshot *EventSnapshot) map[string]json.RawMessage {
+	fields := map[string]json.RawMessage{}
+	if snapshot == nil {
+		return fields
+	}
+	b, _ := json.Marshal(snapshot)
+	json.Unmarshal(b, &fields)
+	delete(fields, "overrides")
+	for _, o := range snapshot.Overrides {
+		fields[fmt.Sprintf("overrides[%s]", o.RecurrenceDate)], _ = json.Marshal(o)
+	}
+	return fields
+}
+
+// JSON null for a missing value
+func nullable(value json.RawMessage) json.RawMessage {
+	if value == nil {
+		return json.RawMessage("null")
+	}
+	return value
+}
+
+func (c FieldChanges) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+func (c *FieldChanges) Scan(value interface{}) error {
+	return scanJSON(value, c)
+}
+
+// Decode a JSON text column
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	case []byte:
+		return json.Unmarshal(v, dest)
+	default:
+		return fmt.Errorf("cannot scan %T into %T", value, dest)
+	}
+}
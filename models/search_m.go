@@ -0,0 +1,168 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Largest number of terms in a search
+const maxSearchTerms = 32
+
+// Errors returned by ParseSearch
+var (
+	ErrInvalidSearch = errors.New("Invalid search")
+	ErrSearchTooLong = errors.New("Search has too many terms")
+)
+
+// SearchQuery is a parsed full-text search such as `standup "sprint review" OR retro`.
+// Terms next to each other must all match, and OR separates alternatives.
+type SearchQuery struct {
+	// Alternatives holds the terms of each alternative, a text matches when it matches all the terms of one
+	Alternatives [][]SearchTerm
+}
+
+// SearchTerm is a word or a quoted phrase of a search
+type SearchTerm struct {
+	// Text is the word, or the words of the phrase separated by single spaces
+	Text   string
+	Phrase bool
+}
+
+// ParseSearch parses a search made of words, quoted phrases and OR
+func ParseSearch(s string) (*SearchQuery, error) {
+	query := &SearchQuery{Alternatives: [][]SearchTerm{nil}}
+	count := 0
+	add := func(term SearchTerm) {
+		last := len(query.Alternatives) - 1
+		query.Alternatives[last] = append(query.Alternatives[last], term)
+		count++
+	}
+
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] == '"' {
+			// A phrase runs to the next quote, or to the end of the search when it is not closed
+			end := strings.IndexByte(s[1:], '"')
+			phrase := s[1:]
+			if end >= 0 {
+				phrase, s = s[1:end+1], s[end+2:]
+			} else {
+				s = ""
+			}
+			if words := strings.Fields(phrase); len(words) > 0 {
+				add(SearchTerm{Text: strings.Join(words, " "), Phrase: len(words) > 1})
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(s, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		word := s
+		if end >= 0 {
+			word, s = s[:end], s[end:]
+		} else {
+			s = ""
+		}
+		if word == "OR" {
+			if len(query.Alternatives[len(query.Alternatives)-1]) > 0 {
+				query.Alternatives = append(query.Alternatives, nil)
+			}
+			continue
+		}
+		add(SearchTerm{Text: word})
+	}
+
+	// A trailing OR leaves an empty alternative
+	if last := len(query.Alternatives) - 1; len(query.Alternatives[last]) == 0 {
+		query.Alternatives = query.Alternatives[:last]
+	}
+	if len(query.Alternatives) == 0 {
+		return nil, ErrInvalidSearch
+	}
+	if count > maxSearchTerms {
+		return nil, ErrSearchTooLong
+	}
+	return query, nil
+}
+
+// Matches reports whether every term of an alternative appears in the text, ignoring case and accents.
+// Databases also match the words of the text sharing a stem with the terms.
+func (q *SearchQuery) Matches(text string) bool {
+	folded := FoldSearchText(text)
+	for _, terms := range q.Alternatives {
+		matched := true
+		for _, term := range terms {
+			matched = matched && strings.Contains(folded, FoldSearchText(term.Text))
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Rank scores how well a text matches the search, higher is better. Each term of the best alternative
+// scores 3 as a whole word or phrase of the text, 2 at the start of a word and 1 inside a word, and the
+// score is divided by the number of words of the text so that shorter texts come first.
+func (q *SearchQuery) Rank(text string) float64 {
+	folded := FoldSearchText(text)
+	words := len(strings.Fields(folded))
+	if words == 0 {
+		return 0
+	}
+	best := 0
+	for _, terms := range q.Alternatives {
+		score := 0
+		for _, term := range terms {
+			score += termScore(folded, FoldSearchText(term.Text))
+		}
+		if score > best {
+			best = score
+		}
+	}
+	return float64(best) / float64(words)
+}
+
+// Best score of the occurrences of a folded term in a folded text
+func termScore(text, term string) int {
+	best := 0
+	for offset := 0; term != "" && offset < len(text); {
+		i := strings.Index(text[offset:], term)
+		if i < 0 {
+			break
+		}
+		start, end := offset+i, offset+i+len(term)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		score := 1
+		if start == 0 || !isWordRune(before) {
+			score = 2
+			if end == len(text) || !isWordRune(after) {
+				score = 3
+			}
+		}
+		if score > best {
+			best = score
+		}
+		_, size := utf8.DecodeRuneInString(text[start:])
+		offset = start + size
+	}
+	return best
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// FoldSearchText lowers the case of a text and removes its accents, so that "Réunion" is searched as "reunion"
+func FoldSearchText(s string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		folded = s
+	}
+	return strings.ToLower(folded)
+}
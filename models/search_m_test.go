@@ -0,0 +1,46 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseSearch(t *testing.T) {
+	query, err := ParseSearch(`  standup "sprint   review" OR retro OR OR "" "demo`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, [][]SearchTerm{
+		{{Text: "standup"}, {Text: "sprint review", Phrase: true}},
+		{{Text: "retro"}},
+		{{Text: "demo"}},
+	}, query.Alternatives)
+
+	// A quote ends a word and a quoted single word is a word
+	query, err = ParseSearch(`plan"ning" or`)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, [][]SearchTerm{{{Text: "plan"}, {Text: "ning"}, {Text: "or"}}}, query.Alternatives)
+
+	for _, search := range []string{"", "   ", `""`, "OR", "OR OR"} {
+		_, err := ParseSearch(search)
+		assert.Equal(t, ErrInvalidSearch, err, search)
+	}
+	_, err = ParseSearch(strings.Repeat("word ", 33))
+	assert.Equal(t, ErrSearchTooLong, err)
+}
+
+func TestSearchRank(t *testing.T) {
+	query, err := ParseSearch("review OR plan")
+	assert.NilError(t, err)
+
+	// Whole words rank above the start of a word, which ranks above the inside of a word
+	assert.Assert(t, query.Rank("Review") > query.Rank("Reviewing"))
+	assert.Assert(t, query.Rank("Reviewing") > query.Rank("Preview"))
+	// Shorter titles rank first
+	assert.Assert(t, query.Rank("Sprint review") > query.Rank("Sprint review with the team"))
+	// Accents and case are ignored
+	assert.Equal(t, query.Rank("Plan"), query.Rank("PLÂN"))
+	assert.Equal(t, 0.0, query.Rank("Standup"))
+	assert.Assert(t, query.Matches("Réview"))
+	assert.Assert(t, !query.Matches("Standup"))
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits on the tags of an event
+const (
+	MaxEventTags = 20
+	maxTagLength = 50
+)
+
+// Errors returned by NormalizeTags
+var (
+	ErrInvalidTag  = errors.New("Tags must have 1 to 50 characters and no commas")
+	ErrTooManyTags = errors.New("Events have at most 20 tags")
+)
+
+// Tag is a label shared by events, such as "work" or "travel".
+// Tags are written in JSON as their name.
+type Tag struct {
+	ID        uint      `gorm:"primaryKey"`
+	Name      string    `gorm:"uniqueIndex;not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// EventTag links an event to one of its tags
+type EventTag struct {
+	EventID uint `gorm:"primaryKey"`
+	TagID   uint `gorm:"primaryKey;index"`
+}
+
+func (EventTag) TableName() string {
+	return "event_tags"
+}
+
+func (t Tag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Name)
+}
+
+func (t *Tag) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &t.Name)
+}
+
+// NormalizeTagName trims a tag name, lowers its case and collapses its spaces, so that " Team  Offsite" is "team offsite"
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// NormalizeTags normalizes the names of tags, removes the duplicates and sorts them by name.
+// Names cannot hold commas, which separate tags in query parameters and iCalendar.
+func NormalizeTags(tags []Tag) ([]Tag, error) {
+	seen := map[string]bool{}
+	normalized := []Tag{}
+	for _, tag := range tags {
+		name := NormalizeTagName(tag.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxTagLength || strings.Contains(name, ",") {
+			return nil, ErrInvalidTag
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, Tag{Name: name})
+		}
+	}
+	if len(normalized) > MaxEventTags {
+		return nil, ErrTooManyTags
+	}
+	sort.Slice(normalized, func(i, j int) bool { return normalized[i].Name < normalized[j].Name })
+	return normalized, nil
+}
+
+// TagNames returns the names of the tags of the event
+func (e *Event) TagNames() []string {
+	names := make([]string, len(e.Tags))
+	for i, tag := range e.Tags {
+		names[i] = tag.Name
+	}
+	return names
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// User is an account of the API. Admins see and change every event and manage the users.
+type User struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex;not null" json:"name" binding:"required,max=100"`
+	Admin     bool      `gorm:"not null;default:false" json:"admin"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+func (User) TableName() string {
+	return "users"
+}
+
+// APIKey authenticates a user with a secret that is only shown when the key is created.
+// The key stores the SHA-256 hash of the secret and its first characters to tell keys apart.
+type APIKey struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	Name      string     `gorm:"not null;default:''" json:"name" binding:"max=100"`
+	Prefix    string     `gorm:"not null" json:"prefix"`
+	Hash      string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// apiKeyPrefix starts the secrets of API keys, so that leaked keys are easy to find
+const apiKeyPrefix = "aimet_"
+
+// NewAPIKeySecret generates the secret of an API key and sets the hash and prefix of the key
+func (k *APIKey) NewAPIKeySecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	k.Hash = HashAPIKey(secret)
+	k.Prefix = secret[:len(apiKeyPrefix)+6]
+	return secret, nil
+}
+
+// HashAPIKey hashes the secret of an API key, secrets are random so they need no salt
+func HashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsAPIKey reports whether a bearer token looks like the secret of an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// Expired reports whether the key can no longer be used at the time now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
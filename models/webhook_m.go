@@ -0,0 +1,206 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change types sent to webhooks, one for each revision action but reverts, which are updates
+const (
+	ChangeEventCreated  = "event.created"
+	ChangeEventUpdated  = "event.updated"
+	ChangeEventDeleted  = "event.deleted"
+	ChangeEventRestored = "event.restored"
+)
+
+// ChangeType is the change type of a revision action
+func ChangeType(action string) string {
+	switch action {
+	case RevisionCreate:
+		return ChangeEventCreated
+	case RevisionDelete:
+		return ChangeEventDeleted
+	case RevisionRestore:
+		return ChangeEventRestored
+	default:
+		return ChangeEventUpdated
+	}
+}
+
+// Webhook subscribes a URL to the changes of events. Each change is POSTed as a WebhookPayload
+// signed with the secret of the webhook, which is only shown when the webhook is created.
+type Webhook struct {
+	ID  uint   `gorm:"primaryKey" json:"id"`
+	URL string `gorm:"not null" json:"url" binding:"required,url,max=2048"`
+	// Types are the change types sent to the webhook, every type when empty
+	Types     ChangeTypes `gorm:"type:text;not null;default:''" json:"types" binding:"dive,oneof=event.created event.updated event.deleted event.restored"`
+	Secret    string      `gorm:"not null" json:"-"`
+	Disabled  bool        `gorm:"not null;default:false" json:"disabled"`
+	CreatedAt time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time   `gorm:"autoUpdateTime" json:"-"`
+}
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// webhookSecretPrefix starts the secrets of webhooks
+const webhookSecretPrefix = "whsec_"
+
+// NewWebhookSecret generates and sets the secret of a webhook
+func (w *Webhook) NewWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	w.Secret = webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return w.Secret, nil
+}
+
+// Subscribes reports whether the change type is sent to the webhook
+func (w *Webhook) Subscribes(changeType string) bool {
+	if w.Disabled {
+		return false
+	}
+	if len(w.Types) == 0 {
+		return true
+	}
+	for _, t := range w.Types {
+		if t == changeType {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature of a body sent at a Unix time: the hex HMAC-SHA256 of
+// the time, a dot and the body, keyed with the secret of the webhook and prefixed by "sha256="
+func (w *Webhook) Sign(timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ChangeTypes is a list of change types stored as comma separated text
+type ChangeTypes []string
+
+func (t ChangeTypes) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+func (t *ChangeTypes) Scan(value interface{}) error {
+	var s string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ChangeTypes", value)
+	}
+	*t = nil
+	if s == "" {
+		return nil
+	}
+	*t = strings.Split(s, ",")
+	return nil
+}
+
+// WebhookPayload is the body sent to webhooks for a change of an event. Before is null for
+// created events and after is null for deleted events.
+type WebhookPayload struct {
+	Type       string    `json:"type"`
+	EventID    uint      `json:"event_id"`
+	RevisionID uint      `json:"revision_id"`
+	Version    uint      `json:"version"`
+	Actor      string    `json:"actor"`
+	RequestID  string    `json:"request_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Before     *Event    `json:"before"`
+	After      *Event    `json:"after"`
+}
+
+// NewWebhookPayload describes the change of an event recorded by a revision
+func NewWebhookPayload(revision *EventRevision, before, after *Event) *WebhookPayload {
+	payload := &WebhookPayload{
+		Type:       ChangeType(revision.Action),
+		EventID:    revision.EventID,
+		RevisionID: revision.ID,
+		Version:    revision.Version,
+		Actor:      revision.Actor,
+		RequestID:  revision.RequestID,
+		OccurredAt: revision.CreatedAt,
+		Before:     before,
+		After:      after,
+	}
+	if payload.Type == ChangeEventDeleted {
+		payload.After = nil
+	}
+	return payload
+}
+
+// Statuses of webhook deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is the log of sending a payload to a webhook. Pending deliveries are attempted
+// again at NextAttemptAt until one attempt succeeds or they run out of attempts and fail.
+type WebhookDelivery struct {
+	ID         uint    `gorm:"primaryKey" json:"id"`
+	WebhookID  uint    `gorm:"index;not null" json:"webhook_id"`
+	RevisionID uint    `gorm:"not null" json:"revision_id"`
+	Type       string  `gorm:"not null" json:"type"`
+	Payload    RawJSON `gorm:"type:text;not null" json:"payload"`
+	Status     string  `gorm:"not null" json:"status"`
+	Attempts   int     `gorm:"not null;default:0" json:"attempts"`
+	// NextAttemptAt is when a pending delivery is sent, nil once it succeeded or failed
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `gorm:"not null;default:0" json:"response_status,omitempty"`
+	Error          string     `gorm:"not null;default:''" json:"error,omitempty"`
+	// RedeliveryOf is the delivery whose payload is sent again
+	RedeliveryOf *uint     `json:"redelivery_of,omitempty"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// Due reports whether the delivery is pending and its next attempt is at or before now
+func (d *WebhookDelivery) Due(now time.Time) bool {
+	return d.Status == DeliveryPending && d.NextAttemptAt != nil && !d.NextAttemptAt.After(now)
+}
+
+// RawJSON is a JSON document stored as text and written as is in JSON
+type RawJSON string
+
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+func (j *RawJSON) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*j = ""
+		return nil
+	}
+	*j = RawJSON(data)
+	return nil
+}
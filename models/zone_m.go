@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidTimeZone is returned for time zones that are not IANA names such as Asia/Bangkok
+var ErrInvalidTimeZone = errors.New("Invalid time zone")
+
+// Time zones already loaded, by name
+var timeZones sync.Map
+
+// LoadTimeZone loads a time zone of the IANA database, such as Europe/Berlin or UTC.
+// The server's own zone cannot be named "Local".
+func LoadTimeZone(name string) (*time.Location, error) {
+	if loc, ok := timeZones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	if name == "" || name == "Local" || strings.HasPrefix(name, "/") || strings.Contains(name, "..") {
+		return nil, ErrInvalidTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimeZone
+	}
+	timeZones.Store(name, loc)
+	return loc, nil
+}
+
+// Layouts of times of day, offsets are written in hours unless they have minutes
+const (
+	timeOfDayLayout        = "15:04:05-07"
+	timeOfDayMinutesLayout = "15:04:05-07:00"
+	localTimeOfDayLayout   = "15:04:05"
+)
+
+// ParseTimeOfDay parses a time with offset such as 15:00:00+07 or 15:00:00+05:30
+func ParseTimeOfDay(s string) (time.Time, error) {
+	t, err := time.Parse(timeOfDayLayout, s)
+	if err != nil {
+		t, err = time.Parse(timeOfDayMinutesLayout, s)
+	}
+	return t, err
+}
+
+// ParseLocalTimeOfDay parses a wall clock time without offset such as 15:00:00
+func ParseLocalTimeOfDay(s string) (time.Time, error) {
+	return time.Parse(localTimeOfDayLayout, s)
+}
+
+// FormatTimeOfDay writes the time of day of t with its offset, like timetz
+func FormatTimeOfDay(t time.Time) TimeOfDay {
+	if _, offset := t.Zone(); offset%3600 != 0 {
+		return TimeOfDay(t.Format(timeOfDayMinutesLayout))
+	}
+	return TimeOfDay(t.Format(timeOfDayLayout))
+}
+
+// Zone returns the time zone of the event, nil when its times only have fixed offsets
+func (e *Event) Zone() *time.Location {
+	if e.TimeZone == "" {
+		return nil
+	}
+	loc, err := LoadTimeZone(e.TimeZone)
+	if err != nil {
+		return nil
+	}
+	return loc
+}
+
+// Zone all-day events start and end their days in, the event's own or else the server's
+func (e *Event) dayZone() *time.Location {
+	if loc := e.Zone(); loc != nil {
+		return loc
+	}
+	return time.Local
+}
+
+// SetInstants stores the instants the event starts and ends at, which all-day events do not have
+func (e *Event) SetInstants() {
+	e.StartAt, e.EndAt = nil, nil
+	if e.AllDay || e.GetStartTime().IsZero() || e.GetEndTime().IsZero() {
+		return
+	}
+	startAt, endAt := e.GetStartAt().UTC(), e.GetEndAt().UTC()
+	e.StartAt, e.EndAt = &startAt, &endAt
+}
+
+// SetSpan sets the dates and times of a timed event from the instants it starts and ends at,
+// written in its time zone or in the offsets of the instants when it has none
+func (e *Event) SetSpan(startAt, endAt time.Time) {
+	if loc := e.Zone(); loc != nil {
+		startAt, endAt = startAt.In(loc), endAt.In(loc)
+	}
+	e.SetEventDate(startAt)
+	e.SetStartTime(startAt)
+	e.SetEndDate(endAt)
+	e.SetEndTime(endAt)
+	e.SetInstants()
+}
+
+// In writes the dates and times of the event and its overrides in the time zone, without changing the instants.
+// All-day events float and keep their dates. The event is meant for display and keeps its own time zone.
+func (e *Event) In(loc *time.Location) {
+	if e.AllDay {
+		return
+	}
+	startAt, endAt := e.GetStartAt().In(loc), e.GetEndAt().In(loc)
+	e.SetEventDate(startAt)
+	e.SetStartTime(startAt)
+	e.SetEndDate(endAt)
+	e.SetEndTime(endAt)
+	e.StartAt, e.EndAt = &startAt, &endAt
+	for i := range e.Overrides {
+		o := &e.Overrides[i]
+		occurrence := Event{EventDate: o.EventDate, EndDate: o.EndDate, StartTime: o.StartTime, EndTime: o.EndTime}
+		if occurrence.GetStartTime().IsZero() || occurrence.GetEndTime().IsZero() {
+			continue
+		}
+		occurrence.In(loc)
+		o.EventDate, o.EndDate = occurrence.EventDate, occurrence.EndDate
+		o.StartTime, o.EndTime = occurrence.StartTime, occurrence.EndTime
+	}
+}
+
+// IntersectsIn reports whether the event touches any day within [from, to] in the time zone.
+// All-day events float and are compared by date, like every event when the zone is nil.
+func (e *Event) IntersectsIn(from, to time.Time, loc *time.Location) bool {
+	if loc == nil || e.AllDay {
+		return e.Intersects(from, to)
+	}
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, loc)
+	return e.GetStartAt().Before(end) && e.GetEndAt().After(start)
+}
+
+// OccurrencesIn expands the event like Occurrences into the occurrences that touch any day within [from, to]
+// in the time zone. Dates of occurrences in the event's own offsets can be two days away from those in the zone.
+func (e *Event) OccurrencesIn(from, to time.Time, loc *time.Location) ([]Event, error) {
+	if loc == nil {
+		return e.Occurrences(from, to)
+	}
+	expanded, err := e.Occurrences(from.AddDate(0, 0, -2), to.AddDate(0, 0, 2))
+	if err != nil {
+		return nil, err
+	}
+	occurrences := expanded[:0]
+	for _, occurrence := range expanded {
+		if occurrence.IntersectsIn(from, to, loc) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences, nil
+}
+
+// Move the times of an occurrence to the offsets its time zone has on its dates,
+// so that occurrences keep their wall clock time across daylight saving changes
+func (e *Event) localizeTimes(loc *time.Location) {
+	start, end := e.GetStartTime(), e.GetEndTime()
+	if start.IsZero() || end.IsZero() {
+		return
+	}
+	startDate, endDate := e.GetEventDate(), e.GetEndDate()
+	e.SetStartTime(time.Date(startDate.Year(), startDate.Month(), startDate.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc))
+	e.SetEndTime(time.Date(endDate.Year(), endDate.Month(), endDate.Day(), end.Hour(), end.Minute(), end.Second(), 0, loc))
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLoadTimeZone(t *testing.T) {
+	// Test case 1: IANA names
+	for _, name := range []string{"Asia/Bangkok", "Europe/Berlin", "America/St_Johns", "UTC"} {
+		loc, err := LoadTimeZone(name)
+		assert.NilError(t, err, name)
+		assert.Equal(t, name, loc.String())
+	}
+
+	// Test case 2: the server's zone, paths and unknown names are rejected
+	for _, name := range []string{"", "Local", "Mars/Olympus", "../etc/passwd", "/usr/share/zoneinfo/UTC"} {
+		_, err := LoadTimeZone(name)
+		assert.Equal(t, ErrInvalidTimeZone, err, name)
+	}
+}
+
+func TestTimeOfDayOffsets(t *testing.T) {
+	// Offsets are written in hours unless they have minutes, and both forms are read
+	for _, s := range []string{"15:00:00+07", "15:00:00+05:30", "15:00:00-03:30", "15:00:00+00"} {
+		clock, err := ParseTimeOfDay(s)
+		assert.NilError(t, err, s)
+		assert.Equal(t, TimeOfDay(s), FormatTimeOfDay(clock))
+	}
+	_, err := ParseTimeOfDay("15:00:00")
+	assert.Assert(t, err != nil)
+}
+
+func TestOccurrencesAcrossDaylightSaving(t *testing.T) {
+	// A weekly meeting at 09:00 in Berlin, which moves to summer time on 2024-03-31
+	event := Event{ID: 1, Title: "Sync", EventDate: "2024-03-25", StartTime: "09:00:00+01", EndTime: "10:00:00+01", TimeZone: "Europe/Berlin", RRule: "FREQ=WEEKLY;COUNT=3"}
+
+	// Test case 1: occurrences keep their wall clock time in the offset of their date
+	occurrences, err := event.Occurrences(date("2024-03-01"), date("2024-04-30"))
+	assert.NilError(t, err)
+	assert.Equal(t, 3, len(occurrences))
+	assert.Equal(t, TimeOfDay("09:00:00+01"), occurrences[0].StartTime)
+	assert.Equal(t, TimeOfDay("09:00:00+02"), occurrences[1].StartTime)
+	assert.Equal(t, TimeOfDay("10:00:00+02"), occurrences[2].EndTime)
+	assert.Equal(t, time.Date(2024, 4, 1, 7, 0, 0, 0, time.UTC), *occurrences[1].StartAt)
+
+	// Test case 2: without a time zone the offset stays fixed
+	event.TimeZone = ""
+	occurrences, err = event.Occurrences(date("2024-03-01"), date("2024-04-30"))
+	assert.NilError(t, err)
+	assert.Equal(t, TimeOfDay("09:00:00+01"), occurrences[1].StartTime)
+
+	// Test case 3: occurrences in the days of another zone, 09:00 in Berlin is the evening of the same day in Auckland
+	event.TimeZone = "Europe/Berlin"
+	auckland, err := LoadTimeZone("Pacific/Auckland")
+	assert.NilError(t, err)
+	occurrences, err = event.OccurrencesIn(date("2024-03-25"), date("2024-03-25"), auckland)
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(occurrences))
+	occurrences, err = event.OccurrencesIn(date("2024-04-02"), date("2024-04-02"), auckland)
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(occurrences))
+}
+
+func TestEventIn(t *testing.T) {
+	event := Event{Title: "Call", EventDate: "2024-03-01", StartTime: "23:30:00+07", EndTime: "00:30:00+07", EndDate: "2024-03-02", TimeZone: "Asia/Bangkok"}
+	kolkata, err := LoadTimeZone("Asia/Kolkata")
+	assert.NilError(t, err)
+
+	// The dates and times move to the other zone, the event keeps its own
+	event.In(kolkata)
+	assert.Equal(t, "2024-03-01", event.EventDate)
+	assert.Equal(t, TimeOfDay("22:00:00+05:30"), event.StartTime)
+	assert.Equal(t, "2024-03-01", event.EndDate)
+	assert.Equal(t, TimeOfDay("23:00:00+05:30"), event.EndTime)
+	assert.Equal(t, "Asia/Bangkok", event.TimeZone)
+	assert.Equal(t, time.Date(2024, 3, 1, 16, 30, 0, 0, time.UTC), event.StartAt.UTC())
+}
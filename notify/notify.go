@@ -0,0 +1,134 @@
+// Package notify delivers the reminders of events to their users through the notifiers of their methods.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+)
+
+// Notification tells a user that an occurrence of an event starts soon
+type Notification struct {
+	FiringID   uint          `json:"firing_id"`
+	ReminderID uint          `json:"reminder_id"`
+	UserID     uint          `json:"user_id"`
+	Email      string        `json:"email,omitempty"`
+	Event      *models.Event `json:"event"`
+	// OccurrenceAt is when the occurrence starts, in the time zone of the event
+	OccurrenceAt time.Time `json:"occurrence_at"`
+	FireAt       time.Time `json:"fire_at"`
+}
+
+// Subject of the notification, like "Review starts at 2024-03-01 09:00 +07:00"
+func (n *Notification) Subject() string {
+	return fmt.Sprintf("%s starts at %s", n.Event.Title, n.OccurrenceAt.Format("2006-01-02 15:04 -07:00"))
+}
+
+// Notifier delivers notifications through one reminder method
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Notifiers are the notifiers of the configured reminder methods
+type Notifiers map[string]Notifier
+
+// LogNotifier writes notifications to a logger, or to the standard logger without one
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func (l *LogNotifier) Notify(ctx context.Context, n *Notification) error {
+	message := fmt.Sprintf("Reminder %d for user %d: %s", n.ReminderID, n.UserID, n.Subject())
+	if l.Logger == nil {
+		log.Print(message)
+		return nil
+	}
+	l.Logger.Print(message)
+	return nil
+}
+
+// WebhookNotifier POSTs notifications as JSON to a URL, signed like the deliveries of webhooks
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "aimet-reminders")
+	req.Header.Set("X-Aimet-Delivery", strconv.FormatUint(uint64(n.FiringID), 10))
+	req.Header.Set("X-Aimet-Event", "reminder.fired")
+	req.Header.Set("X-Aimet-Timestamp", strconv.FormatInt(timestamp, 10))
+	if w.Secret != "" {
+		req.Header.Set("X-Aimet-Signature", (&models.Webhook{Secret: w.Secret}).Sign(timestamp, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Reading the body lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Unexpected response status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SMTPNotifier emails notifications through an SMTP server. SendMail is smtp.SendMail
+// unless it is replaced, by tests for example.
+type SMTPNotifier struct {
+	Addr     string
+	From     string
+	Auth     smtp.Auth
+	SendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (s *SMTPNotifier) Notify(ctx context.Context, n *Notification) error {
+	if n.Email == "" {
+		return fmt.Errorf("Reminder %d has no email", n.ReminderID)
+	}
+	// Addresses are validated by the API, line breaks are still kept out of the headers
+	subject := strings.NewReplacer("\r", " ", "\n", " ").Replace(n.Subject())
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", n.Email)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n", subject)
+	if n.Event.Description != "" {
+		fmt.Fprintf(&msg, "\r\n%s\r\n", n.Event.Description)
+	}
+
+	send := s.SendMail
+	if send == nil {
+		send = smtp.SendMail
+	}
+	return send(s.Addr, s.Auth, s.From, []string{n.Email}, msg.Bytes())
+}
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"gotest.tools/v3/assert"
+)
+
+// Notification of an occurrence starting at 09:00 in Bangkok
+func newTestNotification() *Notification {
+	bangkok := time.FixedZone("", 7*60*60)
+	return &Notification{
+		FiringID:     3,
+		ReminderID:   2,
+		UserID:       1,
+		Email:        "alice@example.com",
+		Event:        &models.Event{ID: 1, Title: "Review", Description: "Quarterly review"},
+		OccurrenceAt: time.Date(2024, 3, 1, 9, 0, 0, 0, bangkok),
+		FireAt:       time.Date(2024, 3, 1, 1, 50, 0, 0, time.UTC),
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	status := http.StatusOK
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+	notifier := &WebhookNotifier{URL: receiver.URL, Secret: "secret"}
+
+	// Test case 1: notifications are POSTed as JSON with a signature of their timestamp and body
+	assert.NilError(t, notifier.Notify(context.Background(), newTestNotification()))
+	assert.Equal(t, "reminder.fired", received.Header.Get("X-Aimet-Event"))
+	assert.Equal(t, "3", received.Header.Get("X-Aimet-Delivery"))
+	timestamp, err := strconv.ParseInt(received.Header.Get("X-Aimet-Timestamp"), 10, 64)
+	assert.NilError(t, err)
+	assert.Equal(t, (&models.Webhook{Secret: "secret"}).Sign(timestamp, body), received.Header.Get("X-Aimet-Signature"))
+	var n Notification
+	assert.NilError(t, json.Unmarshal(body, &n))
+	assert.Equal(t, uint(2), n.ReminderID)
+	assert.Equal(t, "Review", n.Event.Title)
+
+	// Test case 2: responses other than 2xx are errors
+	status = http.StatusBadGateway
+	err = notifier.Notify(context.Background(), newTestNotification())
+	assert.Error(t, err, "Unexpected response status 502")
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	var to []string
+	var msg string
+	notifier := &SMTPNotifier{Addr: "smtp.example.com:587", From: "aimet@example.com",
+		SendMail: func(addr string, a smtp.Auth, from string, recipients []string, body []byte) error {
+			to, msg = recipients, string(body)
+			return nil
+		}}
+
+	// Test case 1: notifications are emailed to the address of the reminder
+	assert.NilError(t, notifier.Notify(context.Background(), newTestNotification()))
+	assert.DeepEqual(t, []string{"alice@example.com"}, to)
+	assert.Assert(t, strings.Contains(msg, "Subject: Review starts at 2024-03-01 09:00 +07:00\r\n"))
+	assert.Assert(t, strings.Contains(msg, "\r\n\r\nReview starts at 2024-03-01 09:00 +07:00\r\n\r\nQuarterly review\r\n"))
+
+	// Test case 2: line breaks in titles are kept out of the headers
+	n := newTestNotification()
+	n.Event.Title = "Review\r\nBcc: mallory@example.com"
+	assert.NilError(t, notifier.Notify(context.Background(), n))
+	assert.Assert(t, !strings.Contains(msg, "\r\nBcc:"))
+
+	// Test case 3: reminders without an address are not sent
+	n.Email = ""
+	assert.ErrorContains(t, notifier.Notify(context.Background(), n), "has no email")
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"gorm.io/gorm"
+)
+
+// sqlDialect writes the SQL that differs between the databases of the GORM repositories.
+// Dates are stored as YYYY-MM-DD and times with offset as 15:04:05+07 or 15:04:05+05:30 in both.
+type sqlDialect interface {
+	// timestamp is the value of an instant compared with instant
+	timestamp(t time.Time) interface{}
+	// instant reads a timestamp written by GORM as an instant comparable with timestamp
+	instant(column string) string
+	// timeOfDay orders times with offset by instant, like timetz
+	timeOfDay(clock string) string
+	// contains matches the column containing the keyword, case sensitive
+	contains(column, keyword string) (string, interface{})
+	// searchTitle matches the events whose title has the words of the term, or contains it,
+	// ignoring case and accents. The search indexes of the migrations serve both.
+	searchTitle(term models.SearchTerm) (string, []interface{})
+}
+
+// Pick the dialect of the database
+func dialectOf(db *gorm.DB) sqlDialect {
+	if db.Dialector.Name() == "sqlite" {
+		return sqliteDialect{}
+	}
+	return postgresDialect{}
+}
+
+// postgresDialect relies on the date and timetz types of PostgreSQL
+type postgresDialect struct{}
+
+func (postgresDialect) timestamp(t time.Time) interface{} {
+	return t
+}
+
+func (postgresDialect) instant(column string) string {
+	return column
+}
+
+func (postgresDialect) timeOfDay(clock string) string {
+	return clock
+}
+
+func (postgresDialect) contains(column, keyword string) (string, interface{}) {
+	return column + " LIKE ?", "%" + keyword + "%"
+}
+
+// Words are matched by their stem with the event_search configuration, which removes accents
+func (postgresDialect) searchTitle(term models.SearchTerm) (string, []interface{}) {
+	query := "plainto_tsquery"
+	if term.Phrase {
+		query = "phraseto_tsquery"
+	}
+	return "(to_tsvector('event_search', coalesce(title, '')) @@ " + query + "('event_search', ?) OR event_search_fold(title) LIKE ?)",
+		[]interface{}{term.Text, likePattern(term.Text)}
+}
+
+// sqliteDialect computes instants with the date and time functions of SQLite, which read
+// offsets as +07:00. Instants are compared as UTC text and times of day as fractional days,
+// so unlike timetz the same instant written with different offsets sorts as equal.
+type sqliteDialect struct{}
+
+func (sqliteDialect) timestamp(t time.Time) interface{} {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+func (sqliteDialect) instant(column string) string {
+	return "datetime(" + column + ")"
+}
+
+// Offsets in hours such as +07 are completed to +07:00, those with minutes are kept
+func (sqliteDialect) timeOfDay(clock string) string {
+	return fmt.Sprintf("(julianday('2000-01-01 ' || substr(%s || ':00', 1, 14)) - julianday('2000-01-01'))", clock)
+}
+
+func (sqliteDialect) contains(column, keyword string) (string, interface{}) {
+	return "instr(" + column + ", ?) > 0", keyword
+}
+
+// Words are matched by their stem in the events_search FTS5 table, and event_search_fold
+// is registered with the driver by configs.OpenSQLiteDB
+func (sqliteDialect) searchTitle(term models.SearchTerm) (string, []interface{}) {
+	return "(id IN (SELECT rowid FROM events_search WHERE events_search MATCH ?) OR event_search_fold(title) LIKE ? ESCAPE '\\')",
+		[]interface{}{`"` + strings.ReplaceAll(term.Text, `"`, `""`) + `"`, likePattern(term.Text)}
+}
+
+// LIKE pattern of the folded text, with its wildcards escaped by backslashes
+func likePattern(text string) string {
+	text = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(models.FoldSearchText(text))
+	return "%" + text + "%"
+}
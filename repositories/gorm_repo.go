@@ -0,0 +1,1025 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gormEventRepository stores events in the database
+type gormEventRepository struct {
+	db      *gorm.DB
+	dialect sqlDialect
+}
+
+// NewGormEventRepository stores events with GORM in PostgreSQL or SQLite
+func NewGormEventRepository(db *gorm.DB) EventRepository {
+	return &gormEventRepository{db: db, dialect: dialectOf(db)}
+}
+
+func (r *gormEventRepository) Get(id uint) (*models.Event, error) {
+	var event models.Event
+	if err := preloadDetails(r.db).Where("id = ?", id).First(&event).Error; err != nil {
+		return nil, notFound(err)
+	}
+	event.FormatDates()
+	return &event, nil
+}
+
+func (r *gormEventRepository) FindByUID(uid string) (*models.Event, error) {
+	var event models.Event
+	if err := preloadDetails(r.db).Where("uid = ?", uid).First(&event).Error; err != nil {
+		return nil, notFound(err)
+	}
+	event.FormatDates()
+	return &event, nil
+}
+
+func (r *gormEventRepository) List(filter EventFilter) ([]models.Event, error) {
+	query := preloadDetails(r.db.Model(&models.Event{}))
+	startAt, endAt := filter.instants()
+	switch {
+	case filter.StartDate == "":
+	case filter.Location != nil:
+		query = query.Where("(rrule <> '' OR (all_day AND end_date >= ?) OR (NOT all_day AND "+r.dialect.instant("end_at")+" > ?))",
+			filter.StartDate, r.dialect.timestamp(startAt))
+	default:
+		query = query.Where("(rrule <> '' OR end_date >= ?)", filter.StartDate)
+	}
+	switch {
+	case filter.EndDate == "":
+	case filter.Location != nil:
+		query = query.Where("((all_day AND event_date <= ?) OR (NOT all_day AND "+r.dialect.instant("start_at")+" < ?))",
+			filter.EndDate, r.dialect.timestamp(endAt))
+	default:
+		query = query.Where("event_date <= ?", filter.EndDate)
+	}
+	if filter.Keyword != "" {
+		query = query.Where(r.dialect.contains("title", filter.Keyword))
+	}
+	if filter.Search != nil {
+		condition, args := r.search(filter.Search)
+		query = query.Where(condition, args...)
+	}
+	if len(filter.CalendarIDs) > 0 {
+		query = query.Where("calendar_id IN ?", filter.CalendarIDs)
+	}
+	if filter.Access != nil {
+		condition, args := accessible(filter.Access)
+		query = query.Where(condition, args...)
+	}
+	if len(filter.Tags) > 0 {
+		query = query.Where("id IN (?)", r.tagged(filter.Tags, filter.AllTags))
+	}
+	if filter.BusyOnly {
+		query = query.Where("busy = ?", true)
+	}
+	if filter.Recurring != nil {
+		if *filter.Recurring {
+			query = query.Where("rrule <> ''")
+		} else {
+			query = query.Where("rrule = ''")
+		}
+	}
+	if filter.After != nil {
+		query = r.after(query, filter.After, filter.Desc != filter.Reverse, filter.Reverse)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var events []models.Event
+	if err := query.Order(r.occurrenceOrder(filter.Desc != filter.Reverse, filter.Reverse)).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for i := range events {
+		events[i].FormatDates()
+	}
+	return events, nil
+}
+
+// Condition matching the titles that match all the terms of one of the alternatives of a search
+func (r *gormEventRepository) search(search *models.SearchQuery) (string, []interface{}) {
+	var alternatives []string
+	var args []interface{}
+	for _, terms := range search.Alternatives {
+		var conditions []string
+		for _, term := range terms {
+			condition, termArgs := r.dialect.searchTitle(term)
+			conditions = append(conditions, condition)
+			args = append(args, termArgs...)
+		}
+		alternatives = append(alternatives, "("+strings.Join(conditions, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args
+}
+
+// Query the ids of the events having one of the tags, or all of them
+func (r *gormEventRepository) tagged(names []string, all bool) *gorm.DB {
+	query := r.db.Table("event_tags").Select("event_tags.event_id").
+		Joins("JOIN tags ON tags.id = event_tags.tag_id").
+		Where("tags.name IN ?", names)
+	if all {
+		query = query.Group("event_tags.event_id").Having("COUNT(*) = ?", len(names))
+	}
+	return query
+}
+
+// Restrict a query to the events sorted after a position, by date and time in descending order when desc.
+// All-day events come first on each date, or last in reverse order.
+func (r *gormEventRepository) after(query *gorm.DB, position *models.Event, desc, reverse bool) *gorm.DB {
+	cmp := ">"
+	if desc {
+		cmp = "<"
+	}
+	date := position.GetEventDate().Format("2006-01-02")
+	start, startAt := r.dialect.timeOfDay("start_time"), r.dialect.timeOfDay("?")
+	switch {
+	case position.AllDay && reverse:
+		return query.Where("event_date "+cmp+" ? OR (event_date = ? AND all_day AND id "+cmp+" ?)",
+			date, date, position.ID)
+	case position.AllDay:
+		// Timed events come after all-day events on the same date
+		return query.Where("event_date "+cmp+" ? OR (event_date = ? AND (NOT all_day OR id "+cmp+" ?))",
+			date, date, position.ID)
+	case reverse:
+		// All-day events come after timed events on the same date
+		return query.Where("event_date "+cmp+" ? OR (event_date = ? AND (all_day OR "+start+" "+cmp+" "+startAt+" OR ("+start+" = "+startAt+" AND id "+cmp+" ?)))",
+			date, date, position.StartTime, position.StartTime, position.ID)
+	}
+	return query.Where("event_date "+cmp+" ? OR (event_date = ? AND NOT all_day AND ("+start+" "+cmp+" "+startAt+" OR ("+start+" = "+startAt+" AND id "+cmp+" ?)))",
+		date, date, position.StartTime, position.StartTime, position.ID)
+}
+
+// Order of events matching EventFilter.Compare
+func (r *gormEventRepository) occurrenceOrder(desc, reverse bool) string {
+	direction, allDay := "ASC", "DESC"
+	if desc {
+		direction = "DESC"
+	}
+	if reverse {
+		allDay = "ASC"
+	}
+	return fmt.Sprintf("event_date %[1]s, all_day %[2]s, %[3]s %[1]s, id %[1]s", direction, allDay, r.dialect.timeOfDay("start_time"))
+}
+
+func (r *gormEventRepository) Create(event *models.Event, audit models.Audit) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Tags").Create(event).Error; err != nil {
+			return err
+		}
+		if err := saveTags(tx, event); err != nil {
+			return err
+		}
+		return record(tx, models.RevisionCreate, event.ID, nil, audit)
+	})
+	if err != nil {
+		return err
+	}
+	event.FormatDates()
+	return nil
+}
+
+func (r *gormEventRepository) Update(event *models.Event, audit models.Audit) error {
+	version := event.Version
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := storedEvent(tx, event.ID)
+		if err != nil {
+			return err
+		}
+		event.Version = version + 1
+		result := tx.Model(event).Where("version = ?", version).Select("*").Omit("id", "uid", "created_at", "deleted_at", "Overrides", "Tags").Updates(event)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		if err := saveTags(tx, event); err != nil {
+			return err
+		}
+		return record(tx, models.RevisionUpdate, event.ID, before, audit)
+	})
+	if err != nil {
+		event.Version = version
+	}
+	return err
+}
+
+func (r *gormEventRepository) Delete(event *models.Event, audit models.Audit) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := storedEvent(tx, event.ID)
+		if err != nil {
+			return err
+		}
+		result := tx.Where("version = ?", event.Version).Delete(event)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return record(tx, models.RevisionDelete, event.ID, before, audit)
+	})
+}
+
+func (r *gormEventRepository) FindOverlaps(event *models.Event, excludeID uint) (bool, error) {
+	occurrences, err := overlapOccurrences(event)
+	if err != nil || len(occurrences) == 0 {
+		return false, err
+	}
+	from, to, err := event.Span()
+	if err != nil {
+		return false, err
+	}
+
+	// Busy single events intersecting the days the event occurs on
+	singles := func() *gorm.DB {
+		return r.db.Model(&models.Event{}).Where("calendar_id = ? AND rrule = '' AND busy = ? AND id <> ?", event.CalendarID, true, excludeID)
+	}
+	if !event.IsRecurring() {
+		var count int64
+		if err := singles().
+			Where("all_day = ? AND "+r.dialect.instant("start_at")+" < ? AND "+r.dialect.instant("end_at")+" > ?",
+				false, r.dialect.timestamp(event.GetEndAt()), r.dialect.timestamp(event.GetStartAt())).
+			Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+
+	// All-day events have no times to compare in the query, and occurrences of a
+	// recurring event are compared one by one. Dates are widened by a day for time zone offsets.
+	var events []models.Event
+	candidates := singles().Where("event_date <= ? AND end_date >= ?", to.AddDate(0, 0, 1).Format("2006-01-02"), from.AddDate(0, 0, -1).Format("2006-01-02"))
+	if !event.IsRecurring() {
+		candidates = candidates.Where("all_day = ?", true)
+	}
+	if err := candidates.Find(&events).Error; err != nil {
+		return false, err
+	}
+	if overlapsAny(occurrences, events) {
+		return true, nil
+	}
+
+	// Occurrences of busy recurring events that started before the end of the span
+	var recurring []models.Event
+	if err := r.db.Model(&models.Event{}).Preload("Overrides").
+		Where("calendar_id = ? AND rrule <> '' AND busy = ? AND event_date <= ? AND id <> ?", event.CalendarID, true, to.Format("2006-01-02"), excludeID).
+		Find(&recurring).Error; err != nil {
+		return false, err
+	}
+	for i := range recurring {
+		expanded, err := recurring[i].Occurrences(from, to)
+		if err != nil {
+			return false, err
+		}
+		if overlapsAny(occurrences, expanded) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PostgreSQL locks the row of the calendar. SQLite has no row locks, its transactions hold the
+// write lock of the database from their start instead.
+func (r *gormEventRepository) LockCalendar(calendarID uint) error {
+	var calendars []models.Calendar
+	return r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", calendarID).Find(&calendars).Error
+}
+
+func (r *gormEventRepository) SaveOverride(event *models.Event, override *models.EventOverride, audit models.Audit) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := storedEvent(tx, event.ID)
+		if err != nil {
+			return err
+		}
+		if err := bumpVersion(tx, event); err != nil {
+			return err
+		}
+		var existing models.EventOverride
+		err = tx.Where("event_id = ? AND recurrence_date = ?", event.ID, override.RecurrenceDate).First(&existing).Error
+		if err == nil {
+			override.ID = existing.ID
+			override.CreatedAt = existing.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		override.EventID = event.ID
+		if err := tx.Save(override).Error; err != nil {
+			return err
+		}
+		return record(tx, models.RevisionUpdate, event.ID, before, audit)
+	})
+}
+
+func (r *gormEventRepository) CancelOccurrence(event *models.Event, recurrenceDate string, audit models.Audit) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := storedEvent(tx, event.ID)
+		if err != nil {
+			return err
+		}
+		if err := bumpVersion(tx, event); err != nil {
+			return err
+		}
+		if err := tx.Where("event_id = ? AND recurrence_date = ?", event.ID, recurrenceDate).Delete(&models.EventOverride{}).Error; err != nil {
+			return err
+		}
+		event.ExDates = append(event.ExDates, recurrenceDate)
+		if err := tx.Model(&models.Event{}).Where("id = ?", event.ID).Update("exdates", event.ExDates).Error; err != nil {
+			return err
+		}
+		return record(tx, models.RevisionUpdate, event.ID, before, audit)
+	})
+}
+
+func (r *gormEventRepository) GetDeleted(id uint) (*models.Event, error) {
+	var event models.Event
+	if err := preloadDetails(r.trash(r.db, TrashFilter{IDs: []uint{id}})).First(&event).Error; err != nil {
+		return nil, notFound(err)
+	}
+	event.FormatDates()
+	return &event, nil
+}
+
+func (r *gormEventRepository) ListDeleted(filter TrashFilter) ([]models.Event, error) {
+	var events []models.Event
+	if err := preloadDetails(r.trash(r.db, filter)).Order("deleted_at DESC, id DESC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for i := range events {
+		events[i].FormatDates()
+	}
+	return events, nil
+}
+
+// Query the deleted events matching a filter
+func (r *gormEventRepository) trash(db *gorm.DB, filter TrashFilter) *gorm.DB {
+	query := db.Unscoped().Model(&models.Event{}).Where("deleted_at IS NOT NULL")
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	if filter.Keyword != "" {
+		query = query.Where(r.dialect.contains("title", filter.Keyword))
+	}
+	if len(filter.CalendarIDs) > 0 {
+		query = query.Where("calendar_id IN ?", filter.CalendarIDs)
+	}
+	if filter.Access != nil {
+		condition, args := accessible(filter.Access)
+		query = query.Where(condition, args...)
+	}
+	if !filter.DeletedBefore.IsZero() {
+		query = query.Where(r.dialect.instant("deleted_at")+" < ?", r.dialect.timestamp(filter.DeletedBefore))
+	}
+	return query
+}
+
+func (r *gormEventRepository) Restore(event *models.Event, audit models.Audit) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		before, err := storedEvent(tx, event.ID)
+		if err != nil {
+			return err
+		}
+		result := tx.Unscoped().Model(&models.Event{}).
+			Where("id = ? AND version = ? AND deleted_at IS NOT NULL", event.ID, event.Version).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": event.Version + 1})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+		return record(tx, models.RevisionRestore, event.ID, before, audit)
+	})
+	if err != nil {
+		return err
+	}
+	event.Version++
+	event.DeletedAt = gorm.DeletedAt{}
+	return nil
+}
+
+func (r *gormEventRepository) Purge(filter TrashFilter) (int64, error) {
+	var purged int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := r.trash(tx, filter).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("event_id IN ?", ids).Delete(&models.EventOverride{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id IN ?", ids).Delete(&models.EventTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id IN ?", ids).Delete(&models.Grant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id IN ?", ids).Delete(&models.ReminderFiring{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("event_id IN ?", ids).Delete(&models.Reminder{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Event{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+func (r *gormEventRepository) ListRevisions(eventID uint) ([]models.EventRevision, error) {
+	var revisions []models.EventRevision
+	if err := r.db.Where("event_id = ?", eventID).Order("id ASC").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *gormEventRepository) GetRevision(eventID, revisionID uint) (*models.EventRevision, error) {
+	var revision models.EventRevision
+	if err := r.db.Where("event_id = ? AND id = ?", eventID, revisionID).First(&revision).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &revision, nil
+}
+
+func (r *gormEventRepository) ListRevisionsAfter(afterID uint, limit int) ([]models.EventRevision, error) {
+	var revisions []models.EventRevision
+	if err := r.db.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *gormEventRepository) LastRevisionID() (uint, error) {
+	var id uint
+	err := r.db.Model(&models.EventRevision{}).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+func (r *gormEventRepository) Transaction(fn func(events EventRepository, calendars CalendarRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormEventRepository{db: tx, dialect: r.dialect}, NewGormCalendarRepository(tx))
+	})
+}
+
+// Load the overrides and the tags of the events read by a query
+func preloadDetails(query *gorm.DB) *gorm.DB {
+	return query.Preload("Overrides").Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name ASC")
+	})
+}
+
+// Link an event to its tags in place of its previous ones, creating the tags no event used yet
+func saveTags(tx *gorm.DB, event *models.Event) error {
+	if err := tx.Where("event_id = ?", event.ID).Delete(&models.EventTag{}).Error; err != nil {
+		return err
+	}
+	names := event.TagNames()
+	tags := []models.Tag{}
+	if len(names) > 0 {
+		missing := make([]models.Tag, len(names))
+		for i, name := range names {
+			missing[i] = models.Tag{Name: name}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&missing).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("name IN ?", names).Order("name ASC").Find(&tags).Error; err != nil {
+			return err
+		}
+		links := make([]models.EventTag, len(tags))
+		for i := range tags {
+			links[i] = models.EventTag{EventID: event.ID, TagID: tags[i].ID}
+		}
+		if err := tx.Create(&links).Error; err != nil {
+			return err
+		}
+	}
+	event.Tags = tags
+	return nil
+}
+
+// Read an event as stored, deleted or not, with its overrides and tags.
+// An event purged since it was read is a version conflict.
+func storedEvent(tx *gorm.DB, id uint) (*models.Event, error) {
+	var event models.Event
+	if err := preloadDetails(tx.Unscoped()).Where("id = ?", id).First(&event).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrVersionConflict
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+// Record the change of an event from its state before, read back as it is now stored,
+// schedule its reminders again and queue its deliveries to the webhooks
+func record(tx *gorm.DB, action string, eventID uint, before *models.Event, audit models.Audit) error {
+	after, err := storedEvent(tx, eventID)
+	if err != nil {
+		return err
+	}
+	revision := newRevision(action, before, after, audit)
+	if err := tx.Create(revision).Error; err != nil {
+		return err
+	}
+
+	var reminders []models.Reminder
+	if err := tx.Where("event_id = ?", eventID).Find(&reminders).Error; err != nil {
+		return err
+	}
+	for i := range reminders {
+		reminders[i].Schedule(after, revision.CreatedAt)
+		if err := tx.Model(&reminders[i]).Select("next_fire_at", "occurrence_at").Updates(&reminders[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	var webhooks []models.Webhook
+	if err := tx.Where("disabled = ?", false).Order("id").Find(&webhooks).Error; err != nil {
+		return err
+	}
+	deliveries, err := newDeliveries(webhooks, revision, before, after)
+	if err != nil || len(deliveries) == 0 {
+		return err
+	}
+	return tx.Create(&deliveries).Error
+}
+
+// Move an event to its next version, unless it changed since it was read
+func bumpVersion(tx *gorm.DB, event *models.Event) error {
+	result := tx.Model(&models.Event{}).Where("id = ? AND version = ?", event.ID, event.Version).Update("version", event.Version+1)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	event.Version++
+	return nil
+}
+
+// gormCalendarRepository stores calendars in the database
+type gormCalendarRepository struct {
+	db *gorm.DB
+}
+
+// NewGormCalendarRepository stores calendars with GORM
+func NewGormCalendarRepository(db *gorm.DB) CalendarRepository {
+	return &gormCalendarRepository{db: db}
+}
+
+func (r *gormCalendarRepository) List() ([]models.Calendar, error) {
+	var calendars []models.Calendar
+	if err := r.db.Order("id ASC").Find(&calendars).Error; err != nil {
+		return nil, err
+	}
+	return calendars, nil
+}
+
+func (r *gormCalendarRepository) Get(id uint) (*models.Calendar, error) {
+	var calendar models.Calendar
+	if err := r.db.Where("id = ?", id).First(&calendar).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &calendar, nil
+}
+
+func (r *gormCalendarRepository) GetDefault() (*models.Calendar, error) {
+	var calendar models.Calendar
+	if err := r.db.Where("is_default = ?", true).First(&calendar).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &calendar, nil
+}
+
+func (r *gormCalendarRepository) Create(calendar *models.Calendar) error {
+	return r.db.Create(calendar).Error
+}
+
+func (r *gormCalendarRepository) Update(calendar *models.Calendar) error {
+	return r.db.Save(calendar).Error
+}
+
+func (r *gormCalendarRepository) Delete(calendar *models.Calendar, audit models.Audit) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var events []models.Event
+		if err := preloadDetails(tx).Where("calendar_id = ?", calendar.ID).Find(&events).Error; err != nil {
+			return err
+		}
+		for i := range events {
+			if err := tx.Delete(&events[i]).Error; err != nil {
+				return err
+			}
+			if err := record(tx, models.RevisionDelete, events[i].ID, &events[i], audit); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(calendar).Error
+	})
+}
+
+// gormUserRepository stores users and their API keys in the database
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewGormUserRepository stores users with GORM
+func NewGormUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) List() ([]models.User, error) {
+	var users []models.User
+	if err := r.db.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *gormUserRepository) Get(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepository) Create(user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("name = ?", user.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrNameTaken
+		}
+		return tx.Create(user).Error
+	})
+}
+
+func (r *gormUserRepository) Delete(user *models.User) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.APIKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Grant{}).Error; err != nil {
+			return err
+		}
+		reminders := tx.Model(&models.Reminder{}).Select("id").Where("user_id = ?", user.ID)
+		if err := tx.Where("reminder_id IN (?)", reminders).Delete(&models.ReminderFiring{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Reminder{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(user).Error
+	})
+}
+
+func (r *gormUserRepository) ListKeys(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *gormUserRepository) CreateKey(key *models.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *gormUserRepository) DeleteKey(userID, keyID uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", keyID, userID).Delete(&models.APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormUserRepository) FindKey(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.db.Where("hash = ?", hash).First(&key).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &key, nil
+}
+
+// gormGrantRepository stores the grants on calendars and events in the database
+type gormGrantRepository struct {
+	db *gorm.DB
+}
+
+// NewGormGrantRepository stores grants with GORM
+func NewGormGrantRepository(db *gorm.DB) GrantRepository {
+	return &gormGrantRepository{db: db}
+}
+
+func (r *gormGrantRepository) ListForUser(userID uint) ([]models.Grant, error) {
+	return r.list("user_id = ?", userID)
+}
+
+func (r *gormGrantRepository) ListForCalendar(calendarID uint) ([]models.Grant, error) {
+	return r.list("calendar_id = ?", calendarID)
+}
+
+func (r *gormGrantRepository) ListForEvent(eventID uint) ([]models.Grant, error) {
+	return r.list("event_id = ?", eventID)
+}
+
+// List the grants matching the condition, oldest first
+func (r *gormGrantRepository) list(condition string, id uint) ([]models.Grant, error) {
+	var grants []models.Grant
+	if err := r.db.Where(condition, id).Order("id ASC").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (r *gormGrantRepository) Save(grant *models.Grant) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var stored models.Grant
+		err := granted(tx, grant).First(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(grant).Error
+		}
+		if err != nil {
+			return err
+		}
+		stored.Role, stored.GrantedBy = grant.Role, grant.GrantedBy
+		if err := tx.Save(&stored).Error; err != nil {
+			return err
+		}
+		*grant = stored
+		return nil
+	})
+}
+
+func (r *gormGrantRepository) Delete(grant *models.Grant) error {
+	result := granted(r.db, grant).Delete(&models.Grant{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Query of the grant of the same user on the same calendar or event
+func granted(db *gorm.DB, grant *models.Grant) *gorm.DB {
+	if grant.EventID != nil {
+		return db.Where("event_id = ? AND user_id = ?", *grant.EventID, grant.UserID)
+	}
+	return db.Where("calendar_id = ? AND user_id = ?", grant.CalendarID, grant.UserID)
+}
+
+// gormWebhookRepository stores webhooks and their deliveries in the database
+type gormWebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewGormWebhookRepository stores webhooks with GORM
+func NewGormWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &gormWebhookRepository{db: db}
+}
+
+func (r *gormWebhookRepository) List() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	if err := r.db.Order("id ASC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *gormWebhookRepository) Get(id uint) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := r.db.Where("id = ?", id).First(&webhook).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &webhook, nil
+}
+
+func (r *gormWebhookRepository) Create(webhook *models.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *gormWebhookRepository) Update(webhook *models.Webhook) error {
+	return r.db.Save(webhook).Error
+}
+
+func (r *gormWebhookRepository) Delete(webhook *models.Webhook) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(webhook).Error
+	})
+}
+
+func (r *gormWebhookRepository) ListDeliveries(webhookID uint, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	if err := r.db.Where("webhook_id = ?", webhookID).Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *gormWebhookRepository) GetDelivery(webhookID, deliveryID uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := r.db.Where("webhook_id = ? AND id = ?", webhookID, deliveryID).First(&delivery).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &delivery, nil
+}
+
+func (r *gormWebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *gormWebhookRepository) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Joins("JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id").
+		Where("webhook_deliveries.status = ? AND webhook_deliveries.next_attempt_at <= ? AND webhooks.disabled = ?", models.DeliveryPending, now, false).
+		Order("webhook_deliveries.next_attempt_at ASC, webhook_deliveries.id ASC").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *gormWebhookRepository) ClaimDelivery(delivery *models.WebhookDelivery, now, until time.Time) (bool, error) {
+	// The lease is matched again when the delivery is saved, it is kept to the microseconds the database stores
+	until = until.Truncate(time.Microsecond)
+	result := r.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, models.DeliveryPending, now).
+		Update("next_attempt_at", until)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	delivery.NextAttemptAt = &until
+	return true, nil
+}
+
+func (r *gormWebhookRepository) SaveDelivery(delivery *models.WebhookDelivery, lease time.Time) (bool, error) {
+	result := r.db.Model(delivery).Where("next_attempt_at = ?", lease).Select("*").Updates(delivery)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// gormReminderRepository stores reminders and their firings in the database
+type gormReminderRepository struct {
+	db *gorm.DB
+}
+
+// NewGormReminderRepository stores reminders with GORM
+func NewGormReminderRepository(db *gorm.DB) ReminderRepository {
+	return &gormReminderRepository{db: db}
+}
+
+func (r *gormReminderRepository) ListForEvent(eventID uint) ([]models.Reminder, error) {
+	return r.list("event_id = ?", eventID)
+}
+
+func (r *gormReminderRepository) ListForUser(userID uint) ([]models.Reminder, error) {
+	return r.list("user_id = ?", userID)
+}
+
+// List the reminders matching the condition, oldest first
+func (r *gormReminderRepository) list(condition string, id uint) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	if err := r.db.Where(condition, id).Order("id ASC").Find(&reminders).Error; err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+func (r *gormReminderRepository) Get(eventID, id uint) (*models.Reminder, error) {
+	var reminder models.Reminder
+	if err := r.db.Where("event_id = ? AND id = ?", eventID, id).First(&reminder).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &reminder, nil
+}
+
+func (r *gormReminderRepository) Create(reminder *models.Reminder) error {
+	return r.db.Create(reminder).Error
+}
+
+func (r *gormReminderRepository) Delete(reminder *models.Reminder) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("reminder_id = ?", reminder.ID).Delete(&models.ReminderFiring{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(reminder).Error
+	})
+}
+
+func (r *gormReminderRepository) DueReminders(now time.Time, limit int) ([]models.Reminder, error) {
+	var reminders []models.Reminder
+	err := r.db.Where("next_fire_at <= ?", now.UTC()).Order("next_fire_at ASC, id ASC").Limit(limit).Find(&reminders).Error
+	if err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+func (r *gormReminderRepository) Fire(reminder *models.Reminder, firing *models.ReminderFiring) (bool, error) {
+	fired := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Reminder{}).
+			Where("id = ? AND occurrence_at = ?", reminder.ID, firing.OccurrenceAt).
+			Updates(map[string]interface{}{"next_fire_at": reminder.NextFireAt, "occurrence_at": reminder.OccurrenceAt})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		// The unique index on the reminder and occurrence keeps a second firing out
+		result = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(firing)
+		fired = result.RowsAffected > 0
+		return result.Error
+	})
+	return fired, err
+}
+
+func (r *gormReminderRepository) ListFirings(reminderID uint, limit int) ([]models.ReminderFiring, error) {
+	var firings []models.ReminderFiring
+	if err := r.db.Where("reminder_id = ?", reminderID).Order("id DESC").Limit(limit).Find(&firings).Error; err != nil {
+		return nil, err
+	}
+	return firings, nil
+}
+
+func (r *gormReminderRepository) DueFirings(now time.Time, limit int) ([]models.ReminderFiring, error) {
+	var firings []models.ReminderFiring
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", models.FiringPending, now).
+		Order("next_attempt_at ASC, id ASC").Limit(limit).Find(&firings).Error
+	if err != nil {
+		return nil, err
+	}
+	return firings, nil
+}
+
+func (r *gormReminderRepository) ClaimFiring(firing *models.ReminderFiring, now, until time.Time) (bool, error) {
+	// The lease is matched again when the firing is saved, it is kept to the microseconds the database stores
+	until = until.Truncate(time.Microsecond)
+	result := r.db.Model(&models.ReminderFiring{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", firing.ID, models.FiringPending, now).
+		Update("next_attempt_at", until)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	firing.NextAttemptAt = &until
+	return true, nil
+}
+
+func (r *gormReminderRepository) SaveFiring(firing *models.ReminderFiring, lease time.Time) (bool, error) {
+	result := r.db.Model(firing).Where("next_attempt_at = ?", lease).Select("*").Updates(firing)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Condition selecting the events of an access
+func accessible(access *Access) (string, []interface{}) {
+	condition, args := "owner_id = ?", []interface{}{access.OwnerID}
+	if len(access.CalendarIDs) > 0 {
+		condition, args = condition+" OR calendar_id IN ?", append(args, access.CalendarIDs)
+	}
+	if len(access.EventIDs) > 0 {
+		condition, args = condition+" OR id IN ?", append(args, access.EventIDs)
+	}
+	return "(" + condition + ")", args
+}
+
+// Translate the record not found error of GORM
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,810 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thunthup/aimet-test/configs"
+	"github.com/thunthup/aimet-test/migrations"
+	"github.com/thunthup/aimet-test/models"
+	"gorm.io/gorm"
+	"gotest.tools/v3/assert"
+)
+
+// Open a new migrated SQLite database
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	db, err := configs.OpenSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	assert.NilError(t, err)
+	migrator, err := migrations.New(db)
+	assert.NilError(t, err)
+	_, err = migrator.Up()
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+// Open the GORM repositories on a new SQLite database
+func newSQLiteRepositories(t *testing.T) (EventRepository, CalendarRepository) {
+	db := newSQLiteDB(t)
+	return NewGormEventRepository(db), NewGormCalendarRepository(db)
+}
+
+// Create the events in the default calendar and return the repositories of both backends
+func seedBackends(t *testing.T, events []models.Event) map[string]EventRepository {
+	sqliteEvents, sqliteCalendars := newSQLiteRepositories(t)
+	memoryEvents, memoryCalendars, _, _, _ := NewMemoryRepositories()
+	backends := map[string]EventRepository{"sqlite": sqliteEvents, "memory": memoryEvents}
+	calendars := map[string]CalendarRepository{"sqlite": sqliteCalendars, "memory": memoryCalendars}
+	for name, repo := range backends {
+		calendar, err := calendars[name].GetDefault()
+		assert.NilError(t, err)
+		for i := range events {
+			event := events[i]
+			event.CalendarID = calendar.ID
+			assert.NilError(t, repo.Create(&event, models.Audit{}), name)
+		}
+	}
+	return backends
+}
+
+func TestSQLiteEventRepository(t *testing.T) {
+	events, calendars := newSQLiteRepositories(t)
+	calendar, err := calendars.GetDefault()
+	assert.NilError(t, err)
+
+	event := models.Event{CalendarID: calendar.ID, Title: "Meeting", EventDate: "2024-03-01", StartTime: "23:00:00+07", EndTime: "01:00:00+07"}
+	event.SetEndDate(event.GetEventDate().AddDate(0, 0, 1))
+	assert.NilError(t, events.Create(&event, models.Audit{}))
+
+	// Dates are read back as YYYY-MM-DD and times keep their offset
+	stored, err := events.Get(event.ID)
+	assert.NilError(t, err)
+	assert.Equal(t, "2024-03-01", stored.EventDate)
+	assert.Equal(t, "2024-03-02", stored.EndDate)
+	assert.Equal(t, models.TimeOfDay("23:00:00+07"), stored.StartTime)
+	assert.Equal(t, uint(1), stored.Version)
+
+	// Updates check the version
+	stored.Title = "Renamed"
+	assert.NilError(t, events.Update(stored, models.Audit{}))
+	assert.Equal(t, uint(2), stored.Version)
+	stored.Version = 1
+	assert.Equal(t, ErrVersionConflict, events.Update(stored, models.Audit{}))
+
+	// The trigger rejects overlapping events written without the repository check
+	overlapping := models.Event{CalendarID: calendar.ID, Title: "Overlapping", EventDate: "2024-03-01", StartTime: "17:30:00+00", EndTime: "18:30:00+00"}
+	assert.ErrorContains(t, events.Create(&overlapping, models.Audit{}), "Event overlaps with another event in the same calendar")
+	adjacent := models.Event{CalendarID: calendar.ID, Title: "Adjacent", EventDate: "2024-03-01", StartTime: "20:00:00+02", EndTime: "21:00:00+02"}
+	assert.NilError(t, events.Create(&adjacent, models.Audit{}))
+
+	// Series are left to the repository check, their first date may be an exception
+	series := models.Event{CalendarID: calendar.ID, Title: "Series", EventDate: "2024-03-01", StartTime: "17:30:00+00", EndTime: "18:30:00+00",
+		RRule: "FREQ=WEEKLY", ExDates: models.DateList{"2024-03-01"}}
+	assert.NilError(t, events.Create(&series, models.Audit{}))
+
+	_, err = events.Get(999)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestSQLiteTrash(t *testing.T) {
+	events, calendars := newSQLiteRepositories(t)
+	calendar, err := calendars.GetDefault()
+	assert.NilError(t, err)
+
+	event := models.Event{CalendarID: calendar.ID, Title: "Meeting", EventDate: "2024-03-01", StartTime: "15:00:00+07", EndTime: "16:00:00+07"}
+	assert.NilError(t, events.Create(&event, models.Audit{}))
+	assert.NilError(t, events.Delete(&event, models.Audit{}))
+
+	deleted, err := events.ListDeleted(TrashFilter{Keyword: "Meet"})
+	assert.NilError(t, err)
+	assert.Equal(t, 1, len(deleted))
+	assert.Equal(t, "2024-03-01", deleted[0].EventDate)
+	assert.Assert(t, deleted[0].DeletedAt.Valid)
+	deleted, err = events.ListDeleted(TrashFilter{DeletedBefore: time.Now().Add(-time.Hour)})
+	assert.NilError(t, err)
+	assert.Equal(t, 0, len(deleted))
+
+	// Restoring runs the overlap trigger again
+	taken := models.Event{CalendarID: calendar.ID, Title: "Taken", EventDate: "2024-03-01", StartTime: "08:00:00+00", EndTime: "08:30:00+00"}
+	assert.NilError(t, events.Create(&taken, models.Audit{}))
+	restored, err := events.GetDeleted(event.ID)
+	assert.NilError(t, err)
+	assert.ErrorContains(t, events.Restore(restored, models.Audit{}), "Event overlaps with another event in the same calendar")
+	assert.NilError(t, events.Delete(&taken, models.Audit{}))
+	assert.NilError(t, events.Restore(restored, models.Audit{}))
+	assert.Equal(t, uint(2), restored.Version)
+	_, err = events.Get(event.ID)
+	assert.NilError(t, err)
+
+	purged, err := events.Purge(TrashFilter{DeletedBefore: time.Now().Add(time.Hour)})
+	assert.NilError(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = events.GetDeleted(taken.ID)
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestSQLiteMatchesMemory(t *testing.T) {
+	busy := true
+	backends := seedBackends(t, []models.Event{
+		{Title: "Late", EventDate: "2024-03-01", StartTime: "10:00:00+00", EndTime: "11:00:00+00"},
+		{Title: "Early", EventDate: "2024-03-01", StartTime: "15:00:00+07", EndTime: "16:00:00+07"},
+		{Title: "Holiday", EventDate: "2024-03-01", AllDay: true},
+		{Title: "Overnight", EventDate: "2024-03-02", EndDate: "2024-03-03", StartTime: "22:00:00+00", EndTime: "02:00:00+00"},
+		{Title: "Standup", EventDate: "2024-02-26", StartTime: "09:00:00+07", EndTime: "09:15:00+07", RRule: "FREQ=DAILY"},
+		{Title: "Trip", EventDate: "2024-03-05", EndDate: "2024-03-06", AllDay: true, Busy: &busy},
+		{Title: "Review", EventDate: "2024-03-02", StartTime: "09:00:00+05:30", EndTime: "10:00:00+05:30"},
+	})
+	bangkok, err := models.LoadTimeZone("Asia/Bangkok")
+	assert.NilError(t, err)
+
+	titles := func(events []models.Event) []string {
+		result := []string{}
+		for _, event := range events {
+			result = append(result, event.Title)
+		}
+		return result
+	}
+	filters := []EventFilter{
+		{},
+		{Desc: true},
+		{StartDate: "2024-03-02", EndDate: "2024-03-05"},
+		// Overnight is on March 3 in Bangkok, Late is on March 1
+		{StartDate: "2024-03-02", EndDate: "2024-03-02", Location: bangkok},
+		{Keyword: "a"},
+		{BusyOnly: true, Limit: 3},
+	}
+	for _, filter := range filters {
+		expected, err := backends["memory"].List(filter)
+		assert.NilError(t, err)
+		actual, err := backends["sqlite"].List(filter)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, titles(expected), titles(actual))
+
+		// Paging after each event gives the same rest of the list
+		for i := range expected {
+			page := filter
+			page.After = &expected[i]
+			expectedPage, err := backends["memory"].List(page)
+			assert.NilError(t, err)
+			actualPage, err := backends["sqlite"].List(page)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, titles(expectedPage), titles(actualPage))
+		}
+	}
+
+	// Recurring events are kept whatever their start
+	inBangkok, err := backends["sqlite"].List(EventFilter{StartDate: "2024-03-02", EndDate: "2024-03-02", Location: bangkok})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{"Standup", "Review"}, titles(inBangkok))
+
+	candidates := []struct {
+		event       models.Event
+		overlapping bool
+	}{
+		// Inside Early written in another offset
+		{models.Event{EventDate: "2024-03-01", StartTime: "08:30:00+00", EndTime: "08:45:00+00"}, true},
+		// Between Early and Late
+		{models.Event{EventDate: "2024-03-01", StartTime: "09:00:00+00", EndTime: "10:00:00+00"}, false},
+		// Crosses into the overnight event
+		{models.Event{EventDate: "2024-03-03", StartTime: "08:30:00+07", EndTime: "09:30:00+07"}, true},
+		// Hits an occurrence of the standup
+		{models.Event{EventDate: "2024-04-10", StartTime: "02:00:00+00", EndTime: "02:10:00+00"}, true},
+		{models.Event{EventDate: "2024-04-10", StartTime: "03:00:00+00", EndTime: "04:00:00+00"}, false},
+		// Inside the review written with an offset in minutes
+		{models.Event{EventDate: "2024-03-02", StartTime: "04:00:00+00", EndTime: "04:15:00+00"}, true},
+		// Inside the busy all-day trip
+		{models.Event{EventDate: "2024-03-06", StartTime: "12:00:00+00", EndTime: "13:00:00+00"}, true},
+	}
+	for _, candidate := range candidates {
+		// The default calendar is the first one in both backends
+		candidate.event.CalendarID = 1
+		for name, repo := range backends {
+			overlapping, err := repo.FindOverlaps(&candidate.event, 0)
+			assert.NilError(t, err)
+			assert.Equal(t, candidate.overlapping, overlapping, "%s %s %s", name, candidate.event.EventDate, candidate.event.StartTime)
+		}
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	sqliteEvents, sqliteCalendars := newSQLiteRepositories(t)
+	memoryEvents, memoryCalendars, _, _, _ := NewMemoryRepositories()
+	backends := map[string]EventRepository{"sqlite": sqliteEvents, "memory": memoryEvents}
+	calendars := map[string]CalendarRepository{"sqlite": sqliteCalendars, "memory": memoryCalendars}
+	audit := models.Audit{Actor: "alice", RequestID: "req-1", Method: "PUT", Path: "/api/events/1"}
+
+	for name, events := range backends {
+		calendar := models.Calendar{Name: "Work"}
+		assert.NilError(t, calendars[name].Create(&calendar), name)
+
+		// Every change records the fields it changed
+		event := models.Event{CalendarID: calendar.ID, Title: "Standup", EventDate: "2024-03-04", StartTime: "09:00:00+07", EndTime: "09:15:00+07", RRule: "FREQ=DAILY;COUNT=5"}
+		assert.NilError(t, events.Create(&event, audit), name)
+		event.Title = "Daily standup"
+		assert.NilError(t, events.Update(&event, audit), name)
+		override := models.EventOverride{RecurrenceDate: "2024-03-05", EventDate: "2024-03-05", StartTime: "10:00:00+07", EndTime: "10:15:00+07"}
+		assert.NilError(t, events.SaveOverride(&event, &override, audit), name)
+		assert.NilError(t, events.CancelOccurrence(&event, "2024-03-05", audit), name)
+		assert.NilError(t, events.Delete(&event, audit), name)
+		deleted, err := events.GetDeleted(event.ID)
+		assert.NilError(t, err, name)
+		assert.NilError(t, events.Restore(deleted, audit), name)
+		assert.NilError(t, calendars[name].Delete(&calendar, audit), name)
+
+		revisions, err := events.ListRevisions(event.ID)
+		assert.NilError(t, err, name)
+		actions := []string{}
+		for _, revision := range revisions {
+			actions = append(actions, revision.Action)
+		}
+		assert.DeepEqual(t, []string{"create", "update", "update", "update", "delete", "restore", "delete"}, actions)
+		assert.Equal(t, "alice", revisions[0].Actor, name)
+		assert.Equal(t, "req-1", revisions[0].RequestID, name)
+		assert.Equal(t, `"Standup"`, string(revisions[0].Changes["title"].New), name)
+		assert.Equal(t, `null`, string(revisions[0].Changes["title"].Old), name)
+		assert.DeepEqual(t, models.FieldChanges{"title": {Old: []byte(`"Standup"`), New: []byte(`"Daily standup"`)}}, revisions[1].Changes)
+		assert.Equal(t, uint(2), revisions[1].Version, name)
+		assert.Equal(t, 1, len(revisions[2].Changes), name)
+		assert.Equal(t, `null`, string(revisions[2].Changes["overrides[2024-03-05]"].Old), name)
+		assert.Equal(t, 2, len(revisions[3].Changes), name)
+		assert.DeepEqual(t, []string{"2024-03-05"}, revisions[3].Snapshot.ExDates)
+		assert.Equal(t, 0, len(revisions[4].Changes), name)
+		assert.Equal(t, "Daily standup", revisions[6].Snapshot.Title, name)
+
+		revision, err := events.GetRevision(event.ID, revisions[1].ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, "Daily standup", revision.Snapshot.Title, name)
+		_, err = events.GetRevision(event.ID+1, revisions[1].ID)
+		assert.Equal(t, ErrNotFound, err, name)
+
+		// Purged events keep their history
+		_, err = events.Purge(TrashFilter{IDs: []uint{event.ID}})
+		assert.NilError(t, err, name)
+		revisions, err = events.ListRevisions(event.ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 7, len(revisions), name)
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	sqliteEvents, _ := newSQLiteRepositories(t)
+	memoryEvents, _, _, _, _ := NewMemoryRepositories()
+	backends := map[string]EventRepository{"sqlite": sqliteEvents, "memory": memoryEvents}
+	rollback := errors.New("rollback")
+
+	for name, events := range backends {
+		// Changes are discarded when the transaction fails
+		var created models.Event
+		err := events.Transaction(func(events EventRepository, calendars CalendarRepository) error {
+			calendar, err := calendars.GetDefault()
+			assert.NilError(t, err, name)
+			created = models.Event{CalendarID: calendar.ID, Title: "Discarded", EventDate: "2024-03-01", StartTime: "15:00:00+07", EndTime: "16:00:00+07"}
+			assert.NilError(t, events.Create(&created, models.Audit{}), name)
+			_, err = events.Get(created.ID)
+			assert.NilError(t, err, name)
+			return rollback
+		})
+		assert.Equal(t, rollback, err, name)
+		_, err = events.Get(created.ID)
+		assert.Equal(t, ErrNotFound, err, name)
+		revisions, err := events.ListRevisions(created.ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 0, len(revisions), name)
+
+		// A failed change does not end the transaction, the others are kept
+		var kept models.Event
+		err = events.Transaction(func(events EventRepository, calendars CalendarRepository) error {
+			calendar, err := calendars.GetDefault()
+			assert.NilError(t, err, name)
+			kept = models.Event{CalendarID: calendar.ID, Title: "Kept", EventDate: "2024-03-01", StartTime: "15:00:00+07", EndTime: "16:00:00+07"}
+			assert.NilError(t, events.Create(&kept, models.Audit{}), name)
+			stale := kept
+			stale.Version = 0
+			assert.Equal(t, ErrVersionConflict, events.Delete(&stale, models.Audit{}), name)
+			return nil
+		})
+		assert.NilError(t, err, name)
+		_, err = events.Get(kept.ID)
+		assert.NilError(t, err, name)
+
+		// Overlaps found while the calendar is locked hold until the event is written, series included
+		// which the triggers leave out
+		overlapping := errors.New("overlapping")
+		errs := make([]error, 4)
+		var wg sync.WaitGroup
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = events.Transaction(func(events EventRepository, calendars CalendarRepository) error {
+					series := models.Event{CalendarID: kept.CalendarID, Title: "Series", EventDate: "2024-03-04", StartTime: "15:00:00+07", EndTime: "16:00:00+07", RRule: "FREQ=WEEKLY"}
+					if err := events.LockCalendar(series.CalendarID); err != nil {
+						return err
+					}
+					found, err := events.FindOverlaps(&series, 0)
+					if err != nil {
+						return err
+					}
+					if found {
+						return overlapping
+					}
+					return events.Create(&series, models.Audit{})
+				})
+			}(i)
+		}
+		wg.Wait()
+		written := 0
+		for _, err := range errs {
+			if err == nil {
+				written++
+			} else {
+				assert.Equal(t, overlapping, err, name)
+			}
+		}
+		assert.Equal(t, 1, written, name)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	backends := seedBackends(t, []models.Event{
+		{Title: "Réunion d'équipe", EventDate: "2024-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"},
+		{Title: "Sprint Review", EventDate: "2024-03-01", StartTime: "10:00:00+07", EndTime: "11:00:00+07"},
+		{Title: "Review of the sprint", EventDate: "2024-03-01", StartTime: "11:00:00+07", EndTime: "12:00:00+07"},
+		{Title: "Planning meetings", EventDate: "2024-03-01", StartTime: "13:00:00+07", EndTime: "14:00:00+07"},
+		{Title: "100%_done party", EventDate: "2024-03-01", StartTime: "15:00:00+07", EndTime: "16:00:00+07"},
+	})
+	for _, tc := range []struct {
+		search   string
+		expected []string
+		// stemmed are the extra titles the databases match by the stem of a word
+		stemmed []string
+	}{
+		{search: "reunion", expected: []string{"Réunion d'équipe"}},
+		{search: "EQUIPE", expected: []string{"Réunion d'équipe"}},
+		{search: "sprint review", expected: []string{"Sprint Review", "Review of the sprint"}},
+		{search: `"sprint review"`, expected: []string{"Sprint Review"}},
+		{search: "plan OR union", expected: []string{"Réunion d'équipe", "Planning meetings"}},
+		{search: "meeting", expected: []string{"Planning meetings"}},
+		{search: "meetings planned", expected: []string{}, stemmed: []string{"Planning meetings"}},
+		{search: "100%_", expected: []string{"100%_done party"}},
+		{search: "0%", expected: []string{"100%_done party"}},
+		{search: "_", expected: []string{"100%_done party"}},
+		{search: "view -", expected: []string{}},
+	} {
+		search, err := models.ParseSearch(tc.search)
+		assert.NilError(t, err, tc.search)
+		for name, repo := range backends {
+			events, err := repo.List(EventFilter{Search: search})
+			assert.NilError(t, err, tc.search)
+			titles := []string{}
+			for _, event := range events {
+				titles = append(titles, event.Title)
+			}
+			expected := tc.expected
+			if name == "sqlite" && tc.stemmed != nil {
+				expected = tc.stemmed
+			}
+			assert.DeepEqual(t, expected, titles)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	tags := func(names ...string) []models.Tag {
+		result := []models.Tag{}
+		for _, name := range names {
+			result = append(result, models.Tag{Name: name})
+		}
+		return result
+	}
+	backends := seedBackends(t, []models.Event{
+		{Title: "Offsite", EventDate: "2024-03-01", AllDay: true, Location: "Chiang Mai", Color: "#1e90ff", Tags: tags("work", "travel")},
+		{Title: "Flight", EventDate: "2024-03-02", StartTime: "09:00:00+07", EndTime: "10:00:00+07", URL: "https://example.com/booking", Tags: tags("travel")},
+		{Title: "Review", EventDate: "2024-03-03", StartTime: "09:00:00+07", EndTime: "10:00:00+07", Description: "Agenda:\n- budget", Tags: tags("work")},
+		{Title: "Lunch", EventDate: "2024-03-03", StartTime: "12:00:00+07", EndTime: "13:00:00+07"},
+	})
+	titles := func(repo EventRepository, filter EventFilter) []string {
+		events, err := repo.List(filter)
+		assert.NilError(t, err)
+		result := []string{}
+		for _, event := range events {
+			result = append(result, event.Title)
+		}
+		return result
+	}
+
+	for name, repo := range backends {
+		// Test case 1: the details are read back with the tags sorted by name
+		offsite, err := repo.Get(1)
+		assert.NilError(t, err, name)
+		assert.Equal(t, "Chiang Mai", offsite.Location)
+		assert.Equal(t, "#1e90ff", offsite.Color)
+		assert.DeepEqual(t, []string{"travel", "work"}, offsite.TagNames())
+		review, err := repo.Get(3)
+		assert.NilError(t, err, name)
+		assert.Equal(t, "Agenda:\n- budget", review.Description)
+
+		// Test case 2: events having any or all of the tags
+		assert.DeepEqual(t, []string{"Offsite", "Flight", "Review"}, titles(repo, EventFilter{Tags: []string{"work", "travel"}}))
+		assert.DeepEqual(t, []string{"Offsite"}, titles(repo, EventFilter{Tags: []string{"work", "travel"}, AllTags: true}))
+		assert.DeepEqual(t, []string{}, titles(repo, EventFilter{Tags: []string{"work", "unknown"}, AllTags: true}))
+
+		// Test case 3: updates replace the tags, revisions keep them
+		review.Tags = tags("travel", "budget")
+		assert.NilError(t, repo.Update(review, models.Audit{}), name)
+		assert.DeepEqual(t, []string{"Offsite", "Flight", "Review"}, titles(repo, EventFilter{Tags: []string{"travel"}}))
+		assert.DeepEqual(t, []string{"Offsite"}, titles(repo, EventFilter{Tags: []string{"work"}}))
+		revisions, err := repo.ListRevisions(review.ID)
+		assert.NilError(t, err, name)
+		assert.DeepEqual(t, []string{"budget", "travel"}, revisions[1].Snapshot.Tags)
+		assert.Equal(t, `["work"]`, string(revisions[1].Changes["tags"].Old))
+
+		// Test case 4: purged events lose their tags, other events keep them
+		assert.NilError(t, repo.Delete(offsite, models.Audit{}), name)
+		_, err = repo.Purge(TrashFilter{})
+		assert.NilError(t, err, name)
+		assert.DeepEqual(t, []string{"Flight", "Review"}, titles(repo, EventFilter{Tags: []string{"travel"}}))
+	}
+}
+
+func TestUserRepository(t *testing.T) {
+	backends := map[string]UserRepository{"sqlite": NewGormUserRepository(newSQLiteDB(t)), "memory": NewMemoryUserRepository()}
+
+	for name, users := range backends {
+		// Test case 1: names are unique
+		alice, bob := models.User{Name: "alice"}, models.User{Name: "bob", Admin: true}
+		assert.NilError(t, users.Create(&alice), name)
+		assert.NilError(t, users.Create(&bob), name)
+		assert.Equal(t, ErrNameTaken, users.Create(&models.User{Name: "alice"}), name)
+		list, err := users.List()
+		assert.NilError(t, err, name)
+		assert.Equal(t, 2, len(list), name)
+		assert.Equal(t, true, list[1].Admin, name)
+
+		// Test case 2: keys are found by the hash of their secret and only deleted by their user
+		key := models.APIKey{UserID: alice.ID, Name: "laptop"}
+		secret, err := key.NewAPIKeySecret()
+		assert.NilError(t, err, name)
+		assert.NilError(t, users.CreateKey(&key), name)
+		found, err := users.FindKey(models.HashAPIKey(secret))
+		assert.NilError(t, err, name)
+		assert.Equal(t, alice.ID, found.UserID, name)
+		assert.Equal(t, secret[:len(found.Prefix)], found.Prefix, name)
+		_, err = users.FindKey(models.HashAPIKey(secret + "x"))
+		assert.Equal(t, ErrNotFound, err, name)
+		assert.Equal(t, ErrNotFound, users.DeleteKey(bob.ID, key.ID), name)
+		keys, err := users.ListKeys(alice.ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(keys), name)
+
+		// Test case 3: deleting a user deletes their keys
+		assert.NilError(t, users.Delete(&alice), name)
+		_, err = users.Get(alice.ID)
+		assert.Equal(t, ErrNotFound, err, name)
+		_, err = users.FindKey(models.HashAPIKey(secret))
+		assert.Equal(t, ErrNotFound, err, name)
+	}
+}
+
+func TestAccessFilter(t *testing.T) {
+	alice, bob := uint(1), uint(2)
+	backends := seedBackends(t, []models.Event{
+		{OwnerID: &alice, Title: "Alice", EventDate: "2024-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"},
+		{OwnerID: &bob, Title: "Bob", EventDate: "2024-03-01", StartTime: "10:00:00+07", EndTime: "11:00:00+07"},
+		{Title: "Nobody", EventDate: "2024-03-01", StartTime: "11:00:00+07", EndTime: "12:00:00+07"},
+	})
+
+	for name, events := range backends {
+		// Events are limited to their owner, events without one only match without a filter
+		owned, err := events.List(EventFilter{Access: &Access{OwnerID: alice}})
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(owned), name)
+		assert.Equal(t, "Alice", owned[0].Title, name)
+		all, err := events.List(EventFilter{})
+		assert.NilError(t, err, name)
+		assert.Equal(t, 3, len(all), name)
+
+		// Events shared one by one or with their calendar are added
+		shared, err := events.List(EventFilter{Access: &Access{OwnerID: alice, EventIDs: []uint{all[2].ID}}})
+		assert.NilError(t, err, name)
+		assert.Equal(t, 2, len(shared), name)
+		shared, err = events.List(EventFilter{Access: &Access{OwnerID: 99, CalendarIDs: []uint{all[0].CalendarID}}})
+		assert.NilError(t, err, name)
+		assert.Equal(t, 3, len(shared), name)
+
+		// Deleted events too
+		for i := range all {
+			assert.NilError(t, events.Delete(&all[i], models.Audit{}), name)
+		}
+		deleted, err := events.ListDeleted(TrashFilter{Access: &Access{OwnerID: bob}})
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(deleted), name)
+		assert.Equal(t, "Bob", deleted[0].Title, name)
+	}
+}
+
+func TestGrantRepository(t *testing.T) {
+	db := newSQLiteDB(t)
+	memoryEvents, memoryCalendars, memoryGrants, _, _ := NewMemoryRepositories()
+	type backend struct {
+		events    EventRepository
+		calendars CalendarRepository
+		grants    GrantRepository
+		users     UserRepository
+	}
+	backends := map[string]backend{
+		"sqlite": {NewGormEventRepository(db), NewGormCalendarRepository(db), NewGormGrantRepository(db), NewGormUserRepository(db)},
+		"memory": {memoryEvents, memoryCalendars, memoryGrants, NewMemoryUserRepository()},
+	}
+
+	for name, repos := range backends {
+		alice := models.User{Name: "alice"}
+		assert.NilError(t, repos.users.Create(&alice), name)
+		calendar, err := repos.calendars.GetDefault()
+		assert.NilError(t, err, name)
+		event := models.Event{CalendarID: calendar.ID, Title: "Review", EventDate: "2024-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, repos.events.Create(&event, models.Audit{}), name)
+
+		// Test case 1: a user has one grant on a calendar, saving it again changes its role
+		grant := models.Grant{CalendarID: &calendar.ID, UserID: alice.ID, Role: models.RoleViewer}
+		assert.NilError(t, repos.grants.Save(&grant), name)
+		again := models.Grant{CalendarID: &calendar.ID, UserID: alice.ID, Role: models.RoleEditor, GrantedBy: 7}
+		assert.NilError(t, repos.grants.Save(&again), name)
+		assert.Equal(t, grant.ID, again.ID, name)
+		grants, err := repos.grants.ListForCalendar(calendar.ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(grants), name)
+		assert.Equal(t, models.RoleEditor, grants[0].Role, name)
+		assert.Equal(t, uint(7), grants[0].GrantedBy, name)
+
+		// Test case 2: grants on events are apart from the grants on calendars
+		eventGrant := models.Grant{EventID: &event.ID, UserID: alice.ID, Role: models.RoleOwner}
+		assert.NilError(t, repos.grants.Save(&eventGrant), name)
+		assert.Assert(t, eventGrant.ID != grant.ID, name)
+		grants, err = repos.grants.ListForUser(alice.ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 2, len(grants), name)
+		grants, err = repos.grants.ListForEvent(event.ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(grants), name)
+
+		// Test case 3: revoking a missing grant is not found
+		assert.NilError(t, repos.grants.Delete(&models.Grant{CalendarID: &calendar.ID, UserID: alice.ID}), name)
+		assert.Equal(t, ErrNotFound, repos.grants.Delete(&models.Grant{CalendarID: &calendar.ID, UserID: alice.ID}), name)
+
+		// Test case 4: purging an event removes its grants
+		assert.NilError(t, repos.events.Delete(&event, models.Audit{}), name)
+		_, err = repos.events.Purge(TrashFilter{})
+		assert.NilError(t, err, name)
+		grants, err = repos.grants.ListForUser(alice.ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 0, len(grants), name)
+	}
+}
+
+func TestWebhookRepository(t *testing.T) {
+	db := newSQLiteDB(t)
+	memoryEvents, _, _, memoryWebhooks, _ := NewMemoryRepositories()
+	type backend struct {
+		events   EventRepository
+		webhooks WebhookRepository
+	}
+	backends := map[string]backend{
+		"sqlite": {NewGormEventRepository(db), NewGormWebhookRepository(db)},
+		"memory": {memoryEvents, memoryWebhooks},
+	}
+	rollback := errors.New("rollback")
+
+	for name, repos := range backends {
+		all := models.Webhook{URL: "http://localhost/all", Secret: "all"}
+		assert.NilError(t, repos.webhooks.Create(&all), name)
+		deletes := models.Webhook{URL: "http://localhost/deletes", Types: models.ChangeTypes{models.ChangeEventDeleted}, Secret: "deletes"}
+		assert.NilError(t, repos.webhooks.Create(&deletes), name)
+
+		// Test case 1: changes queue a delivery to each webhook subscribed to their type
+		event := models.Event{CalendarID: 1, Title: "Review", EventDate: "2024-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, repos.events.Create(&event, models.Audit{Actor: "alice"}), name)
+		assert.NilError(t, repos.events.Delete(&event, models.Audit{Actor: "alice"}), name)
+		deliveries, err := repos.webhooks.ListDeliveries(all.ID, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 2, len(deliveries), name)
+		assert.Equal(t, models.ChangeEventDeleted, deliveries[0].Type, name)
+		assert.Equal(t, models.ChangeEventCreated, deliveries[1].Type, name)
+		assert.Equal(t, models.DeliveryPending, deliveries[1].Status, name)
+		var payload models.WebhookPayload
+		assert.NilError(t, json.Unmarshal([]byte(deliveries[1].Payload), &payload), name)
+		assert.Equal(t, event.ID, payload.EventID, name)
+		assert.Equal(t, "alice", payload.Actor, name)
+		assert.Assert(t, payload.Before == nil, name)
+		assert.Equal(t, "Review", payload.After.Title, name)
+		deliveries, err = repos.webhooks.ListDeliveries(deletes.ID, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(deliveries), name)
+		assert.NilError(t, json.Unmarshal([]byte(deliveries[0].Payload), &payload), name)
+		assert.Equal(t, "Review", payload.Before.Title, name)
+		assert.Assert(t, payload.After == nil, name)
+
+		// Test case 2: changes rolled back with their transaction queue nothing
+		err = repos.events.Transaction(func(events EventRepository, calendars CalendarRepository) error {
+			discarded := models.Event{CalendarID: 1, Title: "Discarded", EventDate: "2024-03-01", StartTime: "15:00:00+07", EndTime: "16:00:00+07"}
+			assert.NilError(t, events.Create(&discarded, models.Audit{}), name)
+			return rollback
+		})
+		assert.Equal(t, rollback, err, name)
+		deliveries, err = repos.webhooks.ListDeliveries(all.ID, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 2, len(deliveries), name)
+
+		// Test case 3: due deliveries are claimed by one sender only
+		now := time.Now().Add(time.Second)
+		due, err := repos.webhooks.DueDeliveries(now, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 3, len(due), name)
+		claimed, err := repos.webhooks.ClaimDelivery(&due[0], now, now.Add(time.Minute))
+		assert.NilError(t, err, name)
+		assert.Assert(t, claimed, name)
+		claimed, err = repos.webhooks.ClaimDelivery(&due[0], now, now.Add(time.Minute))
+		assert.NilError(t, err, name)
+		assert.Assert(t, !claimed, name)
+
+		// Test case 4: outcomes are only saved while the lease of the claim holds
+		lease := *due[0].NextAttemptAt
+		due[0].Status, due[0].NextAttemptAt = models.DeliverySucceeded, nil
+		saved, err := repos.webhooks.SaveDelivery(&due[0], lease.Add(-time.Minute))
+		assert.NilError(t, err, name)
+		assert.Assert(t, !saved, name)
+		saved, err = repos.webhooks.SaveDelivery(&due[0], lease)
+		assert.NilError(t, err, name)
+		assert.Assert(t, saved, name)
+		stored, err := repos.webhooks.GetDelivery(due[0].WebhookID, due[0].ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, models.DeliverySucceeded, stored.Status, name)
+		due, err = repos.webhooks.DueDeliveries(now, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 2, len(due), name)
+
+		// Test case 5: the deliveries of disabled webhooks wait until they are enabled
+		deletes.Disabled = true
+		assert.NilError(t, repos.webhooks.Update(&deletes), name)
+		due, err = repos.webhooks.DueDeliveries(now, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(due), name)
+		assert.Equal(t, all.ID, due[0].WebhookID, name)
+
+		// Test case 6: deleting a webhook deletes its deliveries
+		assert.NilError(t, repos.webhooks.Delete(&all), name)
+		_, err = repos.webhooks.Get(all.ID)
+		assert.Equal(t, ErrNotFound, err, name)
+		_, err = repos.webhooks.GetDelivery(all.ID, due[0].ID)
+		assert.Equal(t, ErrNotFound, err, name)
+	}
+}
+
+func TestReminderRepository(t *testing.T) {
+	db := newSQLiteDB(t)
+	memoryEvents, _, _, _, memoryReminders := NewMemoryRepositories()
+	type backend struct {
+		events    EventRepository
+		reminders ReminderRepository
+	}
+	backends := map[string]backend{
+		"sqlite": {NewGormEventRepository(db), NewGormReminderRepository(db)},
+		"memory": {memoryEvents, memoryReminders},
+	}
+	minutes := 10
+	now := time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, repos := range backends {
+		event := models.Event{CalendarID: 1, Title: "Review", EventDate: "2099-03-01", StartTime: "09:00:00+07", EndTime: "10:00:00+07"}
+		assert.NilError(t, repos.events.Create(&event, models.Audit{}), name)
+		reminder := models.Reminder{EventID: event.ID, UserID: 1, MinutesBefore: &minutes, Method: models.ReminderLog}
+		reminder.Schedule(&event, now)
+		assert.NilError(t, repos.reminders.Create(&reminder), name)
+
+		// Test case 1: reminders follow their event when it is rescheduled
+		event.StartTime, event.EndTime = "11:00:00+07", "12:00:00+07"
+		assert.NilError(t, repos.events.Update(&event, models.Audit{}), name)
+		reminders, err := repos.reminders.ListForEvent(event.ID)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(reminders), name)
+		assert.Assert(t, reminders[0].NextFireAt.Equal(time.Date(2099, 3, 1, 3, 50, 0, 0, time.UTC)), name)
+		assert.Assert(t, reminders[0].OccurrenceAt.Equal(time.Date(2099, 3, 1, 4, 0, 0, 0, time.UTC)), name)
+		mine, err := repos.reminders.ListForUser(1)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(mine), name)
+		mine, err = repos.reminders.ListForUser(2)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 0, len(mine), name)
+
+		// Test case 2: due reminders fire once for their occurrence
+		due, err := repos.reminders.DueReminders(*reminders[0].NextFireAt, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(due), name)
+		next := due[0]
+		firing := models.ReminderFiring{ReminderID: next.ID, EventID: event.ID, OccurrenceAt: *next.OccurrenceAt,
+			FireAt: *next.NextFireAt, Status: models.FiringPending, NextAttemptAt: next.NextFireAt}
+		next.NextFireAt, next.OccurrenceAt = nil, nil
+		fired, err := repos.reminders.Fire(&next, &firing)
+		assert.NilError(t, err, name)
+		assert.Assert(t, fired, name)
+		again := firing
+		again.ID = 0
+		fired, err = repos.reminders.Fire(&due[0], &again)
+		assert.NilError(t, err, name)
+		assert.Assert(t, !fired, name)
+		firings, err := repos.reminders.ListFirings(reminder.ID, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(firings), name)
+
+		// Test case 3: an occurrence fired before does not fire again once scheduled back
+		event.Title = "Design Review"
+		assert.NilError(t, repos.events.Update(&event, models.Audit{}), name)
+		stored, err := repos.reminders.Get(event.ID, reminder.ID)
+		assert.NilError(t, err, name)
+		assert.Assert(t, stored.OccurrenceAt.Equal(firing.OccurrenceAt), name)
+		again.ID = 0
+		fired, err = repos.reminders.Fire(&next, &again)
+		assert.NilError(t, err, name)
+		assert.Assert(t, !fired, name)
+		firings, err = repos.reminders.ListFirings(reminder.ID, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(firings), name)
+
+		// Test case 4: pending firings are claimed by one scheduler only
+		attemptAt := firing.FireAt.Add(time.Second)
+		pending, err := repos.reminders.DueFirings(attemptAt, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 1, len(pending), name)
+		claimed, err := repos.reminders.ClaimFiring(&pending[0], attemptAt, attemptAt.Add(time.Minute))
+		assert.NilError(t, err, name)
+		assert.Assert(t, claimed, name)
+		claimed, err = repos.reminders.ClaimFiring(&pending[0], attemptAt, attemptAt.Add(time.Minute))
+		assert.NilError(t, err, name)
+		assert.Assert(t, !claimed, name)
+
+		// Test case 5: outcomes are only saved while the lease of the claim holds
+		lease := *pending[0].NextAttemptAt
+		pending[0].Status, pending[0].NextAttemptAt = models.FiringSent, nil
+		saved, err := repos.reminders.SaveFiring(&pending[0], lease.Add(-time.Minute))
+		assert.NilError(t, err, name)
+		assert.Assert(t, !saved, name)
+		saved, err = repos.reminders.SaveFiring(&pending[0], lease)
+		assert.NilError(t, err, name)
+		assert.Assert(t, saved, name)
+		firings, err = repos.reminders.ListFirings(reminder.ID, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, models.FiringSent, firings[0].Status, name)
+		saved, err = repos.reminders.SaveFiring(&pending[0], lease)
+		assert.NilError(t, err, name)
+		assert.Assert(t, !saved, name)
+
+		// Test case 6: deleting the event clears its reminders, restoring it schedules them again
+		assert.NilError(t, repos.events.Delete(&event, models.Audit{}), name)
+		stored, err = repos.reminders.Get(event.ID, reminder.ID)
+		assert.NilError(t, err, name)
+		assert.Assert(t, stored.NextFireAt == nil, name)
+		deleted, err := repos.events.GetDeleted(event.ID)
+		assert.NilError(t, err, name)
+		assert.NilError(t, repos.events.Restore(deleted, models.Audit{}), name)
+		stored, err = repos.reminders.Get(event.ID, reminder.ID)
+		assert.NilError(t, err, name)
+		assert.Assert(t, stored.NextFireAt != nil, name)
+
+		// Test case 7: purged events take their reminders and firings with them
+		assert.NilError(t, repos.events.Delete(deleted, models.Audit{}), name)
+		_, err = repos.events.Purge(TrashFilter{})
+		assert.NilError(t, err, name)
+		_, err = repos.reminders.Get(event.ID, reminder.ID)
+		assert.Equal(t, ErrNotFound, err, name)
+		firings, err = repos.reminders.ListFirings(reminder.ID, 10)
+		assert.NilError(t, err, name)
+		assert.Equal(t, 0, len(firings), name)
+	}
+}
@@ -0,0 +1,1193 @@
+package repositories
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+	"gorm.io/gorm"
+)
+
+// memoryStore holds the events, calendars, grants, webhooks and reminders of the in-memory repositories.
+// Deleted records are kept with DeletedAt set, like the soft deletes of GORM.
+type memoryStore struct {
+	mu             sync.Mutex
+	txMu           sync.Mutex // held by the running transaction
+	events         map[uint]*models.Event
+	calendars      map[uint]*models.Calendar
+	revisions      []models.EventRevision
+	grants         map[uint]*models.Grant
+	webhooks       map[uint]*models.Webhook
+	deliveries     map[uint]*models.WebhookDelivery
+	reminders      map[uint]*models.Reminder
+	firings        map[uint]*models.ReminderFiring
+	nextEventID    uint
+	nextOverrideID uint
+	nextCalendarID uint
+	nextGrantID    uint
+	nextWebhookID  uint
+	nextDeliveryID uint
+	nextReminderID uint
+	nextFiringID   uint
+}
+
+// NewMemoryRepositories stores events, calendars, grants, webhooks and reminders in memory, starting with an empty
+// default calendar. They follow the same rules as the GORM repositories and are meant for tests.
+func NewMemoryRepositories() (EventRepository, CalendarRepository, GrantRepository, WebhookRepository, ReminderRepository) {
+	store := &memoryStore{
+		events:     map[uint]*models.Event{},
+		calendars:  map[uint]*models.Calendar{},
+		grants:     map[uint]*models.Grant{},
+		webhooks:   map[uint]*models.Webhook{},
+		deliveries: map[uint]*models.WebhookDelivery{},
+		reminders:  map[uint]*models.Reminder{},
+		firings:    map[uint]*models.ReminderFiring{},
+	}
+	store.createCalendar(&models.Calendar{Name: "Default", IsDefault: true})
+	return &memoryEventRepository{store: store}, &memoryCalendarRepository{store}, &memoryGrantRepository{store},
+		&memoryWebhookRepository{store}, &memoryReminderRepository{store}
+}
+
+// memoryEventRepository stores events in a memoryStore
+type memoryEventRepository struct {
+	store *memoryStore
+	// inTransaction is set on the repository given to the function of a transaction
+	inTransaction bool
+}
+
+func (r *memoryEventRepository) Get(id uint) (*models.Event, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	event, ok := r.store.events[id]
+	if !ok || event.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return copyEvent(event), nil
+}
+
+func (r *memoryEventRepository) FindByUID(uid string) (*models.Event, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range r.store.eventIDs() {
+		if event := r.store.events[id]; !event.DeletedAt.Valid && event.UID == uid {
+			return copyEvent(event), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryEventRepository) List(filter EventFilter) ([]models.Event, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	events := []models.Event{}
+	for _, event := range r.store.events {
+		if event.DeletedAt.Valid || !matchesFilter(event, &filter) {
+			continue
+		}
+		events = append(events, *copyEvent(event))
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return filter.Compare(&events[i], &events[j]) < 0
+	})
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[:filter.Limit]
+	}
+	return events, nil
+}
+
+// Check an event against the conditions of a filter
+func matchesFilter(event *models.Event, filter *EventFilter) bool {
+	startAt, endAt := filter.instants()
+	if filter.Location != nil && !event.AllDay {
+		if filter.StartDate != "" && !event.IsRecurring() && !event.GetEndAt().After(startAt) {
+			return false
+		}
+		if filter.EndDate != "" && !event.GetStartAt().Before(endAt) {
+			return false
+		}
+	} else {
+		if filter.StartDate != "" && !event.IsRecurring() && event.EndDate < filter.StartDate {
+			return false
+		}
+		if filter.EndDate != "" && event.EventDate > filter.EndDate {
+			return false
+		}
+	}
+	if filter.Keyword != "" && !strings.Contains(event.Title, filter.Keyword) {
+		return false
+	}
+	if filter.Search != nil && !filter.Search.Matches(event.Title) {
+		return false
+	}
+	if len(filter.CalendarIDs) > 0 && !inCalendars(event, filter.CalendarIDs) {
+		return false
+	}
+	if filter.Access != nil && !filter.Access.includes(event) {
+		return false
+	}
+	if len(filter.Tags) > 0 && !hasTags(event, filter.Tags, filter.AllTags) {
+		return false
+	}
+	if filter.BusyOnly && !event.IsBusy() {
+		return false
+	}
+	if filter.Recurring != nil && event.IsRecurring() != *filter.Recurring {
+		return false
+	}
+	if filter.After != nil && filter.Compare(event, filter.After) <= 0 {
+		return false
+	}
+	return true
+}
+
+// Check whether an event has one of the tags, or all of them
+func hasTags(event *models.Event, names []string, all bool) bool {
+	count := 0
+	for _, name := range names {
+		for _, tag := range event.Tags {
+			if tag.Name == name {
+				count++
+				break
+			}
+		}
+	}
+	if all {
+		return count == len(names)
+	}
+	return count > 0
+}
+
+// Check whether an event is in one of the calendars
+func inCalendars(event *models.Event, calendarIDs []uint64) bool {
+	for _, id := range calendarIDs {
+		if uint64(event.CalendarID) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryEventRepository) Create(event *models.Event, audit models.Audit) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.nextEventID++
+	event.ID = r.store.nextEventID
+	event.CreatedAt = time.Now()
+	event.UpdatedAt = event.CreatedAt
+	event.BeforeSave(nil)
+	sortTags(event)
+	for i := range event.Overrides {
+		r.store.nextOverrideID++
+		event.Overrides[i].ID = r.store.nextOverrideID
+		event.Overrides[i].EventID = event.ID
+		event.Overrides[i].BeforeSave(nil)
+	}
+	event.FormatDates()
+	r.store.events[event.ID] = copyEvent(event)
+	r.store.record(models.RevisionCreate, event.ID, nil, audit)
+	return nil
+}
+
+func (r *memoryEventRepository) Update(event *models.Event, audit models.Audit) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.events[event.ID]
+	if !ok || stored.DeletedAt.Valid || stored.Version != event.Version {
+		return ErrVersionConflict
+	}
+	event.Version++
+	event.UpdatedAt = time.Now()
+	event.BeforeSave(nil)
+	sortTags(event)
+	event.FormatDates()
+
+	// The UID and the overrides are not changed by updates
+	updated := copyEvent(event)
+	updated.UID = stored.UID
+	updated.CreatedAt = stored.CreatedAt
+	updated.Overrides = stored.Overrides
+	r.store.events[event.ID] = updated
+	r.store.record(models.RevisionUpdate, event.ID, stored, audit)
+	return nil
+}
+
+func (r *memoryEventRepository) Delete(event *models.Event, audit models.Audit) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.events[event.ID]
+	if !ok || stored.DeletedAt.Valid || stored.Version != event.Version {
+		return ErrVersionConflict
+	}
+	before := copyEvent(stored)
+	stored.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.store.record(models.RevisionDelete, event.ID, before, audit)
+	return nil
+}
+
+// Transactions of the memory store already run one at a time
+func (r *memoryEventRepository) LockCalendar(calendarID uint) error {
+	return nil
+}
+
+func (r *memoryEventRepository) FindOverlaps(event *models.Event, excludeID uint) (bool, error) {
+	occurrences, err := overlapOccurrences(event)
+	if err != nil || len(occurrences) == 0 {
+		return false, err
+	}
+	from, to, err := event.Span()
+	if err != nil {
+		return false, err
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, id := range r.store.eventIDs() {
+		other := r.store.events[id]
+		if other.DeletedAt.Valid || other.ID == excludeID || other.CalendarID != event.CalendarID || !other.IsBusy() {
+			continue
+		}
+		expanded, err := other.Occurrences(from, to)
+		if err != nil {
+			return false, err
+		}
+		if overlapsAny(occurrences, expanded) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryEventRepository) SaveOverride(event *models.Event, override *models.EventOverride, audit models.Audit) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, before, err := r.store.bumpVersion(event)
+	if err != nil {
+		return err
+	}
+	override.EventID = event.ID
+	override.UpdatedAt = time.Now()
+	override.BeforeSave(nil)
+	replaced := false
+	for i := range stored.Overrides {
+		if stored.Overrides[i].RecurrenceDate == override.RecurrenceDate {
+			override.ID = stored.Overrides[i].ID
+			override.CreatedAt = stored.Overrides[i].CreatedAt
+			stored.Overrides[i] = *override
+			replaced = true
+		}
+	}
+	if !replaced {
+		r.store.nextOverrideID++
+		override.ID = r.store.nextOverrideID
+		override.CreatedAt = override.UpdatedAt
+		stored.Overrides = append(stored.Overrides, *override)
+	}
+	r.store.record(models.RevisionUpdate, event.ID, before, audit)
+	return nil
+}
+
+func (r *memoryEventRepository) CancelOccurrence(event *models.Event, recurrenceDate string, audit models.Audit) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, before, err := r.store.bumpVersion(event)
+	if err != nil {
+		return err
+	}
+	overrides := []models.EventOverride{}
+	for _, o := range stored.Overrides {
+		if o.RecurrenceDate != recurrenceDate {
+			overrides = append(overrides, o)
+		}
+	}
+	stored.Overrides = overrides
+	stored.ExDates = append(stored.ExDates, recurrenceDate)
+	event.ExDates = append(models.DateList{}, stored.ExDates...)
+	r.store.record(models.RevisionUpdate, event.ID, before, audit)
+	return nil
+}
+
+func (r *memoryEventRepository) GetDeleted(id uint) (*models.Event, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	event, ok := r.store.events[id]
+	if !ok || !event.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return copyEvent(event), nil
+}
+
+func (r *memoryEventRepository) ListDeleted(filter TrashFilter) ([]models.Event, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	events := []models.Event{}
+	for _, id := range r.store.eventIDs() {
+		if event := r.store.events[id]; matchesTrashFilter(event, &filter) {
+			events = append(events, *copyEvent(event))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].DeletedAt.Time.Equal(events[j].DeletedAt.Time) {
+			return events[i].DeletedAt.Time.After(events[j].DeletedAt.Time)
+		}
+		return events[i].ID > events[j].ID
+	})
+	return events, nil
+}
+
+// Check a deleted event against the conditions of a trash filter
+func matchesTrashFilter(event *models.Event, filter *TrashFilter) bool {
+	if !event.DeletedAt.Valid {
+		return false
+	}
+	if len(filter.IDs) > 0 {
+		found := false
+		for _, id := range filter.IDs {
+			found = found || event.ID == id
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.Keyword != "" && !strings.Contains(event.Title, filter.Keyword) {
+		return false
+	}
+	if len(filter.CalendarIDs) > 0 && !inCalendars(event, filter.CalendarIDs) {
+		return false
+	}
+	if filter.Access != nil && !filter.Access.includes(event) {
+		return false
+	}
+	if !filter.DeletedBefore.IsZero() && !event.DeletedAt.Time.Before(filter.DeletedBefore) {
+		return false
+	}
+	return true
+}
+
+func (r *memoryEventRepository) Restore(event *models.Event, audit models.Audit) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.events[event.ID]
+	if !ok || !stored.DeletedAt.Valid || stored.Version != event.Version {
+		return ErrVersionConflict
+	}
+	before := copyEvent(stored)
+	stored.DeletedAt = gorm.DeletedAt{}
+	stored.Version++
+	stored.UpdatedAt = time.Now()
+	event.DeletedAt = gorm.DeletedAt{}
+	event.Version = stored.Version
+	r.store.record(models.RevisionRestore, event.ID, before, audit)
+	return nil
+}
+
+func (r *memoryEventRepository) Purge(filter TrashFilter) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var purged int64
+	for _, id := range r.store.eventIDs() {
+		if matchesTrashFilter(r.store.events[id], &filter) {
+			delete(r.store.events, id)
+			for grantID, grant := range r.store.grants {
+				if grant.EventID != nil && *grant.EventID == id {
+					delete(r.store.grants, grantID)
+				}
+			}
+			for reminderID, reminder := range r.store.reminders {
+				if reminder.EventID == id {
+					r.store.deleteReminder(reminderID)
+				}
+			}
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *memoryEventRepository) ListRevisions(eventID uint) ([]models.EventRevision, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	revisions := []models.EventRevision{}
+	for _, revision := range r.store.revisions {
+		if revision.EventID == eventID {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+func (r *memoryEventRepository) ListRevisionsAfter(afterID uint, limit int) ([]models.EventRevision, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	revisions := []models.EventRevision{}
+	for _, revision := range r.store.revisions {
+		if revision.ID > afterID && len(revisions) < limit {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+func (r *memoryEventRepository) LastRevisionID() (uint, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return uint(len(r.store.revisions)), nil
+}
+
+func (r *memoryEventRepository) GetRevision(eventID, revisionID uint) (*models.EventRevision, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, revision := range r.store.revisions {
+		if revision.EventID == eventID && revision.ID == revisionID {
+			copied := revision
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Transactions run one at a time and put back the records they started from when fn fails.
+// Changes made outside the transaction while it runs are lost with it. Transactions started
+// within a transaction only put back their own changes, like savepoints.
+func (r *memoryEventRepository) Transaction(fn func(events EventRepository, calendars CalendarRepository) error) error {
+	if !r.inTransaction {
+		r.store.txMu.Lock()
+		defer r.store.txMu.Unlock()
+	}
+
+	r.store.mu.Lock()
+	saved := r.store.snapshot()
+	r.store.mu.Unlock()
+	if err := fn(&memoryEventRepository{store: r.store, inTransaction: true}, &memoryCalendarRepository{r.store}); err != nil {
+		r.store.mu.Lock()
+		r.store.restore(saved)
+		r.store.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// memorySnapshot holds copies of the records of a memoryStore
+type memorySnapshot struct {
+	events         map[uint]*models.Event
+	calendars      map[uint]*models.Calendar
+	revisions      []models.EventRevision
+	deliveries     map[uint]*models.WebhookDelivery
+	reminders      map[uint]*models.Reminder
+	nextEventID    uint
+	nextOverrideID uint
+	nextCalendarID uint
+	nextDeliveryID uint
+}
+
+// Copy the records of the store
+func (s *memoryStore) snapshot() *memorySnapshot {
+	saved := &memorySnapshot{
+		events:         map[uint]*models.Event{},
+		calendars:      map[uint]*models.Calendar{},
+		revisions:      append([]models.EventRevision(nil), s.revisions...),
+		deliveries:     map[uint]*models.WebhookDelivery{},
+		reminders:      map[uint]*models.Reminder{},
+		nextEventID:    s.nextEventID,
+		nextOverrideID: s.nextOverrideID,
+		nextCalendarID: s.nextCalendarID,
+		nextDeliveryID: s.nextDeliveryID,
+	}
+	for id, event := range s.events {
+		saved.events[id] = copyEvent(event)
+	}
+	for id, calendar := range s.calendars {
+		copied := *calendar
+		saved.calendars[id] = &copied
+	}
+	for id, delivery := range s.deliveries {
+		copied := *delivery
+		saved.deliveries[id] = &copied
+	}
+	for id, reminder := range s.reminders {
+		copied := *reminder
+		saved.reminders[id] = &copied
+	}
+	return saved
+}
+
+// Put back the records of a snapshot
+func (s *memoryStore) restore(saved *memorySnapshot) {
+	s.events = saved.events
+	s.calendars = saved.calendars
+	s.revisions = saved.revisions
+	s.deliveries = saved.deliveries
+	s.reminders = saved.reminders
+	s.nextEventID = saved.nextEventID
+	s.nextOverrideID = saved.nextOverrideID
+	s.nextCalendarID = saved.nextCalendarID
+	s.nextDeliveryID = saved.nextDeliveryID
+}
+
+// Record the change of a stored event from its state before, nil for a creation
+func (s *memoryStore) record(action string, eventID uint, before *models.Event, audit models.Audit) {
+	after := copyEvent(s.events[eventID])
+	revision := newRevision(action, before, after, audit)
+	revision.ID = uint(len(s.revisions) + 1)
+	s.revisions = append(s.revisions, *revision)
+
+	// Queue the deliveries to the webhooks, events always encode to JSON
+	var webhooks []models.Webhook
+	for _, webhook := range s.webhooks {
+		webhooks = append(webhooks, *webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	deliveries, _ := newDeliveries(webhooks, revision, before, after)
+	for i := range deliveries {
+		s.nextDeliveryID++
+		deliveries[i].ID = s.nextDeliveryID
+		deliveries[i].CreatedAt = revision.CreatedAt
+		s.deliveries[deliveries[i].ID] = &deliveries[i]
+	}
+
+	for _, reminder := range s.reminders {
+		if reminder.EventID == eventID {
+			reminder.Schedule(after, revision.CreatedAt)
+		}
+	}
+}
+
+// Move a stored event to its next version, unless it changed since it was read.
+// The stored event is returned with a copy of it as it was before.
+func (s *memoryStore) bumpVersion(event *models.Event) (*models.Event, *models.Event, error) {
+	stored, ok := s.events[event.ID]
+	if !ok || stored.DeletedAt.Valid || stored.Version != event.Version {
+		return nil, nil, ErrVersionConflict
+	}
+	before := copyEvent(stored)
+	stored.Version++
+	stored.UpdatedAt = time.Now()
+	event.Version = stored.Version
+	return stored, before, nil
+}
+
+// IDs of the stored events in creation order
+func (s *memoryStore) eventIDs() []uint {
+	ids := make([]uint, 0, len(s.events))
+	for id := range s.events {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Sort the tags of an event by name like the database returns them
+func sortTags(event *models.Event) {
+	sort.Slice(event.Tags, func(i, j int) bool { return event.Tags[i].Name < event.Tags[j].Name })
+}
+
+// Copy an event so that the stored one is not changed through the copy
+func copyEvent(event *models.Event) *models.Event {
+	copied := *event
+	if event.Busy != nil {
+		busy := *event.Busy
+		copied.Busy = &busy
+	}
+	copied.ExDates = append(models.DateList(nil), event.ExDates...)
+	copied.Overrides = append([]models.EventOverride(nil), event.Overrides...)
+	copied.Tags = append([]models.Tag(nil), event.Tags...)
+	return &copied
+}
+
+// memoryCalendarRepository stores calendars in a memoryStore
+type memoryCalendarRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryCalendarRepository) List() ([]models.Calendar, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	calendars := []models.Calendar{}
+	for _, calendar := range r.store.calendars {
+		if !calendar.DeletedAt.Valid {
+			calendars = append(calendars, *calendar)
+		}
+	}
+	sort.Slice(calendars, func(i, j int) bool { return calendars[i].ID < calendars[j].ID })
+	return calendars, nil
+}
+
+func (r *memoryCalendarRepository) Get(id uint) (*models.Calendar, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	calendar, ok := r.store.calendars[id]
+	if !ok || calendar.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	copied := *calendar
+	return &copied, nil
+}
+
+func (r *memoryCalendarRepository) GetDefault() (*models.Calendar, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, calendar := range r.store.calendars {
+		if calendar.IsDefault && !calendar.DeletedAt.Valid {
+			copied := *calendar
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryCalendarRepository) Create(calendar *models.Calendar) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.createCalendar(calendar)
+	return nil
+}
+
+func (s *memoryStore) createCalendar(calendar *models.Calendar) {
+	s.nextCalendarID++
+	calendar.ID = s.nextCalendarID
+	calendar.CreatedAt = time.Now()
+	calendar.UpdatedAt = calendar.CreatedAt
+	copied := *calendar
+	s.calendars[calendar.ID] = &copied
+}
+
+func (r *memoryCalendarRepository) Update(calendar *models.Calendar) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	if _, ok := r.store.calendars[calendar.ID]; !ok {
+		return ErrNotFound
+	}
+	calendar.UpdatedAt = time.Now()
+	copied := *calendar
+	r.store.calendars[calendar.ID] = &copied
+	return nil
+}
+
+func (r *memoryCalendarRepository) Delete(calendar *models.Calendar, audit models.Audit) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	deletedAt := gorm.DeletedAt{Time: time.Now(), Valid: true}
+	for _, id := range r.store.eventIDs() {
+		if event := r.store.events[id]; event.CalendarID == calendar.ID && !event.DeletedAt.Valid {
+			before := copyEvent(event)
+			event.DeletedAt = deletedAt
+			r.store.record(models.RevisionDelete, id, before, audit)
+		}
+	}
+	if stored, ok := r.store.calendars[calendar.ID]; ok {
+		stored.DeletedAt = deletedAt
+	}
+	return nil
+}
+
+// memoryGrantRepository stores grants in a memoryStore
+type memoryGrantRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryGrantRepository) ListForUser(userID uint) ([]models.Grant, error) {
+	return r.list(func(grant *models.Grant) bool { return grant.UserID == userID })
+}
+
+func (r *memoryGrantRepository) ListForCalendar(calendarID uint) ([]models.Grant, error) {
+	return r.list(func(grant *models.Grant) bool { return grant.CalendarID != nil && *grant.CalendarID == calendarID })
+}
+
+func (r *memoryGrantRepository) ListForEvent(eventID uint) ([]models.Grant, error) {
+	return r.list(func(grant *models.Grant) bool { return grant.EventID != nil && *grant.EventID == eventID })
+}
+
+// List the grants matching, oldest first
+func (r *memoryGrantRepository) list(matches func(grant *models.Grant) bool) ([]models.Grant, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	grants := []models.Grant{}
+	for _, grant := range r.store.grants {
+		if matches(grant) {
+			grants = append(grants, *grant)
+		}
+	}
+	sort.Slice(grants, func(i, j int) bool { return grants[i].ID < grants[j].ID })
+	return grants, nil
+}
+
+func (r *memoryGrantRepository) Save(grant *models.Grant) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	now := time.Now()
+	if stored := r.store.granted(grant); stored != nil {
+		stored.Role, stored.GrantedBy, stored.UpdatedAt = grant.Role, grant.GrantedBy, now
+		*grant = *stored
+		return nil
+	}
+	r.store.nextGrantID++
+	grant.ID = r.store.nextGrantID
+	grant.CreatedAt, grant.UpdatedAt = now, now
+	copied := *grant
+	r.store.grants[grant.ID] = &copied
+	return nil
+}
+
+func (r *memoryGrantRepository) Delete(grant *models.Grant) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	stored := r.store.granted(grant)
+	if stored == nil {
+		return ErrNotFound
+	}
+	delete(r.store.grants, stored.ID)
+	return nil
+}
+
+// Find the stored grant of the same user on the same calendar or event
+func (s *memoryStore) granted(grant *models.Grant) *models.Grant {
+	for _, stored := range s.grants {
+		if stored.UserID != grant.UserID {
+			continue
+		}
+		if grant.EventID != nil && stored.EventID != nil && *stored.EventID == *grant.EventID ||
+			grant.EventID == nil && grant.CalendarID != nil && stored.CalendarID != nil && *stored.CalendarID == *grant.CalendarID {
+			return stored
+		}
+	}
+	return nil
+}
+
+// memoryWebhookRepository stores webhooks and their deliveries in a memoryStore
+type memoryWebhookRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryWebhookRepository) List() ([]models.Webhook, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	webhooks := []models.Webhook{}
+	for _, webhook := range r.store.webhooks {
+		webhooks = append(webhooks, *webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) Get(id uint) (*models.Webhook, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	webhook, ok := r.store.webhooks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (r *memoryWebhookRepository) Create(webhook *models.Webhook) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.nextWebhookID++
+	webhook.ID = r.store.nextWebhookID
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = webhook.CreatedAt
+	copied := *webhook
+	r.store.webhooks[webhook.ID] = &copied
+	return nil
+}
+
+func (r *memoryWebhookRepository) Update(webhook *models.Webhook) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	webhook.UpdatedAt = time.Now()
+	copied := *webhook
+	r.store.webhooks[webhook.ID] = &copied
+	return nil
+}
+
+func (r *memoryWebhookRepository) Delete(webhook *models.Webhook) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for id, delivery := range r.store.deliveries {
+		if delivery.WebhookID == webhook.ID {
+			delete(r.store.deliveries, id)
+		}
+	}
+	delete(r.store.webhooks, webhook.ID)
+	return nil
+}
+
+func (r *memoryWebhookRepository) ListDeliveries(webhookID uint, limit int) ([]models.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range r.store.deliveries {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *memoryWebhookRepository) GetDelivery(webhookID, deliveryID uint) (*models.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	delivery, ok := r.store.deliveries[deliveryID]
+	if !ok || delivery.WebhookID != webhookID {
+		return nil, ErrNotFound
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *memoryWebhookRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.nextDeliveryID++
+	delivery.ID = r.store.nextDeliveryID
+	delivery.CreatedAt = time.Now()
+	delivery.UpdatedAt = delivery.CreatedAt
+	copied := *delivery
+	r.store.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (r *memoryWebhookRepository) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range r.store.deliveries {
+		if webhook, ok := r.store.webhooks[delivery.WebhookID]; ok && !webhook.Disabled && delivery.Due(now) {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttemptAt.Equal(*deliveries[j].NextAttemptAt) {
+			return deliveries[i].NextAttemptAt.Before(*deliveries[j].NextAttemptAt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *memoryWebhookRepository) ClaimDelivery(delivery *models.WebhookDelivery, now, until time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	stored, ok := r.store.deliveries[delivery.ID]
+	if !ok || !stored.Due(now) {
+		return false, nil
+	}
+	stored.NextAttemptAt = &until
+	delivery.NextAttemptAt = &until
+	return true, nil
+}
+
+func (r *memoryWebhookRepository) SaveDelivery(delivery *models.WebhookDelivery, lease time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	stored, ok := r.store.deliveries[delivery.ID]
+	if !ok || stored.NextAttemptAt == nil || !stored.NextAttemptAt.Equal(lease) {
+		return false, nil
+	}
+	delivery.UpdatedAt = time.Now()
+	copied := *delivery
+	r.store.deliveries[delivery.ID] = &copied
+	return true, nil
+}
+
+// memoryReminderRepository stores reminders and their firings in a memoryStore
+type memoryReminderRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryReminderRepository) ListForEvent(eventID uint) ([]models.Reminder, error) {
+	return r.list(func(reminder *models.Reminder) bool { return reminder.EventID == eventID })
+}
+
+func (r *memoryReminderRepository) ListForUser(userID uint) ([]models.Reminder, error) {
+	return r.list(func(reminder *models.Reminder) bool { return reminder.UserID == userID })
+}
+
+// List the reminders matching the condition, oldest first
+func (r *memoryReminderRepository) list(matches func(reminder *models.Reminder) bool) ([]models.Reminder, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	reminders := []models.Reminder{}
+	for _, reminder := range r.store.reminders {
+		if matches(reminder) {
+			reminders = append(reminders, *reminder)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i].ID < reminders[j].ID })
+	return reminders, nil
+}
+
+func (r *memoryReminderRepository) Get(eventID, id uint) (*models.Reminder, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	reminder, ok := r.store.reminders[id]
+	if !ok || reminder.EventID != eventID {
+		return nil, ErrNotFound
+	}
+	copied := *reminder
+	return &copied, nil
+}
+
+func (r *memoryReminderRepository) Create(reminder *models.Reminder) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.nextReminderID++
+	reminder.ID = r.store.nextReminderID
+	reminder.CreatedAt = time.Now()
+	reminder.UpdatedAt = reminder.CreatedAt
+	copied := *reminder
+	r.store.reminders[reminder.ID] = &copied
+	return nil
+}
+
+func (r *memoryReminderRepository) Delete(reminder *models.Reminder) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.deleteReminder(reminder.ID)
+	return nil
+}
+
+func (r *memoryReminderRepository) DueReminders(now time.Time, limit int) ([]models.Reminder, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	reminders := []models.Reminder{}
+	for _, reminder := range r.store.reminders {
+		if reminder.NextFireAt != nil && !reminder.NextFireAt.After(now) {
+			reminders = append(reminders, *reminder)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		if !reminders[i].NextFireAt.Equal(*reminders[j].NextFireAt) {
+			return reminders[i].NextFireAt.Before(*reminders[j].NextFireAt)
+		}
+		return reminders[i].ID < reminders[j].ID
+	})
+	if len(reminders) > limit {
+		reminders = reminders[:limit]
+	}
+	return reminders, nil
+}
+
+func (r *memoryReminderRepository) Fire(reminder *models.Reminder, firing *models.ReminderFiring) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	stored, ok := r.store.reminders[reminder.ID]
+	if !ok || stored.OccurrenceAt == nil || !stored.OccurrenceAt.Equal(firing.OccurrenceAt) {
+		return false, nil
+	}
+	stored.NextFireAt, stored.OccurrenceAt = reminder.NextFireAt, reminder.OccurrenceAt
+	for _, fired := range r.store.firings {
+		if fired.ReminderID == firing.ReminderID && fired.OccurrenceAt.Equal(firing.OccurrenceAt) {
+			return false, nil
+		}
+	}
+	r.store.nextFiringID++
+	firing.ID = r.store.nextFiringID
+	firing.CreatedAt = time.Now()
+	firing.UpdatedAt = firing.CreatedAt
+	copied := *firing
+	r.store.firings[firing.ID] = &copied
+	return true, nil
+}
+
+func (r *memoryReminderRepository) ListFirings(reminderID uint, limit int) ([]models.ReminderFiring, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	firings := []models.ReminderFiring{}
+	for _, firing := range r.store.firings {
+		if firing.ReminderID == reminderID {
+			firings = append(firings, *firing)
+		}
+	}
+	sort.Slice(firings, func(i, j int) bool { return firings[i].ID > firings[j].ID })
+	if len(firings) > limit {
+		firings = firings[:limit]
+	}
+	return firings, nil
+}
+
+func (r *memoryReminderRepository) DueFirings(now time.Time, limit int) ([]models.ReminderFiring, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	firings := []models.ReminderFiring{}
+	for _, firing := range r.store.firings {
+		if firing.Due(now) {
+			firings = append(firings, *firing)
+		}
+	}
+	sort.Slice(firings, func(i, j int) bool {
+		if !firings[i].NextAttemptAt.Equal(*firings[j].NextAttemptAt) {
+			return firings[i].NextAttemptAt.Before(*firings[j].NextAttemptAt)
+		}
+		return firings[i].ID < firings[j].ID
+	})
+	if len(firings) > limit {
+		firings = firings[:limit]
+	}
+	return firings, nil
+}
+
+func (r *memoryReminderRepository) ClaimFiring(firing *models.ReminderFiring, now, until time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	stored, ok := r.store.firings[firing.ID]
+	if !ok || !stored.Due(now) {
+		return false, nil
+	}
+	stored.NextAttemptAt = &until
+	firing.NextAttemptAt = &until
+	return true, nil
+}
+
+func (r *memoryReminderRepository) SaveFiring(firing *models.ReminderFiring, lease time.Time) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	stored, ok := r.store.firings[firing.ID]
+	if !ok || stored.NextAttemptAt == nil || !stored.NextAttemptAt.Equal(lease) {
+		return false, nil
+	}
+	firing.UpdatedAt = time.Now()
+	copied := *firing
+	r.store.firings[firing.ID] = &copied
+	return true, nil
+}
+
+// Delete a reminder with its firings
+func (s *memoryStore) deleteReminder(id uint) {
+	for firingID, firing := range s.firings {
+		if firing.ReminderID == id {
+			delete(s.firings, firingID)
+		}
+	}
+	delete(s.reminders, id)
+}
+
+// memoryUserRepository stores users and their API keys in memory, apart from the events
+type memoryUserRepository struct {
+	mu        sync.Mutex
+	users     map[uint]*models.User
+	keys      map[uint]*models.APIKey
+	nextID    uint
+	nextKeyID uint
+}
+
+// NewMemoryUserRepository stores users in memory, starting without any user. It is meant for tests.
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{users: map[uint]*models.User{}, keys: map[uint]*models.APIKey{}}
+}
+
+func (r *memoryUserRepository) List() ([]models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	users := []models.User{}
+	for _, user := range r.users {
+		users = append(users, *user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *memoryUserRepository) Get(id uint) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *memoryUserRepository) Create(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.users {
+		if other.Name == user.Name {
+			return ErrNameTaken
+		}
+	}
+	r.nextID++
+	user.ID = r.nextID
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, key := range r.keys {
+		if key.UserID == user.ID {
+			delete(r.keys, id)
+		}
+	}
+	delete(r.users, user.ID)
+	return nil
+}
+
+func (r *memoryUserRepository) ListKeys(userID uint) ([]models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := []models.APIKey{}
+	for _, key := range r.keys {
+		if key.UserID == userID {
+			keys = append(keys, *key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (r *memoryUserRepository) CreateKey(key *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextKeyID++
+	key.ID = r.nextKeyID
+	key.CreatedAt = time.Now()
+	copied := *key
+	r.keys[key.ID] = &copied
+	return nil
+}
+
+func (r *memoryUserRepository) DeleteKey(userID, keyID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[keyID]
+	if !ok || key.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.keys, keyID)
+	return nil
+}
+
+func (r *memoryUserRepository) FindKey(hash string) (*models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, key := range r.keys {
+		if key.Hash == hash {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
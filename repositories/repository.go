@@ -0,0 +1,324 @@
+// Package repositories stores events, calendars, users and webhooks for the controllers.
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/thunthup/aimet-test/models"
+)
+
+// Errors returned by the repositories
+var (
+	ErrNotFound        = errors.New("record not found")
+	ErrVersionConflict = errors.New("Event has been modified by another request")
+	ErrNameTaken       = errors.New("User name is already taken")
+)
+
+// EventFilter selects the events returned by EventRepository.List
+type EventFilter struct {
+	// Events ending on or after StartDate and starting on or before EndDate, as YYYY-MM-DD.
+	// Recurring events are kept whatever their end because later occurrences may be in range.
+	StartDate string
+	EndDate   string
+	// Location compares timed events by instant with the days from StartDate to EndDate in this time zone.
+	// All-day events float and are compared by date, like every event when Location is nil.
+	Location *time.Location
+	// Title contains the keyword, case sensitive
+	Keyword string
+	// Title matches the search, ignoring case and accents. The databases also match words by their stem.
+	Search      *models.SearchQuery
+	CalendarIDs []uint64
+	// Access keeps the events a user has a role on, nil keeps every event
+	Access *Access
+	// Events having one of the tags, or all of them when AllTags is set. Tag names are normalized.
+	Tags     []string
+	AllTags  bool
+	BusyOnly bool
+	// Recurring keeps only recurring events when true and only single events when false
+	Recurring *bool
+	// After keeps the events sorted after this position
+	After *models.Event
+	// Events are sorted with models.CompareOccurrences
+	Desc bool
+	// Reverse sorts the events in the exact opposite order, and with After keeps the events sorted before it
+	Reverse bool
+	// Limit caps the number of events, zero means no limit
+	Limit int
+}
+
+// TrashFilter selects the deleted events returned by EventRepository.ListDeleted and removed by Purge
+type TrashFilter struct {
+	IDs []uint
+	// Title contains the keyword, case sensitive
+	Keyword     string
+	CalendarIDs []uint64
+	// Access keeps the events a user has a role on, nil keeps every event
+	Access *Access
+	// DeletedBefore keeps the events deleted before this time, zero means any time
+	DeletedBefore time.Time
+}
+
+// Matches reports whether the filter keeps an event, the way the memory repositories do.
+// Searches do not match the stems of words as the databases do. After, sorting and Limit are ignored.
+func (f EventFilter) Matches(event *models.Event) bool {
+	filter := f
+	filter.After = nil
+	return matchesFilter(event, &filter)
+}
+
+// Access selects the events a user has a role on: the events they own,
+// the events of calendars shared with them and the events shared with them
+type Access struct {
+	OwnerID     uint
+	CalendarIDs []uint
+	EventIDs    []uint
+}
+
+// EventRepository stores events with their overridden occurrences and their tags.
+// Events are returned with their overrides, their tags sorted by name and their dates formatted as YYYY-MM-DD.
+// Tags are created when an event first uses them.
+// Every change is recorded as a revision of the event, in the same transaction, with the audit of the change.
+type EventRepository interface {
+	Get(id uint) (*models.Event, error)
+	FindByUID(uid string) (*models.Event, error)
+	List(filter EventFilter) ([]models.Event, error)
+	Create(event *models.Event, audit models.Audit) error
+	// Update saves the event when its version is still the stored one, and moves it to the next version
+	Update(event *models.Event, audit models.Audit) error
+	// Delete deletes the event when its version is still the stored one
+	Delete(event *models.Event, audit models.Audit) error
+	// FindOverlaps reports whether an occurrence of the event overlaps an occurrence of a busy event
+	// in the same calendar, other than the event with excludeID
+	FindOverlaps(event *models.Event, excludeID uint) (bool, error)
+	// LockCalendar holds a calendar until the end of the transaction it runs in, so that the overlaps
+	// found with FindOverlaps stay true until the event is written
+	LockCalendar(calendarID uint) error
+	// SaveOverride creates or replaces the override of an occurrence and moves the event to the next version
+	SaveOverride(event *models.Event, override *models.EventOverride, audit models.Audit) error
+	// CancelOccurrence removes the override of an occurrence, adds it to the exception dates
+	// and moves the event to the next version
+	CancelOccurrence(event *models.Event, recurrenceDate string, audit models.Audit) error
+	// GetDeleted gets a deleted event, with the time it was deleted
+	GetDeleted(id uint) (*models.Event, error)
+	// ListDeleted lists the deleted events, last deleted first
+	ListDeleted(filter TrashFilter) ([]models.Event, error)
+	// Restore undeletes the event when its version is still the stored one, and moves it to the next version
+	Restore(event *models.Event, audit models.Audit) error
+	// Purge permanently removes the deleted events with their overrides and returns how many were removed.
+	// Their tags are kept for other events.
+	// Their revisions are kept.
+	Purge(filter TrashFilter) (int64, error)
+	// ListRevisions lists the revisions of an event, deleted or not, oldest first
+	ListRevisions(eventID uint) ([]models.EventRevision, error)
+	// GetRevision gets a revision of an event
+	GetRevision(eventID, revisionID uint) (*models.EventRevision, error)
+	// ListRevisionsAfter lists the revisions of every event with an ID above afterID, oldest first,
+	// at most limit of them. IDs increase but a revision may commit after a higher one.
+	ListRevisionsAfter(afterID uint, limit int) ([]models.EventRevision, error)
+	// LastRevisionID is the highest ID of the revisions, zero when there are none
+	LastRevisionID() (uint, error)
+	// Transaction runs fn with event and calendar repositories sharing one transaction.
+	// Their changes are kept when fn returns nil and all discarded otherwise.
+	Transaction(fn func(events EventRepository, calendars CalendarRepository) error) error
+}
+
+// CalendarRepository stores calendars
+type CalendarRepository interface {
+	List() ([]models.Calendar, error)
+	Get(id uint) (*models.Calendar, error)
+	GetDefault() (*models.Calendar, error)
+	Create(calendar *models.Calendar) error
+	Update(calendar *models.Calendar) error
+	// Delete deletes the calendar together with its events, recording a revision for each event
+	Delete(calendar *models.Calendar, audit models.Audit) error
+}
+
+// UserRepository stores users and their API keys
+type UserRepository interface {
+	List() ([]models.User, error)
+	Get(id uint) (*models.User, error)
+	// Create creates the user, or returns ErrNameTaken when another user has its name
+	Create(user *models.User) error
+	// Delete removes the user with its API keys, its events keep it as their owner
+	Delete(user *models.User) error
+	// ListKeys lists the API keys of a user, oldest first
+	ListKeys(userID uint) ([]models.APIKey, error)
+	CreateKey(key *models.APIKey) error
+	// DeleteKey removes an API key of a user
+	DeleteKey(userID, keyID uint) error
+	// FindKey finds the API key whose secret has the hash
+	FindKey(hash string) (*models.APIKey, error)
+}
+
+// GrantRepository stores the grants giving users a role on calendars and events.
+// The grants of an event are removed when it is purged.
+type GrantRepository interface {
+	// ListForUser lists the grants of a user on calendars and events
+	ListForUser(userID uint) ([]models.Grant, error)
+	// ListForCalendar lists the grants on a calendar, oldest first
+	ListForCalendar(calendarID uint) ([]models.Grant, error)
+	// ListForEvent lists the grants on an event, oldest first
+	ListForEvent(eventID uint) ([]models.Grant, error)
+	// Save creates the grant, or changes the role of the grant the user already has on its calendar or event
+	Save(grant *models.Grant) error
+	// Delete removes the grant of the user on its calendar or event
+	Delete(grant *models.Grant) error
+}
+
+// WebhookRepository stores webhooks and the log of their deliveries. The event repositories queue a delivery
+// for each webhook subscribed to a change in the transaction recording its revision, so rolled back changes are not sent.
+type WebhookRepository interface {
+	List() ([]models.Webhook, error)
+	Get(id uint) (*models.Webhook, error)
+	Create(webhook *models.Webhook) error
+	Update(webhook *models.Webhook) error
+	// Delete removes the webhook with its deliveries
+	Delete(webhook *models.Webhook) error
+	// ListDeliveries lists the last deliveries of a webhook, newest first
+	ListDeliveries(webhookID uint, limit int) ([]models.WebhookDelivery, error)
+	GetDelivery(webhookID, deliveryID uint) (*models.WebhookDelivery, error)
+	// CreateDelivery queues a delivery, to send a payload again
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	// DueDeliveries lists the pending deliveries of enabled webhooks to attempt at now, oldest first
+	DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	// ClaimDelivery moves the next attempt of a delivery due at now to until, so that other senders skip it.
+	// It reports false when another sender claimed it first.
+	ClaimDelivery(delivery *models.WebhookDelivery, now, until time.Time) (bool, error)
+	// SaveDelivery stores the outcome of an attempt made while the delivery was claimed until lease. It reports false,
+	// storing nothing, when the lease ran out and another sender claimed the delivery since.
+	SaveDelivery(delivery *models.WebhookDelivery, lease time.Time) (bool, error)
+}
+
+// ReminderRepository stores the reminders of events and the log of their firings. The event repositories
+// schedule the reminders of an event again in the transaction recording each change of the event.
+type ReminderRepository interface {
+	// ListForEvent lists the reminders of an event, oldest first
+	ListForEvent(eventID uint) ([]models.Reminder, error)
+	// ListForUser lists the reminders of a user on all events, oldest first
+	ListForUser(userID uint) ([]models.Reminder, error)
+	Get(eventID, id uint) (*models.Reminder, error)
+	// Create stores a reminder, scheduled on its event by the caller
+	Create(reminder *models.Reminder) error
+	// Delete removes the reminder with its firings
+	Delete(reminder *models.Reminder) error
+	// DueReminders lists the reminders to fire at now, soonest first
+	DueReminders(now time.Time, limit int) ([]models.Reminder, error)
+	// Fire queues the firing of a due reminder and stores the next schedule of the reminder, unless the reminder
+	// was fired or scheduled again since it was read. It reports false then, and when the occurrence of the firing
+	// was already fired, in which case the reminder is moved to its next schedule all the same.
+	Fire(reminder *models.Reminder, firing *models.ReminderFiring) (bool, error)
+	// ListFirings lists the last firings of a reminder, newest first
+	ListFirings(reminderID uint, limit int) ([]models.ReminderFiring, error)
+	// DueFirings lists the pending firings to attempt at now, oldest first
+	DueFirings(now time.Time, limit int) ([]models.ReminderFiring, error)
+	// ClaimFiring moves the next attempt of a firing due at now to until, so that other schedulers skip it.
+	// It reports false when another scheduler claimed it first.
+	ClaimFiring(firing *models.ReminderFiring, now, until time.Time) (bool, error)
+	// SaveFiring stores the outcome of an attempt made while the firing was claimed until lease. It reports false,
+	// storing nothing, when the lease ran out and another scheduler claimed the firing since.
+	SaveFiring(firing *models.ReminderFiring, lease time.Time) (bool, error)
+}
+
+// Check whether an event is selected by the access
+func (a *Access) includes(event *models.Event) bool {
+	if event.OwnerID != nil && *event.OwnerID == a.OwnerID {
+		return true
+	}
+	for _, id := range a.CalendarIDs {
+		if event.CalendarID == id {
+			return true
+		}
+	}
+	for _, id := range a.EventIDs {
+		if event.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Instants the days from StartDate to EndDate start and end at in the time zone of the filter
+func (f *EventFilter) instants() (time.Time, time.Time) {
+	var startAt, endAt time.Time
+	if f.Location == nil {
+		return startAt, endAt
+	}
+	if date, err := models.ParseDate(f.StartDate); err == nil {
+		startAt = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, f.Location)
+	}
+	if date, err := models.ParseDate(f.EndDate); err == nil {
+		endAt = time.Date(date.Year(), date.Month(), date.Day()+1, 0, 0, 0, 0, f.Location)
+	}
+	return startAt, endAt
+}
+
+// Compare compares the position of two events in the order of the filter
+func (f *EventFilter) Compare(a, b *models.Event) int {
+	if f.Reverse {
+		return -models.CompareOccurrences(a, b, f.Desc)
+	}
+	return models.CompareOccurrences(a, b, f.Desc)
+}
+
+// Check whether any of the occurrences overlaps one of the other occurrences
+func overlapsAny(occurrences []models.Event, others []models.Event) bool {
+	for i := range others {
+		for j := range occurrences {
+			if occurrences[j].OverlapsWith(&others[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Expand the event whose overlaps are searched, nothing is returned when it is not busy
+func overlapOccurrences(event *models.Event) ([]models.Event, error) {
+	if !event.IsBusy() {
+		return nil, nil
+	}
+	from, to, err := event.Span()
+	if err != nil {
+		return nil, err
+	}
+	return event.Occurrences(from, to)
+}
+
+// Record a change of an event, with the dates of both states formatted
+func newRevision(action string, before, after *models.Event, audit models.Audit) *models.EventRevision {
+	if before != nil {
+		before.FormatDates()
+	}
+	after.FormatDates()
+	return models.NewEventRevision(action, before, after, audit)
+}
+
+// Deliveries of the change recorded by a revision to the webhooks subscribed to its type, due at once
+func newDeliveries(webhooks []models.Webhook, revision *models.EventRevision, before, after *models.Event) ([]models.WebhookDelivery, error) {
+	changeType := models.ChangeType(revision.Action)
+	var deliveries []models.WebhookDelivery
+	var payload []byte
+	for i := range webhooks {
+		if !webhooks[i].Subscribes(changeType) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(models.NewWebhookPayload(revision, before, after)); err != nil {
+				return nil, err
+			}
+		}
+		due := revision.CreatedAt
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     webhooks[i].ID,
+			RevisionID:    revision.ID,
+			Type:          changeType,
+			Payload:       models.RawJSON(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &due,
+		})
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,18 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/controllers"
+)
+
+func CalendarRoute(router gin.IRouter, h *controllers.Handler) {
+	router.GET("/api/calendars", h.ListCalendars)
+	router.GET("/api/calendars/:id", h.GetCalendarById)
+	router.POST("/api/calendars", h.CreateCalendar)
+	router.PUT("/api/calendars/:id", h.UpdateCalendar)
+	router.DELETE("/api/calendars/:id", h.DeleteCalendar)
+	router.GET("/api/calendars/:id/grants", h.ListCalendarGrants)
+	router.POST("/api/calendars/:id/grants", h.GrantCalendar)
+	router.DELETE("/api/calendars/:id/grants/:user", h.RevokeCalendarGrant)
+
+}
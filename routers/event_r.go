@@ -5,11 +5,31 @@ import (
 	"github.com/thunthup/aimet-test/controllers"
 )
 
-func EventRoute(router *gin.Engine) {
-	router.GET("/api/events", controllers.ListEvents)
-	router.GET("/api/events/:id", controllers.GetEventById)
-	router.POST("/api/events", controllers.CreateEvent)
-	router.PUT("/api/events/:id", controllers.UpdateEvent)
-	router.DELETE("/api/events/:id", controllers.DeleteEvent)
+func EventRoute(router gin.IRouter, h *controllers.Handler) {
+	router.GET("/api/events", h.ListEvents)
+	router.GET("/api/events/export", h.ExportEvents)
+	router.GET("/api/events/stream", h.StreamEvents)
+	router.POST("/api/events/import", h.ImportEvents)
+	router.POST("/api/events/bulk", h.BulkEvents)
+	router.GET("/api/events/trash", h.ListTrash)
+	router.DELETE("/api/events/trash", h.PurgeTrash)
+	router.POST("/api/events/trash/:id/restore", h.RestoreEvent)
+	router.DELETE("/api/events/trash/:id", h.PurgeEvent)
+	router.GET("/api/events/:id", h.GetEventById)
+	router.POST("/api/events", h.CreateEvent)
+	router.PUT("/api/events/:id", h.UpdateEvent)
+	router.PATCH("/api/events/:id", h.PatchEvent)
+	router.DELETE("/api/events/:id", h.DeleteEvent)
+	router.PUT("/api/events/:id/occurrences/:date", h.UpdateOccurrence)
+	router.DELETE("/api/events/:id/occurrences/:date", h.CancelOccurrence)
+	router.GET("/api/events/:id/history", h.GetEventHistory)
+	router.POST("/api/events/:id/history/:revision/revert", h.RevertEvent)
+	router.GET("/api/events/:id/grants", h.ListEventGrants)
+	router.POST("/api/events/:id/grants", h.GrantEvent)
+	router.DELETE("/api/events/:id/grants/:user", h.RevokeEventGrant)
+	router.GET("/api/events/:id/reminders", h.ListReminders)
+	router.POST("/api/events/:id/reminders", h.CreateReminder)
+	router.DELETE("/api/events/:id/reminders/:reminder", h.DeleteReminder)
+	router.GET("/api/events/:id/reminders/:reminder/firings", h.ListReminderFirings)
 
 }
@@ -0,0 +1,12 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/controllers"
+)
+
+func FreeBusyRoute(router gin.IRouter, h *controllers.Handler) {
+	router.GET("/api/freebusy", h.GetFreeBusy)
+	router.GET("/api/slots", h.FindSlots)
+
+}
@@ -0,0 +1,20 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/controllers"
+)
+
+func UserRoute(router gin.IRouter, h *controllers.Handler) {
+	router.GET("/api/me", h.GetCurrentUser)
+
+	admin := router.Group("/api/users", controllers.RequireAdmin)
+	admin.GET("", h.ListUsers)
+	admin.GET("/:id", h.GetUserById)
+	admin.POST("", h.CreateUser)
+	admin.DELETE("/:id", h.DeleteUser)
+	admin.GET("/:id/keys", h.ListAPIKeys)
+	admin.POST("/:id/keys", h.CreateAPIKey)
+	admin.DELETE("/:id/keys/:key", h.DeleteAPIKey)
+
+}
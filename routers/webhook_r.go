@@ -0,0 +1,18 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/thunthup/aimet-test/controllers"
+)
+
+func WebhookRoute(router gin.IRouter, h *controllers.Handler) {
+	admin := router.Group("/api/webhooks", controllers.RequireAdmin)
+	admin.GET("", h.ListWebhooks)
+	admin.GET("/:id", h.GetWebhookById)
+	admin.POST("", h.CreateWebhook)
+	admin.PATCH("/:id", h.UpdateWebhook)
+	admin.DELETE("/:id", h.DeleteWebhook)
+	admin.GET("/:id/deliveries", h.ListWebhookDeliveries)
+	admin.GET("/:id/deliveries/:delivery", h.GetWebhookDelivery)
+	admin.POST("/:id/deliveries/:delivery/redeliver", h.RedeliverWebhook)
+}
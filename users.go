@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/thunthup/aimet-test/configs"
+	"github.com/thunthup/aimet-test/migrations"
+	"github.com/thunthup/aimet-test/models"
+	"github.com/thunthup/aimet-test/repositories"
+)
+
+const usersUsage = `usage: aimet-test users <command>
+
+commands:
+  add <name> [admin]   create a user, an admin with the admin argument, and print an API key
+  key <name>           print a new API key of the user
+  list                 list the users`
+
+// Run the users command, which creates the first users and their API keys before the API can be used
+func runUsers(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usersUsage)
+		os.Exit(2)
+	}
+	migrator, err := migrations.New(configs.DB)
+	if err != nil {
+		log.Fatalf("Error while loading migrations %s", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		log.Fatalf("Error while migrating database %s", err)
+	}
+	users := repositories.NewGormUserRepository(configs.DB)
+
+	switch {
+	case args[0] == "add" && (len(args) == 2 || len(args) == 3 && args[2] == "admin"):
+		user := models.User{Name: args[1], Admin: len(args) == 3}
+		if err := users.Create(&user); err != nil {
+			log.Fatalf("Error while creating user %s", err)
+		}
+		fmt.Printf("created user %d %s\n", user.ID, user.Name)
+		printNewAPIKey(users, &user)
+	case args[0] == "key" && len(args) == 2:
+		user, err := findUserByName(users, args[1])
+		if err != nil {
+			log.Fatalf("Error while reading users %s", err)
+		}
+		printNewAPIKey(users, user)
+	case args[0] == "list" && len(args) == 1:
+		list, err := users.List()
+		if err != nil {
+			log.Fatalf("Error while reading users %s", err)
+		}
+		for _, user := range list {
+			role := "user"
+			if user.Admin {
+				role = "admin"
+			}
+			fmt.Printf("%d\t%s\t%s\n", user.ID, user.Name, role)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usersUsage)
+		os.Exit(2)
+	}
+}
+
+// Find the user with the name
+func findUserByName(users repositories.UserRepository, name string) (*models.User, error) {
+	list, err := users.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		if list[i].Name == name {
+			return &list[i], nil
+		}
+	}
+	return nil, errors.New("user not found")
+}
+
+// Create an API key for the user and print its secret, which cannot be read again
+func printNewAPIKey(users repositories.UserRepository, user *models.User) {
+	key := models.APIKey{UserID: user.ID, Name: "cli"}
+	secret, err := key.NewAPIKeySecret()
+	if err != nil {
+		log.Fatalf("Error while generating API key %s", err)
+	}
+	if err := users.CreateKey(&key); err != nil {
+		log.Fatalf("Error while creating API key %s", err)
+	}
+	fmt.Printf("api key %d %s\n", key.ID, secret)
+}